@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cespare/xxhash/v2"
+
+	"main.go/wordlist"
+)
+
+// An audit list holds the passwords a system with a given policy must
+// refuse: those too short for it, and those that meet its length and
+// classes but are built on a dictionary word or a keyboard walk, which
+// guidance such as NIST SP 800-63B tells systems to block as well. Trying
+// each against the system shows which rules it actually enforces.
+
+// auditReport is written next to an audit list to describe it.
+type auditReport struct {
+	Policy   passwordPolicy `json:"policy"`
+	Created  time.Time      `json:"created"`
+	List     string         `json:"list"`
+	SHA256   string         `json:"sha256"`
+	Words    int64          `json:"words"`
+	Sections []auditSection `json:"sections"`
+}
+
+// auditSection is one rule's part of the list, in the order written.
+type auditSection struct {
+	Name  string `json:"name"`
+	Rule  string `json:"rule"`
+	Words int64  `json:"words"`
+	Note  string `json:"note,omitempty"`
+}
+
+// auditList writes the words of an audit list, dropping a word given twice
+// by the dictionary and walk sections, and checksums what it writes.
+type auditList struct {
+	w        *listOutput
+	sum      hash.Hash
+	seen     *dedupSet
+	short    string // the symbols of the short section
+	shortMax int    // its longest length
+	section  *auditSection
+}
+
+// add writes word unless it was written already.
+func (a *auditList) add(word string) error {
+	if a.inShort(word) || !a.seen.add(xxhash.Sum64String(word)) {
+		return nil
+	}
+	return a.write([]byte(word))
+}
+
+func (a *auditList) write(word []byte) error {
+	a.section.Words++
+	a.sum.Write(word)
+	a.sum.Write([]byte{'\n'})
+	return a.w.writeLine(word)
+}
+
+// inShort reports whether the short section already holds word.
+func (a *auditList) inShort(word string) bool {
+	if utf8.RuneCountInString(word) > a.shortMax {
+		return false
+	}
+	for _, r := range word {
+		if !strings.ContainsRune(a.short, r) {
+			return false
+		}
+	}
+	return true
+}
+
+func auditCmd(args []string) error {
+	fs := newToolFlags("audit", "[dictionary...]")
+	policyPath := fs.String("policy", "", "audit this JSON `policy` (as written by the policy tool)")
+	examples := fs.String("examples", "", "infer the policy from the passwords in this `file` instead")
+	short := fs.String("short-charset", "abcdefghijklmnopqrstuvwxyz0123456789", "`symbols` of the too-short passwords, every one of which is listed")
+	limit := fs.Int64("short-limit", 100_000_000, "list the too-short passwords only up to the longest length that keeps them under this many `words`")
+	out := fs.String("o", "audit.txt", "write the list to this `file` (- for stdout)")
+	reportPath := fs.String("report", "audit-report.json", "write the report to this `file`")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	var p passwordPolicy
+	var err error
+	switch {
+	case (*policyPath == "") == (*examples == ""):
+		return fmt.Errorf("%w: audit needs one of -policy and -examples", ErrConfig)
+	case *policyPath != "":
+		p, err = loadPolicy(*policyPath)
+	default:
+		var ex []string
+		if ex, err = readPolicyExamples(*examples); err == nil {
+			if p, err = inferPolicy(ex); err == nil {
+				reportInferred(&p, len(ex))
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	list := &auditList{w: w, sum: sha256.New(), seen: newDedupSet(0), short: *short}
+	report := auditReport{Policy: p, Created: time.Now().UTC(), List: *out}
+	err = list.shortSection(&report, p, *limit)
+	if err == nil {
+		err = list.dictionarySection(&report, fs.Args())
+	}
+	if err == nil {
+		err = list.walkSection(&report)
+	}
+	if cerr := w.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	report.SHA256 = hex.EncodeToString(list.sum.Sum(nil))
+	for _, s := range report.Sections {
+		report.Words += s.Words
+	}
+	if err := writeAuditReport(*reportPath, &report); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "📋 Audit list for %s: %s passwords the policy should refuse, in %s (report in %s)\n",
+		&p, fmtInt(report.Words), *out, *reportPath)
+	for _, s := range report.Sections {
+		fmt.Fprintf(os.Stderr, "   %-14s %15s  %s\n", s.Name, fmtInt(s.Words), s.Rule)
+		if s.Note != "" {
+			fmt.Fprintf(os.Stderr, "   %-14s %15s  ⚠️  %s\n", "", "", s.Note)
+		}
+	}
+	return nil
+}
+
+// shortSection lists every password of the short charset below the
+// policy's minimum length, as far as the limit allows.
+func (a *auditList) shortSection(report *auditReport, p passwordPolicy, limit int64) error {
+	report.Sections = append(report.Sections, auditSection{Name: "too-short",
+		Rule: fmt.Sprintf("shorter than %d characters, over %q", p.MinLen, a.short)})
+	a.section = &report.Sections[len(report.Sections)-1]
+	if p.MinLen <= 1 {
+		a.section.Note = "the policy has no minimum length"
+		return nil
+	}
+	symbols := wordlist.Runes(a.short)
+	if len(symbols) == 0 {
+		return fmt.Errorf("%w: -short-charset is empty", ErrConfig)
+	}
+	for n := 1; n < p.MinLen; n++ {
+		if ks, err := wordlist.NewKeyspace(symbols, 1, n); err != nil || ks.Total() > limit {
+			break
+		}
+		a.shortMax = n
+	}
+	if a.shortMax < p.MinLen-1 {
+		a.section.Note = fmt.Sprintf("lengths %d-%d left out, over -short-limit; generate them with -charset %q -min-len %d -max-len %d",
+			a.shortMax+1, p.MinLen-1, a.short, a.shortMax+1, p.MinLen-1)
+	}
+	if a.shortMax == 0 {
+		return nil
+	}
+	ks, err := wordlist.NewKeyspace(symbols, 1, a.shortMax)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	it, err := ks.Range(0, ks.Total())
+	if err != nil {
+		return err
+	}
+	batch := make([][]byte, 4096)
+	for {
+		n := it.NextBatch(batch, len(batch))
+		if n == 0 {
+			return nil
+		}
+		for _, word := range batch[:n] {
+			if err := a.write(word); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// dictionarySection lists the common passwords and the words of the
+// dictionaries in lower, capitalized and upper case, bare and with the
+// suffixes and years people add to get a word past a policy.
+func (a *auditList) dictionarySection(report *auditReport, dicts []string) error {
+	rule := "a common password"
+	if len(dicts) > 0 {
+		rule += " or a word of " + describeLists(dicts)
+	}
+	report.Sections = append(report.Sections, auditSection{Name: "dictionary",
+		Rule: rule + ", in three cases, bare or with a common suffix or recent year"})
+	a.section = &report.Sections[len(report.Sections)-1]
+
+	suffixes := append([]string{""}, commonSuffixes...)
+	year := time.Now().Year()
+	for y := year + 1; y >= year-3; y-- {
+		suffixes = append(suffixes, strconv.Itoa(y), strconv.Itoa(y)+"!")
+	}
+	variants := func(word string) error {
+		for _, v := range []string{strings.ToLower(word), capitalize(word), strings.ToUpper(word)} {
+			for _, s := range suffixes {
+				if err := a.add(v + s); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, word := range strings.Fields(commonPasswords) {
+		if err := variants(word); err != nil {
+			return err
+		}
+	}
+	if len(dicts) == 0 {
+		return nil
+	}
+	_, err := forEachLine(dicts, "expanding", func(word []byte) error {
+		if len(word) == 0 {
+			return nil
+		}
+		return variants(string(word))
+	})
+	return err
+}
+
+// walkSection lists keyboard walks: four or more keys along a row or
+// column either way, and runs of two to four neighbouring columns such as
+// 1qaz2wsx, bare and capitalized.
+func (a *auditList) walkSection(report *auditReport) error {
+	report.Sections = append(report.Sections, auditSection{Name: "keyboard-walk",
+		Rule: "four or more keys along a keyboard row or column, or neighbouring columns in turn"})
+	a.section = &report.Sections[len(report.Sections)-1]
+
+	lines := slices.Concat(keyboardRows, keyboardColumns)
+	for n := 2; n <= 4; n++ {
+		for i := 0; i+n <= len(keyboardColumns); i++ {
+			lines = append(lines, strings.Join(keyboardColumns[i:i+n], ""))
+		}
+	}
+	for _, line := range lines {
+		for _, l := range []string{line, reverseString(line)} {
+			for i := range len(l) {
+				for j := i + 4; j <= len(l); j++ {
+					for _, v := range []string{l[i:j], capitalize(l[i:j])} {
+						if err := a.add(v); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeAuditReport(path string, report *auditReport) error {
+	w, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		w.Close()
+		return diskError("write report", err)
+	}
+	if err := w.Close(); err != nil {
+		return diskError("write report", err)
+	}
+	return nil
+}
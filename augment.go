@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// defaultSuffixes are the symbol and emoji endings people add to a word to
+// satisfy "needs a symbol", most frequent first. augment -dump-suffixes
+// writes them out for editing.
+var defaultSuffixes = []string{
+	"!", "1", "1!", "!!", "123", "!1", "@", "12", "#", "?",
+	"123!", "@123", "!@#", "*", ".", "$", "!!!", "1234", "@1", "#1",
+	"!123", "_", "01", "69", "007", "2024", "2025", "2023", "!2024", "!2025",
+	"❤️", "❤", "♥", "😊", "😂", "😍", "🔥", "⭐", "✨", "👍",
+	"<3", ":)", ";)", ":D", "xD", "xoxo", "!?", "$$", "**", "~",
+}
+
+// readSuffixes reads a suffix table: one suffix per line, most frequent
+// first; blank lines and lines starting with "# " are ignored.
+func readSuffixes(path string) ([]string, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, l := range lines {
+		if l == "" || strings.HasPrefix(l, "# ") {
+			continue
+		}
+		if !slices.Contains(out, l) {
+			out = append(out, l)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%w: %s has no suffixes", ErrConfig, path)
+	}
+	return out, nil
+}
+
+func augmentCmd(args []string) error {
+	fs := newToolFlags("augment", "list...")
+	table := fs.String("suffixes", "", "read the suffix table from this `file` (one per line, most frequent first) instead of the built-in one")
+	top := fs.Int("top", 0, "use only the first `n` suffixes of the table; 0 for all")
+	by := fs.String("by", "suffix", "ordering: suffix (every word with the first suffix, then the second...) or word (every suffix of a word together; works on stdin)")
+	keep := fs.Bool("keep-words", false, "also write each word unchanged, first")
+	dump := fs.Bool("dump-suffixes", false, "write the built-in suffix table, to edit and pass back with -suffixes")
+	out := fs.String("o", "", "write the candidates to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if *dump {
+		w, err := newListOutput(*out)
+		if err != nil {
+			return err
+		}
+		w.writeLine([]byte("# suffix table for augment -suffixes: one per line, most frequent first"))
+		for _, s := range defaultSuffixes {
+			w.writeLine([]byte(s))
+		}
+		return w.close()
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: augment needs at least one list (- for stdin with -by word)", ErrConfig)
+	}
+	switch {
+	case *by != "suffix" && *by != "word":
+		return fmt.Errorf("%w: -by %q (want suffix or word)", ErrConfig, *by)
+	case *by == "suffix" && slices.Contains(fs.Args(), "-"):
+		return fmt.Errorf("%w: -by suffix rereads the lists and cannot read stdin; use -by word", ErrConfig)
+	}
+
+	suffixes := defaultSuffixes
+	if *table != "" {
+		var err error
+		if suffixes, err = readSuffixes(*table); err != nil {
+			return err
+		}
+	}
+	if *top > 0 && *top < len(suffixes) {
+		suffixes = suffixes[:*top]
+	}
+	if *keep {
+		suffixes = append([]string{""}, suffixes...)
+	}
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	var words, written int64
+	write := func(word []byte, suffix string) error {
+		buf = append(append(buf[:0], word...), suffix...)
+		written++
+		return w.writeLine(buf)
+	}
+	switch *by {
+	case "word":
+		_, err = forEachLine(fs.Args(), "augmenting", func(word []byte) error {
+			if len(word) == 0 {
+				return nil
+			}
+			words++
+			for _, s := range suffixes {
+				if err := write(word, s); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case "suffix":
+		// One pass over the lists per suffix keeps memory flat.
+		for _, s := range suffixes {
+			words = 0
+			if _, err = forEachLine(fs.Args(), "augmenting with "+s, func(word []byte) error {
+				if len(word) == 0 {
+					return nil
+				}
+				words++
+				return write(word, s)
+			}); err != nil {
+				break
+			}
+		}
+	}
+	if cerr := w.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "➕ Augmented %s words with %d suffixes: %s candidates\n",
+		fmtInt(words), len(suffixes), fmtInt(written))
+	return nil
+}
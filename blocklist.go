@@ -0,0 +1,159 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// blocklistFormat is what a password system takes as a list of banned
+// passwords: how it compares terms, which it accepts, and how many.
+type blocklistFormat struct {
+	name           string
+	minLen, maxLen int // in characters; 0 for no bound
+	limit          int // the most terms it takes by default
+	normalize      func(word string) string
+	key            func(term string) string // what the system compares
+}
+
+// blocklistFormats are the formats blocklist writes. Entra ID (Azure AD)
+// takes up to 1,000 terms of 4 to 16 characters in its custom banned
+// password list, compared without regard to case and after undoing common
+// substitutions, so one lower-case term stands for all its spellings. NIST
+// SP 800-63B asks for passwords to be compared after Unicode
+// normalization, NFKC here.
+var blocklistFormats = map[string]blocklistFormat{
+	"azure": {name: "azure", minLen: 4, maxLen: 16, limit: 1000, normalize: cases.Fold().String, key: func(term string) string {
+		plain, _ := unleet(term, leetSubs[1])
+		return plain
+	}},
+	"nist": {name: "nist", limit: 100_000, normalize: norm.NFKC.String, key: func(term string) string { return term }},
+}
+
+func blocklistCmd(args []string) error {
+	fs := newToolFlags("blocklist", "[list...]")
+	format := fs.String("format", "nist", "write the list for `system`: nist (NFKC-normalized, one per line) or azure (Entra ID custom banned passwords: lower case, substitutions undone, 4-16 characters)")
+	limit := fs.Int("max-words", 0, "keep at most this many `terms`, the likeliest (0 for the format's default: 1,000 for azure, 100,000 for nist)")
+	minLen := fs.Int("min-length", 0, "drop terms shorter than this many characters, such as those the policy refuses anyway")
+	modelPath := fs.String("model", "", "rank by probability under this `model` from learn instead of by estimated strength")
+	var dicts stringList
+	fs.Var(&dicts, "strength-dict", "rank the words of this `list`, most common first, among the common passwords the strength estimate knows; may be repeated")
+	common := fs.Bool("common", true, "include the common passwords the strength estimate knows")
+	out := fs.String("o", "", "write the list to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	f, ok := blocklistFormats[*format]
+	if !ok {
+		return fmt.Errorf("%w: unknown -format %q (want nist or azure)", ErrConfig, *format)
+	}
+	if *limit < 0 || *minLen < 0 {
+		return fmt.Errorf("%w: -max-words and -min-length must not be negative", ErrConfig)
+	}
+	if *limit == 0 {
+		*limit = f.limit
+	}
+	f.minLen = max(f.minLen, *minLen)
+	if fs.NArg() == 0 && !*common {
+		fs.Usage()
+		return fmt.Errorf("%w: blocklist needs a list (- for stdin), or -common", ErrConfig)
+	}
+
+	// A term's cost is how late an attacker would guess it: log10 of the
+	// estimated guesses, or minus log10 of the model's probability. Of the
+	// terms the system takes for one, the cheapest is written.
+	var cost func(word string) float64
+	how := "estimated strength"
+	if *modelPath != "" {
+		model, err := loadStyleModel(*modelPath)
+		if err != nil {
+			return err
+		}
+		cost = func(word string) float64 { return -math.Log10(model.probability(word)) }
+		how = "probability under " + *modelPath
+	} else {
+		est, err := newStrengthEstimator(dicts)
+		if err != nil {
+			return err
+		}
+		cost = func(word string) float64 { return math.Log10(est.guesses(word)) }
+	}
+	type ranked struct {
+		term string
+		cost float64
+	}
+	terms := make(map[string]ranked)
+	var candidates, outside int64
+	add := func(word string) {
+		candidates++
+		term := f.normalize(word)
+		if n := utf8.RuneCountInString(term); n < max(f.minLen, 1) || f.maxLen > 0 && n > f.maxLen {
+			outside++
+			return
+		}
+		c, key := cost(word), f.key(term)
+		if old, ok := terms[key]; !ok || c < old.cost {
+			terms[key] = ranked{term, c}
+		}
+	}
+	if *common {
+		for _, word := range strings.Fields(commonPasswords) {
+			add(word)
+		}
+	}
+	var st listStats
+	if fs.NArg() > 0 {
+		var err error
+		if st, err = forEachLine(fs.Args(), "ranking", func(word []byte) error {
+			add(string(word))
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	unique := slices.Collect(maps.Values(terms))
+	slices.SortFunc(unique, func(a, b ranked) int {
+		return cmp.Or(cmp.Compare(a.cost, b.cost), strings.Compare(a.term, b.term))
+	})
+	kept := unique[:min(len(unique), *limit)]
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	for _, r := range kept {
+		if err = w.writeLine([]byte(r.term)); err != nil {
+			break
+		}
+	}
+	if cerr := w.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	source := "the common passwords"
+	switch {
+	case fs.NArg() > 0 && *common:
+		source += " and " + describeLists(fs.Args())
+	case fs.NArg() > 0:
+		source = describeLists(fs.Args())
+	}
+	fmt.Fprintf(os.Stderr, "🛡️  Blocklist for %s: %s terms of %s candidates from %s, likeliest first by %s\n",
+		f.name, fmtInt(int64(len(kept))), fmtInt(candidates), source, how)
+	fmt.Fprintf(os.Stderr, "   %s outside the lengths, %s repeats once normalized, %s past the limit of %s\n",
+		fmtInt(outside), fmtInt(candidates-outside-int64(len(unique))), fmtInt(int64(len(unique)-len(kept))), fmtInt(int64(*limit)))
+	if st.tooLong > 0 {
+		fmt.Fprintf(os.Stderr, "   %s lines over %s skipped\n", fmtInt(st.tooLong), fmtBytes(maxListLine))
+	}
+	return nil
+}
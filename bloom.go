@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+// bloomHashes is how many bits a word sets. Seven is best at about ten bits
+// per word, where a filter answers wrongly for 1 in 120 new words; fuller
+// filters still do better with it than with fewer.
+const bloomHashes = 7
+
+// bloomFilter remembers word hashes in fixed memory. It never forgets one,
+// but may claim to have seen a word it has not: then dedup drops a unique
+// word as a repeat, the price of a whole stream in a bounded size.
+type bloomFilter struct {
+	bits  []uint64
+	m     uint64 // bits
+	added int64
+}
+
+func newBloomFilter(size int64) (*bloomFilter, error) {
+	if size < 8 {
+		return nil, fmt.Errorf("%w: a Bloom filter needs at least 8 bytes", ErrConfig)
+	}
+	words := (size + 7) / 8
+	return &bloomFilter{bits: make([]uint64, words), m: uint64(words) * 64}, nil
+}
+
+// add reports whether h is new, remembering it if so. The bit positions
+// come from h and a second hash derived from it, stepping by the second
+// (Kirsch and Mitzenmacher), which is as good as independent hashes.
+func (b *bloomFilter) add(h uint64) bool {
+	step := bits.RotateLeft64(h, 32)*0x9e3779b97f4a7c15 | 1
+	seen := true
+	for range bloomHashes {
+		i := h % b.m
+		if b.bits[i/64]&(1<<(i%64)) == 0 {
+			seen = false
+			b.bits[i/64] |= 1 << (i % 64)
+		}
+		h += step
+	}
+	if !seen {
+		b.added++
+	}
+	return !seen
+}
+
+// falsePositive is the chance that a new word is taken for a repeat now.
+// It only grows as words are added, so times the words added it bounds how
+// many were dropped wrongly on the way.
+func (b *bloomFilter) falsePositive() float64 {
+	return math.Pow(1-math.Exp(-bloomHashes*float64(b.added)/float64(b.m)), bloomHashes)
+}
+
+// parseDedupMode reads -dedup: "exact", or "bloom:SIZE" for a Bloom filter
+// of SIZE bytes. The filter is nil for exact dedup.
+func parseDedupMode(mode string) (*bloomFilter, error) {
+	if mode == "exact" {
+		return nil, nil
+	}
+	size, ok := strings.CutPrefix(mode, "bloom:")
+	if !ok {
+		return nil, fmt.Errorf("%w: -dedup %q: want exact or bloom:SIZE, such as bloom:4GB", ErrConfig, mode)
+	}
+	n, err := parseBytes(size)
+	if err != nil {
+		return nil, err
+	}
+	return newBloomFilter(n)
+}
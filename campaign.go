@@ -0,0 +1,269 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// target describes the system a campaign is aimed at.
+type target struct {
+	Company  string         `json:"company"`
+	Domain   string         `json:"domain"`
+	Locale   string         `json:"locale"`
+	Keywords []string       `json:"keywords"`
+	Policy   passwordPolicy `json:"policy"`
+}
+
+// campaign is a prioritized plan of attack: the stages run in order, the
+// likeliest and cheapest first, and every candidate must pass Policy.
+type campaign struct {
+	Target string         `json:"target"`
+	Locale string         `json:"locale"`
+	Policy passwordPolicy `json:"policy"`
+	Seeds  []string       `json:"seeds"`
+	Stages []stage        `json:"stages"`
+}
+
+// stage is one step of a campaign. A candidate is a seed word (when Seeds is
+// set) followed by one of Suffixes or by a word of Mask.
+type stage struct {
+	Priority   int      `json:"priority"`
+	Name       string   `json:"name"`
+	Seeds      bool     `json:"seeds,omitempty"`
+	Suffixes   []string `json:"suffixes,omitempty"`
+	Mask       string   `json:"mask,omitempty"`
+	Candidates int64    `json:"candidates"` // that pass the policy, for masks an upper bound
+}
+
+// localeSeeds are words people in each language build passwords from.
+var localeSeeds = map[string][]string{
+	"en": {"password", "welcome", "summer", "winter", "spring", "autumn", "letmein"},
+	"de": {"passwort", "willkommen", "sommer", "winter", "fruehling", "herbst", "hallo"},
+	"fr": {"motdepasse", "bienvenue", "ete", "hiver", "printemps", "automne", "soleil"},
+	"es": {"contrasena", "bienvenido", "verano", "invierno", "primavera", "otono", "hola"},
+	"it": {"password", "benvenuto", "estate", "inverno", "primavera", "autunno", "ciao"},
+	"nl": {"wachtwoord", "welkom", "zomer", "winter", "lente", "herfst", "hallo"},
+	"pt": {"senha", "bemvindo", "verao", "inverno", "primavera", "outono", "ola"},
+}
+
+// Suffixes users add to a word to get it past a policy, most common first.
+var (
+	commonSuffixes = []string{"1", "12", "123", "1234", "!", "1!", "123!", "01", "#1", "@1"}
+	suffixMasks    = []string{"?d", "?d?d", "?d?d?d", "?d?d?s", "?d?d?d?d", "?d?d?d?d?s"}
+)
+
+func campaignCmd(args []string) error {
+	fs := newToolFlags("campaign", "target.json")
+	out := fs.String("o", "", "write the campaign to this `file` instead of stdout")
+	examples := fs.String("examples", "", "infer the policy from the passwords in this `file` when the target has none")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("%w: campaign needs one target descriptor", ErrConfig)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	defer f.Close()
+	var t target
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&t); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrConfig, fs.Arg(0), err)
+	}
+	if *examples != "" {
+		if err := t.inferPolicy(*examples); err != nil {
+			return err
+		}
+	}
+	if err := t.Policy.validate(); err != nil {
+		return err
+	}
+
+	c := planCampaign(t, time.Now().Year())
+	w, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(c); err != nil {
+		w.Close()
+		return diskError("write campaign", err)
+	}
+	if err := w.Close(); err != nil {
+		return diskError("write campaign", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "🎯 %s: %d seeds, %d stages, policy %s\n", c.Target, len(c.Seeds), len(c.Stages), &c.Policy)
+	for _, s := range c.Stages {
+		fmt.Fprintf(os.Stderr, "   %2d. %-32s %12s candidates\n", s.Priority, s.Name, fmtCount(s.Candidates))
+	}
+	return nil
+}
+
+// inferPolicy fills in t's policy from the example passwords in path, unless
+// the descriptor already states one, which is then only checked against them.
+func (t *target) inferPolicy(path string) error {
+	examples, err := readPolicyExamples(path)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(t.Policy, passwordPolicy{}) {
+		for _, ex := range examples {
+			if !t.Policy.allows(ex) {
+				slog.Warn("example password violates the target's policy", "policy", t.Policy.String(), "length", utf8.RuneCountInString(ex))
+			}
+		}
+		return nil
+	}
+	p, err := inferPolicy(examples)
+	if err != nil {
+		return err
+	}
+	t.Policy = p
+	reportInferred(&p, len(examples))
+	return nil
+}
+
+// planCampaign derives the seeds and stages for t, taking year as the
+// current year.
+func planCampaign(t target, year int) campaign {
+	lang, _, _ := strings.Cut(strings.ToLower(strings.ReplaceAll(t.Locale, "-", "_")), "_")
+	if _, ok := localeSeeds[lang]; !ok {
+		lang = "en"
+	}
+	name := t.Company
+	if name == "" {
+		name = t.Domain
+	}
+	c := campaign{Target: name, Locale: lang, Policy: t.Policy}
+
+	// Seeds: the organisation's names and keywords, then the locale's
+	// favourites, each lower-case, capitalized and upper-case.
+	var base []string
+	words := strings.FieldsFunc(t.Company, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	base = append(base, strings.Join(words, ""))
+	base = append(base, words...)
+	domain := strings.TrimPrefix(strings.ToLower(t.Domain), "www.")
+	if label, _, _ := strings.Cut(domain, "."); label != "" {
+		base = append(base, label)
+	}
+	base = append(base, t.Keywords...)
+	base = append(base, localeSeeds[lang]...)
+	for _, w := range base {
+		for _, v := range []string{strings.ToLower(w), capitalize(w), strings.ToUpper(w)} {
+			if v != "" && !slices.Contains(c.Seeds, v) {
+				c.Seeds = append(c.Seeds, v)
+			}
+		}
+	}
+
+	var years []string
+	for y := year + 1; y >= year-3; y-- {
+		years = append(years, strconv.Itoa(y), strconv.Itoa(y)+"!", strconv.Itoa(y%100))
+	}
+	var stages []stage
+	add := func(s stage) {
+		if s.Candidates > 0 {
+			stages = append(stages, s)
+		}
+	}
+	add(c.suffixStage("seed + year", years))
+	add(c.suffixStage("seed + common suffix", commonSuffixes))
+	for _, m := range suffixMasks {
+		add(c.seedMaskStage("seed + "+m, m))
+	}
+	seeded := len(stages)
+	for _, m := range c.policyMasks() {
+		add(stage{Name: "mask " + m, Mask: m, Candidates: maskSize(mustParseMask(m))})
+	}
+
+	// Seeded stages are far likelier than blind masks; within each group
+	// the smaller stage goes first.
+	bySize := func(a, b stage) int { return int(min(max(a.Candidates-b.Candidates, -1), 1)) }
+	slices.SortStableFunc(stages[:seeded], bySize)
+	slices.SortStableFunc(stages[seeded:], bySize)
+	for i := range stages {
+		stages[i].Priority = i + 1
+	}
+	c.Stages = stages
+	return c
+}
+
+// suffixStage counts the seed and suffix pairs that pass the policy.
+func (c *campaign) suffixStage(name string, suffixes []string) stage {
+	s := stage{Name: name, Seeds: true, Suffixes: suffixes}
+	for _, seed := range c.Seeds {
+		for _, suffix := range suffixes {
+			if c.Policy.allows(seed + suffix) {
+				s.Candidates++
+			}
+		}
+	}
+	return s
+}
+
+// seedMaskStage counts the seeds that can pass the policy followed by a
+// word of mask, times the size of mask.
+func (c *campaign) seedMaskStage(name, mask string) stage {
+	positions := mustParseMask(mask)
+	s := stage{Name: name, Seeds: true, Mask: mask}
+	for _, seed := range c.Seeds {
+		classes := unionClasses(classesIn(seed), shapeClasses(strings.ReplaceAll(mask, "?", "")))
+		if c.Policy.allowsClasses(utf8.RuneCountInString(seed)+len(positions), classes) {
+			s.Candidates += maskSize(positions)
+		}
+	}
+	return s
+}
+
+// policyMasks returns the usual human shapes of a password, at the policy's
+// shortest lengths, that can satisfy it: Capital + lowers + digits and so
+// on.
+func (c *campaign) policyMasks() []string {
+	minLen := max(c.Policy.MinLen, 6)
+	var masks []string
+	for n := minLen; n <= minLen+2; n++ {
+		if c.Policy.MaxLen > 0 && n > c.Policy.MaxLen {
+			break
+		}
+		shapes := []string{
+			"u" + strings.Repeat("l", n-2) + "d",
+			"u" + strings.Repeat("l", n-3) + "dd",
+			"u" + strings.Repeat("l", n-3) + "ds",
+			"u" + strings.Repeat("l", n-5) + "dddd",
+			strings.Repeat("l", n-2) + "dd",
+			strings.Repeat("l", n-4) + "dddd",
+			strings.Repeat("d", n),
+		}
+		for _, shape := range shapes {
+			if m := maskFor(shape); c.Policy.allowsClasses(n, shapeClasses(shape)) && !slices.Contains(masks, m) {
+				masks = append(masks, m)
+			}
+		}
+	}
+	return masks
+}
+
+// mustParseMask parses a mask built into the program.
+func mustParseMask(mask string) []string {
+	positions, err := parseMask(mask)
+	if err != nil {
+		panic(err)
+	}
+	return positions
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+
+	"main.go/wordlist"
+)
+
+// charsetFile is -charset-file, read into charset by loadCharsetFile.
+var charsetFile string
+
+// keyspaceMask is -mask: when set, the keyspace is the words of this
+// hashcat-style mask instead of charset and lengths.
+var keyspaceMask string
+
+// hybrid is -hybrid-dict with its masks: when dict is set, the keyspace is
+// every word of the dictionary between a word of prefix and one of suffix.
+var hybrid struct {
+	dict           string
+	prefix, suffix string
+	words          int    // distinct words read from dict
+	sum            string // XXH64 of dict, which states record
+}
+
+// combinator is -left-list and -right-list, which combine takes as its
+// arguments, and -separator: when left is set, the keyspace is every word
+// of left joined to every word of right.
+var combinator struct {
+	left, right       string
+	seps              stringList
+	leftSum, rightSum string // their XXH64, which states record
+}
+
+// addKeyspaceFlags registers the flags that shape the keyspace on fs:
+// -charset, -charset-file, -min-len, -max-len, -mask, crunch's -t and -l,
+// -hybrid-dict with -prefix-mask and -suffix-mask, and -left-list and
+// -right-list with -separator.
+func addKeyspaceFlags(fs *flag.FlagSet) {
+	fs.StringVar(&charset, "charset", charset, "`symbols` of the keyspace, one per character, in order")
+	fs.StringVar(&charsetFile, "charset-file", "", "read the charset from this `file` (UTF-8, a final newline dropped)")
+	fs.IntVar(&minLength, "min-len", minLength, "shortest words, in `symbols`")
+	fs.IntVar(&maxLength, "max-len", maxLength, "longest words, in `symbols`")
+	fs.StringVar(&keyspaceMask, "mask", "", "generate the words of this `mask` (?u?l?l?l?d?d: ?l ?u ?d ?s ?a, ?? and other characters literal) instead of a charset and lengths")
+	fs.StringVar(&crunch.template, "t", "", "generate the words of this crunch `template` instead of a charset and lengths: pass@@%% takes @ lowercase, , uppercase, % digit and ^ symbol, other characters literal")
+	fs.StringVar(&crunch.literal, "l", "", "with -t, take the placeholder literally where this `string`, as long as the template, has @ , % or ^ (crunch's -l)")
+	fs.StringVar(&hybrid.dict, "hybrid-dict", "", "generate every word of this `dictionary` (one per line) with every -suffix-mask after it and -prefix-mask before it, instead of a charset and lengths")
+	fs.StringVar(&hybrid.prefix, "prefix-mask", "", "with -hybrid-dict, put the words of this `mask` before every dictionary word")
+	fs.StringVar(&hybrid.suffix, "suffix-mask", "", "with -hybrid-dict, put the words of this `mask` after every dictionary word")
+	fs.StringVar(&combinator.left, "left-list", "", "generate every word of this `list` joined to every word of -right-list, as combine does")
+	fs.StringVar(&combinator.right, "right-list", "", "the `list` whose words follow those of -left-list")
+	fs.Var(&combinator.seps, "separator", "with combine, put this `string` between the words, each in turn; may be repeated, and empty to join them directly")
+}
+
+// hybridKeyspace builds the -hybrid-dict keyspace. Its words all have one
+// length in positions, the masks' and one for the dictionary word, which
+// becomes -min-len and -max-len so states and headers show it.
+func hybridKeyspace() (*wordlist.Keyspace, error) {
+	switch {
+	case keyspaceMask != "":
+		return nil, fmt.Errorf("%w: -mask and -hybrid-dict both give the keyspace", ErrConfig)
+	case charset != defaultCharset || charsetFile != "":
+		return nil, fmt.Errorf("%w: -hybrid-dict takes its characters from the masks; drop -charset and -charset-file", ErrConfig)
+	case hybrid.prefix == "" && hybrid.suffix == "":
+		return nil, fmt.Errorf("%w: -hybrid-dict needs -suffix-mask, -prefix-mask or both", ErrConfig)
+	}
+	prefix, err := parseMask(hybrid.prefix)
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := parseMask(hybrid.suffix)
+	if err != nil {
+		return nil, err
+	}
+	words, sum, err := readDictionary("-hybrid-dict", hybrid.dict)
+	if err != nil {
+		return nil, err
+	}
+	ks, err := wordlist.NewHybridKeyspace(words, prefix, suffix)
+	if err != nil {
+		return nil, fmt.Errorf("%w: -hybrid-dict %s: %w", ErrConfig, hybrid.dict, err)
+	}
+	hybrid.words, hybrid.sum = len(words), sum
+	minLength, maxLength = len(prefix)+1+len(suffix), len(prefix)+1+len(suffix)
+	return ks, nil
+}
+
+// combinatorKeyspace builds the keyspace of combine, -left-list and
+// -right-list. Its words have two positions, three with -separator.
+func combinatorKeyspace() (*wordlist.Keyspace, error) {
+	switch {
+	case combinator.left == "" || combinator.right == "":
+		return nil, fmt.Errorf("%w: -left-list and -right-list go together", ErrConfig)
+	case keyspaceMask != "" || hybrid.dict != "":
+		return nil, fmt.Errorf("%w: -mask, -hybrid-dict and the lists of combine each give the keyspace", ErrConfig)
+	case charset != defaultCharset || charsetFile != "":
+		return nil, fmt.Errorf("%w: combine takes its words from the lists; drop -charset and -charset-file", ErrConfig)
+	}
+	left, leftSum, err := readDictionary("left list", combinator.left)
+	if err != nil {
+		return nil, err
+	}
+	right, rightSum, err := readDictionary("right list", combinator.right)
+	if err != nil {
+		return nil, err
+	}
+	ks, err := wordlist.NewCombinatorKeyspace(left, right, combinator.seps)
+	if err != nil {
+		return nil, fmt.Errorf("%w: combine %s %s: %w", ErrConfig, combinator.left, combinator.right, err)
+	}
+	combinator.leftSum, combinator.rightSum = leftSum, rightSum
+	minLength = 2 + min(len(combinator.seps), 1)
+	maxLength = minLength
+	return ks, nil
+}
+
+// readDictionary reads the words of a list, one per line, in its order
+// and with its XXH64; what names it in errors. A repeated word would stand
+// for two positions, so only its first line counts, and empty lines none.
+func readDictionary(what, path string) (words []string, sum string, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %s: %w", ErrConfig, what, err)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		word := strings.TrimSuffix(line, "\r")
+		if word == "" || seen[word] {
+			continue
+		}
+		seen[word] = true
+		words = append(words, word)
+	}
+	if len(words) == 0 {
+		return nil, "", fmt.Errorf("%w: %s %s holds no words", ErrConfig, what, path)
+	}
+	return words, fmt.Sprintf("%016x", xxhash.Sum64(data)), nil
+}
+
+// combinatorSpec is the combine keyspace as states record it: the lists'
+// XXH64 and the separators between them, or "" without one.
+func combinatorSpec() string {
+	if combinator.left == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s %q %s", combinator.leftSum, []string(combinator.seps), combinator.rightSum)
+}
+
+// hybridSpec is the -hybrid-dict keyspace as states record it: the masks
+// around the dictionary's XXH64 in braces, or "" without one.
+func hybridSpec() string {
+	if hybrid.dict == "" {
+		return ""
+	}
+	return hybrid.prefix + "{" + hybrid.sum + "}" + hybrid.suffix
+}
+
+// maskKeyspace builds the -mask keyspace. Its words all have one length,
+// which becomes -min-len and -max-len so states and headers show it.
+func maskKeyspace() (*wordlist.Keyspace, error) {
+	if charset != defaultCharset || charsetFile != "" {
+		return nil, fmt.Errorf("%w: -mask gives every position its own characters; drop -charset and -charset-file", ErrConfig)
+	}
+	positions, err := parseMask(keyspaceMask)
+	if err != nil {
+		return nil, err
+	}
+	ks, err := wordlist.NewMaskKeyspace(positions)
+	if err != nil {
+		return nil, fmt.Errorf("%w: -mask %q: %w", ErrConfig, keyspaceMask, err)
+	}
+	minLength, maxLength = len(positions), len(positions)
+	return ks, nil
+}
+
+// loadCharsetFile sets charset from -charset-file, if given.
+func loadCharsetFile() error {
+	if charsetFile == "" {
+		return nil
+	}
+	if charset != defaultCharset {
+		return fmt.Errorf("%w: -charset and -charset-file both give the charset", ErrConfig)
+	}
+	data, err := os.ReadFile(charsetFile)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	text := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	if text == "" {
+		return fmt.Errorf("%w: %s holds no symbols", ErrConfig, charsetFile)
+	}
+	charset, charsetFile = text, "" // loaded once
+	return nil
+}
+
+// describeSeparators is -separator for the run header.
+func describeSeparators() string {
+	if len(combinator.seps) == 0 {
+		return "nothing"
+	}
+	quoted := make([]string, len(combinator.seps))
+	for i, s := range combinator.seps {
+		quoted[i] = strconv.Quote(s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// describeCharset is the charset for the run header.
+func describeCharset() string {
+	if charset == defaultCharset {
+		return "a-z A-Z 0-9 _ ."
+	}
+	return strconv.Quote(charset)
+}
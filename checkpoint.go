@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// checkpointInterval is -checkpoint-interval: a duration such as 30s, or a
+// number of entries. Zero saves the state only between chunks.
+type checkpointInterval struct {
+	every   time.Duration
+	entries int64
+}
+
+func (c *checkpointInterval) String() string {
+	switch {
+	case c.every > 0:
+		return c.every.String()
+	case c.entries > 0:
+		return strconv.FormatInt(c.entries, 10)
+	}
+	return "0"
+}
+
+func (c *checkpointInterval) Set(s string) error {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n >= 0 {
+		*c = checkpointInterval{entries: n}
+		return nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return fmt.Errorf("want a duration such as 30s or a number of entries, not %q", s)
+	}
+	*c = checkpointInterval{every: d}
+	return nil
+}
+
+func (c checkpointInterval) enabled() bool { return c.every > 0 || c.entries > 0 }
+
+// checkpointer decides when writeChunk saves how far into a chunk it is, and
+// saves it: a crash then costs the words since the last checkpoint instead
+// of the whole chunk.
+type checkpointer struct {
+	interval checkpointInterval
+	save     func(partial) error
+	time     time.Time // of the last checkpoint, or the chunk's start
+	pos      int64
+}
+
+// start resets the interval for a chunk beginning at pos.
+func (c *checkpointer) start(pos int64) {
+	c.time, c.pos = time.Now(), pos
+}
+
+// due reports whether a checkpoint is due at pos, and if so counts the
+// interval again from there.
+func (c *checkpointer) due(pos int64) bool {
+	now := time.Now()
+	if c.interval.every > 0 && now.Sub(c.time) < c.interval.every ||
+		c.interval.entries > 0 && pos-c.pos < c.interval.entries {
+		return false
+	}
+	c.time, c.pos = now, pos
+	return true
+}
+
+// describeCheckpoint is the interval for the run header.
+func describeCheckpoint(c checkpointInterval) string {
+	if c.every > 0 {
+		return fmtDuration(c.every)
+	}
+	return fmtInt(c.entries) + " entries"
+}
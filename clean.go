@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+)
+
+// What clean does with a line that has a given defect.
+const (
+	fixDrop     = "drop"     // leave the line out
+	fixStrip    = "strip"    // remove the offending bytes or characters
+	fixTruncate = "truncate" // cut an overlong line to the limit
+	fixKeep     = "keep"     // write the line as it is
+)
+
+// cleanReport counts what clean found and did; it is also written as JSON
+// with -report.
+type cleanReport struct {
+	Lines       int64  `json:"lines"`
+	Written     int64  `json:"written"`
+	BOMs        int64  `json:"boms_removed"`
+	CRLF        int64  `json:"crlf_endings"`
+	InvalidUTF8 int64  `json:"invalid_utf8"`
+	Control     int64  `json:"control_characters"`
+	Long        int64  `json:"long"`
+	Overlong    int64  `json:"skipped_over_64k"`
+	Empty       int64  `json:"empty_dropped"`
+	Dropped     int64  `json:"dropped"`
+	Modified    int64  `json:"modified"`
+	InvalidFix  string `json:"invalid_utf8_policy"`
+	ControlFix  string `json:"control_policy"`
+	LongFix     string `json:"long_policy"`
+	MaxLength   int    `json:"max_length"`
+}
+
+// cleaner sanitizes one line at a time, reusing two buffers.
+type cleaner struct {
+	r          *cleanReport
+	buf, spare []byte
+}
+
+// clean returns the sanitized line and whether to write it.
+func (c *cleaner) clean(line []byte) ([]byte, bool) {
+	r := c.r
+	orig := len(line)
+	for bytes.HasPrefix(line, []byte("\uFEFF")) {
+		line = line[3:]
+		r.BOMs++
+	}
+
+	if !utf8.Valid(line) {
+		r.InvalidUTF8++
+		switch r.InvalidFix {
+		case fixDrop:
+			return nil, false
+		case fixStrip:
+			line = c.keepRunes(line, func(r rune, size int) bool { return r != utf8.RuneError || size > 1 })
+		}
+	}
+
+	if bytes.ContainsFunc(line, unicode.IsControl) {
+		r.Control++
+		switch r.ControlFix {
+		case fixDrop:
+			return nil, false
+		case fixStrip:
+			line = c.keepRunes(line, func(r rune, _ int) bool { return !unicode.IsControl(r) })
+		}
+	}
+
+	if r.MaxLength > 0 && utf8.RuneCount(line) > r.MaxLength {
+		r.Long++
+		switch r.LongFix {
+		case fixDrop:
+			return nil, false
+		case fixTruncate:
+			cut := 0
+			for range r.MaxLength {
+				_, size := utf8.DecodeRune(line[cut:])
+				cut += size
+			}
+			line = line[:cut]
+		}
+	}
+
+	if len(line) == 0 {
+		r.Empty++
+		return nil, false
+	}
+	if len(line) != orig {
+		r.Modified++
+	}
+	return line, true
+}
+
+// keepRunes returns the runes of line that keep accepts. line may be the
+// result of an earlier call.
+func (c *cleaner) keepRunes(line []byte, keep func(r rune, size int) bool) []byte {
+	out := c.spare[:0]
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRune(line[i:])
+		if keep(r, size) {
+			out = append(out, line[i:i+size]...)
+		}
+		i += size
+	}
+	c.spare, c.buf = c.buf, out
+	return out
+}
+
+func cleanCmd(args []string) error {
+	fs := newToolFlags("clean", "list...")
+	r := &cleanReport{}
+	fs.StringVar(&r.InvalidFix, "invalid", fixDrop, "lines with invalid UTF-8: drop, strip (the bad bytes) or keep")
+	fs.StringVar(&r.ControlFix, "control", fixStrip, "control characters (tabs, stray CRs, NULs...): drop the line, strip them or keep")
+	fs.IntVar(&r.MaxLength, "max-length", 128, "lines longer than this many characters are too long; 0 for no limit")
+	fs.StringVar(&r.LongFix, "long", fixDrop, "too long lines: drop, truncate or keep")
+	out := fs.String("o", "", "write the clean list to this `file` instead of stdout")
+	reportPath := fs.String("report", "", "also write the report as JSON to this `file`")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: clean needs at least one list (- for stdin)", ErrConfig)
+	}
+	for _, f := range []struct {
+		name, value string
+		allowed     []string
+	}{
+		{"invalid", r.InvalidFix, []string{fixDrop, fixStrip, fixKeep}},
+		{"control", r.ControlFix, []string{fixDrop, fixStrip, fixKeep}},
+		{"long", r.LongFix, []string{fixDrop, fixTruncate, fixKeep}},
+	} {
+		if !slices.Contains(f.allowed, f.value) {
+			return fmt.Errorf("%w: -%s %q (want one of %v)", ErrConfig, f.name, f.value, f.allowed)
+		}
+	}
+	if r.MaxLength < 0 {
+		return fmt.Errorf("%w: -max-length %d", ErrConfig, r.MaxLength)
+	}
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	c := cleaner{r: r}
+	st, err := forEachLine(fs.Args(), "cleaning", func(line []byte) error {
+		line, ok := c.clean(line)
+		if !ok {
+			return nil
+		}
+		r.Written++
+		return w.writeLine(line)
+	})
+	if cerr := w.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	r.Lines, r.CRLF, r.Overlong = st.lines+st.tooLong, st.crlf, st.tooLong
+	r.Dropped = r.Lines - r.Written
+
+	if *reportPath != "" {
+		data, _ := json.MarshalIndent(r, "", "  ")
+		if err := os.WriteFile(*reportPath, append(data, '\n'), 0o644); err != nil {
+			return diskError("write "+*reportPath, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "🧼 Cleaned %s: wrote %s of %s lines, %s modified, %s dropped\n",
+		describeLists(fs.Args()), fmtInt(r.Written), fmtInt(r.Lines), fmtInt(r.Modified), fmtInt(r.Dropped))
+	for _, row := range []struct {
+		what string
+		n    int64
+		fix  string
+	}{
+		{"byte order marks", r.BOMs, "removed"},
+		{"CRLF line endings", r.CRLF, "converted"},
+		{"lines with invalid UTF-8", r.InvalidUTF8, r.InvalidFix},
+		{"lines with control characters", r.Control, r.ControlFix},
+		{fmt.Sprintf("lines over %d characters", r.MaxLength), r.Long, r.LongFix},
+		{"lines over 64 kB", r.Overlong, fixDrop},
+		{"empty lines", r.Empty, fixDrop},
+	} {
+		if row.n > 0 {
+			fmt.Fprintf(os.Stderr, "   %-32s %12s  (%s)\n", row.what, fmtInt(row.n), row.fix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// command is a subcommand that works on the run in the current directory.
+// Unlike a tool it takes the run's own flags, so the keyspace, -per-file
+// and -compress of a status, seek or verify are those the run was made
+// with.
+type command struct {
+	summary string
+	usage   string // its arguments after the flags; "" when it takes none
+	run     func(ctx context.Context, opts *options, args []string) error
+}
+
+// commands is filled in init, since the usage message reads it.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"generate": {"generate the keyspace into chunks, continuing from " + stateFile + " if there is one", "", generateCmd},
+		"resume":   {"continue the run " + stateFile + " records, and fail when there is none", "", resumeCmd},
+		"recover":  {"rebuild " + stateFile + " from the chunk files on disk", "", recoverCmd},
+		"status":   {"print how far the run has got and when it should finish", "", statusCmd},
+		"plan":     {"print what the run will write and how long it should take on this machine, measuring it on first use", "", planCmd},
+		"seek":     {"print the position of each word, and the chunk and line it is on (words from stdin without any)", "[WORD...]", seekCmd},
+		"lookup":   {"the same as seek", "[WORD...]", seekCmd},
+		"split":    {"print the positions, chunks and state file of each node of -nodes (or of -node)", "", splitCmd},
+		"verify":   {"generate the chunks on disk again and compare, and check them against " + manifestFile, "", verifyCmd},
+		"coverage": {"print a map of the keyspace by length and first symbol, showing what is published, generated and remaining, and draw it in a PNG", "[FILE.png]", coverageCmd},
+		"combine":  {"generate every word of the left list joined to every word of the right one, continuing from " + stateFile + " if there is one", "LEFT RIGHT", combineCmd},
+	}
+}
+
+// commandUsage lists the commands for the main usage message.
+func commandUsage(w io.Writer) {
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		c := commands[name]
+		fmt.Fprintf(w, "  %s\t%s\n", strings.TrimSpace(name+" "+c.usage), c.summary)
+	}
+}
+
+func generateCmd(ctx context.Context, opts *options, _ []string) error {
+	switch {
+	case opts.listSnapshots:
+		return printSnapshots()
+	case opts.rollback != "":
+		last, err := rollbackState(stateFile, opts.rollback)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("⏪ %s restored from %s: resuming after position %s\n", stateFile, opts.rollback, fmtInt(last))
+		return nil
+	}
+	if opts.errs.disk == skip {
+		return fmt.Errorf("%w: -on-disk-error=skip would leave a gap in the wordlist; use abort or retry", ErrConfig)
+	}
+	if opts.testMode {
+		return runTestMode(ctx, opts)
+	}
+	if err := run(ctx, opts); errors.Is(err, ErrReaderGone) {
+		slog.Info("the reader closed the stream; stopping", "state", stateFile)
+		fmt.Printf("\n🔌 The reader closed the stream. %s holds the last complete chunk; run again to continue from it.\n", stateFile)
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// combineCmd is generate over the combinator keyspace of its two lists.
+func combineCmd(ctx context.Context, opts *options, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%w: combine takes two lists, LEFT and RIGHT", ErrConfig)
+	}
+	if combinator.left != "" || combinator.right != "" {
+		return fmt.Errorf("%w: combine takes its lists as arguments; drop -left-list and -right-list", ErrConfig)
+	}
+	combinator.left, combinator.right = args[0], args[1]
+	return generateCmd(ctx, opts, nil)
+}
+
+// resumeCmd is generate for a run that has started: without a state it
+// fails rather than start a fresh run in what may be the wrong directory.
+func resumeCmd(ctx context.Context, opts *options, args []string) error {
+	if _, err := os.Stat(stateFile); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: there is no %s here to resume from; use generate to start a run", ErrConfig, stateFile)
+	} else if err != nil {
+		return fmt.Errorf("%w: %w", ErrStateCorrupt, err)
+	}
+	return generateCmd(ctx, opts, args)
+}
+
+func recoverCmd(_ context.Context, opts *options, _ []string) error {
+	ks, err := newKeyspace()
+	if err == nil {
+		chunkExt, err = opts.compress.ext()
+	}
+	if err != nil {
+		return err
+	}
+	return recoverState(ks)
+}
+
+// statusCmd reports the position in the state and an expected finish. The
+// speed comes from the oldest and newest state snapshots, as bytes per
+// second, since later words are longer.
+func statusCmd(_ context.Context, _ *options, _ []string) error {
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	pos, err := readState(stateFile, total)
+	if err != nil {
+		return err
+	}
+	start, end := runSlice()
+	past := pos > end // the state went on past -stop-at, or belongs to a later slice
+	pos = min(max(pos, start), end)
+	files, done := sliceFiles(start, end), pos/entriesPerFile-start/entriesPerFile
+	if pos >= end {
+		done = files // the last chunk may be short
+	}
+	fmt.Printf("Keyspace  : %s\n", describeKeyspace())
+	if node > 0 {
+		fmt.Printf("Node      : %d of %d, positions %s–%s\n", node, nodes, fmtInt(start), fmtInt(end-1))
+	}
+	fmt.Printf("Position  : %s of %s (%s%%)\n", fmtInt(pos), fmtInt(end), fmtFloat(percentOf(pos-start, end-start), 4))
+	fmt.Printf("Files     : %s of %s completed\n", fmtInt(done), fmtInt(files))
+	if p, ok := statePartial(stateFile); ok {
+		fmt.Printf("Partial   : %s holds %s words, continued on the next run\n", p.name, fmtInt(p.pos-pos))
+	}
+	if pos >= end {
+		fmt.Println("Finished  : every word generated")
+		if past {
+			fmt.Printf("            %s is already past position %s, this run's end\n", stateFile, fmtInt(end))
+		}
+		return nil
+	}
+	fmt.Printf("Remaining : %s words, %s\n", fmtInt(end-pos), fmtBytes(ks.Bytes(pos, end)))
+
+	snaps, err := listSnapshots()
+	if err != nil {
+		return err
+	}
+	snaps = slices.DeleteFunc(snaps, func(s snapshot) bool { return s.err != nil || s.time.IsZero() })
+	if len(snaps) < 2 || !snaps[0].time.After(snaps[len(snaps)-1].time) || snaps[0].last <= snaps[len(snaps)-1].last {
+		hw, ok := storedHardware()
+		if !ok {
+			fmt.Printf("ETA       : unknown until %s holds two snapshots of progress\n", snapshotDir)
+			return nil
+		}
+		eta := time.Duration(float64(ks.Bytes(pos, end)) / hw.Generate * float64(time.Second))
+		fmt.Printf("ETA       : around %s on one core at the speed plan measured, until %s holds two snapshots of progress\n", fmtDuration(eta), snapshotDir)
+		return nil
+	}
+	newest, oldest := snaps[0], snaps[len(snaps)-1]
+	rate := float64(ks.Bytes(oldest.last+1, newest.last+1)) / newest.time.Sub(oldest.time).Seconds()
+	eta := time.Duration(float64(ks.Bytes(pos, end)) / rate * float64(time.Second))
+	fmt.Printf("Speed     : %s/s from %s to %s\n", fmtBytes(int64(rate)), oldest.time.Local().Format(time.DateTime), newest.time.Local().Format(time.DateTime))
+	fmt.Printf("ETA       : %s, around %s if the run is still going\n", fmtDuration(eta), newest.time.Add(eta).Local().Format(time.DateTime))
+	return nil
+}
+
+// seekCmd prints each word's position and where the run puts it, as
+// tab-separated word, position, chunk number and chunk:line. Words come from
+// the arguments or, one per line, from stdin.
+func seekCmd(_ context.Context, _ *options, words []string) error {
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	out := bufio.NewWriter(os.Stdout)
+	var outside int
+	query := func(word string) error {
+		indices, err := ks.IndicesOf(word)
+		if err != nil {
+			outside++
+			_, err := fmt.Fprintf(out, "%s\t-\t-\t-\n", word)
+			return err
+		}
+		// A word a combinator keyspace holds more than once gets a row for
+		// each place.
+		for _, i := range indices {
+			where := "excluded"
+			if !ks.Excluded(i) {
+				where = fmt.Sprintf("%s:%d", chunkName(int(i/entriesPerFile)+1), ks.ChunkLine(i, entriesPerFile))
+			}
+			if _, err := fmt.Fprintf(out, "%s\t%d\t%d\t%s\n", word, i, i/entriesPerFile+1, where); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(words) > 0 && !(len(words) == 1 && words[0] == "-") {
+		for _, word := range words {
+			if err := query(word); err != nil {
+				return diskError("write output", err)
+			}
+		}
+	} else if _, err := forEachLine([]string{"-"}, "looking up", func(word []byte) error {
+		return query(string(word))
+	}); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return diskError("write output", err)
+	}
+	if outside > 0 {
+		return fmt.Errorf("%w: %d of the words are not in the keyspace", ErrNotFound, outside)
+	}
+	return nil
+}
+
+// verifyCmd checks every chunk the state counts as complete: that it is on
+// disk, holds exactly the words of its range, and matches its checksum in
+// the manifest when it is listed there.
+func verifyCmd(ctx context.Context, opts *options, _ []string) error {
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	if chunkExt, err = opts.compress.ext(); err != nil {
+		return err
+	}
+	if c, ok, err := readContract(); err != nil {
+		return err
+	} else if ok {
+		if err := c.check(currentContract(opts.compress)); err != nil {
+			return err
+		}
+	}
+	pos, err := readState(stateFile, total)
+	if err != nil {
+		return err
+	}
+	first, last := runSlice()
+	// A state past -stop-at has the whole run done, its last chunk written
+	// on to the state's position by the run that went further.
+	written := max(pos, last)
+	pos = min(pos, last)
+	if pos <= first {
+		fmt.Printf("ℹ️  %s is missing or at the start: no chunk is complete yet\n", stateFile)
+		return nil
+	}
+	sums, err := readManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	var checked, problems int
+	// The chunks at the ends of a bounded run hold only its part of them.
+	for n := int(first/entriesPerFile) + 1; int64(n-1)*entriesPerFile < pos; n++ {
+		start, end := max(int64(n-1)*entriesPerFile, first), min(int64(n)*entriesPerFile, written)
+		name := chunkName(n)
+		err := verifyChunk(ctx, ks, name, start, end)
+		if err == nil {
+			if want, ok := sums[name]; ok {
+				if sum, serr := fileChecksum(name); serr != nil {
+					err = serr
+				} else if !sum.matches(want) {
+					err = errors.New("checksum does not match " + manifestFile)
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		checked++
+		if err != nil {
+			problems++
+			fmt.Printf("❌ %s: %v\n", name, err)
+		}
+	}
+	if problems > 0 {
+		return fmt.Errorf("verify: %d of %d chunks are missing or wrong (regenerate them with -rollback or recover)", problems, checked)
+	}
+	fmt.Printf("✅ %s chunks hold exactly the words %s–%s\n", fmtInt(int64(checked)), fmtInt(first), fmtInt(pos-1))
+	return nil
+}
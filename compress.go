@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressBlockSize is how much raw output goes into one compressed frame.
+// Frames are compressed independently, so they can be made in parallel, and
+// concatenated they still form one valid .gz or .zst stream.
+const compressBlockSize = 4 << 20
+
+// compression is the -compress setting.
+type compression struct {
+	codec   string // none, gzip or zstd
+	level   int    // codec level, 0 for its default
+	workers int    // compressing goroutines, 0 for one per CPU
+}
+
+// chunkExt is appended to every chunk name; it names the codec in use.
+var chunkExt = ""
+
+// ext returns the file extension of c's codec.
+func (c compression) ext() (string, error) {
+	switch c.codec {
+	case "", "none":
+		return "", nil
+	case "gzip":
+		return ".gz", nil
+	case "zstd":
+		return ".zst", nil
+	}
+	return "", fmt.Errorf("%w: unknown -compress codec %q (want none, gzip or zstd)", ErrConfig, c.codec)
+}
+
+// blockEncoder appends the compressed form of src to dst. One encoder is
+// used by one goroutine only.
+type blockEncoder func(dst, src []byte) ([]byte, error)
+
+func (c compression) encoder() (blockEncoder, error) {
+	switch c.codec {
+	case "gzip":
+		level := c.level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		var buf bytes.Buffer
+		zw, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, fmt.Errorf("%w: -compress-level: %w", ErrConfig, err)
+		}
+		return func(dst, src []byte) ([]byte, error) {
+			buf.Reset()
+			zw.Reset(&buf)
+			zw.Write(src)
+			if err := zw.Close(); err != nil {
+				return dst, err
+			}
+			return append(dst, buf.Bytes()...), nil
+		}, nil
+	case "zstd":
+		// The fastest levels find too few matches in sorted short words
+		// to beat storing them raw.
+		level := zstd.SpeedBetterCompression
+		if c.level != 0 {
+			level = zstd.EncoderLevelFromZstd(c.level)
+		}
+		zw, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(level), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return func(dst, src []byte) ([]byte, error) {
+			return zw.EncodeAll(src, dst), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: no encoder for %q", ErrConfig, c.codec)
+}
+
+// openChunk opens a chunk file for reading its words, decompressing it
+// according to its extension (ignoring a .part suffix).
+func openChunk(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := decompress(name, f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return readCloser{r, closerFunc(func() error { r.Close(); return f.Close() })}, nil
+}
+
+// decompress reads the file called name from r, decompressing it according
+// to its extension (ignoring a .part suffix). Closing the result releases the
+// decoder but not r.
+func decompress(name string, r io.Reader) (io.ReadCloser, error) {
+	switch name = strings.TrimSuffix(name, partSuffix); {
+	case strings.HasSuffix(name, ".gz"):
+		return gzip.NewReader(r)
+	case strings.HasSuffix(name, ".zst"):
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return readCloser{zr, closerFunc(func() error { zr.Close(); return nil })}, nil
+	}
+	return io.NopCloser(r), nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+// compressPool is the set of goroutines compressing output blocks. It runs
+// apart from generation: the generating goroutine only fills raw blocks and
+// hands them over, so a slow, high-ratio level costs CPU on other cores
+// rather than generation speed, until every worker is busy.
+type compressPool struct {
+	jobs    chan *block
+	workers int
+	free    sync.Pool
+}
+
+// block is one frame's worth of raw output and its compressed form.
+type block struct {
+	raw, out []byte
+	err      error
+	done     chan struct{}
+}
+
+// newCompressPool starts c's workers, or returns nil when c compresses
+// nothing.
+func newCompressPool(c compression) (*compressPool, error) {
+	if c.codec == "" || c.codec == "none" {
+		return nil, nil
+	}
+	workers := c.workers
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	p := &compressPool{jobs: make(chan *block), workers: workers}
+	for range workers {
+		enc, err := c.encoder()
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			for b := range p.jobs {
+				b.out, b.err = enc(b.out[:0], b.raw)
+				close(b.done)
+			}
+		}()
+	}
+	return p, nil
+}
+
+func (p *compressPool) get() *block {
+	b, _ := p.free.Get().(*block)
+	if b == nil {
+		b = &block{raw: make([]byte, 0, compressBlockSize)}
+	}
+	b.raw, b.err, b.done = b.raw[:0], nil, make(chan struct{})
+	return b
+}
+
+// writer returns a writer compressing into w. Blocks are compressed in
+// parallel and written to w in order; at most two per worker are in flight,
+// after which Write waits.
+func (p *compressPool) writer(w io.Writer) *blockWriter {
+	bw := &blockWriter{
+		pool:   p,
+		queue:  make(chan *block, 2*p.workers),
+		result: make(chan error, 1),
+		failed: make(chan struct{}),
+	}
+	go bw.drain(w)
+	return bw
+}
+
+// blockWriter is the io.WriteCloser returned by compressPool.writer. Close
+// must be called to write the last block and learn about write errors.
+type blockWriter struct {
+	pool   *compressPool
+	cur    *block
+	queue  chan *block // submitted blocks in output order
+	result chan error  // drain's final error
+	failed chan struct{}
+	err    error // set before failed is closed
+	closed bool
+}
+
+func (bw *blockWriter) Write(p []byte) (int, error) {
+	select {
+	case <-bw.failed:
+		return 0, bw.err
+	default:
+	}
+	n := 0
+	for len(p) > 0 {
+		if bw.cur == nil {
+			bw.cur = bw.pool.get()
+		}
+		k := min(len(p), compressBlockSize-len(bw.cur.raw))
+		bw.cur.raw = append(bw.cur.raw, p[:k]...)
+		p, n = p[k:], n+k
+		if len(bw.cur.raw) == compressBlockSize {
+			bw.submit()
+		}
+	}
+	return n, nil
+}
+
+func (bw *blockWriter) submit() {
+	b := bw.cur
+	bw.cur = nil
+	bw.queue <- b // waits while the queue is full
+	bw.pool.jobs <- b
+}
+
+// drain writes the compressed blocks to w in order. After a write error it
+// keeps taking blocks, without writing them, so submit never blocks forever.
+func (bw *blockWriter) drain(w io.Writer) {
+	var err error
+	for b := range bw.queue {
+		<-b.done
+		if err == nil {
+			err = b.err
+			if err == nil {
+				_, err = w.Write(b.out)
+			}
+			if err != nil {
+				bw.err = err
+				close(bw.failed)
+			}
+		}
+		bw.pool.free.Put(b)
+	}
+	bw.result <- err
+}
+
+// Close compresses and writes what is left and waits for every block to
+// reach the underlying writer. Calling it again does nothing.
+func (bw *blockWriter) Close() error {
+	if bw.closed {
+		return nil
+	}
+	bw.closed = true
+	if bw.cur != nil && len(bw.cur.raw) > 0 {
+		bw.submit()
+	}
+	close(bw.queue)
+	return <-bw.result
+}
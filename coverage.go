@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// A campaign of days over several machines is easier to follow as a map
+// than as positions: coverage draws the keyspace as a grid, a row per word
+// length and a column per first symbol, each cell showing how much of its
+// words are published (their chunks are in the manifest), generated but
+// not yet published, or still to come. With -nodes it reads the state file
+// of every node it finds in the directory, so the state files gathered from
+// the machines, beside the merged manifest, map the whole campaign.
+
+// coveragePixels is the side of a cell of the PNG map.
+const coveragePixels = 12
+
+// The colours of the PNG map; a cell mixes them by its share of each.
+var (
+	publishedColor = color.RGBA{46, 160, 67, 255}
+	generatedColor = color.RGBA{56, 139, 253, 255}
+	remainingColor = color.RGBA{221, 221, 221, 255}
+)
+
+// span is the positions [start, end).
+type span struct{ start, end int64 }
+
+// coverageCell is how many of a cell's words are published, generated but
+// not published, and there are.
+type coverageCell struct {
+	published, generated, words int64
+}
+
+// mark is the character of the text map for the cell.
+func (c coverageCell) mark() string {
+	switch {
+	case c.published == c.words:
+		return "█"
+	case c.published+c.generated == c.words:
+		return "▓"
+	case c.published+c.generated > 0:
+		return "▒"
+	}
+	return "·"
+}
+
+// coverageCmd prints the coverage map and, given a file name, also draws it
+// as a PNG.
+func coverageCmd(_ context.Context, _ *options, args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("%w: coverage takes at most one PNG file to draw the map in", ErrConfig)
+	}
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	if ks.Hybrid() {
+		return fmt.Errorf("%w: coverage maps charset and mask keyspaces, whose words start with a symbol", ErrConfig)
+	}
+	if ks.Shuffled() {
+		return fmt.Errorf("%w: coverage maps keyspace order; a shuffled run spreads every stretch of positions over the whole map", ErrConfig)
+	}
+	generated, err := generatedSpans()
+	if err != nil {
+		return err
+	}
+	published, err := publishedSpans()
+	if err != nil {
+		return err
+	}
+	generated = mergeSpans(append(generated, published...))
+
+	firsts := ks.FirstSymbols()
+	var rows [][]coverageCell
+	for l := ks.MinLen(); l <= ks.MaxLen(); l++ {
+		start, end := ks.LengthRange(l)
+		share := (end - start) / int64(len(firsts))
+		row := make([]coverageCell, len(firsts))
+		for d := range row {
+			a := start + int64(d)*share
+			pub := overlap(published, a, a+share)
+			row[d] = coverageCell{published: pub, generated: overlap(generated, a, a+share) - pub, words: share}
+		}
+		rows = append(rows, row)
+	}
+
+	fmt.Printf("Keyspace  : %s\n", describeKeyspace())
+	fmt.Println("Map       : █ published  ▓ generated  ▒ partly generated  · remaining")
+	fmt.Println()
+	labels := make([]string, len(firsts))
+	for d, s := range firsts {
+		r, _ := utf8.DecodeRuneInString(s)
+		labels[d] = string(r)
+	}
+	fmt.Printf("%6s  %s\n", "length", strings.Join(labels, ""))
+	var all coverageCell
+	for i, row := range rows {
+		var marks strings.Builder
+		var sum coverageCell
+		for _, c := range row {
+			marks.WriteString(c.mark())
+			sum.published += c.published
+			sum.generated += c.generated
+			sum.words += c.words
+		}
+		fmt.Printf("%6d  %s  %s%% generated, %s%% published\n", ks.MinLen()+i, marks.String(),
+			fmtFloat(percentOf(sum.published+sum.generated, sum.words), 1), fmtFloat(percentOf(sum.published, sum.words), 1))
+		all.published += sum.published
+		all.generated += sum.generated
+		all.words += sum.words
+	}
+	fmt.Println()
+	fmt.Printf("Generated : %s of %s words (%s%%)\n", fmtInt(all.published+all.generated), fmtInt(all.words), fmtFloat(percentOf(all.published+all.generated, all.words), 4))
+	fmt.Printf("Published : %s words (%s%%)\n", fmtInt(all.published), fmtFloat(percentOf(all.published, all.words), 4))
+	if len(args) == 0 {
+		return nil
+	}
+	if err := drawCoverage(args[0], rows); err != nil {
+		return err
+	}
+	fmt.Printf("Map drawn in %s, a row per length from %d, a column per first symbol in keyspace order\n", args[0], ks.MinLen())
+	return nil
+}
+
+// generatedSpans returns the positions the state files record as
+// generated: the run's, or with -nodes those of every node's that is here.
+func generatedSpans() ([]span, error) {
+	if nodes == 0 {
+		start, _ := runSlice()
+		pos, err := readState(stateFile, total)
+		return []span{{start, max(pos, start)}}, err
+	}
+	var spans []span
+	for n := 1; n <= nodes; n++ {
+		start, _, _, _ := nodeSlice(n)
+		start = max(start, windowStart)
+		pos, err := readState(nodeStateFile(n), total)
+		if err != nil {
+			return nil, err
+		}
+		if pos > start {
+			spans = append(spans, span{start, pos})
+		}
+	}
+	return mergeSpans(spans), nil
+}
+
+// publishedSpans returns the positions of the chunks the manifest lists.
+func publishedSpans() ([]span, error) {
+	sums, err := readManifest(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	var spans []span
+	for name := range sums {
+		n, ok := chunkLayout.Number(name)
+		if !ok {
+			continue // not a chunk
+		}
+		start, end := max((n-1)*entriesPerFile, windowStart), min(n*entriesPerFile, windowEnd)
+		if start < end {
+			spans = append(spans, span{start, end})
+		}
+	}
+	return mergeSpans(spans), nil
+}
+
+// mergeSpans sorts spans and joins those that overlap or touch.
+func mergeSpans(spans []span) []span {
+	slices.SortFunc(spans, func(a, b span) int { return cmp.Compare(a.start, b.start) })
+	var merged []span
+	for _, s := range spans {
+		if k := len(merged) - 1; k >= 0 && s.start <= merged[k].end {
+			merged[k].end = max(merged[k].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// overlap counts the positions of [start, end) that the sorted spans hold.
+func overlap(spans []span, start, end int64) int64 {
+	var n int64
+	for _, s := range spans {
+		if s.start >= end {
+			break
+		}
+		n += max(min(s.end, end)-max(s.start, start), 0)
+	}
+	return n
+}
+
+// drawCoverage writes the map to path as a PNG, a square of colour per
+// cell.
+func drawCoverage(path string, rows [][]coverageCell) error {
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0])*coveragePixels, len(rows)*coveragePixels))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for y, row := range rows {
+		for x, c := range row {
+			cell := image.Rect(x*coveragePixels, y*coveragePixels, (x+1)*coveragePixels-1, (y+1)*coveragePixels-1)
+			draw.Draw(img, cell, image.NewUniform(c.color()), image.Point{}, draw.Src)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	return diskError("write "+path, writeFileAtomic(path, buf.Bytes()))
+}
+
+// color mixes the map's colours by the cell's share of each.
+func (c coverageCell) color() color.RGBA {
+	pub, gen := float64(c.published)/float64(c.words), float64(c.generated)/float64(c.words)
+	mix := func(p, g, r uint8) uint8 {
+		return uint8(pub*float64(p) + gen*float64(g) + (1-pub-gen)*float64(r) + 0.5)
+	}
+	return color.RGBA{
+		mix(publishedColor.R, generatedColor.R, remainingColor.R),
+		mix(publishedColor.G, generatedColor.G, remainingColor.G),
+		mix(publishedColor.B, generatedColor.B, remainingColor.B),
+		255,
+	}
+}
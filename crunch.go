@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// crunch is -t and -l, crunch's template options: a template like pass@@%%
+// is a mask in crunch's spelling, @ , % and ^ standing for a lowercase
+// letter, an uppercase one, a digit and a symbol, and every other character
+// for itself. -l, as long as the template, takes the placeholder literally
+// wherever it has one of the four. newKeyspace turns the template into the
+// -mask it spells, so the run is a mask run and resumes from either.
+var crunch struct {
+	template, literal string
+}
+
+// crunchClasses are the mask classes of crunch's placeholders.
+var crunchClasses = map[rune]string{'@': "?l", ',': "?u", '%': "?d", '^': "?s"}
+
+// foldTemplate turns -t and -l into -mask.
+func foldTemplate() error {
+	switch {
+	case crunch.template == "" && crunch.literal != "":
+		return fmt.Errorf("%w: -l goes with -t", ErrConfig)
+	case crunch.template == "":
+		return nil
+	case keyspaceMask != "":
+		return fmt.Errorf("%w: -t and -mask both give the keyspace", ErrConfig)
+	case charset != defaultCharset || charsetFile != "":
+		return fmt.Errorf("%w: -t gives every position its own characters; drop -charset and -charset-file", ErrConfig)
+	}
+	mask, err := crunchMask(crunch.template, crunch.literal)
+	if err != nil {
+		return err
+	}
+	keyspaceMask, crunch.template, crunch.literal = mask, "", ""
+	return nil
+}
+
+// crunchMask spells template as a mask: "pass@@%%" is "pass?l?l?d?d".
+func crunchMask(template, literal string) (string, error) {
+	literals := []rune(literal)
+	if literal != "" && len(literals) != utf8.RuneCountInString(template) {
+		return "", fmt.Errorf("%w: -l %q has %d characters, -t %q has %d; crunch wants them the same length",
+			ErrConfig, literal, len(literals), template, utf8.RuneCountInString(template))
+	}
+	var b strings.Builder
+	i := 0
+	for _, r := range template {
+		class, placeholder := crunchClasses[r]
+		if placeholder && (literal == "" || crunchClasses[literals[i]] == "") {
+			b.WriteString(class)
+		} else if r == '?' {
+			b.WriteString("??")
+		} else {
+			b.WriteRune(r)
+		}
+		i++
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"main.go/wordlist"
+)
+
+// -dashboard keeps a page on the local disk for the one running the
+// generation: a single HTML file, its charts drawn as inline SVG, that any
+// browser opens straight from the disk and that reloads itself. It shows the
+// throughput of this run over time, how far each word length has got and
+// the publishes of PUBLISHED.jsonl. Nothing is sent anywhere, and nothing
+// but the file is written.
+
+// dashboardSamples caps the throughput history; past it, every other
+// sample is dropped, so a long run keeps its whole span at a coarser grain.
+const dashboardSamples = 720
+
+// Chart dimensions, in SVG user units.
+const (
+	chartWidth  = 640
+	chartHeight = 160
+)
+
+// throughputSample is the speed over the interval ending at time.
+type throughputSample struct {
+	time         time.Time
+	words, bytes float64 // per second
+}
+
+// dashboardSink rewrites the dashboard after every file and publish, and
+// every interval in between.
+type dashboardSink struct {
+	path       string
+	interval   time.Duration
+	ks         *wordlist.Keyspace
+	keyspace   string
+	start, end int64 // the run's slice
+
+	state     string
+	started   time.Time
+	pos       int64
+	files     int
+	samples   []throughputSample
+	since     time.Time // start of the interval being counted
+	words     int64     // written since since
+	bytes     int64
+	written   time.Time // when the page was last written
+	published []dashboardPublish
+}
+
+func newDashboardSink(path string, interval time.Duration, ks *wordlist.Keyspace, start, end int64) *dashboardSink {
+	return &dashboardSink{path: path, interval: interval, ks: ks, keyspace: describeKeyspace(), start: start, end: end}
+}
+
+func (d *dashboardSink) handle(e event) {
+	switch e.kind {
+	case evStart:
+		d.state, d.started, d.since, d.pos, d.files = "running", e.time, e.time, e.pos, e.files
+		d.published = readPublished()
+	case evTick:
+		d.words += e.n
+		d.bytes += e.bytes
+		if e.time.Sub(d.written) < d.interval {
+			return
+		}
+	case evFile:
+		d.pos, d.files = e.pos, e.files
+	case evPublish:
+		d.published = readPublished()
+	case evError:
+		switch e.action {
+		case "interrupted":
+			d.state = "interrupted"
+		case "fatal":
+			d.state = "failed"
+		default:
+			return
+		}
+	case evDone:
+		d.state, d.pos, d.files = "complete", e.pos, e.files
+	default:
+		return
+	}
+	d.sample(e.time)
+	if err := d.write(e.time); err != nil {
+		slog.Warn("could not update the dashboard", "file", d.path, "err", err)
+	}
+}
+
+// sample closes the interval being counted at now, once it is long enough
+// to say something.
+func (d *dashboardSink) sample(now time.Time) {
+	secs := now.Sub(d.since).Seconds()
+	if secs < 1 {
+		return
+	}
+	d.samples = append(d.samples, throughputSample{now, float64(d.words) / secs, float64(d.bytes) / secs})
+	if len(d.samples) > dashboardSamples {
+		for i := range len(d.samples) / 2 {
+			d.samples[i] = d.samples[2*i+1]
+		}
+		d.samples = d.samples[:len(d.samples)/2]
+	}
+	d.since, d.words, d.bytes = now, 0, 0
+}
+
+// readPublished reads the publishes of PUBLISHED.jsonl; the dashboard does
+// without them when it cannot.
+func readPublished() []dashboardPublish {
+	f, err := os.Open(publishedLog)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Warn("the dashboard cannot read the published log", "file", publishedLog, "err", err)
+		}
+		return nil
+	}
+	defer f.Close()
+	var published []dashboardPublish
+	scan := bufio.NewScanner(f)
+	scan.Buffer(nil, 64<<20) // a record lists every file of its publish
+	for scan.Scan() {
+		var rec publishRecord
+		if json.Unmarshal(scan.Bytes(), &rec) == nil {
+			published = append(published, dashboardPublish{rec.Time, len(rec.Files), rec.End - rec.Start, rec.Position})
+		}
+	}
+	return published
+}
+
+// dashboardLength is a word length's row of the page.
+type dashboardLength struct {
+	Length       int
+	Words        int64
+	Percent      float64 // generated
+	BytesPending int64
+}
+
+// dashboardPublish is a publish, as the page shows it.
+type dashboardPublish struct {
+	Time     time.Time
+	Files    int
+	Words    int64
+	Position int64
+}
+
+// dashboardPage is what the template shows.
+type dashboardPage struct {
+	State, Keyspace     string
+	Position, Total     int64
+	Percent             float64
+	Files               int
+	Started, Updated    time.Time
+	Lengths             []dashboardLength
+	Speed, Peak         float64 // words per second: the last sample and the highest
+	Throughput          string  // SVG polyline points
+	PublishChart        string
+	Publishes           []dashboardPublish
+	Width, Height       int
+	FirstTime, LastTime time.Time
+	FirstPub, LastPub   time.Time
+	Refresh             int
+}
+
+// write renders the page at now and replaces the file with it.
+func (d *dashboardSink) write(now time.Time) error {
+	d.written = now
+	p := dashboardPage{
+		State: d.state, Keyspace: d.keyspace,
+		Position: d.pos, Total: total, Percent: percentOf(d.pos-d.start, d.end-d.start), Files: d.files,
+		Started: d.started, Updated: now,
+		Width: chartWidth, Height: chartHeight,
+		Refresh: max(int(d.interval.Seconds()), 5),
+	}
+	if !d.ks.Hybrid() && !d.ks.Shuffled() {
+		for l := d.ks.MinLen(); l <= d.ks.MaxLen(); l++ {
+			start, end := d.ks.LengthRange(l)
+			done := min(max(d.pos-start, 0), end-start)
+			p.Lengths = append(p.Lengths, dashboardLength{l, end - start, percentOf(done, end-start), d.ks.Bytes(start+done, end)})
+		}
+	}
+	if n := len(d.samples); n > 0 {
+		p.Speed = d.samples[n-1].words
+		p.FirstTime, p.LastTime = d.samples[0].time, d.samples[n-1].time
+		times, speeds := make([]time.Time, n), make([]float64, n)
+		for i, s := range d.samples {
+			times[i], speeds[i] = s.time, s.words
+			p.Peak = max(p.Peak, s.words)
+		}
+		if n > 1 {
+			p.Throughput = chartPath(times, speeds, p.Peak)
+		}
+	}
+	if n := len(d.published); n > 0 {
+		p.FirstPub, p.LastPub = d.published[0].Time, d.published[n-1].Time
+		times, positions := make([]time.Time, n), make([]float64, n)
+		for i, pub := range d.published {
+			times[i], positions[i] = pub.Time, float64(pub.Position)
+		}
+		if n > 1 {
+			p.PublishChart = chartPath(times, positions, float64(total))
+		}
+		for i := n - 1; i >= max(n-10, 0); i-- {
+			p.Publishes = append(p.Publishes, d.published[i]) // the last ten, newest first
+		}
+	}
+	var page bytes.Buffer
+	if err := dashboardTemplate.Execute(&page, p); err != nil {
+		return err
+	}
+	return diskError("write "+d.path, writeFileAtomic(d.path, page.Bytes()))
+}
+
+// chartPath is the SVG polyline points of values over times, scaled so the
+// times span the chart's width and top its height.
+func chartPath(times []time.Time, values []float64, top float64) string {
+	span := times[len(times)-1].Sub(times[0]).Seconds()
+	var b strings.Builder
+	for i, v := range values {
+		x := 0.0
+		if span > 0 {
+			x = times[i].Sub(times[0]).Seconds() / span * chartWidth
+		}
+		y := float64(chartHeight)
+		if top > 0 {
+			y -= v / top * chartHeight
+		}
+		fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"int":   fmtInt,
+	"pct":   func(p float64) string { return fmtFloat(p, 2) },
+	"rate":  func(r float64) string { return fmtFloat(r, 0) },
+	"bytes": fmtBytes,
+	"stamp": func(t time.Time) string { return t.Local().Format("2006-01-02 15:04:05") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="{{.Refresh}}">
+<title>{{pct .Percent}}% · wordlist dashboard</title>
+<style>
+body { font: 15px/1.5 system-ui, sans-serif; max-width: 44em; margin: 2em auto; padding: 0 1em; color: #222; }
+h2 { font-size: 1.1em; margin-top: 2em; }
+.bar { background: #ddd; border-radius: 3px; height: 1em; min-width: 8em; }
+.bar div { background: #2a7; border-radius: 3px; height: 100%; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 0.1em 0.8em 0.1em 0; }
+th { font-weight: normal; color: #555; }
+td.n { text-align: right; font-variant-numeric: tabular-nums; }
+svg { width: 100%; height: auto; background: #f6f6f6; border-radius: 3px; }
+svg polyline { fill: none; stroke-width: 2; }
+.axis { display: flex; justify-content: space-between; color: #777; font-size: 0.85em; }
+</style>
+</head>
+<body>
+<h1>Wordlist generation: {{.State}}</h1>
+<div class="bar"><div style="width: {{printf "%.2f" .Percent}}%"></div></div>
+<p>{{int .Position}} of {{int .Total}} words ({{pct .Percent}}%), {{.Files}} files. {{.Keyspace}}.<br>
+This run started {{stamp .Started}}; updated {{stamp .Updated}}.</p>
+
+<h2>Throughput</h2>
+{{- if .Speed}}
+<p>{{rate .Speed}} words/s now, {{rate .Peak}} at most.</p>
+{{- end}}
+{{- if .Throughput}}
+<svg viewBox="0 0 {{.Width}} {{.Height}}" preserveAspectRatio="none"><polyline stroke="#38b" points="{{.Throughput}}"/></svg>
+<div class="axis"><span>{{stamp .FirstTime}}</span><span>{{stamp .LastTime}}</span></div>
+{{- else if not .Speed}}
+<p>No measurement yet.</p>
+{{- end}}
+
+{{- if .Lengths}}
+<h2>By length</h2>
+<table>
+<tr><th>Length</th><th>Words</th><th>Generated</th><th></th><th>Still to write</th></tr>
+{{- range .Lengths}}
+<tr><td class="n">{{.Length}}</td><td class="n">{{int .Words}}</td><td class="n">{{pct .Percent}}%</td><td><div class="bar"><div style="width: {{printf "%.2f" .Percent}}%"></div></div></td><td class="n">{{bytes .BytesPending}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+
+<h2>Publishes</h2>
+{{- if .PublishChart}}
+<p>The position each publish reached, of the whole keyspace.</p>
+<svg viewBox="0 0 {{.Width}} {{.Height}}" preserveAspectRatio="none"><polyline stroke="#2a7" points="{{.PublishChart}}"/></svg>
+<div class="axis"><span>{{stamp .FirstPub}}</span><span>{{stamp .LastPub}}</span></div>
+{{- end}}
+{{- if .Publishes}}
+<table>
+<tr><th>Published</th><th>Files</th><th>Words</th><th>Position</th></tr>
+{{- range .Publishes}}
+<tr><td>{{stamp .Time}}</td><td class="n">{{.Files}}</td><td class="n">{{int .Words}}</td><td class="n">{{int .Position}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>Nothing published yet.</p>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// dedupKey maps a word to what identifies it for dedup, appending to dst.
+type dedupKey func(dst, word []byte) []byte
+
+// newDedupKey returns the key for the equivalences asked for: NFC makes
+// composed and decomposed accents equal, folding makes case variants equal
+// (with full Unicode folding, so "STRASSE" and "straße" are one word).
+func newDedupKey(nfc, fold bool) dedupKey {
+	folder := cases.Fold()
+	return func(dst, word []byte) []byte {
+		if nfc || fold {
+			word = norm.NFC.Append(dst[:0], word...)
+		}
+		if fold {
+			// Folding can decompose, so normalize again after it.
+			return norm.NFC.Append(word[:0], folder.Bytes(word)...)
+		}
+		return append(dst[:0], word...)
+	}
+}
+
+// dedupSet remembers word hashes: all of them, or with a window only the
+// last ones added, forgetting the oldest as each new one comes in. Lists
+// concatenated from sorted or clustered sources repeat words close to each
+// other, so a window of a few million catches most repeats in fixed memory.
+type dedupSet struct {
+	seen map[uint64]struct{}
+	ring []uint64 // the window, oldest at next once it is full
+	next int
+}
+
+func newDedupSet(window int) *dedupSet {
+	d := &dedupSet{seen: make(map[uint64]struct{})}
+	if window > 0 {
+		d.ring = make([]uint64, 0, window)
+	}
+	return d
+}
+
+// add reports whether h is new, remembering it if so.
+func (d *dedupSet) add(h uint64) bool {
+	if _, dup := d.seen[h]; dup {
+		return false
+	}
+	d.seen[h] = struct{}{}
+	switch {
+	case cap(d.ring) == 0:
+	case len(d.ring) < cap(d.ring):
+		d.ring = append(d.ring, h)
+	default:
+		delete(d.seen, d.ring[d.next])
+		d.ring[d.next] = h
+		d.next = (d.next + 1) % len(d.ring)
+	}
+	return true
+}
+
+func dedupCmd(args []string) error {
+	fs := newToolFlags("dedup", "list...")
+	nfc := fs.Bool("nfc", false, "treat NFC-equivalent words as duplicates and write every word in NFC")
+	fold := fs.Bool("fold", false, "treat words equal after Unicode case folding as duplicates (implies -nfc); the first spelling seen is kept")
+	dedupMode := fs.String("dedup", "exact", "`mode`: exact, remembering every unique word's hash, or bloom:SIZE (bloom:4GB), in fixed memory and dropping a few unique words as repeats")
+	window := fs.Int("dedup-window", 0, "only drop a word repeating one of the last `N` words kept, in memory bounded by N instead of by the unique words")
+	out := fs.String("o", "", "write the unique words to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: dedup needs at least one list (- for stdin)", ErrConfig)
+	}
+	if *window < 0 {
+		return fmt.Errorf("%w: -dedup-window must not be negative", ErrConfig)
+	}
+	bloom, err := parseDedupMode(*dedupMode)
+	if err != nil {
+		return err
+	}
+	if bloom != nil && *window > 0 {
+		return fmt.Errorf("%w: -dedup bloom and -dedup-window are exclusive", ErrConfig)
+	}
+	*nfc = *nfc || *fold
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	// Words are remembered by a 64-bit hash of their key: a tenth of the
+	// memory of the words themselves, and even a billion distinct words
+	// collide with a chance of about 3%, costing one word.
+	var seen interface{ add(h uint64) bool } = newDedupSet(*window)
+	if bloom != nil {
+		seen = bloom
+	}
+	key := newDedupKey(*nfc, *fold)
+	var kbuf, wbuf []byte
+	var unique int64
+	st, err := forEachLine(fs.Args(), "deduplicating", func(word []byte) error {
+		kbuf = key(kbuf, word)
+		if !seen.add(xxhash.Sum64(kbuf)) {
+			return nil
+		}
+		unique++
+		if *nfc {
+			wbuf = norm.NFC.Append(wbuf[:0], word...)
+			word = wbuf
+		}
+		return w.writeLine(word)
+	})
+	if cerr := w.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	mode := "exact"
+	switch {
+	case *fold:
+		mode = "case-folded NFC"
+	case *nfc:
+		mode = "NFC"
+	}
+	if *window > 0 {
+		mode += fmt.Sprintf(", window of %s", fmtInt(int64(*window)))
+	}
+	if bloom != nil {
+		filter := "Bloom filter of " + fmtBytes(int64(bloom.m/8))
+		if mode == "exact" {
+			mode = filter
+		} else {
+			mode += ", " + filter
+		}
+	}
+	fmt.Fprintf(os.Stderr, "🧹 Deduplicated %s (%s): %s unique of %s words, %s duplicates (%s%%)\n",
+		describeLists(fs.Args()), mode, fmtInt(unique), fmtInt(st.lines), fmtInt(st.lines-unique),
+		fmtFloat(percentOf(st.lines-unique, st.lines), 2))
+	if bloom != nil {
+		p := bloom.falsePositive()
+		rate := fmtFloat(100*p, 4) + "%"
+		if p < 1e-6 {
+			rate = "under 0.0001%"
+		}
+		fmt.Fprintf(os.Stderr, "   false-positive rate now %s: expect at most %s unique words dropped as repeats\n",
+			rate, fmtInt(int64(math.Ceil(p*float64(unique)))))
+	}
+	if st.tooLong > 0 {
+		fmt.Fprintf(os.Stderr, "   %s lines over %s skipped\n", fmtInt(st.tooLong), fmtBytes(maxListLine))
+	}
+	fmt.Fprintf(os.Stderr, "   Read %s in %s\n", fmtBytes(st.bytes), fmtDuration(st.duration))
+	return nil
+}
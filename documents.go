@@ -0,0 +1,256 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxDocumentText bounds what is read of one document, or of one part or
+// stream of it, so a huge spreadsheet or a zip bomb cannot exhaust memory.
+const maxDocumentText = 64 << 20
+
+// textParts are the zip members holding the text of OOXML and ODF
+// documents, with the local name of the elements the text is in.
+var textParts = []struct {
+	pattern *regexp.Regexp
+	element string
+}{
+	{regexp.MustCompile(`^word/(document|header\d*|footer\d*|footnotes|comments)\.xml$`), "t"},
+	{regexp.MustCompile(`^xl/(sharedStrings|worksheets/sheet\d+)\.xml$`), "t"},
+	{regexp.MustCompile(`^ppt/(slides/slide|notesSlides/notesSlide)\d+\.xml$`), "t"},
+	{regexp.MustCompile(`^content\.xml$`), ""}, // ODF: all text
+}
+
+// documentText returns the body text of the PDF, OOXML or ODF document at
+// path, by its extension; other files have none.
+func documentText(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".docx", ".xlsx", ".pptx", ".docm", ".xlsm", ".pptm", ".odt", ".ods", ".odp":
+		return zipText(path)
+	case ".pdf":
+		data, err := readHead(path, maxDocumentText)
+		if err != nil {
+			return "", err
+		}
+		return pdfText(data), nil
+	}
+	return "", nil
+}
+
+func readHead(path string, n int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(io.LimitReader(f, n))
+}
+
+func zipText(path string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", err
+	}
+	defer zr.Close()
+	var b strings.Builder
+	for _, f := range zr.File {
+		for _, p := range textParts {
+			if !p.pattern.MatchString(f.Name) {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				return b.String(), err
+			}
+			err = xmlText(&b, io.LimitReader(rc, maxDocumentText), p.element)
+			rc.Close()
+			if err != nil {
+				return b.String(), err
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// paragraphElements end a line of text: paragraphs and headings, shared
+// strings and cells. Word processors split words across the runs inside a
+// paragraph, so runs do not end lines.
+var paragraphElements = map[string]bool{"p": true, "h": true, "si": true, "c": true, "tc": true}
+
+// xmlText writes the text of r to b, a line per paragraph, keeping only the
+// text of elements with the local name element unless it is "".
+func xmlText(b *strings.Builder, r io.Reader, element string) error {
+	dec := xml.NewDecoder(r)
+	inside := element == ""
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local == element {
+				inside = true
+			}
+		case xml.EndElement:
+			if t.Name.Local == element {
+				inside = false
+			}
+			if paragraphElements[t.Name.Local] {
+				b.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inside {
+				b.Write(t)
+			}
+		}
+	}
+}
+
+var (
+	pdfStream = regexp.MustCompile(`>>\s*stream\r?\n`)
+	pdfInfo   = regexp.MustCompile(`/(Title|Author|Subject|Keywords|Creator)\s*\(`)
+)
+
+// pdfText returns the strings shown by the content streams of a PDF: the
+// literal strings of its text objects, in uncompressed or Flate streams.
+// Fonts with their own encodings (most CID fonts) come out as noise that
+// the harvester's length limits mostly drop; this is a seed list, not a
+// faithful rendering.
+func pdfText(data []byte) string {
+	var b strings.Builder
+	for _, m := range pdfStream.FindAllIndex(data, -1) {
+		// The stream's dictionary, from the start of its object.
+		dict := data[max(m[0]-1024, 0):m[0]]
+		if i := bytes.LastIndex(dict, []byte("obj")); i >= 0 {
+			dict = dict[i:]
+		}
+		body := data[m[1]:]
+		end := bytes.Index(body, []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		body = body[:end]
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			zr, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				continue
+			}
+			// A truncated stream still gives what it decoded.
+			body, _ = io.ReadAll(io.LimitReader(zr, maxDocumentText))
+		case bytes.Contains(dict, []byte("/Filter")):
+			continue // images and other codecs
+		}
+		if bytes.Contains(body, []byte("BT")) && bytes.Contains(body, []byte("ET")) {
+			pdfStrings(&b, body)
+		}
+	}
+	return b.String()
+}
+
+// pdfMetadata returns the document information strings of a PDF that
+// stores them uncompressed, as most do.
+func pdfMetadata(data []byte) []string {
+	var values []string
+	for _, m := range pdfInfo.FindAllIndex(data, -1) {
+		if s, _ := pdfLiteral(data[m[1]:]); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values
+}
+
+// pdfStrings writes the literal strings of a content stream to b, a line
+// per text object.
+func pdfStrings(b *strings.Builder, content []byte) {
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '(':
+			s, n := pdfLiteral(content[i+1:])
+			b.WriteString(s)
+			i += n
+		case 'E':
+			if i+1 < len(content) && content[i+1] == 'T' {
+				b.WriteByte('\n')
+			}
+		}
+	}
+}
+
+// pdfLiteral decodes the literal string at the start of s, just after its
+// opening parenthesis, and returns it with the bytes it took.
+func pdfLiteral(s []byte) (string, int) {
+	var out []byte
+	depth := 1
+	i := 0
+	for ; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return decodePDFString(out), i + 1
+			}
+		case '\\':
+			if i++; i >= len(s) {
+				break
+			}
+			switch e := s[i]; e {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b', 'f':
+				c = ' '
+			case '\r', '\n':
+				continue // a line continuation
+			default:
+				c = e
+				if e >= '0' && e <= '7' {
+					v := 0
+					for j := 0; j < 3 && i < len(s) && s[i] >= '0' && s[i] <= '7'; j++ {
+						v = v*8 + int(s[i]-'0')
+						i++
+					}
+					i--
+					c = byte(v)
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return decodePDFString(out), i
+}
+
+// decodePDFString decodes a UTF-16BE string (with its byte order mark) or
+// one in PDFDocEncoding, taken as Latin-1.
+func decodePDFString(s []byte) string {
+	if bytes.HasPrefix(s, []byte{0xfe, 0xff}) {
+		u := make([]uint16, (len(s)-2)/2)
+		for j := range u {
+			u[j] = binary.BigEndian.Uint16(s[2+2*j:])
+		}
+		return string(utf16.Decode(u))
+	}
+	r := make([]rune, len(s))
+	for j, c := range s {
+		r[j] = rune(c)
+	}
+	return string(r)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"main.go/wordlist"
+)
+
+// ledger is what a run has covered of the keyspace: the positions before
+// the one in stateFile were generated, and the chunks in the manifests were
+// published.
+type ledger struct {
+	generated, published wordlist.Ranges
+	names                map[int]string // published chunk number → path
+}
+
+// loadLedger reads the coverage recorded in state and manifests; either may
+// be missing.
+func loadLedger(state string, manifests []string) (*ledger, error) {
+	l := &ledger{names: make(map[int]string)}
+	if state != "" {
+		next, err := readState(state, total)
+		if err != nil {
+			return nil, err
+		}
+		l.generated.Add(0, next)
+	}
+	for _, path := range manifests {
+		sums, err := readManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		for name := range sums {
+			num, ok := chunkLayout.Number(name)
+			if !ok {
+				continue // not a chunk
+			}
+			n := int(num)
+			start := int64(n-1) * entriesPerFile
+			if n < 1 || start >= total {
+				return nil, fmt.Errorf("%w: %s lists %s, beyond the keyspace's %s words", ErrPublishFailed, path, name, fmtInt(total))
+			}
+			l.published.Add(start, min(start+entriesPerFile, total))
+			l.names[n] = filepath.Join(filepath.Dir(path), filepath.FromSlash(name))
+		}
+	}
+	return l, nil
+}
+
+// status says whether index was published, only generated, or neither, and
+// where it is: the chunk file and line.
+func (l *ledger) status(index, line int64) (status, where string) {
+	n := int(index/entriesPerFile) + 1
+	switch {
+	case l.published.Contains(index):
+		return "published", fmt.Sprintf("%s:%d", l.names[n], line)
+	case l.generated.Contains(index):
+		return "generated", fmt.Sprintf("%s:%d", chunkName(n), line)
+	}
+	return "pending", fmt.Sprintf("%s:%d", chunkName(n), line)
+}
+
+func emittedCmd(args []string) error {
+	fs := newToolFlags("emitted", "[word...]")
+	perFile := fs.Int64("per-file", entriesPerFile, "words per chunk file the run was generated with")
+	state := fs.String("state", stateFile, "read the generated positions from this `file`; empty to ignore it")
+	manifests := fs.String("manifests", manifestFile, "comma-separated `manifests` listing the published chunks; empty for none")
+	addKeyspaceFlags(fs)
+	addLayoutFlags(fs)
+	addExclusionFlags(fs)
+	addOrderFlags(fs)
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	entriesPerFile = *perFile
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	var paths []string
+	if *manifests != "" {
+		paths = strings.Split(*manifests, ",")
+	}
+	l, err := loadLedger(*state, paths)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	counts := make(map[string]int64)
+	query := func(word string) error {
+		status, where, index := "outside", "-", "-"
+		if i, err := ks.IndexOf(word); err == nil {
+			status, where = l.status(i, ks.ChunkLine(i, entriesPerFile))
+			if ks.Excluded(i) {
+				status = "excluded"
+			}
+			index = strconv.FormatInt(i, 10)
+		}
+		counts[status]++
+		_, err := fmt.Fprintf(out, "%s\t%s\t%s\t%s\n", word, status, index, where)
+		return err
+	}
+	if fs.NArg() > 0 && !(fs.NArg() == 1 && fs.Arg(0) == "-") {
+		for _, word := range fs.Args() {
+			if err := query(word); err != nil {
+				return diskError("write output", err)
+			}
+		}
+	} else if _, err := forEachLine([]string{"-"}, "looking up", func(word []byte) error {
+		return query(string(word))
+	}); err != nil {
+		return err
+	}
+	if err := out.Flush(); err != nil {
+		return diskError("write output", err)
+	}
+	fmt.Fprintf(os.Stderr, "🔎 %s published, %s generated but unpublished, %s pending, %s excluded, %s outside the keyspace (of %s words, %s generated and %s published)\n",
+		fmtInt(counts["published"]), fmtInt(counts["generated"]), fmtInt(counts["pending"]), fmtInt(counts["excluded"]), fmtInt(counts["outside"]),
+		fmtInt(total), fmtInt(l.generated.Count()), fmtInt(l.published.Count()))
+	return nil
+}
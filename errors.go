@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+// Error classes. Every failure that reaches main wraps exactly one of these,
+// which decides the policy applied to it and how it is reported.
+var (
+	ErrConfig        = errors.New("invalid configuration")
+	ErrDiskFull      = errors.New("disk full")
+	ErrOutput        = errors.New("output write failed")
+	ErrStateCorrupt  = errors.New("state file is corrupt")
+	ErrPublishFailed = errors.New("publish failed")
+	ErrFetch         = errors.New("fetch failed") // reading a published run back, as mirror does
+	ErrNotFound      = errors.New("not found")    // a lookup's word is not in the keyspace
+)
+
+// ErrReaderGone is a stream sink's reader closing its end. It is not a
+// failure: main stops there as a completed run, since no policy can write to
+// a reader that left.
+var ErrReaderGone = errors.New("the reader closed the stream")
+
+// diskError wraps a filesystem error in ErrDiskFull or ErrOutput. op is
+// only mentioned when err does not already name the file it failed on.
+func diskError(op string, err error) error {
+	if err == nil || errors.Is(err, ErrReaderGone) {
+		return err
+	}
+	class := ErrOutput
+	if errors.Is(err, syscall.ENOSPC) || errors.Is(err, syscall.EDQUOT) {
+		class = ErrDiskFull
+	}
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return fmt.Errorf("%w: %w", class, err)
+	}
+	return fmt.Errorf("%w: %s: %w", class, op, err)
+}
+
+// Process exit codes, documented in README.md. Wrappers branch on these.
+const (
+	exitCompleted   = 0   // every file generated (and published)
+	exitFailure     = 1   // an error outside the classes below, or a word seek did not find
+	exitConfig      = 2   // bad flags or configuration; nothing was written
+	exitState       = 3   // state file unreadable or inconsistent
+	exitDisk        = 4   // output could not be written (disk full, I/O error)
+	exitPublish     = 5   // git commit/push failed under -on-publish-error=abort/retry
+	exitFetch       = 6   // a published run could not be fetched, or did not match its checksums
+	exitInterrupted = 130 // stopped by SIGINT/SIGTERM; run again to resume
+)
+
+// failure is the machine-readable summary printed as the last line on
+// stderr when a run does not complete.
+type failure struct {
+	ExitCode  int    `json:"exit_code"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
+	Resumable bool   `json:"resumable"`
+}
+
+// classify maps an error returned by run to its exit code and reason, and
+// says whether running again can continue the run: after an interrupt, or
+// once the state, the disk, the remote or the source is put right. Any other error
+// fails the same way again.
+func classify(err error) failure {
+	f := failure{ExitCode: exitFailure, Reason: "error", Message: err.Error(), Resumable: true}
+	switch {
+	case errors.Is(err, context.Canceled):
+		f.ExitCode, f.Reason = exitInterrupted, "interrupted"
+	case errors.Is(err, ErrStateCorrupt):
+		f.ExitCode, f.Reason = exitState, "state_corrupt"
+	case errors.Is(err, ErrDiskFull):
+		f.ExitCode, f.Reason = exitDisk, "disk_full"
+	case errors.Is(err, ErrOutput):
+		f.ExitCode, f.Reason = exitDisk, "output"
+	case errors.Is(err, ErrPublishFailed):
+		f.ExitCode, f.Reason = exitPublish, "publish"
+	case errors.Is(err, ErrFetch):
+		f.ExitCode, f.Reason = exitFetch, "fetch"
+	case errors.Is(err, ErrConfig):
+		f.ExitCode, f.Reason, f.Resumable = exitConfig, "config", false
+	case errors.Is(err, ErrNotFound):
+		f.Reason, f.Resumable = "not_found", false
+	default:
+		f.Resumable = false
+	}
+	return f
+}
+
+// exit logs err, prints it as one JSON object on the last line of stderr,
+// and exits with its code.
+func exit(err error) {
+	f := classify(err)
+	action := "fatal"
+	if f.ExitCode == exitInterrupted {
+		action = "interrupted"
+	}
+	events.emit(event{kind: evError, err: err, action: action})
+	json.NewEncoder(os.Stderr).Encode(f)
+	os.Exit(f.ExitCode)
+}
+
+// policy is what to do when an operation of one error class fails.
+type policy int
+
+const (
+	abort policy = iota // stop the run
+	retry               // try again after a delay, then abort
+	skip                // report it and carry on
+)
+
+var policyNames = []string{"abort", "retry", "skip"}
+
+func (p policy) String() string { return policyNames[p] }
+
+func (p *policy) Set(s string) error {
+	for i, name := range policyNames {
+		if s == name {
+			*p = policy(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown policy %q (want abort, retry or skip)", s)
+}
+
+// policies holds the per-class error policies chosen on the command line.
+type policies struct {
+	disk    policy
+	state   policy
+	publish policy
+	retries int
+	delay   time.Duration
+}
+
+// forError returns the policy for err's class.
+func (p *policies) forError(err error) policy {
+	switch {
+	case errors.Is(err, ErrDiskFull), errors.Is(err, ErrOutput):
+		return p.disk
+	case errors.Is(err, ErrStateCorrupt):
+		return p.state
+	case errors.Is(err, ErrPublishFailed):
+		return p.publish
+	}
+	return abort
+}
+
+// do runs op and applies the policy for the class of any error it returns.
+// A skipped error is reported and swallowed; retries wait delay*attempt
+// between attempts and give up with the last error.
+func (p *policies) do(ctx context.Context, op func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		switch p.forError(err) {
+		case skip:
+			events.emit(event{kind: evError, err: err, action: "skipped"})
+			return nil
+		case retry:
+			if attempt > p.retries {
+				return fmt.Errorf("%w (gave up after %d retries)", err, p.retries)
+			}
+			wait := p.delay * time.Duration(attempt)
+			events.emit(event{kind: evError, err: err, action: fmt.Sprintf("retry %d/%d in %v", attempt, p.retries, wait)})
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return err
+			}
+		default:
+			return err
+		}
+	}
+}
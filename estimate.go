@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"main.go/wordlist"
+)
+
+// z95 is the normal quantile of a two-sided 95% confidence interval.
+const z95 = 1.959964
+
+// indexedSpace is a set of words addressable by index: a keyspace, a mask or
+// a phrase.
+type indexedSpace interface {
+	Total() int64
+	AppendWord(dst []byte, index int64) ([]byte, error)
+}
+
+// wilson returns the 95% Wilson score interval of a proportion with k
+// successes in n trials; unlike the normal approximation it stays inside
+// [0, 1] and is honest when k is 0 or n.
+func wilson(k, n int64) (lo, hi float64) {
+	if n == 0 {
+		return 0, 1
+	}
+	p, nf := float64(k)/float64(n), float64(n)
+	z2 := z95 * z95
+	center := (p + z2/(2*nf)) / (1 + z2/nf)
+	half := z95 * math.Sqrt(p*(1-p)/nf+z2/(4*nf*nf)) / (1 + z2/nf)
+	return max(center-half, 0), min(center+half, 1)
+}
+
+// parseLengths parses "MIN-MAX" or a single length.
+func parseLengths(s string) (int, int, error) {
+	lo, hi, found := strings.Cut(s, "-")
+	if !found {
+		hi = lo
+	}
+	minLen, err1 := strconv.Atoi(lo)
+	maxLen, err2 := strconv.Atoi(hi)
+	if err1 != nil || err2 != nil || minLen < 1 || maxLen < minLen {
+		return 0, 0, fmt.Errorf("%w: lengths %q (want MIN-MAX, e.g. 1-%d)", ErrConfig, s, maxLength)
+	}
+	return minLen, maxLen, nil
+}
+
+func estimateCmd(args []string) error {
+	fs := newToolFlags("estimate", "")
+	symbols := fs.String("charset", charset, "`symbols` of the keyspace")
+	lengths := fs.String("lengths", fmt.Sprintf("1-%d", maxLength), "word lengths of the keyspace, `MIN-MAX`")
+	mask := fs.String("mask", "", "estimate this `mask` (?l ?u ?d ?s ?a) instead of the keyspace")
+	ff := addFilterFlags(fs)
+	samples := fs.Int64("samples", 100_000, "random words to test")
+	seed := fs.Uint64("seed", 1, "random `seed`, for repeatable estimates")
+	rate := fs.Float64("rate", 0, "expected generation speed in `words/s` for the ETA; 0 measures the sampling, which is slower than a run")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if *samples < 1 {
+		return fmt.Errorf("%w: -samples %d", ErrConfig, *samples)
+	}
+
+	var space indexedSpace
+	var desc string
+	if *mask != "" {
+		positions, err := parseMask(*mask)
+		if err != nil {
+			return err
+		}
+		if maskSize(positions) == math.MaxInt64 {
+			return fmt.Errorf("%w: mask %q has more words than fit in int64", ErrConfig, *mask)
+		}
+		space, desc = maskSpace(positions), "mask "+*mask
+	} else {
+		minLen, maxLen, err := parseLengths(*lengths)
+		if err != nil {
+			return err
+		}
+		ks, err := wordlist.NewKeyspace(wordlist.Runes(*symbols), minLen, maxLen)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+		space, desc = ks, fmt.Sprintf("%d symbols, lengths %d-%d", len(ks.Symbols()), minLen, maxLen)
+	}
+	fl, err := ff.build()
+	if err != nil {
+		return err
+	}
+
+	// A space no bigger than the sample is counted exactly.
+	total := space.Total()
+	exact := total <= *samples
+	n := *samples
+	if exact {
+		n = total
+	}
+	rng := rand.New(rand.NewPCG(*seed, *seed^0x9e3779b97f4a7c15))
+	var buf []byte
+	var passedBytes int64
+	started := time.Now()
+	for i := range n {
+		index := i
+		if !exact {
+			index = rng.Int64N(total)
+		}
+		buf, _ = space.AppendWord(buf[:0], index)
+		if word, ok := fl.pipe.run(buf); ok {
+			passedBytes += int64(len(word)) + 1
+		}
+	}
+	elapsed := time.Since(started)
+	passed := fl.pipe.emitted()
+
+	fmt.Fprintf(os.Stderr, "🎲 %s: %s words, filtered by %s\n", desc, fmtInt(total), &fl.policy)
+	if exact {
+		fmt.Fprintf(os.Stderr, "   Counted all %s words exactly: %s pass\n", fmtInt(n), fmtInt(passed))
+	} else {
+		fmt.Fprintf(os.Stderr, "   Sampled %s random words (seed %d): %s passed\n", fmtInt(n), *seed, fmtInt(passed))
+	}
+	fl.pipe.report(os.Stderr)
+
+	lo, hi := wilson(passed, n)
+	est := float64(passed) / float64(n) * float64(total)
+	estLo, estHi := lo*float64(total), hi*float64(total)
+	if exact {
+		lo, hi, estLo, estHi = float64(passed)/float64(n), float64(passed)/float64(n), est, est
+	}
+	avgBytes := 0.0
+	if passed > 0 {
+		avgBytes = float64(passedBytes) / float64(passed)
+	}
+	speed, measured := *rate, ""
+	if speed <= 0 {
+		speed, measured = float64(n)/elapsed.Seconds(), ", measured on the samples"
+	}
+	eta := time.Duration(float64(total) / speed * float64(time.Second))
+
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "   Pass rate  %s%%   (95%% CI %s%% – %s%%)\n",
+		fmtFloat(float64(passed)/float64(n)*100, 4), fmtFloat(lo*100, 4), fmtFloat(hi*100, 4))
+	fmt.Fprintf(os.Stderr, "   Output     ~%s words (%s – %s), ~%s\n",
+		fmtCount(int64(est)), fmtCount(int64(estLo)), fmtCount(int64(estHi)), fmtBytes(int64(est*avgBytes)))
+	fmt.Fprintf(os.Stderr, "   ETA        %s to generate and filter all %s words at %s words/s%s\n",
+		fmtDuration(eta), fmtCount(total), fmtCount(int64(speed)), measured)
+	if !exact && passed == 0 {
+		fmt.Fprintf(os.Stderr, "   ⚠️  No sample passed; the output is probably empty, and at most ~%s words\n", fmtCount(int64(estHi)))
+	}
+	return nil
+}
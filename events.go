@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+// eventKind says what an event reports.
+type eventKind int
+
+const (
+	evStart        eventKind = iota // generation begins at pos
+	evTick                          // n more words were written, reaching pos
+	evFile                          // file was completed and its state saved
+	evPublishStart                  // a publish of files completed files began
+	evPublish                       // a publish finished
+	evError                         // err was skipped, retried or ended the run
+	evDone                          // the whole keyspace has been generated
+)
+
+// event is one entry in the progress stream. Fields that do not apply to a
+// kind are left zero.
+type event struct {
+	kind    eventKind
+	time    time.Time
+	pos     int64 // position reached
+	total   int64 // keyspace size
+	n       int64 // words in this tick or completed file
+	bytes   int64 // bytes written in this tick, or the completed file's size
+	fileNum int
+	file    string
+	files   int    // files of the run's slice completed so far
+	err     error  // evError only
+	action  string // evError only: what the error policy did about it
+}
+
+// sink consumes progress events. handle runs on the generating goroutine, so
+// a sink must return quickly or hand the work off. With -workers, events
+// come from several goroutines, but the bus hands a sink one at a time.
+type sink interface {
+	handle(event)
+}
+
+// bus fans every event out to its sinks in subscription order.
+type bus struct {
+	mu    sync.Mutex
+	sinks []sink
+}
+
+func (b *bus) subscribe(s sink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sinks = append(b.sinks, s)
+}
+
+// unsubscribe removes s, which must be comparable, as pointers are.
+func (b *bus) unsubscribe(s sink) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sinks = slices.DeleteFunc(b.sinks, func(t sink) bool { return t == s })
+}
+
+func (b *bus) emit(e event) {
+	e.time = time.Now()
+	e.total = total
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.sinks {
+		s.handle(e)
+	}
+}
+
+// events is the progress stream of the current run. Reporting surfaces
+// subscribe to it instead of being called from the generation loop.
+var events bus
+
+// logSink records the milestones of a run through slog.
+type logSink struct{}
+
+func (logSink) handle(e event) {
+	switch e.kind {
+	case evStart:
+		if e.pos > 0 {
+			slog.Info("resuming", "position", e.pos-1, "percent", float64(e.pos-1)/float64(e.total)*100)
+		} else {
+			slog.Info("starting fresh generation")
+		}
+	case evFile:
+		slog.Info("file completed", "file", e.file, "entries", e.n, "bytes", e.bytes, "files", e.files)
+	case evPublishStart:
+		slog.Info("publishing", "files", e.files)
+	case evPublish:
+		slog.Info("published", "files", e.files)
+	case evError:
+		switch e.action {
+		case "interrupted":
+			slog.Warn("interrupted; run again to resume from the state saved")
+		case "fatal":
+			slog.Error("run failed", "err", e.err)
+		default:
+			slog.Warn("error "+e.action, "err", e.err)
+		}
+	case evDone:
+		slog.Info("generation complete", "total", e.total, "files", e.files)
+	}
+}
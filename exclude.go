@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"main.go/wordlist"
+)
+
+// exclusions are the parts of the keyspace a run skips, already covered by
+// some other list: newKeyspace applies them. Positions keep their numbers,
+// so chunks still start every -per-file positions, and hold fewer words.
+var exclusions struct {
+	masks       stringList
+	ranges      stringList
+	constraints stringList
+}
+
+// stringList is a flag that may be given more than once.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, " ") }
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// addExclusionFlags registers -exclude-mask, -exclude-range and
+// -constraint on fs.
+func addExclusionFlags(fs *flag.FlagSet) {
+	fs.Var(&exclusions.masks, "exclude-mask", "skip the words matching this `mask` (?d?d?d?d, admin?d...); may be repeated")
+	fs.Var(&exclusions.ranges, "exclude-range", "skip the words at positions `START-END`, both included; may be repeated")
+	fs.Var(&exclusions.constraints, "constraint", "skip the words breaking these `rules` on their positions, such as 'pos1 in [A-Z]; all != prev; last in ?d'; may be repeated")
+}
+
+// excluding returns ks without the words of the -exclude flags, or ks when
+// there are none.
+func excluding(ks *wordlist.Keyspace) (*wordlist.Keyspace, error) {
+	if !excludes() {
+		return ks, nil
+	}
+	e := wordlist.NewExclusion(ks)
+	for _, m := range exclusions.masks {
+		positions, err := parseMask(m)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.AddMask(positions); err != nil {
+			return nil, fmt.Errorf("%w: -exclude-mask %q: %w", ErrConfig, m, err)
+		}
+	}
+	for _, r := range exclusions.ranges {
+		from, to, ok := strings.Cut(r, "-")
+		start, err1 := strconv.ParseInt(from, 10, 64)
+		end, err2 := strconv.ParseInt(to, 10, 64)
+		if !ok || err1 != nil || err2 != nil || start < 0 || end < start {
+			return nil, fmt.Errorf("%w: -exclude-range %q: want START-END, two positions with START <= END", ErrConfig, r)
+		}
+		e.AddRange(start, end+1)
+	}
+	for _, src := range exclusions.constraints {
+		c, err := wordlist.ParseConstraints(src)
+		if err == nil {
+			err = e.AddConstraints(c)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: -constraint: %w", ErrConfig, err)
+		}
+	}
+	return ks.Without(e), nil
+}
+
+// excludes reports whether the run skips any words.
+func excludes() bool {
+	return len(exclusions.masks)+len(exclusions.ranges)+len(exclusions.constraints) > 0
+}
+
+// describeExclusions is the -exclude flags in a line, or "" when none.
+func describeExclusions() string {
+	parts := []string(exclusions.masks)
+	for _, r := range exclusions.ranges {
+		parts = append(parts, "positions "+r)
+	}
+	for _, c := range exclusions.constraints {
+		parts = append(parts, "breaking "+c)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+
+	"main.go/wordlist"
+)
+
+// fsInfo is what matters to us about the filesystem receiving the output.
+// The zero value is an ordinary local filesystem with no file size limit.
+type fsInfo struct {
+	name            string
+	maxFile         int64 // largest possible file in bytes, 0 if unlimited
+	network         bool  // remote or FUSE-backed: writes and fsync may be slow
+	caseInsensitive bool
+	free            int64 // bytes available to us, 0 if unknown
+}
+
+// checkFilesystem warns about limitations of the filesystem under dir and
+// returns the entries per file to use. With fit set, a chunk that would not
+// fit the filesystem's file size limit is shrunk until it does; otherwise
+// that is a configuration error.
+func checkFilesystem(dir string, ks *wordlist.Keyspace, perFile int64, fit bool) (int64, error) {
+	fs, err := statFS(dir)
+	if err != nil {
+		slog.Warn("cannot identify output filesystem", "dir", dir, "err", err)
+		return perFile, nil
+	}
+	if fs.network {
+		slog.Warn("output is on a network filesystem; writes and fsync may be slow", "fs", fs.name)
+	}
+	if fs.caseInsensitive {
+		slog.Warn("output filesystem is case-insensitive; names differing only in case will collide", "fs", fs.name)
+	}
+	if fs.maxFile == 0 {
+		return perFile, nil
+	}
+
+	largest := chunkBound(ks, perFile)
+	if largest <= fs.maxFile {
+		return perFile, nil
+	}
+	if !fit {
+		return 0, fmt.Errorf("%w: chunks of %s entries reach %s but %s files are limited to %s (use -fit-fs)",
+			ErrConfig, fmtInt(perFile), fmtBytes(largest), fs.name, fmtBytes(fs.maxFile))
+	}
+	capped := fs.maxFile / int64(ks.MaxWordBytes()+1)
+	if capped < 1 {
+		return 0, fmt.Errorf("%w: %s files are limited to %s, smaller than a single word", ErrConfig, fs.name, fmtBytes(fs.maxFile))
+	}
+	slog.Warn("shrinking chunks to fit the filesystem's file size limit",
+		"fs", fs.name, "limit", fs.maxFile, "entries_per_file", capped)
+	return capped, nil
+}
+
+// chunkBound returns a size no chunk of perFile entries of ks can exceed:
+// perFile of its longest words. Which chunk is the largest depends on how
+// long the words of each happen to be, so short of sizing every one this
+// bound is what limits are checked against.
+func chunkBound(ks *wordlist.Keyspace, perFile int64) int64 {
+	total := ks.Total()
+	if perFile >= total {
+		return ks.Bytes(0, total)
+	}
+	word := int64(ks.MaxWordBytes() + 1)
+	if perFile > math.MaxInt64/word {
+		return math.MaxInt64
+	}
+	return perFile * word
+}
+
+// perFileForSize returns the most entries per file that keep every chunk
+// of ks within limit bytes, by chunkBound: as many as fit were each the
+// longest word. Chunks still rotate at fixed positions, so they stay where
+// seek, resume and the other nodes expect them, and chunks of shorter
+// words come out smaller.
+func perFileForSize(ks *wordlist.Keyspace, limit int64) (int64, error) {
+	total := ks.Total()
+	if ks.Bytes(0, total) <= limit {
+		return max(total, 1), nil
+	}
+	perFile := limit / int64(ks.MaxWordBytes()+1)
+	if perFile < 1 {
+		return 0, fmt.Errorf("%w: -max-file-size %s is smaller than a single word", ErrConfig, fmtBytes(limit))
+	}
+	return perFile, nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"regexp"
+)
+
+// filterFlags are the word filters shared by the tools that filter: a
+// policy (given or inferred), length overrides, regexps and estimated
+// strength.
+type filterFlags struct {
+	policy, examples         string
+	minLen, maxLen           int
+	match, exclude           string
+	minStrength, maxStrength int
+	strengthDicts            stringList
+	strength                 *strengthEstimator // built by build when the strength is bounded
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	f := &filterFlags{}
+	fs.StringVar(&f.policy, "policy", "", "keep the words this JSON `policy` accepts (as written by the policy tool)")
+	fs.StringVar(&f.examples, "examples", "", "infer the policy from the passwords in this `file` instead")
+	fs.IntVar(&f.minLen, "min-length", 0, "drop words shorter than this, overriding the policy")
+	fs.IntVar(&f.maxLen, "max-length", 0, "drop words longer than this, overriding the policy")
+	fs.StringVar(&f.match, "match", "", "then keep only the words matching this `regexp`")
+	fs.StringVar(&f.exclude, "exclude", "", "then drop the words matching this `regexp`")
+	fs.IntVar(&f.minStrength, "min-strength", 0, "then drop the words with an estimated strength below this `score` (0-4, as zxcvbn)")
+	fs.IntVar(&f.maxStrength, "max-strength", strengthScores-1, "then drop the words with an estimated strength above this `score`, for lists of weak passwords")
+	fs.Var(&f.strengthDicts, "strength-dict", "rank the words of this `list`, most common first, among the common passwords the strength estimate knows; may be repeated")
+	return f
+}
+
+// filters is a built filter chain.
+type filters struct {
+	policy  passwordPolicy
+	pipe    pipeline
+	dropped [policyClasses + 1]int64 // by the policy, by reason
+}
+
+// build loads or infers the policy and chains it with the regexps.
+func (f *filterFlags) build() (*filters, error) {
+	var p passwordPolicy
+	var err error
+	switch {
+	case f.policy != "" && f.examples != "":
+		return nil, fmt.Errorf("%w: -policy and -examples are exclusive", ErrConfig)
+	case f.policy != "":
+		p, err = loadPolicy(f.policy)
+	case f.examples != "":
+		var ex []string
+		if ex, err = readPolicyExamples(f.examples); err == nil {
+			if p, err = inferPolicy(ex); err == nil {
+				reportInferred(&p, len(ex))
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if f.minLen > 0 {
+		p.MinLen = f.minLen
+	}
+	if f.maxLen > 0 {
+		p.MaxLen = f.maxLen
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	if f.minStrength < 0 || f.maxStrength >= strengthScores || f.minStrength > f.maxStrength {
+		return nil, fmt.Errorf("%w: -min-strength %d and -max-strength %d must be scores from 0 to %d, in order",
+			ErrConfig, f.minStrength, f.maxStrength, strengthScores-1)
+	}
+	if f.minStrength > 0 || f.maxStrength < strengthScores-1 {
+		if f.strength, err = newStrengthEstimator(f.strengthDicts); err != nil {
+			return nil, err
+		}
+	}
+	return f.chain(p)
+}
+
+// chain builds the filter chain for policy p and the regexps. Each chain
+// counts on its own, so goroutines filtering in parallel need one each.
+func (f *filterFlags) chain(p passwordPolicy) (*filters, error) {
+	fl := &filters{policy: p}
+	check := p.compile()
+	fl.pipe.add("policy", func(word []byte) ([]byte, bool) {
+		why := check.check(word)
+		fl.dropped[why]++
+		return word, why == policyOK
+	})
+	for _, r := range []struct {
+		flag, expr string
+		keep       bool
+	}{{"match", f.match, true}, {"exclude", f.exclude, false}} {
+		if r.expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(r.expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: -%s: %w", ErrConfig, r.flag, err)
+		}
+		keep := r.keep
+		fl.pipe.add(r.flag+" "+r.expr, func(word []byte) ([]byte, bool) {
+			return word, re.Match(word) == keep
+		})
+	}
+	if est := f.strength; est != nil {
+		fl.pipe.add(fmt.Sprintf("strength %d-%d", f.minStrength, f.maxStrength), func(word []byte) ([]byte, bool) {
+			s := est.score(string(word))
+			return word, s >= f.minStrength && s <= f.maxStrength
+		})
+	}
+	return fl, nil
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// locale is how numbers are punctuated in console output. Logs and JSON
+// always carry raw numbers.
+type locale struct {
+	group   string // thousands separator
+	decimal string // decimal point
+}
+
+var locales = map[string]locale{
+	"en": {",", "."},
+	"de": {".", ","},
+	"es": {".", ","},
+	"it": {".", ","},
+	"nl": {".", ","},
+	"pt": {".", ","},
+	"fr": {" ", ","},
+	"ru": {" ", ","},
+	"pl": {" ", ","},
+	"sv": {" ", ","},
+	"ch": {"'", "."},
+	"c":  {"", "."},
+}
+
+// loc is the locale selected by -locale or the environment.
+var loc = locales["en"]
+
+// setLocale selects a locale by name ("de", "de_DE.UTF-8", "fr-FR"...). An
+// empty name falls back to LC_ALL, LC_NUMERIC and LANG, then English.
+func setLocale(name string) error {
+	explicit := name != ""
+	if !explicit {
+		for _, env := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+			if name = os.Getenv(env); name != "" {
+				break
+			}
+		}
+	}
+	tag, _, _ := strings.Cut(strings.ToLower(name), ".") // drop .UTF-8
+	tag, _, _ = strings.Cut(tag, "@")
+	lang, region, _ := strings.Cut(strings.ReplaceAll(tag, "-", "_"), "_")
+	switch {
+	case region == "ch":
+		lang = "ch" // de_CH, fr_CH and it_CH share the Swiss apostrophe
+	case lang == "posix":
+		lang = "c"
+	}
+	l, ok := locales[lang]
+	switch {
+	case ok:
+		loc = l
+	case explicit:
+		return fmt.Errorf("%w: unknown -locale %q", ErrConfig, name)
+	default:
+		loc = locales["en"]
+	}
+	return nil
+}
+
+// fmtInt formats n with thousands separators: 17,043,520.
+func fmtInt(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	for i, c := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteString(loc.group)
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// fmtFloat formats f with prec decimals and thousands separators.
+func fmtFloat(f float64, prec int) string {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "—"
+	}
+	s := strconv.FormatFloat(math.Abs(f), 'f', prec, 64)
+	whole, frac, _ := strings.Cut(s, ".")
+	n, _ := strconv.ParseInt(whole, 10, 64)
+	if f < 0 {
+		n = -n
+	}
+	out := fmtInt(n)
+	if f < 0 && n == 0 {
+		out = "-" + out
+	}
+	if frac != "" {
+		out += loc.decimal + frac
+	}
+	return out
+}
+
+// fmtScaled formats n with three significant digits and a unit suffix from
+// units (smallest first, each step a factor of base): 1.23 B, 45.6 GB.
+func fmtScaled(n float64, base float64, units []string) string {
+	i := 0
+	for math.Abs(n) >= base && i < len(units)-1 {
+		n /= base
+		i++
+	}
+	prec := 2
+	switch {
+	case i == 0:
+		prec = 0
+	case math.Abs(n) >= 100:
+		prec = 0
+	case math.Abs(n) >= 10:
+		prec = 1
+	}
+	return strings.TrimSpace(fmtFloat(n, prec) + " " + units[i])
+}
+
+// fmtCount formats a large count on the short scale: 17.0 M, 1.23 B.
+func fmtCount(n int64) string {
+	return fmtScaled(float64(n), 1000, []string{"", "K", "M", "B", "T", "Q"})
+}
+
+// fmtBytes formats a byte size in decimal units: 12.0 MB, 45.6 GB.
+func fmtBytes(n int64) string {
+	return fmtScaled(float64(n), 1000, []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"})
+}
+
+// parseBytes reads a size like fmtBytes writes it, or with binary units:
+// 512MB, 4GB, 1.5 TB, 64KiB, or a plain number of bytes.
+func parseBytes(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12}, {"B", 1},
+	}
+	num, scale := strings.TrimSpace(s), 1.0
+	for _, u := range units {
+		if rest, ok := strings.CutSuffix(num, u.suffix); ok {
+			num, scale = strings.TrimSpace(rest), u.scale
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil || f < 0 || f*scale >= math.MaxInt64 {
+		return 0, fmt.Errorf("%w: size %q (want e.g. 512MB or 4GB)", ErrConfig, s)
+	}
+	return int64(f * scale), nil
+}
+
+// fmtDuration formats d to the second: 45s, 12m05s, 3h04m05s, 2d03h04m.
+func fmtDuration(d time.Duration) string {
+	if d < 0 || d > 100*365*24*time.Hour {
+		return "—"
+	}
+	s := int64(d.Round(time.Second) / time.Second)
+	days, h, m, sec := s/86400, s/3600%24, s/60%60, s%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%02dh%02dm", days, h, m)
+	case h > 0:
+		return fmt.Sprintf("%dh%02dm%02ds", h, m, sec)
+	case m > 0:
+		return fmt.Sprintf("%dm%02ds", m, sec)
+	}
+	return fmt.Sprintf("%ds", sec)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// wordOverhead approximates what one distinct word costs in the counting map
+// besides its bytes: the string header, the count and the map's own slots.
+const wordOverhead = 64
+
+// wordCount is a word and how often it occurs.
+type wordCount struct {
+	word  string
+	count int64
+}
+
+// freqCounter counts words in memory up to a budget, then spills the counts
+// to a run file on disk, sorted by word, and starts over; merging the runs
+// gives the total counts with memory for one word per run.
+type freqCounter struct {
+	counts map[string]int64
+	mem    int64
+	budget int64
+	dir    string
+	runs   []string
+}
+
+func (f *freqCounter) add(word []byte) error {
+	if n, ok := f.counts[string(word)]; ok {
+		f.counts[string(word)] = n + 1
+		return nil
+	}
+	f.counts[string(word)] = 1
+	f.mem += int64(len(word)) + wordOverhead
+	if f.mem >= f.budget {
+		return f.spill()
+	}
+	return nil
+}
+
+func (f *freqCounter) spill() error {
+	if len(f.counts) == 0 {
+		return nil
+	}
+	words := make([]wordCount, 0, len(f.counts))
+	for w, n := range f.counts {
+		words = append(words, wordCount{w, n})
+	}
+	slices.SortFunc(words, byWord)
+	name, err := writeRun(f.dir, words)
+	if err != nil {
+		return err
+	}
+	f.runs = append(f.runs, name)
+	clear(f.counts)
+	f.mem = 0
+	return nil
+}
+
+// each calls fn with every distinct word and its total count, in word order
+// when the counts were spilled and in no particular order otherwise.
+func (f *freqCounter) each(fn func(wordCount) error) error {
+	if len(f.runs) == 0 {
+		for w, n := range f.counts {
+			if err := fn(wordCount{w, n}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := f.spill(); err != nil {
+		return err
+	}
+	var cur wordCount
+	started := false
+	err := mergeRuns(f.runs, byWord, func(w wordCount) error {
+		if started && w.word == cur.word {
+			cur.count += w.count
+			return nil
+		}
+		if started {
+			if err := fn(cur); err != nil {
+				return err
+			}
+		}
+		cur, started = w, true
+		return nil
+	})
+	if err != nil || !started {
+		return err
+	}
+	return fn(cur)
+}
+
+// writeRun writes words to a new file in dir as pairs of a length-prefixed
+// word and a varint count, and returns its name.
+func writeRun(dir string, words []wordCount) (string, error) {
+	file, err := os.CreateTemp(dir, "freq-run-*")
+	if err != nil {
+		return "", diskError("create run file", err)
+	}
+	bw := bufio.NewWriterSize(file, listBufferSize)
+	var buf []byte
+	for _, w := range words {
+		buf = binary.AppendUvarint(buf[:0], uint64(len(w.word)))
+		buf = append(buf, w.word...)
+		buf = binary.AppendUvarint(buf, uint64(w.count))
+		bw.Write(buf)
+	}
+	err = bw.Flush()
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", diskError("write "+file.Name(), err)
+	}
+	return file.Name(), nil
+}
+
+// mergeRuns calls fn with the entries of the runs, each sorted by order, in
+// that order.
+func mergeRuns(runs []string, order func(a, b wordCount) int, fn func(wordCount) error) error {
+	m := runMerge{order: order}
+	for _, name := range runs {
+		file, err := os.Open(name)
+		if err != nil {
+			return diskError("open "+name, err)
+		}
+		defer file.Close()
+		r := &runReader{br: bufio.NewReaderSize(file, 1<<16), name: name}
+		if err := r.next(); err != nil {
+			return err
+		}
+		if !r.done {
+			m.readers = append(m.readers, r)
+		}
+	}
+	heap.Init(&m)
+	for len(m.readers) > 0 {
+		r := m.readers[0]
+		if err := fn(r.cur); err != nil {
+			return err
+		}
+		if err := r.next(); err != nil {
+			return err
+		}
+		if r.done {
+			heap.Pop(&m)
+		} else {
+			heap.Fix(&m, 0)
+		}
+	}
+	return nil
+}
+
+// runReader reads one run file's entries in order.
+type runReader struct {
+	br   *bufio.Reader
+	name string
+	cur  wordCount
+	done bool
+}
+
+func (r *runReader) next() error {
+	n, err := binary.ReadUvarint(r.br)
+	if err == io.EOF {
+		r.done = true
+		return nil
+	}
+	if err != nil {
+		return diskError("read "+r.name, err)
+	}
+	word := make([]byte, n)
+	if _, err := io.ReadFull(r.br, word); err != nil {
+		return diskError("read "+r.name, err)
+	}
+	count, err := binary.ReadUvarint(r.br)
+	if err != nil {
+		return diskError("read "+r.name, err)
+	}
+	r.cur = wordCount{string(word), int64(count)}
+	return nil
+}
+
+// runMerge is a heap of run readers by their current entry.
+type runMerge struct {
+	readers []*runReader
+	order   func(a, b wordCount) int
+}
+
+func (m runMerge) Len() int           { return len(m.readers) }
+func (m runMerge) Less(i, j int) bool { return m.order(m.readers[i].cur, m.readers[j].cur) < 0 }
+func (m runMerge) Swap(i, j int)      { m.readers[i], m.readers[j] = m.readers[j], m.readers[i] }
+func (m *runMerge) Push(x any)        { m.readers = append(m.readers, x.(*runReader)) }
+func (m *runMerge) Pop() any {
+	r := m.readers[len(m.readers)-1]
+	m.readers = m.readers[:len(m.readers)-1]
+	return r
+}
+
+func byWord(a, b wordCount) int { return cmp.Compare(a.word, b.word) }
+
+// byFrequency orders words most frequent first, ties alphabetically, so the
+// output does not depend on how the counting was split.
+func byFrequency(a, b wordCount) int {
+	if c := cmp.Compare(b.count, a.count); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.word, b.word)
+}
+
+// ranking collects the counted words in frequency order. With a limit it
+// keeps only the most frequent in a min-heap whose root is the least
+// frequent kept word; without one it sorts within the memory budget and
+// spills sorted runs like the counter.
+type ranking struct {
+	limit  int
+	words  []wordCount
+	mem    int64
+	budget int64
+	dir    string
+	runs   []string
+}
+
+func (t *ranking) Len() int           { return len(t.words) }
+func (t *ranking) Less(i, j int) bool { return byFrequency(t.words[i], t.words[j]) > 0 }
+func (t *ranking) Swap(i, j int)      { t.words[i], t.words[j] = t.words[j], t.words[i] }
+func (t *ranking) Push(x any)         { t.words = append(t.words, x.(wordCount)) }
+func (t *ranking) Pop() any {
+	w := t.words[len(t.words)-1]
+	t.words = t.words[:len(t.words)-1]
+	return w
+}
+
+func (t *ranking) add(w wordCount) error {
+	switch {
+	case t.limit == 0:
+		t.words = append(t.words, w)
+		if t.mem += int64(len(w.word)) + wordOverhead; t.mem >= t.budget {
+			return t.spill()
+		}
+	case len(t.words) < t.limit:
+		heap.Push(t, w)
+	case byFrequency(w, t.words[0]) < 0:
+		t.words[0] = w
+		heap.Fix(t, 0)
+	}
+	return nil
+}
+
+func (t *ranking) spill() error {
+	slices.SortFunc(t.words, byFrequency)
+	name, err := writeRun(t.dir, t.words)
+	if err != nil {
+		return err
+	}
+	t.runs = append(t.runs, name)
+	t.words, t.mem = t.words[:0], 0
+	return nil
+}
+
+// each calls fn with the ranked words, most frequent first.
+func (t *ranking) each(fn func(wordCount) error) error {
+	if len(t.runs) > 0 {
+		if err := t.spill(); err != nil {
+			return err
+		}
+		return mergeRuns(t.runs, byFrequency, fn)
+	}
+	slices.SortFunc(t.words, byFrequency)
+	for _, w := range t.words {
+		if err := fn(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func freqCmd(args []string) error {
+	fs := newToolFlags("freq", "corpus...")
+	top := fs.Int("top", 0, "keep only the `n` most frequent words; 0 keeps all")
+	minCount := fs.Int64("min-count", 1, "leave out words seen fewer times than this")
+	counts := fs.Bool("counts", false, "write \"count<TAB>word\" lines instead of bare words")
+	memory := fs.Int("memory", 1024, "count up to this many `MB` of words in memory before spilling sorted runs to disk")
+	tmp := fs.String("tmp", os.TempDir(), "`directory` for the spilled runs")
+	out := fs.String("o", "", "write the list to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: freq needs at least one corpus (- for stdin)", ErrConfig)
+	}
+	if *top < 0 || *memory < 1 {
+		return fmt.Errorf("%w: -top %d, -memory %d", ErrConfig, *top, *memory)
+	}
+
+	budget := int64(*memory) << 20
+	fc := &freqCounter{counts: make(map[string]int64), budget: budget, dir: *tmp}
+	rank := &ranking{limit: *top, budget: budget, dir: *tmp}
+	defer func() {
+		for _, r := range slices.Concat(fc.runs, rank.runs) {
+			os.Remove(r)
+		}
+	}()
+	st, err := forEachLine(fs.Args(), "counting", func(word []byte) error {
+		if len(word) == 0 {
+			return nil
+		}
+		return fc.add(word)
+	})
+	if err != nil {
+		return err
+	}
+
+	var distinct int64
+	err = fc.each(func(w wordCount) error {
+		distinct++
+		if w.count < *minCount {
+			return nil
+		}
+		return rank.add(w)
+	})
+	if err != nil {
+		return err
+	}
+	// The counts are no longer needed; the ranking may need the memory.
+	fc.counts = nil
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	var line []byte
+	var written int64
+	var first wordCount
+	err = rank.each(func(wc wordCount) error {
+		if written == 0 {
+			first = wc
+		}
+		written++
+		line = line[:0]
+		if *counts {
+			line = strconv.AppendInt(line, wc.count, 10)
+			line = append(line, '\t')
+		}
+		line = append(line, wc.word...)
+		return w.writeLine(line)
+	})
+	if cerr := w.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "📊 Counted %s: %s words, %s distinct, wrote %s\n",
+		describeLists(fs.Args()), fmtInt(st.lines), fmtInt(distinct), fmtInt(written))
+	if runs := len(fc.runs) + len(rank.runs); runs > 0 {
+		fmt.Fprintf(os.Stderr, "   Spilled %d sorted runs to %s and merged them\n", runs, *tmp)
+	}
+	if written > 0 {
+		fmt.Fprintf(os.Stderr, "   Most frequent: %q (%s times)\n", first.word, fmtInt(first.count))
+	}
+	return nil
+}
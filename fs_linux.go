@@ -0,0 +1,52 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// statFS identifies the filesystem holding dir from its statfs magic number.
+func statFS(dir string) (fsInfo, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return fsInfo{}, err
+	}
+	info := fsInfo{free: int64(st.Bavail) * int64(st.Bsize)}
+	switch uint32(st.Type) {
+	case 0x4d44:
+		info.name, info.maxFile, info.caseInsensitive = "FAT", 1<<32-1, true
+	case 0x2011bab0:
+		info.name, info.caseInsensitive = "exFAT", true
+	case 0x482b:
+		info.name, info.caseInsensitive = "HFS+", true
+	case 0x6969:
+		info.name, info.network = "NFS", true
+	case 0x517b, 0xff534d42, 0xfe534d42:
+		info.name, info.network, info.caseInsensitive = "SMB/CIFS", true, true
+	case 0x00c36400:
+		info.name, info.network = "Ceph", true
+	case 0x5346414f:
+		info.name, info.network = "AFS", true
+	case 0x01021997:
+		info.name, info.network = "9P", true
+	case 0x65735546:
+		info.name, info.network = "FUSE", true // sshfs, rclone, s3fs...
+	}
+	return info, nil
+}
+
+// syncDir makes the renames and creations in dir durable, so a crash after
+// a chunk or state is renamed into place cannot bring back the old name.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
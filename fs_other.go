@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+// statFS cannot identify filesystems on this platform; no checks apply.
+func statFS(dir string) (fsInfo, error) {
+	return fsInfo{}, nil
+}
+
+// syncDir does nothing here: not every platform can sync a directory, and
+// those that cannot make renames durable on their own terms.
+func syncDir(dir string) error {
+	return nil
+}
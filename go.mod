@@ -1,3 +1,9 @@
 module main.go
 
 go 1.24.9
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/klauspost/compress v1.18.2
+	golang.org/x/text v0.28.0
+)
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// harvester collects candidate words with how often they were seen.
+type harvester struct {
+	counts         map[string]int64
+	minLen, maxLen int
+}
+
+// add counts the candidates in s, a name or a property value: s itself,
+// its tokens (split at separators and camelCase humps) and, when there are
+// several, the tokens run together: "Quarterly_Report2019" gives itself,
+// Quarterly, Report, 2019 and QuarterlyReport2019.
+func (h *harvester) add(s string) {
+	h.count(s)
+	tokens := splitTokens(s)
+	if len(tokens) == 1 && tokens[0] == s {
+		return
+	}
+	for _, t := range tokens {
+		h.count(t)
+	}
+	if joined := strings.Join(tokens, ""); len(tokens) > 1 && joined != s {
+		h.count(joined)
+	}
+}
+
+// addText counts the words of a document's text, and pairs of capitalized
+// words such as names ("Jane Doe" gives JaneDoe). Long runs of text are
+// split into words only, unlike names and property values.
+func (h *harvester) addText(text string) {
+	for _, line := range strings.Split(text, "\n") {
+		prev := ""
+		for _, field := range strings.Fields(line) {
+			word := strings.TrimFunc(field, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+			h.add(word)
+			if isCapitalized(prev) && isCapitalized(word) {
+				h.count(prev + word)
+			}
+			prev = word
+			if word != field {
+				prev = "" // punctuation ends a name
+			}
+		}
+	}
+}
+
+// isCapitalized reports whether w is a capital letter followed by at least
+// one lower-case letter and nothing else.
+func isCapitalized(w string) bool {
+	r, size := utf8.DecodeRuneInString(w)
+	if !unicode.IsUpper(r) || size == len(w) {
+		return false
+	}
+	for _, r := range w[size:] {
+		if !unicode.IsLower(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *harvester) count(w string) {
+	if n := utf8.RuneCountInString(w); n >= h.minLen && n <= h.maxLen && !strings.ContainsAny(w, "\n\r\t") {
+		h.counts[w]++
+	}
+}
+
+// splitTokens splits s at every rune that is neither a letter nor a digit,
+// between a lower-case letter and an upper-case one, and between letters
+// and digits.
+func splitTokens(s string) []string {
+	var tokens []string
+	start := -1
+	var prev rune
+	for i, r := range s {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case !word:
+			if start >= 0 {
+				tokens = append(tokens, s[start:i])
+			}
+			start = -1
+		case start < 0:
+			start = i
+		case unicode.IsLower(prev) && unicode.IsUpper(r), unicode.IsDigit(prev) != unicode.IsDigit(r):
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		prev = r
+	}
+	if start >= 0 {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
+
+func harvestCmd(args []string) error {
+	fs := newToolFlags("harvest-fs", "path...")
+	minLen := fs.Int("min-length", 3, "drop candidates shorter than this many characters")
+	maxLen := fs.Int("max-length", 32, "drop candidates longer than this many characters")
+	noMeta := fs.Bool("no-metadata", false, "only harvest file and directory names, without opening any file")
+	noText := fs.Bool("no-text", false, "harvest document properties but not the text of PDF, Office and OpenDocument files")
+	top := fs.Int("top", 0, "write only the `n` most frequent candidates; 0 for all")
+	minCount := fs.Int64("min-count", 1, "drop candidates seen fewer than `n` times")
+	hidden := fs.Bool("hidden", false, "also descend into hidden directories (.git, .cache...)")
+	counts := fs.Bool("counts", false, "prefix each candidate with how often it was seen and a tab")
+	out := fs.String("o", "", "write the candidates to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: harvest-fs needs at least one path", ErrConfig)
+	}
+	if *minLen < 1 || *maxLen < *minLen {
+		return fmt.Errorf("%w: -min-length %d, -max-length %d", ErrConfig, *minLen, *maxLen)
+	}
+
+	h := &harvester{counts: make(map[string]int64), minLen: *minLen, maxLen: *maxLen}
+	var files, dirs, documents, texts, unreadable int
+	for _, root := range fs.Args() {
+		err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				// An evidence tree has corners we may not read; go on without them.
+				unreadable++
+				fmt.Fprintf(os.Stderr, "⚠️  %v\n", err)
+				return nil
+			}
+			name := d.Name()
+			if d.IsDir() {
+				if path != root && strings.HasPrefix(name, ".") && !*hidden {
+					return filepath.SkipDir
+				}
+				dirs++
+				h.add(name)
+				return nil
+			}
+			files++
+			if stem := strings.TrimSuffix(name, filepath.Ext(name)); stem != name && stem != "" {
+				h.count(name)
+				h.add(stem)
+			} else {
+				h.add(name)
+			}
+			if *noMeta || !d.Type().IsRegular() {
+				return nil
+			}
+			values, err := fileMetadata(path)
+			if err != nil {
+				unreadable++
+				fmt.Fprintf(os.Stderr, "⚠️  %s: %v\n", path, err)
+				return nil
+			}
+			if len(values) > 0 {
+				documents++
+			}
+			for _, v := range values {
+				h.add(v)
+			}
+			if *noText {
+				return nil
+			}
+			text, err := documentText(path)
+			if err != nil {
+				unreadable++
+				fmt.Fprintf(os.Stderr, "⚠️  %s: %v\n", path, err)
+			}
+			if text != "" {
+				texts++
+				h.addText(text)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	words := make([]wordCount, 0, len(h.counts))
+	for w, n := range h.counts {
+		if n >= *minCount {
+			words = append(words, wordCount{w, n})
+		}
+	}
+	slices.SortFunc(words, byFrequency)
+	if *top > 0 && *top < len(words) {
+		words = words[:*top]
+	}
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	for _, wc := range words {
+		line := wc.word
+		if *counts {
+			line = fmt.Sprintf("%d\t%s", wc.count, wc.word)
+		}
+		if err := w.writeLine([]byte(line)); err != nil {
+			w.close()
+			return err
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "🌾 Harvested %s candidates from %s files, %s directories, the metadata of %s documents and the text of %s",
+		fmtInt(int64(len(words))), fmtInt(int64(files)), fmtInt(int64(dirs)), fmtInt(int64(documents)), fmtInt(int64(texts)))
+	if unreadable > 0 {
+		fmt.Fprintf(os.Stderr, "; %d unreadable", unreadable)
+	}
+	fmt.Fprintln(os.Stderr)
+	return nil
+}
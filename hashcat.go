@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"main.go/wordlist"
+)
+
+// hcmaskLine is one line of a hashcat .hcmask file: fixed symbols, then one
+// position over a tail of the charset, then positions over all of it.
+type hcmaskLine struct {
+	prefix string // fixed symbols
+	from   int    // the position after prefix takes charset[from:]
+	free   int    // positions after that take the full charset
+}
+
+// hcmaskLines returns masks covering the words of ks from index to the end,
+// in order: the first begins with the word at index, and each covers the
+// words after the previous one. Finishing the first n lines thus covers a
+// prefix of the remaining keyspace, whatever order hashcat tries the words
+// of each line in.
+func hcmaskLines(ks *wordlist.Keyspace, index int64) ([]hcmaskLine, error) {
+	word, err := ks.WordAt(index)
+	if err != nil {
+		return nil, err
+	}
+	symbols := ks.Symbols()
+	l := ks.LengthAt(index)
+	digits := make([]int, l)
+	for i, s := 0, word; i < l; i++ {
+		for d, sym := range symbols {
+			if strings.HasPrefix(s, sym) {
+				digits[i], s = d, s[len(sym):]
+				break
+			}
+		}
+	}
+
+	// Trailing first symbols are whole positions: "ab" + "aa" is ab??.
+	j := l
+	for j > 0 && digits[j-1] == 0 {
+		j--
+	}
+	var lines []hcmaskLine
+	if j == 0 {
+		lines = append(lines, hcmaskLine{from: 0, free: l - 1})
+	} else {
+		lines = append(lines, hcmaskLine{prefixOf(symbols, digits[:j-1]), digits[j-1], l - j})
+		for k := j - 2; k >= 0; k-- {
+			if digits[k]+1 < len(symbols) {
+				lines = append(lines, hcmaskLine{prefixOf(symbols, digits[:k]), digits[k] + 1, l - 1 - k})
+			}
+		}
+	}
+	for longer := l + 1; longer <= ks.MaxLen(); longer++ {
+		lines = append(lines, hcmaskLine{from: 0, free: longer - 1})
+	}
+	return lines, nil
+}
+
+func prefixOf(symbols []string, digits []int) string {
+	var b strings.Builder
+	for _, d := range digits {
+		b.WriteString(symbols[d])
+	}
+	return b.String()
+}
+
+// size is the number of words the line covers.
+func (m hcmaskLine) size(n int) int64 {
+	words := int64(n - m.from)
+	for range m.free {
+		words *= int64(n)
+	}
+	return words
+}
+
+// format writes the line in .hcmask syntax: the custom charsets it uses,
+// then the mask, separated by commas, with "?" and "," escaped.
+func (m hcmaskLine) format(symbols []string) string {
+	esc := strings.NewReplacer("?", "??", ",", `\,`).Replace
+	var fields []string
+	mask := esc(m.prefix)
+	if m.from > 0 {
+		fields = append(fields, esc(strings.Join(symbols[m.from:], "")))
+		mask += "?1"
+	}
+	free := m.free
+	if m.from == 0 {
+		free++ // the tail is the whole charset
+	}
+	if free > 0 {
+		fields = append(fields, esc(strings.Join(symbols, "")))
+		mask += strings.Repeat("?"+strconv.Itoa(len(fields)), free)
+	}
+	return strings.Join(append(fields, mask), ",")
+}
+
+func hashcatCmd(args []string) error {
+	fs := newToolFlags("hashcat", "")
+	perFile := fs.Int64("per-file", entriesPerFile, "words per chunk file the run was generated with")
+	from := fs.Int64("from", -1, "start at this word `index`; -1 for the position in "+stateFile)
+	fromWord := fs.String("from-word", "", "start at this `word` instead")
+	masks := fs.String("masks", "", "write a .hcmask `file` covering the rest of the keyspace, for hashcat -a 3")
+	finished := fs.Int("finished", -1, "hashcat finished the first `n` lines of the .hcmask written from this position: move past them")
+	chunk := fs.String("chunk", "", "with -words: the chunk `file` hashcat -a 0 was running on")
+	words := fs.Int64("words", -1, "with -chunk: the `count` of words hashcat finished in it (its restore point, or --skip plus progress)")
+	save := fs.Bool("save", false, "record the new position in "+stateFile+", rounded down to a chunk boundary so the chunks stay aligned")
+	addKeyspaceFlags(fs)
+	addLayoutFlags(fs)
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	entriesPerFile = *perFile
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	if ks.Hybrid() {
+		return fmt.Errorf("%w: hashcat's hybrid attacks try the words in an order of their own, which positions of a -hybrid-dict run do not map onto", ErrConfig)
+	}
+	if ks.Masked() {
+		return fmt.Errorf("%w: a -mask run maps onto hashcat directly: give it the mask, with --skip at the position", ErrConfig)
+	}
+	symbols := ks.Symbols()
+	for _, s := range symbols {
+		if len(s) != 1 {
+			return fmt.Errorf("%w: hashcat charsets are bytes, and symbol %q is not one", ErrConfig, s)
+		}
+	}
+
+	// Where to start: a chunk and a word count, a word, an index, or the state.
+	var pos int64
+	switch {
+	case *chunk != "" || *words >= 0:
+		n, ok := chunkLayout.Number(*chunk)
+		if !ok || *words < 0 {
+			return fmt.Errorf("%w: -chunk takes a chunk name such as %s, with -words", ErrConfig, chunkName(1))
+		}
+		start := (n - 1) * entriesPerFile
+		if n < 1 || start >= total || *words > min(entriesPerFile, total-start) {
+			return fmt.Errorf("%w: %s has no %s words", ErrConfig, *chunk, fmtInt(*words))
+		}
+		pos = start + *words
+	case *fromWord != "":
+		if pos, err = ks.IndexOf(*fromWord); err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+	case *from >= 0:
+		if *from > total {
+			return fmt.Errorf("%w: -from %d is beyond the keyspace's %s words", ErrConfig, *from, fmtInt(total))
+		}
+		pos = *from
+	default:
+		if pos, err = readState(stateFile, total); err != nil {
+			return err
+		}
+	}
+
+	if *finished >= 0 && pos < total {
+		lines, err := hcmaskLines(ks, pos)
+		if err != nil {
+			return err
+		}
+		if *finished > len(lines) {
+			return fmt.Errorf("%w: -finished %d, but the masks from %s have %d lines", ErrConfig, *finished, fmtInt(pos), len(lines))
+		}
+		for _, m := range lines[:*finished] {
+			pos += m.size(len(symbols))
+		}
+	}
+	describePosition(ks, pos)
+
+	if *masks != "" && pos < total {
+		lines, err := hcmaskLines(ks, pos)
+		if err != nil {
+			return err
+		}
+		var b strings.Builder
+		for _, m := range lines {
+			b.WriteString(m.format(symbols) + "\n")
+		}
+		if err := os.WriteFile(*masks, []byte(b.String()), 0644); err != nil {
+			return diskError("write "+*masks, err)
+		}
+		fmt.Fprintf(os.Stderr, "🎭 Wrote %d masks to %s:  hashcat -a 3 HASHES %s\n", len(lines), *masks, *masks)
+		fmt.Fprintf(os.Stderr, "   After hashcat finishes n of them:  %s hashcat -from %d -finished n\n", os.Args[0], pos)
+	}
+	if *save {
+		aligned := pos / entriesPerFile * entriesPerFile
+		if pos == total {
+			aligned = total
+		}
+		if err := writeState(stateFile, aligned-1); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "💾 %s: resuming at %s (the start of %s)\n", stateFile, fmtInt(aligned), chunkName(int(aligned/entriesPerFile)+1))
+	}
+	return nil
+}
+
+// describePosition prints pos as a word, as a chunk line and as the hashcat
+// -a 0 flags that start there.
+func describePosition(ks *wordlist.Keyspace, pos int64) {
+	if pos >= total {
+		fmt.Fprintf(os.Stderr, "📍 Position %s: the whole keyspace is covered\n", fmtInt(pos))
+		return
+	}
+	word, _ := ks.WordAt(pos)
+	n := int(pos/entriesPerFile) + 1
+	skip := pos % entriesPerFile
+	fmt.Fprintf(os.Stderr, "📍 Position %s: %q, line %s of %s\n", fmtInt(pos), word, fmtInt(skip+1), chunkName(n))
+	fmt.Fprintf(os.Stderr, "   Wordlist:  hashcat -a 0 --skip %d HASHES %s, then the chunks after it\n", skip, chunkName(n))
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// A hash range export is laid out like the Pwned Passwords range API: the
+// hashes of a list, upper-case hex, go into one file per prefix of
+// -prefix-len hex digits, holding the rest of each hash and how often the
+// list has its word, "SUFFIX:COUNT" per CRLF-terminated line. A service
+// asked about a password looks up the file of its hash's prefix and
+// compares the suffixes itself, so the service never learns which
+// password was checked. Every prefix has a file, empty or not, so which
+// files exist gives nothing away either.
+
+// hashRangeBuckets is how many spill files the hashes are sorted into by
+// their first byte before each is sorted in memory.
+const hashRangeBuckets = 256
+
+// hashRangeRecord is a hash and its count; NTLM hashes use the first 16
+// bytes.
+type hashRangeRecord struct {
+	hash  [sha1.Size]byte
+	count int64
+}
+
+// hashFuncs are the hashes hash-ranges writes, by -hash name.
+var hashFuncs = map[string]struct {
+	size int
+	sum  func(dst *[sha1.Size]byte, word []byte)
+}{
+	"sha1": {sha1.Size, func(dst *[sha1.Size]byte, word []byte) { *dst = sha1.Sum(word) }},
+	"ntlm": {16, func(dst *[sha1.Size]byte, word []byte) { *dst = [sha1.Size]byte{}; copy(dst[:], ntlmHash(word)) }},
+}
+
+func hashRangesCmd(args []string) error {
+	fs := newToolFlags("hash-ranges", "list...")
+	hashName := fs.String("hash", "sha1", "hash the words with `function` sha1 or ntlm (MD4 of the UTF-16LE password)")
+	prefixLen := fs.Int("prefix-len", 5, "hex `digits` of the hash that name its file, from 2 to 6")
+	dir := fs.String("o", "ranges", "write the range files to this `directory`")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: hash-ranges needs at least one list (- for stdin)", ErrConfig)
+	}
+	h, ok := hashFuncs[*hashName]
+	if !ok {
+		return fmt.Errorf("%w: unknown -hash %q (want sha1 or ntlm)", ErrConfig, *hashName)
+	}
+	if *prefixLen < 2 || *prefixLen > 6 {
+		return fmt.Errorf("%w: -prefix-len %d is not from 2 to 6", ErrConfig, *prefixLen)
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return diskError("create "+*dir, err)
+	}
+	spill, err := os.MkdirTemp(*dir, ".buckets-")
+	if err != nil {
+		return diskError("create spill directory", err)
+	}
+	defer os.RemoveAll(spill)
+	started := time.Now()
+
+	// First pass: every hash goes to the spill file of its first byte.
+	buckets := make([]*bufio.Writer, hashRangeBuckets)
+	files := make([]*os.File, hashRangeBuckets)
+	for i := range files {
+		if files[i], err = os.Create(filepath.Join(spill, fmt.Sprintf("%02x", i))); err != nil {
+			return diskError("create spill file", err)
+		}
+		defer files[i].Close()
+		buckets[i] = bufio.NewWriterSize(files[i], 64<<10)
+	}
+	var sum [sha1.Size]byte
+	st, err := forEachLine(fs.Args(), "hashing", func(word []byte) error {
+		h.sum(&sum, word)
+		_, err := buckets[sum[0]].Write(sum[:h.size])
+		return err
+	})
+	if err != nil {
+		return diskError("write spill file", err)
+	}
+	for _, b := range buckets {
+		if err := b.Flush(); err != nil {
+			return diskError("write spill file", err)
+		}
+	}
+
+	// Second pass: each bucket is sorted and counted, and its prefixes
+	// written in order, the empty ones too.
+	prefixBits := 4 * *prefixLen
+	perBucket := 1 << (prefixBits - 8)
+	var distinct int64
+	for b, f := range files {
+		records, err := readHashBucket(f, h.size)
+		if err != nil {
+			return diskError("read spill file", err)
+		}
+		f.Close()
+		os.Remove(f.Name())
+		distinct += int64(len(records))
+		next := 0
+		for p := range perBucket {
+			prefix := b<<(prefixBits-8) | p
+			end := next
+			for end < len(records) && hashPrefix(records[end].hash, prefixBits) == prefix {
+				end++
+			}
+			if err := writeHashRange(*dir, prefix, *prefixLen, records[next:end], h.size); err != nil {
+				return err
+			}
+			next = end
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "🔐 Wrote the %s hashes of %s words of %s into %s range files of %d-digit prefixes in %s\n",
+		*hashName, fmtInt(st.lines), describeLists(fs.Args()), fmtInt(int64(1)<<prefixBits), *prefixLen, *dir)
+	fmt.Fprintf(os.Stderr, "   %s distinct hashes, %s a file on average, in %s\n",
+		fmtInt(distinct), fmtFloat(float64(distinct)/float64(int64(1)<<prefixBits), 2), fmtDuration(time.Since(started)))
+	return nil
+}
+
+// readHashBucket reads a spill file of size-byte hashes and returns them
+// sorted, each once with its count.
+func readHashBucket(f *os.File, size int) ([]hashRangeRecord, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	records := make([]hashRangeRecord, 0, len(data)/size)
+	for i := 0; i+size <= len(data); i += size {
+		var r hashRangeRecord
+		copy(r.hash[:], data[i:i+size])
+		records = append(records, r)
+	}
+	slices.SortFunc(records, func(a, b hashRangeRecord) int { return bytes.Compare(a.hash[:], b.hash[:]) })
+	out := records[:0]
+	for _, r := range records {
+		if len(out) > 0 && out[len(out)-1].hash == r.hash {
+			out[len(out)-1].count++
+			continue
+		}
+		r.count = 1
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// hashPrefix is the first n bits of hash.
+func hashPrefix(hash [sha1.Size]byte, n int) int {
+	return int(binary.BigEndian.Uint32(hash[:4]) >> (32 - n))
+}
+
+// writeHashRange writes the file of one prefix: each hash's hex digits
+// after the prefix and its count.
+func writeHashRange(dir string, prefix, digits int, records []hashRangeRecord, size int) error {
+	name := fmt.Sprintf("%0*X", digits, prefix)
+	var b bytes.Buffer
+	hexHash := make([]byte, 2*size)
+	for _, r := range records {
+		hex.Encode(hexHash, r.hash[:size])
+		b.Write(bytes.ToUpper(hexHash[digits:]))
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatInt(r.count, 10))
+		b.WriteString("\r\n")
+	}
+	return diskError("write "+name, os.WriteFile(filepath.Join(dir, name), b.Bytes(), 0o644))
+}
+
+// ntlmHash is the NT hash of password: MD4 of its UTF-16LE encoding.
+func ntlmHash(password []byte) []byte {
+	units := utf16.Encode([]rune(string(password)))
+	msg := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(msg[2*i:], u)
+	}
+	return md4(msg)
+}
+
+// md4 is RFC 1320's MD4, which nothing in the standard library provides
+// and NTLM still uses.
+func md4(msg []byte) []byte {
+	a0, b0, c0, d0 := uint32(0x67452301), uint32(0xefcdab89), uint32(0x98badcfe), uint32(0x10325476)
+	padded := append(slices.Clip(msg), 0x80)
+	for len(padded)%64 != 56 {
+		padded = append(padded, 0)
+	}
+	padded = binary.LittleEndian.AppendUint64(padded, uint64(len(msg))*8)
+
+	var x [16]uint32
+	for block := padded; len(block) > 0; block = block[64:] {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(block[4*i:])
+		}
+		a, b, c, d := a0, b0, c0, d0
+		for _, i := range [4]int{0, 4, 8, 12} {
+			a = bits.RotateLeft32(a+(b&c|^b&d)+x[i], 3)
+			d = bits.RotateLeft32(d+(a&b|^a&c)+x[i+1], 7)
+			c = bits.RotateLeft32(c+(d&a|^d&b)+x[i+2], 11)
+			b = bits.RotateLeft32(b+(c&d|^c&a)+x[i+3], 19)
+		}
+		for _, i := range [4]int{0, 1, 2, 3} {
+			a = bits.RotateLeft32(a+(b&c|b&d|c&d)+x[i]+0x5a827999, 3)
+			d = bits.RotateLeft32(d+(a&b|a&c|b&c)+x[i+4]+0x5a827999, 5)
+			c = bits.RotateLeft32(c+(d&a|d&b|a&b)+x[i+8]+0x5a827999, 9)
+			b = bits.RotateLeft32(b+(c&d|c&a|d&a)+x[i+12]+0x5a827999, 13)
+		}
+		for _, i := range [4]int{0, 2, 1, 3} {
+			a = bits.RotateLeft32(a+(b^c^d)+x[i]+0x6ed9eba1, 3)
+			d = bits.RotateLeft32(d+(a^b^c)+x[i+8]+0x6ed9eba1, 9)
+			c = bits.RotateLeft32(c+(d^a^b)+x[i+4]+0x6ed9eba1, 11)
+			b = bits.RotateLeft32(b+(c^d^a)+x[i+12]+0x6ed9eba1, 15)
+		}
+		a0, b0, c0, d0 = a0+a, b0+b, c0+c, d0+d
+	}
+	sum := make([]byte, 16)
+	for i, v := range [4]uint32{a0, b0, c0, d0} {
+		binary.LittleEndian.PutUint32(sum[4*i:], v)
+	}
+	return sum
+}
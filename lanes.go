@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// A run can feed a cracker the likely words first without giving up the
+// exhaustive archive: -fast-lane names a stream, stdout or tcp://host:port,
+// that gets the words a model from learn finds likely enough, or that a
+// password policy allows, likeliest first, while every word still goes to
+// -output in keyspace order. One pass, two orderings. The fast lane ranks
+// the words it picks -lane-window at a time, so a word waits at most that
+// many picks to be sent. It is best effort: it is not in the state, so a
+// resumed run sends the words of the chunk it was interrupted in again.
+
+// fastLane is the -fast-lane flags and, once openFastLane has run, the
+// stream and what picks its words.
+var fastLane struct {
+	target  string
+	model   string
+	minProb float64
+	policy  string
+	window  int
+
+	sink   outputSink
+	w      chunk // the stream, written one whole window of words at a time
+	mu     sync.Mutex
+	pick   func(word []byte) (score float64, ok bool)
+	queue  []laneWord // picked and not yet sent, under mu
+	words  int64      // sent so far, under mu
+	ranked bool       // whether the score is a probability to sort by
+}
+
+// laneWord is a word the fast lane picked, with the chance -lane-model
+// gives it.
+type laneWord struct {
+	word  string
+	score float64
+}
+
+// addLaneFlags registers the -fast-lane flags on fs.
+func addLaneFlags(fs *flag.FlagSet) {
+	fs.StringVar(&fastLane.target, "fast-lane", "", "also send the likely words, as -lane-model and -lane-policy pick them, to this `stream` (stdout or tcp://host:port) as they are generated")
+	fs.StringVar(&fastLane.model, "lane-model", "", "the fast lane takes the words this `model` from learn gives at least -lane-min-probability")
+	fs.Float64Var(&fastLane.minProb, "lane-min-probability", 1e-9, "the `probability` a word needs under -lane-model")
+	fs.StringVar(&fastLane.policy, "lane-policy", "", "the fast lane takes the words this `policy` (from the policy tool) allows; with -lane-model, those it also allows")
+	fs.IntVar(&fastLane.window, "lane-window", 10000, "the fast lane sends the `words` it picks this many at a time, the likeliest under -lane-model first")
+}
+
+// openFastLane connects the fast lane, if there is one.
+func openFastLane() error {
+	if fastLane.target == "" {
+		if fastLane.model != "" || fastLane.policy != "" {
+			return fmt.Errorf("%w: -lane-model and -lane-policy pick the words of -fast-lane", ErrConfig)
+		}
+		return nil
+	}
+	if fastLane.window < 1 {
+		return fmt.Errorf("%w: -lane-window must be at least 1", ErrConfig)
+	}
+	// Each pick returns the chance -lane-model gives the word, 0 without one.
+	var picks []func([]byte) (float64, bool)
+	if fastLane.model != "" {
+		model, err := loadStyleModel(fastLane.model)
+		if err != nil {
+			return err
+		}
+		model.probability("") // fills in the totals before the workers share it
+		minProb := fastLane.minProb
+		picks = append(picks, func(word []byte) (float64, bool) {
+			p := model.probability(string(word))
+			return p, p >= minProb
+		})
+	}
+	if fastLane.policy != "" {
+		p, err := loadPolicy(fastLane.policy)
+		if err != nil {
+			return err
+		}
+		check := p.compile()
+		picks = append(picks, func(word []byte) (float64, bool) { return 0, check.check(word) == policyOK })
+	}
+	if len(picks) == 0 {
+		return fmt.Errorf("%w: -fast-lane needs -lane-model or -lane-policy to pick its words", ErrConfig)
+	}
+	fastLane.ranked = fastLane.model != ""
+	fastLane.pick = func(word []byte) (float64, bool) {
+		var score float64
+		for _, pick := range picks {
+			p, ok := pick(word)
+			if !ok {
+				return 0, false
+			}
+			score += p
+		}
+		return score, true
+	}
+	sink, err := newTarget(fastLane.target, "")
+	if err == nil && sink.keeps() || fastLane.target == "null" {
+		err = fmt.Errorf("%w: -fast-lane %s is not a stream (want stdout or tcp://host:port)", ErrConfig, fastLane.target)
+	}
+	if err != nil {
+		return err
+	}
+	fastLane.sink = sink
+	fastLane.w, err = sink.open("fast lane")
+	return err
+}
+
+// closeFastLane sends the words still queued, disconnects the fast lane and
+// reports how many words it took.
+func closeFastLane() (int64, error) {
+	if fastLane.sink == nil {
+		return 0, nil
+	}
+	fastLane.mu.Lock()
+	err := sendLane()
+	fastLane.mu.Unlock()
+	if _, cerr := fastLane.w.commit(); err == nil {
+		err = cerr
+	}
+	if cerr := fastLane.sink.close(); err == nil {
+		err = cerr
+	}
+	fastLane.sink, fastLane.w = nil, nil
+	return fastLane.words, err
+}
+
+// describeLane says which words the fast lane takes.
+func describeLane() string {
+	var picks []string
+	if fastLane.model != "" {
+		picks = append(picks, fmt.Sprintf("%s gives at least %g", fastLane.model, fastLane.minProb))
+	}
+	if fastLane.policy != "" {
+		picks = append(picks, fastLane.policy+" allows")
+	}
+	lane := strings.Join(picks, " and ")
+	if fastLane.model != "" {
+		lane += fmt.Sprintf(", likeliest first %d at a time", fastLane.window)
+	}
+	return lane
+}
+
+// sendLane sends the queued words to the fast lane, likeliest first, with
+// fastLane.mu held. Words the model gives the same chance keep their
+// keyspace order.
+func sendLane() error {
+	q := fastLane.queue
+	if len(q) == 0 {
+		return nil
+	}
+	if fastLane.ranked {
+		slices.SortStableFunc(q, func(a, b laneWord) int { return cmp.Compare(b.score, a.score) })
+	}
+	var out bytes.Buffer
+	for _, lw := range q {
+		out.WriteString(lw.word)
+		out.WriteByte('\n')
+	}
+	fastLane.queue = q[:0]
+	fastLane.words += int64(len(q))
+	_, err := fastLane.w.Write(out.Bytes())
+	return err
+}
+
+// laneSplit returns w, or with -fast-lane a writer that also sends the
+// fast lane the newline-terminated words written to it that it picks.
+func laneSplit(w io.Writer) io.Writer {
+	if fastLane.w == nil {
+		return w
+	}
+	return &laneWriter{w: w}
+}
+
+// laneWriter passes every write on to w, and the picked words, once whole,
+// to the fast lane's queue, which it sends once -lane-window words are in
+// it. A write ending inside a word keeps the rest until the next.
+type laneWriter struct {
+	w      io.Writer
+	tail   []byte // an unfinished word
+	picked []laneWord
+}
+
+func (lw *laneWriter) Write(p []byte) (int, error) {
+	n, err := lw.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+	data := p
+	if len(lw.tail) > 0 {
+		lw.tail = append(lw.tail, p...)
+		data = lw.tail
+	}
+	lw.picked = lw.picked[:0]
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if score, ok := fastLane.pick(data[:i]); ok {
+			lw.picked = append(lw.picked, laneWord{string(data[:i]), score})
+		}
+		data = data[i+1:]
+	}
+	lw.tail = append(lw.tail[:0], data...)
+	if len(lw.picked) > 0 {
+		fastLane.mu.Lock()
+		fastLane.queue = append(fastLane.queue, lw.picked...)
+		if len(fastLane.queue) >= fastLane.window {
+			err = sendLane()
+		}
+		fastLane.mu.Unlock()
+		if err != nil {
+			return n, fmt.Errorf("fast lane: %w", err)
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// passwordColumns are the header names of the password column in the CSV
+// exports of browsers (Chrome, Edge, Firefox, Safari) and password managers
+// (KeePass, KeePassXC, Bitwarden, 1Password), compared case-insensitively.
+var passwordColumns = []string{"password", "login_password"}
+
+// readPasswordExport returns the passwords in a CSV export, found by the
+// name of their column, or in a plain list with one per line.
+func readPasswordExport(path string) ([]string, error) {
+	if !strings.EqualFold(filepath.Ext(path), ".csv") {
+		return readPolicyExamples(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1 // notes with stray commas are still rows
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrConfig, path, err)
+	}
+	col := -1
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if slices.ContainsFunc(passwordColumns, func(c string) bool { return strings.EqualFold(c, name) }) {
+			col = i
+			break
+		}
+	}
+	if col < 0 {
+		return nil, fmt.Errorf("%w: %s has no password column among %q", ErrConfig, path, header)
+	}
+	var passwords []string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			return passwords, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrConfig, path, err)
+		}
+		if col < len(rec) && rec[col] != "" {
+			passwords = append(passwords, rec[col])
+		}
+	}
+}
+
+func learnCmd(args []string) error {
+	fs := newToolFlags("learn", "export.csv...")
+	out := fs.String("o", "style.json", "write the model to this `file` (readable by you only)")
+	add := fs.Bool("add", false, "add to the model already in -o instead of replacing it")
+	masks := fs.String("masks", "", "also write the masks to this `file`, likeliest per candidate first, one per line")
+	reuse := fs.Bool("keep-reuse", false, "count a password once per entry; by default a password reused across sites counts once")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: learn needs at least one export", ErrConfig)
+	}
+
+	m := newStyleModel()
+	if *add {
+		var err error
+		if m, err = loadStyleModel(*out); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if m == nil {
+			m = newStyleModel()
+		}
+	}
+	seen := make(map[string]bool)
+	var entries, distinct int
+	for _, path := range fs.Args() {
+		passwords, err := readPasswordExport(path)
+		if err != nil {
+			return err
+		}
+		entries += len(passwords)
+		if !*reuse {
+			passwords = slices.DeleteFunc(passwords, func(p string) bool {
+				dup := seen[p]
+				seen[p] = true
+				return dup
+			})
+		}
+		distinct += len(passwords)
+		m.train(passwords)
+		fmt.Fprintf(os.Stderr, "🔐 %s: %d passwords\n", path, len(passwords))
+	}
+	if m.Passwords == 0 {
+		return fmt.Errorf("%w: the exports hold no passwords", ErrConfig)
+	}
+	if err := m.save(*out); err != nil {
+		return err
+	}
+	if *masks != "" {
+		var b strings.Builder
+		for _, mc := range m.rankedMasks() {
+			b.WriteString(mc.Mask + "\n")
+		}
+		if err := os.WriteFile(*masks, []byte(b.String()), 0600); err != nil {
+			return diskError("write masks", err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "🧠 Learned from %d passwords", m.Passwords)
+	if !*reuse && distinct < entries {
+		fmt.Fprintf(os.Stderr, " (%d entries; reused ones counted once)", entries)
+	}
+	fmt.Fprintf(os.Stderr, ", wrote %s\n", *out)
+	lengths := slices.SortedFunc(maps.Keys(m.Lengths), func(a, b int) int {
+		if c := cmp.Compare(m.Lengths[b], m.Lengths[a]); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+	fmt.Fprint(os.Stderr, "   Lengths:")
+	for _, l := range lengths[:min(len(lengths), 5)] {
+		fmt.Fprintf(os.Stderr, "  %d (%s%%)", l, fmtFloat(percentOf(m.Lengths[l], m.Passwords), 1))
+	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "   Likeliest masks per candidate:")
+	for _, mc := range m.rankedMasks()[:min(len(m.Masks), 5)] {
+		fmt.Fprintf(os.Stderr, "   %-28s %s%% of passwords, %s candidates\n",
+			mc.Mask, fmtFloat(percentOf(mc.Count, m.Passwords), 1), fmtCount(maskSize(mustParseMask(mc.Mask))))
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limits of the list tools' line reader. A line longer than maxListLine is
+// no password; it is skipped and counted rather than failing the run.
+const (
+	listBufferSize = 1 << 20
+	maxListLine    = 64 << 10
+)
+
+// listStats is what a pass over some lists read.
+type listStats struct {
+	lines    int64
+	bytes    int64 // of the files as stored, compressed or not
+	tooLong  int64 // lines skipped for exceeding maxListLine
+	crlf     int64 // lines that ended in CRLF
+	duration time.Duration
+}
+
+// forEachLine streams the lines of paths ("-" is stdin; .gz and .zst files
+// are decompressed) to fn without their line ending, drawing a progress line
+// labelled verb on stderr. The slice passed to fn is only valid during the
+// call.
+func forEachLine(paths []string, verb string, fn func(line []byte) error) (listStats, error) {
+	return forEachLineStatus(paths, verb, nil, fn)
+}
+
+// forEachLineStatus is forEachLine with status, when not nil, adding to each
+// progress line and record.
+func forEachLineStatus(paths []string, verb string, status func() string, fn func(line []byte) error) (listStats, error) {
+	return forEachLineFrom(paths, verb, status, listOffset{}, func(line []byte, _ listOffset) error { return fn(line) })
+}
+
+// listOffset is a place in a pass over lists: the index of a list, and the
+// bytes (decompressed) and lines of it before the place.
+type listOffset struct {
+	list  int
+	bytes int64
+	lines int64
+}
+
+// forEachLineFrom is forEachLineStatus starting at from instead of the
+// start of the first list, and telling fn the place just past each line. A
+// plain file is entered with a seek; a compressed list or stdin is read up
+// to the place and the bytes before it dropped.
+func forEachLineFrom(paths []string, verb string, status func() string, from listOffset, fn func(line []byte, next listOffset) error) (listStats, error) {
+	var st listStats
+	started := time.Now()
+	prog := newListProgress(verb, paths)
+	prog.status = status
+	defer prog.finish()
+	for i := from.list; i < len(paths); i++ {
+		at := listOffset{list: i}
+		if i == from.list {
+			at = from
+		}
+		if err := st.read(paths[i], prog, at, fn); err != nil {
+			return st, err
+		}
+	}
+	st.duration = time.Since(started)
+	return st, nil
+}
+
+func (st *listStats) read(path string, prog *listProgress, at listOffset, fn func([]byte, listOffset) error) error {
+	in := io.Reader(os.Stdin)
+	name := strings.TrimSuffix(path, partSuffix)
+	compressed := strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".zst")
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+		defer f.Close()
+		if at.bytes > 0 && !compressed {
+			if _, err := f.Seek(at.bytes, io.SeekStart); err != nil {
+				return fmt.Errorf("%w: %s: %w", ErrConfig, path, err)
+			}
+			st.bytes += at.bytes
+		}
+		in = f
+	}
+	// Progress follows the bytes taken from the file itself, so it is right
+	// for compressed lists too.
+	counted := &countingReader{r: in}
+	r, err := decompress(path, counted)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrConfig, path, err)
+	}
+	defer r.Close()
+	if at.bytes > 0 && (compressed || path == "-") {
+		if _, err := io.CopyN(io.Discard, r, at.bytes); err != nil {
+			return fmt.Errorf("%w: %s: skipping to byte %d: %w", ErrConfig, path, at.bytes, err)
+		}
+	}
+	br := bufio.NewReaderSize(r, listBufferSize)
+	skipping := false
+	for {
+		line, err := br.ReadSlice('\n')
+		at.bytes += int64(len(line))
+		if err == bufio.ErrBufferFull {
+			if !skipping {
+				st.tooLong++
+			}
+			skipping = true
+			continue
+		}
+		switch {
+		case skipping:
+			// The end of a line too long for the buffer.
+			skipping = false
+		case len(line) > maxListLine:
+			st.tooLong++
+		case len(line) > 0:
+			line = bytes.TrimSuffix(line, []byte("\n"))
+			if n := len(line); n > 0 && line[n-1] == '\r' {
+				line = line[:n-1]
+				st.crlf++
+			}
+			st.lines++
+			at.lines++
+			if err := fn(line, at); err != nil {
+				return err
+			}
+		}
+		st.bytes += counted.take()
+		prog.update(st)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrConfig, path, err)
+		}
+	}
+}
+
+// countingReader counts the bytes read through it since the last take.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) take() int64 {
+	n := c.n
+	c.n = 0
+	return n
+}
+
+// listProgress reports a list tool's pass on stderr: redrawn every 150ms on
+// a terminal, logged every 30s otherwise, like the generator's progress.
+type listProgress struct {
+	verb     string
+	status   func() string // more to show, or nil
+	size     int64         // of all the lists, 0 when unknown (stdin)
+	plain    bool
+	interval time.Duration
+	started  time.Time
+	last     time.Time
+	drawn    bool
+}
+
+func newListProgress(verb string, paths []string) *listProgress {
+	p := &listProgress{verb: verb, interval: 150 * time.Millisecond, started: time.Now()}
+	p.last = p.started
+	for _, path := range paths {
+		fi, err := os.Stat(path)
+		if path == "-" || err != nil || !fi.Mode().IsRegular() {
+			p.size = 0
+			break
+		}
+		p.size += fi.Size()
+	}
+	if !isTerminal(os.Stderr) {
+		p.plain, p.interval = true, 30*time.Second
+	}
+	return p
+}
+
+func (p *listProgress) update(st *listStats) {
+	now := time.Now()
+	if now.Sub(p.last) < p.interval {
+		return
+	}
+	p.last = now
+	rate := float64(st.bytes) / now.Sub(p.started).Seconds()
+	var status string
+	if p.status != nil {
+		status = p.status()
+	}
+	if p.plain {
+		attrs := []any{"lines", st.lines, "bytes", st.bytes, "size", p.size, "bytes_per_sec", int64(rate)}
+		if status != "" {
+			attrs = append(attrs, "stages", status)
+		}
+		slog.Info(p.verb, attrs...)
+		return
+	}
+	var done string
+	if p.size > 0 {
+		percent := float64(st.bytes) / float64(p.size) * 100
+		eta := time.Duration(float64(p.size-st.bytes) / rate * float64(time.Second))
+		done = fmt.Sprintf(" %s%% of %s │ ETA: %s", fmtFloat(percent, 2), fmtBytes(p.size), fmtDuration(eta))
+	}
+	if status != "" {
+		done += " │ " + status
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%s %s lines │ %s │ %s/s%s",
+		capitalize(p.verb), fmtInt(st.lines), fmtBytes(st.bytes), fmtBytes(int64(rate)), done)
+	p.drawn = true
+}
+
+// finish clears the progress line so the tool's report starts clean.
+func (p *listProgress) finish() {
+	if p.drawn {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+}
+
+// listOutput is a buffered output for a list tool's lines.
+type listOutput struct {
+	w     *bufio.Writer
+	wc    io.WriteCloser
+	model *styleModel // when set, each line gets a tab and its probability
+}
+
+func newListOutput(path string) (*listOutput, error) {
+	wc, err := createOutput(path)
+	if err != nil {
+		return nil, err
+	}
+	return &listOutput{w: bufio.NewWriterSize(wc, listBufferSize), wc: wc}, nil
+}
+
+func (o *listOutput) writeLine(line []byte) error {
+	o.w.Write(line)
+	if o.model != nil {
+		o.w.WriteByte('\t')
+		o.w.Write(strconv.AppendFloat(o.w.AvailableBuffer(), o.model.probability(string(line)), 'g', 6, 64))
+	}
+	if err := o.w.WriteByte('\n'); err != nil {
+		return diskError("write output", err)
+	}
+	return nil
+}
+
+func (o *listOutput) close() error {
+	err := o.w.Flush()
+	if cerr := o.wc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return diskError("write output", err)
+	}
+	return nil
+}
+
+// describeLists names the inputs in reports.
+func describeLists(paths []string) string {
+	if len(paths) == 1 {
+		return paths[0]
+	}
+	return fmt.Sprintf("%d lists (%s)", len(paths), strings.Join(paths, ", "))
+}
+
+// percentOf is n as a percentage of total, 0 for an empty total.
+func percentOf(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
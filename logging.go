@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// logConfig is how -log-* flags set up the default slog logger.
+type logConfig struct {
+	level   slog.Level
+	format  string // text or json
+	file    string // optional log file, rotated by size
+	maxSize int64  // rotate once the file would grow past this many bytes
+	backups int    // rotated files to keep: file.1 (newest) .. file.N
+}
+
+// setupLogging installs the default logger. Records always go to stderr and,
+// with -log-file, also to a rotating file.
+func setupLogging(cfg logConfig) (io.Closer, error) {
+	var out io.Writer = consoleLog{}
+	var closer io.Closer = io.NopCloser(nil)
+	if cfg.file != "" {
+		f, err := openRotating(cfg.file, cfg.maxSize, cfg.backups)
+		if err != nil {
+			return nil, err
+		}
+		out, closer = io.MultiWriter(out, f), f
+	}
+
+	opts := &slog.HandlerOptions{Level: cfg.level}
+	switch cfg.format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(out, opts)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(out, opts)))
+	default:
+		return nil, fmt.Errorf("%w: unknown -log-format %q (want text or json)", ErrConfig, cfg.format)
+	}
+	return closer, nil
+}
+
+// consoleLog writes to stderr. On a terminal it first clears the line so a
+// record never lands on the end of a half-drawn progress bar.
+type consoleLog struct{}
+
+func (consoleLog) Write(p []byte) (int, error) {
+	if isTerminal(os.Stderr) {
+		os.Stderr.WriteString("\r\033[K")
+	}
+	return os.Stderr.Write(p)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// rotatingFile is an append-only log file that is renamed to path.1 (and
+// older copies shifted up to path.N) once it reaches its size limit.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	f       *os.File
+	size    int64
+}
+
+func openRotating(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return diskError("open log", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return diskError("open log", err)
+	}
+	r.f, r.size = f, fi.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	r.f.Close()
+	if r.backups < 1 {
+		os.Remove(r.path)
+	} else {
+		os.Remove(fmt.Sprintf("%s.%d", r.path, r.backups))
+		for i := r.backups - 1; i >= 1; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+		}
+		os.Rename(r.path, r.path+".1")
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// levelFlag parses -log-level names case-insensitively.
+type levelFlag struct{ l *slog.Level }
+
+func (f levelFlag) String() string {
+	if f.l == nil {
+		return "info"
+	}
+	return strings.ToLower(f.l.String())
+}
+
+func (f levelFlag) Set(s string) error { return f.l.UnmarshalText([]byte(s)) }
@@ -2,208 +2,828 @@ package main
 
 import (
 	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
+
+	"main.go/wordlist"
 )
 
 const (
-	entriesPerFile = 2_000_000 // 2 million combinations per file
-	batchSize      = 250_000   // Optimized batch for smooth progress + speed
-	maxLength      = 4         // Lengths 1 to 5
-	commitEvery    = 20        // Git commit & push every 10 files
+	batchSize   = 250_000 // Optimized batch for smooth progress + speed
+	commitEvery = 20      // default -git-every: commit & push every 20 files
+	pushTimeout = 5 * time.Minute
+	partSuffix  = ".part" // marks a chunk that is still being written
+
+	// defaultCharset: a-z, A-Z, 0-9, _, .
+	defaultCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_."
 )
 
 var (
-	// Charset: a-z, A-Z, 0-9, _, .
-	charset = []byte("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_.")
-	N       = len(charset)
-	pow     = [6]int64{1, 1, 1, 1, 1, 1} // N^0 to N^5
-	cum     = [6]int64{0, 0, 0, 0, 0, 0} // Cumulative totals up to length l
+	entriesPerFile int64  = 2_000_000 // 2 million combinations per file
+	maxFileSize    string             // -max-file-size: sets entriesPerFile from the keyspace
+
+	// stateFile keeps the position; a node of a split run keeps its own
+	// (see selectNode).
+	stateFile = "state.txt"
+
+	charset = defaultCharset // -charset; each character is one symbol
 	total   int64
+
+	// Word lengths, -min-len to -max-len symbols
+	minLength = 1
+	maxLength = 4
 )
 
-func initTotals() {
-	p := int64(1)
-	for l := 1; l <= maxLength; l++ {
-		p *= int64(N)
-		pow[l] = p
-		cum[l] = cum[l-1] + p
-	}
-	total = cum[maxLength]
+// chunkLayout names the chunks, from -out-dir, -file-template and -per-dir,
+// which newKeyspace turns into it once the lengths are known.
+var (
+	chunkLayout = wordlist.DefaultLayout
+	layoutFlags = struct {
+		dir, template string
+		perDir        int64
+	}{template: wordlist.DefaultLayout.Template}
+)
+
+// addLayoutFlags registers -out-dir, -file-template and -per-dir on fs.
+func addLayoutFlags(fs *flag.FlagSet) {
+	fs.StringVar(&layoutFlags.dir, "out-dir", "", "write the chunks into this `directory` instead of the current one")
+	fs.StringVar(&layoutFlags.template, "file-template", layoutFlags.template, "name the chunks by this `template`: {num} the chunk number (six digits), {minlen} and {maxlen} the lengths")
+	fs.Int64Var(&layoutFlags.perDir, "per-dir", 0, "put every `n` chunks in a numbered subdirectory of -out-dir, 00/, 01/... (0 for none)")
 }
 
-func getCombo(pos int64) string {
-	// Find length
-	var L int
-	for l := 1; l <= maxLength; l++ {
-		if pos < cum[l] {
-			L = l
-			break
-		}
+// loadLayout builds chunkLayout from the flags.
+func loadLayout() error {
+	template := strings.NewReplacer("{minlen}", strconv.Itoa(minLength), "{maxlen}", strconv.Itoa(maxLength)).Replace(layoutFlags.template)
+	l, err := wordlist.NewLayout(filepath.ToSlash(layoutFlags.dir), template, layoutFlags.perDir)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrConfig, err)
 	}
-	offset := pos - cum[L-1]
+	chunkLayout = l
+	return nil
+}
 
-	// Build string efficiently
-	s := make([]byte, L)
-	for j := L - 1; j >= 0; j-- {
-		s[j] = charset[offset%int64(N)]
-		offset /= int64(N)
+// describeLayout shows the chunk names, such as out/NN/part_XXXXXX_1-6.txt.
+func describeLayout() string {
+	name := strings.Replace(chunkLayout.Template, "{num}", "XXXXXX", 1) + chunkExt
+	if chunkLayout.PerDir > 0 {
+		name = path.Join("NN", name)
 	}
-	return string(s)
+	return path.Join(chunkLayout.Dir, name)
 }
 
-func gitCommitAndPush(filesCompleted int) {
-	fmt.Printf("\n🔄 Committing and pushing progress (%d files completed)...\n", filesCompleted)
-
-	commands := []struct {
-		name string
-		args []string
-	}{
-		{"git add", []string{"add", "."}},
-		{"git commit", []string{"commit", "-m", fmt.Sprintf("Wordlist progress: added files up to combos_%06d.txt (%d files)", filesCompleted, filesCompleted)}},
-		{"git push", []string{"push", "origin", "main"}},
+// layoutSpec is the chunk layout as a state records it; "" for the default
+// one, as states from before -file-template have it.
+func layoutSpec() string {
+	if chunkLayout.Dir == "" && chunkLayout.PerDir == 0 && chunkLayout.Template == wordlist.DefaultLayout.Template {
+		return ""
 	}
+	return fmt.Sprintf("%s, %d a directory", path.Join(chunkLayout.Dir, chunkLayout.Template), chunkLayout.PerDir)
+}
 
-	for _, cmd := range commands {
-		c := exec.Command("git", cmd.args...)
-		c.Stdout = os.Stdout
-		c.Stderr = os.Stderr
-		if err := c.Run(); err != nil {
-			fmt.Printf("⚠️  %s failed: %v\n", cmd.name, err)
-			return // Stop on failure (e.g. auth or network issue)
-		}
-	}
-	fmt.Println("✅ Successfully committed and pushed!\n")
+// chunkName is the path of the fileNum'th chunk, counting from 1.
+func chunkName(fileNum int) string {
+	return chunkLayout.Name(int64(fileNum)) + chunkExt
 }
 
-func main() {
-	initTotals()
+// chunkPattern matches every chunk name.
+func chunkPattern() string {
+	return chunkLayout.Pattern() + chunkExt
+}
 
-	fmt.Println("╔════════════════════════════════════════════════════════════╗")
-	fmt.Println("║              Alphanumeric + _ . Wordlist Generator         ║")
-	fmt.Println("╚════════════════════════════════════════════════════════════╝")
-	fmt.Printf("Charset   : a-z A-Z 0-9 _ .  (%d characters)\n", N)
-	fmt.Printf("Lengths   : 1 to %d characters\n", maxLength)
-	fmt.Printf("Total     : %,d combinations (~%.3f billion)\n", total, float64(total)/1e9)
-	fmt.Printf("Per file  : %,d entries\n", entriesPerFile)
-	fmt.Printf("Files     : ~%d total\n", (total+entriesPerFile-1)/entriesPerFile)
-	fmt.Println("────────────────────────────────────────────────────────────\n")
-
-	stateFile := "state.txt"
-	var currentPos int64
+// countingWriter counts the bytes that reach w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
 
-	if data, err := os.ReadFile(stateFile); err == nil {
-		currentPos, _ = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
-		currentPos++
-		donePercent := float64(currentPos-1) / float64(total) * 100
-		fmt.Printf("📂 Resuming from position %,d (%.4f%% complete)\n\n", currentPos-1, donePercent)
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeChunk generates the words at positions [start, end) into the chunk
+// called name of out and reports what out stored. With from, it continues
+// the partial chunk an interrupted run left instead of starting over.
+//
+// When the run is interrupted, a sink that can keep an unfinished chunk
+// gets the words written so far, and the error is a *cutShort saying how
+// far they go.
+func writeChunk(ctx context.Context, ks *wordlist.Keyspace, out outputSink, name string, fileNum int, start, end int64, from *partial, cp *checkpointer) (stored, error) {
+	var c chunk
+	var err error
+	if from != nil {
+		start = from.pos
+		c, err = out.(resumer).resume(name, from.size)
 	} else {
-		fmt.Println("🚀 Starting fresh generation...\n")
+		c, err = out.open(name)
+	}
+	if err != nil {
+		return stored{}, err
+	}
+	defer c.abort()
+	words, err := ks.Range(start, end)
+	if err != nil {
+		return stored{}, err
+	}
+	slog.Debug("writing chunk", "file", name, "start", start, "end", end)
+	raw := &countingWriter{w: c} // progress counts uncompressed bytes
+	writer := bufio.NewWriter(raw)
+	ruled := mangle(laneSplit(writer))
+	k, keeps := c.(keeper)
+	if cp != nil {
+		cp.start(start)
 	}
 
-	startTime := time.Now()
-	lastUpdate := startTime
-	var generatedSinceLast int64
+	var reported int64
+	for pos := start; pos < end; {
+		n, err := words.WriteN(ctx, ruled, min(batchSize, end-pos))
+		pos = words.Pos()
+		if err != nil {
+			if ctx.Err() == nil {
+				return stored{}, diskError("write "+name, err)
+			}
+			// WriteN stops between whole words: flushed, the chunk holds
+			// exactly the words before pos.
+			if keeps && pos > start && writer.Flush() == nil {
+				if size, kerr := k.keep(); kerr == nil {
+					return stored{}, &cutShort{partial{name, pos, size}, err}
+				}
+			}
+			return stored{}, err
+		}
+		events.emit(event{kind: evTick, pos: pos, n: n, bytes: raw.n - reported, fileNum: fileNum, file: name})
+		reported = raw.n
+		if keeps && cp != nil && pos < end && cp.due(pos) {
+			if err := writer.Flush(); err != nil {
+				return stored{}, diskError("write "+name, err)
+			}
+			size, err := k.checkpoint()
+			if err == nil {
+				err = cp.save(partial{name, pos, size})
+			}
+			if err != nil {
+				return stored{}, err
+			}
+		}
+	}
 
-	filesCompleted := int(currentPos / entriesPerFile)
+	if err := writer.Flush(); err != nil {
+		return stored{}, diskError("write "+name, err)
+	}
+	return c.commit()
+}
 
-	stdoutWriter := bufio.NewWriter(os.Stdout)
+// cutShort is the error of a chunk an interrupt stopped after keeping the
+// words it had; it unwraps to the interrupt.
+type cutShort struct {
+	partial
+	err error
+}
 
-	for currentPos < total {
-		fileNum := int(currentPos/entriesPerFile) + 1
-		fileName := fmt.Sprintf("combos_%06d.txt", fileNum)
+func (e *cutShort) Error() string { return e.err.Error() }
+func (e *cutShort) Unwrap() error { return e.err }
+
+// removePartials deletes the unfinished chunks matching pattern that an
+// interrupted run left behind, except keep; they are regenerated from their
+// start anyway.
+func removePartials(pattern, keep string) {
+	parts, _ := filepath.Glob(pattern + partSuffix)
+	for _, part := range parts {
+		if part == keep {
+			continue
+		}
+		slog.Info("removing unfinished chunk", "file", part)
+		os.Remove(part)
+	}
+}
 
-		file, err := os.Create(fileName)
-		if err != nil {
-			panic(err)
+// options are the run settings taken from the command line.
+type options struct {
+	errs              policies
+	fitFS             bool
+	placeholders      string
+	sample            int64  // -sample: write this many random words to stdout instead of chunks
+	shm               string // shared-memory segment prefix; empty writes chunk files
+	shmSegments       int    // segments allowed to wait for the consumer
+	compress          compression
+	output            string // -output: files, null, stdout or tcp://host:port, or several separated by commas
+	teeBuffer         string // -tee-buffer, a size for parseBytes
+	workers           int    // chunks generated at once
+	shards            int    // -shard-output: files the words are spread over; 0 for chunks
+	shardBy           string // how a word picks its shard
+	status            string // -status: none, local or publish
+	dashboard         string // -dashboard: the local HTML page to keep; "" for none
+	dashboardInterval time.Duration
+	checkpoint        checkpointInterval
+	power             powerPolicy
+
+	reprobe       bool   // -reprobe: plan measures the machine again
+	listSnapshots bool   // -list-snapshots: print the state history instead of generating
+	rollback      string // -rollback: the snapshot to restore instead of generating
+	testMode      bool
+
+	progress            string        // -progress: auto, bar, log, json or none
+	progressOut         io.Writer     // where progress goes: stdout, or with -progress json the real one
+	progressInterval    time.Duration // progress bar redraws on a terminal
+	progressLogInterval time.Duration // progress records otherwise
+}
+
+func main() {
+	var opts options
+	errs := &opts.errs
+	errs.publish = skip
+	flag.Var(&errs.disk, "on-disk-error", "`policy` when writing output fails: abort or retry")
+	flag.Var(&errs.state, "on-state-error", "`policy` when "+stateFile+" is corrupt: abort, retry or skip (start fresh)")
+	flag.Var(&errs.publish, "on-publish-error", "`policy` when git commit/push fails: abort, retry or skip")
+	flag.IntVar(&errs.retries, "retries", 3, "retries before a retried error aborts the run")
+	flag.DurationVar(&errs.delay, "retry-delay", 30*time.Second, "wait before the first retry, growing with each attempt")
+	logCfg := logConfig{level: slog.LevelInfo}
+	flag.Var(levelFlag{&logCfg.level}, "log-level", "minimum log `level`: debug, info, warn or error")
+	flag.StringVar(&logCfg.format, "log-format", "text", "log record `format`: text or json")
+	flag.StringVar(&logCfg.file, "log-file", "", "also append log records to this `path`, rotating it by size")
+	flag.Int64Var(&logCfg.maxSize, "log-max-size", 100<<20, "rotate the log file at this many `bytes`")
+	flag.IntVar(&logCfg.backups, "log-backups", 5, "rotated log files to keep")
+	flag.Int64Var(&entriesPerFile, "per-file", entriesPerFile, "`entries` per output file")
+	flag.StringVar(&maxFileSize, "max-file-size", "", "instead of -per-file, put as many entries in a file as keep the largest within this `size`, such as 100MB (before -rules and -compress)")
+	flag.BoolVar(&opts.fitFS, "fit-fs", false, "shrink -per-file when chunks would exceed the output filesystem's file size limit")
+	flag.StringVar(&opts.placeholders, "placeholders", "", "only create the planned chunk files, `empty` or sparse (sized like the real chunks), and exit")
+	flag.Int64Var(&opts.sample, "sample", 0, "write this many `words`, drawn at random by -seed without replacement, to stdout instead of generating chunks, and exit")
+	flag.StringVar(&opts.shm, "shm", "", "write chunks as shared-memory segments "+shmDir+"/`name`.combos_XXXXXX.txt for a local consumer instead of files")
+	flag.IntVar(&opts.shmSegments, "shm-segments", 4, "with -shm, how many finished segments may wait for the consumer")
+	flag.StringVar(&opts.compress.codec, "compress", "none", "compress chunks with this `codec`: none, gzip or zstd")
+	flag.IntVar(&opts.compress.level, "compress-level", 0, "codec `level` (gzip 1-9, zstd 1-22; 0 for the codec default)")
+	flag.IntVar(&opts.compress.workers, "compress-workers", 0, "compressing goroutines, separate from generation (0 for one per CPU)")
+	flag.Float64Var(&opts.power.tempLimit, "thermal-limit", 0, "pause generation while the hottest thermal zone is above this many `degrees` Celsius, until it cools 5 below (Linux; 0 for never)")
+	flag.StringVar(&opts.power.battery, "on-battery", "ignore", "on battery power, `ignore` it, reduce -workers to one, or pause generation (Linux)")
+	flag.DurationVar(&opts.power.poll, "power-poll", 10*time.Second, "read the battery and thermal sensors this often")
+	flag.IntVar(&opts.workers, "workers", 1, "generate this many chunks at once, one goroutine each (-output files or null)")
+	flag.StringVar(&opts.output, "output", "files", "where chunks go: `files`, null (discard, for benchmarking), stdout or tcp://host:port, or several separated by commas (files,stdout)")
+	flag.StringVar(&opts.teeBuffer, "tee-buffer", "64MiB", "with several -output targets, how many `bytes` each may fall behind the others")
+	flag.IntVar(&opts.shards, "shard-output", 0, "spread the words over `n` files in "+shardDir+"/ instead of writing chunks, for n parallel consumers")
+	flag.StringVar(&opts.shardBy, "shard-by", "hash", "with -shard-output, how a word picks its file: `hash` (xxhash of the word, even whatever the keyspace)")
+	flag.StringVar(&opts.status, "status", "none", "keep "+statusJSON+" and "+statusHTML+" up to date after every file: `none`, local, or publish (commit them with the chunks)")
+	flag.StringVar(&opts.dashboard, "dashboard", "", "keep a self-contained HTML `file` with charts of throughput, progress by length and publishes, to open in a browser (nothing leaves the machine)")
+	flag.DurationVar(&opts.dashboardInterval, "dashboard-interval", time.Minute, "with -dashboard, also update it this often between files")
+	flag.Var(&opts.checkpoint, "checkpoint-interval", "also save the position inside the chunk being written this often: a `duration` such as 30s, or a number of entries (-output files, without -workers, -shm or -compress)")
+	toStdout := flag.Bool("stdout", false, "stream the words to stdout for a pipe (| hashcat ...): -output stdout")
+	flag.StringVar(&opts.progress, "progress", "auto", "report progress as a bar, log records, json lines on stdout (other messages move to stderr), or none; `auto` draws the bar on a terminal and logs otherwise")
+	flag.DurationVar(&opts.progressInterval, "progress-interval", 150*time.Millisecond, "redraw the progress bar this often")
+	flag.DurationVar(&opts.progressLogInterval, "progress-log-interval", 30*time.Second, "write a log or json progress record this often")
+	sandbox := sandboxConfig{writable: []string{".", os.TempDir()}}
+	flag.BoolVar(&sandbox.confine, "sandbox", true, "confine writes to the output, log and temp directories, and block listening sockets (Linux landlock)")
+	flag.StringVar(&sandbox.user, "user", "", "when started as root, switch to this `user` before generating")
+	flag.BoolVar(&sandbox.seccomp, "seccomp", false, "install a seccomp filter denying ptrace, mount, module loading and non-publishing sockets (Linux)")
+	flag.Uint64Var(&sandbox.maxFileSize, "limit-fsize", 0, "set RLIMIT_FSIZE: no file written may grow past this many `bytes`")
+	flag.Uint64Var(&sandbox.maxOpenFiles, "limit-nofile", 0, "set RLIMIT_NOFILE: at most this many open `files`")
+	flag.IntVar(&stateHistory, "state-history", stateHistory, "compressed snapshots of "+stateFile+" to keep in "+snapshotDir+" (0 keeps none)")
+	flag.BoolVar(&opts.reprobe, "reprobe", false, "with plan, measure this machine's generation and disk speed again instead of using the stored figures")
+	flag.BoolVar(&opts.listSnapshots, "list-snapshots", false, "list the saved state snapshots, newest first, and exit")
+	flag.StringVar(&opts.rollback, "rollback", "", "restore "+stateFile+" from this `snapshot` and exit")
+	flag.BoolVar(&opts.testMode, "test-mode", false, "run generation, interruption, resume, publishing and recover end to end on a tiny keyspace in a temporary directory, check the results and exit")
+	addKeyspaceFlags(flag.CommandLine)
+	addExclusionFlags(flag.CommandLine)
+	addRuleFlags(flag.CommandLine)
+	addGitFlags(flag.CommandLine)
+	addLayoutFlags(flag.CommandLine)
+	addLaneFlags(flag.CommandLine)
+	addNodeFlags(flag.CommandLine)
+	addWindowFlags(flag.CommandLine)
+	addOrderFlags(flag.CommandLine)
+	localeName := flag.String("locale", "", "number `format` for console output: en, de, fr, ch, c... (default from LC_ALL/LANG)")
+	flag.Usage = func() {
+		out := flag.CommandLine.Output()
+		fmt.Fprintf(out, "Usage: %s [COMMAND] [flags] [ARGS...]\n       %s TOOL [flags] ARGS...\n\nCommands, which take the flags below (generate when none is given):\n", os.Args[0], os.Args[0])
+		commandUsage(out)
+		fmt.Fprintf(out, "\nTools, which take their own flags (%s TOOL -h):\n", os.Args[0])
+		toolUsage(out)
+		fmt.Fprintln(out)
+		flag.PrintDefaults()
+	}
+	if runTool(os.Args[1:]) {
+		return
+	}
+	name, args := "generate", os.Args[1:]
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			name, args = args[0], args[1:]
 		}
-		writer := bufio.NewWriter(file)
+	}
+	cmd := commands[name]
+	flag.CommandLine.Parse(args)
+	if flag.NArg() > 0 && cmd.usage == "" {
+		exit(fmt.Errorf("%w: unknown command or argument %q; see %s -h", ErrConfig, flag.Arg(0), os.Args[0]))
+	}
+	if *toStdout {
+		if opts.output != "files" && opts.output != "stdout" {
+			exit(fmt.Errorf("%w: -stdout and -output %s both say where the words go", ErrConfig, opts.output))
+		}
+		opts.output = "stdout"
+	}
+	if fastLane.target == "stdout" && slices.Contains(outputTargets(opts.output), "stdout") {
+		exit(fmt.Errorf("%w: -fast-lane and -output both want stdout", ErrConfig))
+	}
+	if slices.Contains(outputTargets(opts.output), "stdout") || fastLane.target == "stdout" {
+		os.Stdout = os.Stderr // the words own stdout; messages move to stderr
+		// A reader that exits early, like hashcat once every hash is
+		// cracked, closes the pipe: write errors say so instead of SIGPIPE
+		// killing the run halfway through a chunk.
+		signal.Ignore(syscall.SIGPIPE)
+	}
+	opts.progressOut = os.Stdout
+	if opts.progress == "json" && os.Stdout != os.Stderr {
+		// The records own stdout, for a program to read; the console
+		// messages move to stderr. With the words on stdout, the records
+		// go to stderr with them.
+		os.Stdout = os.Stderr
+	}
 
-		remainingInFile := entriesPerFile
-		if currentPos+int64(entriesPerFile) > total {
-			remainingInFile = int(total - currentPos)
+	events.subscribe(logSink{})
+	logs, err := setupLogging(logCfg)
+	if err != nil {
+		exit(err)
+	}
+	defer logs.Close()
+	if err := setLocale(*localeName); err != nil {
+		exit(err)
+	}
+	if err := loadRules(); err != nil {
+		exit(err)
+	}
+	if logCfg.file != "" {
+		sandbox.writable = append(sandbox.writable, filepath.Dir(logCfg.file))
+	}
+	if opts.dashboard != "" {
+		sandbox.writable = append(sandbox.writable, filepath.Dir(opts.dashboard))
+	}
+	if opts.shm != "" {
+		sandbox.writable = append(sandbox.writable, shmDir)
+	}
+	if layoutFlags.dir != "" {
+		if err := os.MkdirAll(layoutFlags.dir, 0o755); err != nil {
+			exit(diskError("create -out-dir", err))
 		}
+		sandbox.writable = append(sandbox.writable, layoutFlags.dir)
+	}
+	if path, err := hardwarePath(); err == nil && name == "plan" && os.MkdirAll(filepath.Dir(path), 0o755) == nil {
+		sandbox.writable = append(sandbox.writable, filepath.Dir(path))
+	}
+	// Only publishing and tcp:// streams connect anywhere.
+	sandbox.network = gitCfg.mode != "off" || strings.Contains(opts.output, "tcp://") || strings.HasPrefix(fastLane.target, "tcp://")
+	if err := enterSandbox(sandbox); err != nil {
+		exit(err)
+	}
 
-		written := 0
-		for written < remainingInFile {
-			batchEnd := currentPos + batchSize
-			if batchEnd > currentPos+int64(remainingInFile-written) {
-				batchEnd = currentPos + int64(remainingInFile-written)
-			}
-			if batchEnd > total {
-				batchEnd = total
-			}
+	// Ctrl-C / SIGTERM stop generation at the next batch boundary
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := cmd.run(ctx, &opts, flag.Args()); err != nil {
+		exit(err)
+	}
+}
 
-			for pos := currentPos; pos < batchEnd; pos++ {
-				writer.WriteString(getCombo(pos) + "\n")
-			}
+// newKeyspace builds the configured keyspace, sets total and selects the
+// node of a split run.
+func newKeyspace() (*wordlist.Keyspace, error) {
+	var ks *wordlist.Keyspace
+	err := foldTemplate()
+	if err != nil {
+		return nil, err
+	}
+	if combinator.left != "" || combinator.right != "" {
+		ks, err = combinatorKeyspace()
+	} else if hybrid.dict != "" {
+		ks, err = hybridKeyspace()
+	} else if hybrid.prefix != "" || hybrid.suffix != "" {
+		err = fmt.Errorf("%w: -prefix-mask and -suffix-mask go with -hybrid-dict", ErrConfig)
+	} else if len(combinator.seps) > 0 {
+		err = fmt.Errorf("%w: -separator goes with combine", ErrConfig)
+	} else if keyspaceMask != "" {
+		ks, err = maskKeyspace()
+	} else if err = loadCharsetFile(); err == nil {
+		if ks, err = wordlist.NewKeyspace(wordlist.Runes(charset), minLength, maxLength); err != nil {
+			err = fmt.Errorf("%w: %w", ErrConfig, err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	total = ks.Total()
+	if err := loadLayout(); err != nil {
+		return nil, err
+	}
+	if maxFileSize != "" {
+		limit, err := parseBytes(maxFileSize)
+		if err != nil {
+			return nil, err
+		}
+		if entriesPerFile, err = perFileForSize(ks, limit); err != nil {
+			return nil, err
+		}
+	}
+	if entriesPerFile < 1 {
+		return nil, fmt.Errorf("%w: -per-file must be positive", ErrConfig)
+	}
+	if err := selectNode(); err != nil {
+		return nil, err
+	}
+	if ks, err = excluding(ks); err != nil {
+		return nil, err
+	}
+	if ks, err = ordering(ks); err != nil {
+		return nil, err
+	}
+	if err := selectWindow(ks); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
 
-			count := batchEnd - currentPos
-			generatedSinceLast += count
-			currentPos += count
-			written += int(count)
-
-			// Progress update
-			now := time.Now()
-			if now.Sub(lastUpdate).Seconds() >= 0.15 {
-				elapsed := now.Sub(lastUpdate).Seconds()
-				speed := float64(generatedSinceLast) / elapsed
-				percent := float64(currentPos) / float64(total) * 100
-
-				barFilled := int(percent / 2)
-				if barFilled > 50 {
-					barFilled = 50
-				}
-				bar := strings.Repeat("█", barFilled) + strings.Repeat("░", 50-barFilled)
+func run(ctx context.Context, opts *options) error {
+	errs := &opts.errs
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	if opts.sample != 0 {
+		if opts.sample < 0 {
+			return fmt.Errorf("%w: -sample %d", ErrConfig, opts.sample)
+		}
+		return writeSample(ctx, ks, opts.sample, seed)
+	}
+	if chunkExt, err = opts.compress.ext(); err != nil {
+		return err
+	}
+	outDir, prefix := cmp.Or(chunkLayout.Dir, "."), ""
+	if opts.shm != "" {
+		if opts.shmSegments < 1 || strings.ContainsRune(opts.shm, '/') || opts.output != "files" {
+			return fmt.Errorf("%w: -shm needs a plain name, -shm-segments at least 1 and -output files", ErrConfig)
+		}
+		if chunkLayout.Dir != "" || chunkLayout.PerDir > 0 {
+			return fmt.Errorf("%w: -shm names its segments itself; drop -out-dir and -per-dir", ErrConfig)
+		}
+		outDir, prefix = shmDir, filepath.Join(shmDir, opts.shm)+"."
+	}
+	if opts.status != "none" && opts.status != "local" && opts.status != "publish" {
+		return fmt.Errorf("%w: -status %q (want none, local or publish)", ErrConfig, opts.status)
+	}
+	if opts.workers < 1 || opts.workers > 1 && (opts.shm != "" || opts.output != "files" && opts.output != "null") {
+		return fmt.Errorf("%w: -workers needs to be at least 1, and above 1 only works with -output files or null, without -shm", ErrConfig)
+	}
+	if opts.checkpoint.enabled() && (opts.output != "files" || opts.shm != "" || opts.workers > 1 || opts.compress.codec != "none" || opts.shards > 0) {
+		return fmt.Errorf("%w: -checkpoint-interval works with -output files only, without -workers, -shm, -compress or -shard-output", ErrConfig)
+	}
+	gate, err := newPowerGate(opts.power, opts.workers)
+	if err != nil {
+		return err
+	}
+	sliceStart, sliceEnd := runSlice()
+	prog, err := newProgress(ks, sliceStart, sliceEnd, cmp.Or[io.Writer](opts.progressOut, os.Stdout), opts.progress, opts.progressInterval, opts.progressLogInterval)
+	if err != nil {
+		return err
+	}
+	if nodes > 0 && (node == 0 || opts.fitFS) {
+		return fmt.Errorf("%w: -nodes needs -node, and every node the same -per-file, so not -fit-fs", ErrConfig)
+	}
+	if entriesPerFile, err = checkFilesystem(outDir, ks, entriesPerFile, opts.fitFS); err != nil {
+		return err
+	}
 
-				etaSeconds := float64(total-currentPos) / speed
-				eta := time.Duration(etaSeconds) * time.Second
-				etaStr := fmt.Sprintf("%02dh%02dm%02ds", int(eta.Hours()), int(eta.Minutes())%60, int(eta.Seconds())%60)
+	pool, err := newCompressPool(opts.compress)
+	if err != nil {
+		return err
+	}
+	var out outputSink
+	var shards *shardSink
+	if opts.shards > 0 {
+		if opts.shardBy != "hash" || opts.output != "files" || opts.shm != "" || pool != nil || opts.workers > 1 {
+			return fmt.Errorf("%w: -shard-output takes -shard-by hash, and works with -output files only, without -shm, -compress or -workers", ErrConfig)
+		}
+		shards, err = newShardSink(opts.shards)
+		out = shards
+	} else {
+		var buffer int64
+		if buffer, err = parseBytes(opts.teeBuffer); err == nil {
+			out, err = newOutputSink(opts.output, prefix, pool, buffer)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	defer out.close()
+	if err := openFastLane(); err != nil {
+		return err
+	}
+	defer closeFastLane()
 
-				fmt.Fprintf(stdoutWriter,
-					"\r🔧 File %06d │ %s %.4f%% │ %,10d / %,10d │ Speed: %8.0f/s │ ETA: %s",
-					fileNum, bar, percent, currentPos, total, speed, etaStr)
+	fmt.Println("╔════════════════════════════════════════════════════════════╗")
+	fmt.Println("║              Alphanumeric + _ . Wordlist Generator         ║")
+	fmt.Println("╚════════════════════════════════════════════════════════════╝")
+	if combinator.left != "" {
+		fmt.Printf("Combine   : %s + %s, separated by %s\n", combinator.left, combinator.right, describeSeparators())
+	} else if hybrid.dict != "" {
+		fmt.Printf("Hybrid    : %s (%s words) between %q and %q\n", hybrid.dict, fmtInt(int64(hybrid.words)), hybrid.prefix, hybrid.suffix)
+	} else if keyspaceMask != "" {
+		fmt.Printf("Mask      : %s  (%d distinct characters)\n", keyspaceMask, len(ks.Symbols()))
+	} else {
+		fmt.Printf("Charset   : %s  (%d characters)\n", describeCharset(), len(ks.Symbols()))
+	}
+	if hybrid.dict == "" && combinator.left == "" {
+		fmt.Printf("Lengths   : %d to %d characters\n", minLength, maxLength)
+	}
+	fmt.Printf("Total     : %s combinations (%s)\n", fmtInt(total), fmtCount(total))
+	fmt.Printf("Per file  : %s entries (up to %s)\n", fmtInt(entriesPerFile), fmtBytes(chunkBound(ks, entriesPerFile)))
+	if maxFileSize != "" {
+		fmt.Printf("            as many as fit -max-file-size %s; chunks of shorter words are smaller\n", maxFileSize)
+	}
+	fmt.Printf("Size      : %s in total\n", fmtBytes(ks.Bytes(0, total)))
+	if ks.Shuffled() {
+		fmt.Printf("Order     : shuffled, seed %d\n", seed)
+	}
+	if x := describeExclusions(); x != "" {
+		fmt.Printf("Excluded  : %s (counted above, skipped while writing)\n", x)
+	}
+	if n := len(mangling.rules); n > 0 {
+		fmt.Printf("Rules     : %d from %s, each word written up to %d times (sizes above are before them)\n", n, mangling.path, n)
+	}
+	if pool != nil {
+		fmt.Printf("Compress  : %s, %d workers (sizes above are uncompressed)\n", opts.compress.codec, pool.workers)
+	}
+	if opts.workers > 1 {
+		fmt.Printf("Workers   : %d chunks at once\n", opts.workers)
+	}
+	if opts.checkpoint.enabled() {
+		fmt.Printf("Checkpoint: every %s inside a chunk\n", describeCheckpoint(opts.checkpoint))
+	}
+	if shards != nil {
+		fmt.Printf("Shards    : %d files in %s/, by %s\n", opts.shards, shardDir, opts.shardBy)
+	}
+	if fastLane.target != "" {
+		fmt.Printf("Fast lane : %s, the words %s\n", fastLane.target, describeLane())
+	}
+	fmt.Printf("Files     : ~%s total\n", fmtInt(sliceFiles(sliceStart, sliceEnd)))
+	if node > 0 {
+		_, _, first, last := nodeSlice(node)
+		fmt.Printf("Node      : %d of %d, positions %s–%s, files %d–%d, state in %s\n", node, nodes, fmtInt(sliceStart), fmtInt(sliceEnd-1), first, last, stateFile)
+	}
+	if startFrom != "" || stopAt != "" {
+		from, _ := ks.WordAt(sliceStart)
+		to, _ := ks.WordAt(sliceEnd - 1)
+		fmt.Printf("Window    : %q to %q, positions %s–%s, files %d–%d\n", from, to, fmtInt(sliceStart), fmtInt(sliceEnd-1), sliceStart/entriesPerFile+1, (sliceEnd-1)/entriesPerFile+1)
+	}
+	fmt.Println("────────────────────────────────────────────────────────────")
+	fmt.Println()
 
-				stdoutWriter.Flush()
-				generatedSinceLast = 0
-				lastUpdate = now
+	// The null sink stores nothing, so there is no progress to keep. Chunk
+	// files kept on disk are checked against the state before resuming.
+	saveState := opts.output != "null"
+	checkChunk := slices.Contains(outputTargets(opts.output), "files") && opts.shm == "" && shards == nil
+	var currentPos int64
+	err = errs.do(ctx, func() error {
+		if !saveState {
+			return nil
+		}
+		if currentPos, err = readState(stateFile, total); err == nil {
+			err = migrateState(stateFile, total)
+		}
+		if err == nil && checkChunk {
+			if err = checkLastChunk(ctx, ks, prefix, sliceStart, sliceEnd, currentPos); err != nil {
+				currentPos = 0
 			}
 		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	currentPos = min(max(currentPos, sliceStart), sliceEnd)
+	if opts.placeholders != "" {
+		return writePlaceholders(ks, currentPos, sliceEnd, opts.placeholders)
+	}
+	if shards != nil {
+		// The shards hold only the run's slice, so a run yet to pass its
+		// start has no chunk to rewind to.
+		files := 0
+		if currentPos > sliceStart {
+			files = int((currentPos + entriesPerFile - 1) / entriesPerFile)
+		}
+		if err := shards.rewind(files); err != nil {
+			return err
+		}
+	}
+	publish := out.keeps() && opts.shm == "" // segments are consumed, not kept
+	if publish {
+		if err := keepContract(opts.compress); err != nil {
+			return err
+		}
+		if err := checkGit(ctx); err != nil {
+			return err
+		}
+	}
+	// A compressed chunk is costly to make again, so the one an interrupted
+	// run was writing is kept for adoptChunk to check.
+	resumable := ""
+	if pool != nil && currentPos < sliceEnd {
+		resumable = prefix + chunkName(int(currentPos/entriesPerFile)+1) + partSuffix
+	}
+	// An interrupt inside the next chunk left its words so far in the .part
+	// file, which the chunk continues from.
+	keep := resumable
+	var cut *partial
+	if _, ok := out.(resumer); ok && saveState && opts.workers == 1 && opts.shm == "" {
+		p, ok := statePartial(stateFile)
+		if ok && p.name == chunkName(int(currentPos/entriesPerFile)+1) && p.pos > currentPos && p.pos < min(currentPos+entriesPerFile, sliceEnd) {
+			cut, keep = &p, prefix+p.name+partSuffix
+		}
+	}
+	removePartials(prefix+chunkPattern(), keep)
+	var cp *checkpointer
+	if opts.checkpoint.enabled() {
+		cp = &checkpointer{interval: opts.checkpoint, save: func(p partial) error {
+			return writeCheckpoint(stateFile, currentPos-1, p)
+		}}
+	}
 
-		writer.Flush()
-		file.Close()
+	startTime := time.Now()
+	startPos := currentPos
+	var bytesWritten int64
+	fresh := make(map[string]checksum) // chunks written since they were last published
+	// Chunks of the run's slice, so a node or window counts from its own
+	// first chunk and publishes every -git-every of them.
+	filesCompleted := 0
+	if currentPos > sliceStart {
+		filesCompleted = int(sliceFiles(sliceStart, currentPos))
+	}
+	startFiles := filesCompleted
+	events.subscribe(prog)
+	defer events.unsubscribe(prog)
+	if opts.status != "none" {
+		status := newStatusSink(describeKeyspace(), sliceStart, sliceEnd)
+		events.subscribe(status)
+		defer events.unsubscribe(status)
+		if opts.status == "publish" && publish {
+			statusFiles = []string{statusJSON, statusHTML}
+		}
+	}
+	if opts.dashboard != "" {
+		dashboard := newDashboardSink(opts.dashboard, opts.dashboardInterval, ks, sliceStart, sliceEnd)
+		events.subscribe(dashboard)
+		defer events.unsubscribe(dashboard)
+	}
+	events.emit(event{kind: evStart, pos: currentPos, files: filesCompleted})
+
+	// produce makes the chunk fileNum, holding positions [start, end).
+	produce := func(ctx context.Context, fileNum int, start, end int64) (stored, error) {
+		fileName := chunkName(fileNum)
+		if prefix+fileName+partSuffix == resumable {
+			st, adopted, err := adoptChunk(ctx, ks, prefix+fileName, start, end)
+			if err != nil || adopted {
+				return st, err
+			}
+		}
+		var from *partial
+		if cut != nil && cut.name == fileName {
+			from, cut = cut, nil
+		}
+		var st stored
+		err := errs.do(ctx, func() error {
+			var err error
+			st, err = writeChunk(ctx, ks, out, fileName, fileNum, start, end, from, cp)
+			if err != nil && from != nil && ctx.Err() == nil {
+				slog.Warn("cannot continue the partial chunk; writing it again", "file", fileName, "err", err)
+				from = nil
+				st, err = writeChunk(ctx, ks, out, fileName, fileNum, start, end, nil, cp)
+			}
+			return err
+		})
+		return st, err
+	}
+	// finish records a produced chunk; chunks reach it in order.
+	finish := func(fileNum int, end int64, st stored) error {
+		fileName := chunkName(fileNum)
+		bytesWritten += st.size
+		if publish && st.sum != nil {
+			fresh[fileName] = *st.sum
+		}
+		written := end - currentPos
+		currentPos = end
 
 		// Save progress
-		os.WriteFile(stateFile, []byte(strconv.FormatInt(currentPos-1, 10)), 0644)
+		if saveState {
+			if err := errs.do(ctx, func() error { return writeState(stateFile, currentPos-1) }); err != nil {
+				return err
+			}
+		}
 
 		filesCompleted++
-		fmt.Printf("\n✅ Completed: %s (%,d entries) — Total files: %d\n", fileName, written, filesCompleted)
+		events.emit(event{kind: evFile, pos: currentPos, n: written, bytes: st.size, fileNum: fileNum, file: fileName, files: filesCompleted})
 
 		// Auto git commit every N files
-		if filesCompleted%commitEvery == 0 {
-			gitCommitAndPush(filesCompleted)
+		if publish && filesCompleted%gitCfg.every == 0 {
+			if err := errs.do(ctx, func() error { return gitCommitAndPush(ctx, filesCompleted, currentPos, fresh) }); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if opts.workers > 1 {
+		if err := generateParallel(ctx, opts.workers, gate, currentPos, sliceEnd, produce, finish); err != nil {
+			return err
+		}
+	}
+	for currentPos < sliceEnd {
+		fileNum := int(currentPos/entriesPerFile) + 1
+		end := min(int64(fileNum)*entriesPerFile, sliceEnd)
+
+		if opts.shm != "" {
+			if err := waitForSegmentSlot(ctx, opts.shm, opts.shmSegments); err != nil {
+				return err
+			}
+		}
+		if err := gate.wait(ctx, 0); err != nil {
+			return err
+		}
+		st, err := produce(ctx, fileNum, currentPos, end)
+		var short *cutShort
+		if errors.As(err, &short) && saveState {
+			if err := writePartialState(stateFile, currentPos-1, short.partial); err != nil {
+				return err
+			}
+			fmt.Printf("\n⏸  Interrupted in %s: its first %s words are kept and %s records them. Run again to continue at position %s.\n",
+				short.name, fmtInt(short.pos-currentPos), stateFile, fmtInt(short.pos))
+		}
+		if err != nil {
+			return err
+		}
+		if err := finish(fileNum, end, st); err != nil {
+			return err
 		}
 	}
 
 	// Final commit if needed
-	if filesCompleted%commitEvery != 0 {
-		gitCommitAndPush(filesCompleted)
+	if publish && filesCompleted%gitCfg.every != 0 {
+		if err := errs.do(ctx, func() error { return gitCommitAndPush(ctx, filesCompleted, currentPos, fresh) }); err != nil {
+			return err
+		}
+	}
+
+	if opts.shm != "" {
+		if err := os.WriteFile(shmDoneMarker(opts.shm), nil, 0644); err != nil {
+			return diskError("mark segments done", err)
+		}
 	}
 
 	totalTime := time.Since(startTime)
-	avgSpeed := float64(total) / totalTime.Seconds()
+	avgSpeed := float64(sliceEnd-startPos) / totalTime.Seconds()
+	avgBytes := float64(bytesWritten) / totalTime.Seconds()
+	events.emit(event{kind: evDone, pos: sliceEnd, files: filesCompleted})
 
 	fmt.Println("\n╔════════════════════════════════════════════════════════════╗")
 	fmt.Println("║                     🎉 GENERATION COMPLETE!                ║")
 	fmt.Println("╚════════════════════════════════════════════════════════════╝")
-	fmt.Printf("Total combinations : %,d\n", total)
-	fmt.Printf("Time taken         : %v\n", totalTime.Round(time.Second))
-	fmt.Printf("Average speed      : %.0f combinations/sec\n", avgSpeed)
-	fmt.Printf("Total files        : %d\n", filesCompleted)
-	fmt.Println("All files saved as combos_XXXXXX.txt")
-	fmt.Println("Progress backed up via git every 10 files.\n")
+	fmt.Printf("Total combinations : %s (%s)\n", fmtInt(sliceEnd-sliceStart), fmtCount(sliceEnd-sliceStart))
+	fmt.Printf("Written this run   : %s in %s files\n", fmtBytes(bytesWritten), fmtInt(int64(filesCompleted-startFiles)))
+	fmt.Printf("Time taken         : %s\n", fmtDuration(totalTime))
+	fmt.Printf("Average speed      : %s combinations/sec (%s/s)\n", fmtFloat(avgSpeed, 0), fmtBytes(int64(avgBytes)))
+	fmt.Printf("Total files        : %s\n", fmtInt(int64(filesCompleted)))
+	if fastLane.target != "" {
+		lane, err := closeFastLane()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Fast lane          : %s words sent to %s first\n", fmtInt(lane), fastLane.target)
+	}
+	switch {
+	case opts.shm != "":
+		fmt.Printf("All segments handed over as %s\n", filepath.Join(shmDir, opts.shm+"."+describeLayout()))
+	case !out.keeps():
+		fmt.Printf("All chunks sent to %s\n", opts.output)
+	default:
+		fmt.Println("All files saved as " + describeLayout())
+		if others := slices.DeleteFunc(outputTargets(opts.output), func(t string) bool { return t == "files" }); len(others) > 0 {
+			fmt.Printf("All chunks also sent to %s\n", strings.Join(others, ", "))
+		}
+		if gitCfg.enabled {
+			fmt.Printf("Progress pushed to %s %s every %d files.\n", gitCfg.remote, gitCfg.branch, gitCfg.every)
+		} else {
+			fmt.Printf("Progress recorded in %s, without git.\n", manifestFile)
+		}
+	}
+	fmt.Println()
+	return nil
 }
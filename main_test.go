@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testOptions are the options main parses from no flags at all.
+func testOptions() *options {
+	return &options{
+		errs:                policies{publish: skip, retries: 3, delay: 30 * time.Second},
+		shmSegments:         4,
+		compress:            compression{codec: "none"},
+		output:              "files",
+		teeBuffer:           "64MiB",
+		workers:             1,
+		shardBy:             "hash",
+		status:              "none",
+		dashboardInterval:   time.Minute,
+		power:               powerPolicy{battery: "ignore", poll: 10 * time.Second},
+		progress:            "none",
+		progressOut:         os.Stdout,
+		progressInterval:    150 * time.Millisecond,
+		progressLogInterval: 30 * time.Second,
+	}
+}
+
+// tinyRun sets up a run of the test keyspace in a directory of its own.
+func tinyRun(t *testing.T) *options {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	opts := testOptions()
+	useTestKeyspace(opts)
+	return opts
+}
+
+// interrupted runs opts until files chunks are complete, like a Ctrl-C.
+func interrupted(t *testing.T, opts *options, files int) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	stop := &interrupter{files, cancel}
+	events.subscribe(stop)
+	defer events.unsubscribe(stop)
+	if err := run(ctx, opts); !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted run ended with %v, want it cancelled", err)
+	}
+}
+
+// keyspaceWords returns the words of the run's keyspace at [start, end).
+func keyspaceWords(t *testing.T, start, end int64) []string {
+	t.Helper()
+	ks, err := newKeyspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []string
+	for i := start; i < end; i++ {
+		word, err := ks.WordAt(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		words = append(words, word)
+	}
+	return words
+}
+
+// fileLines returns the lines of the files, one after the other.
+func fileLines(t *testing.T, names ...string) []string {
+	t.Helper()
+	var lines []string
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc := bufio.NewScanner(f)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return lines
+}
+
+// chunkWords returns the words of the chunks on disk, in order.
+func chunkWords(t *testing.T) []string {
+	t.Helper()
+	nums, err := chunkFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, n := range nums {
+		names = append(names, chunkName(n))
+	}
+	return fileLines(t, names...)
+}
+
+// TestTestMode runs -test-mode: a run interrupted, resumed, published to a
+// bare repository and recovered.
+func TestTestMode(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Chdir(t.TempDir())
+	if err := runTestMode(t.Context(), testOptions()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	opts := tinyRun(t)
+	opts.checkpoint = checkpointInterval{entries: 3}
+	interrupted(t, opts, 5)
+	if err := run(t.Context(), opts); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	if got, want := chunkWords(t), keyspaceWords(t, 0, total); !slices.Equal(got, want) {
+		t.Fatalf("the chunks hold %d words, want the %d of the keyspace in order", len(got), len(want))
+	}
+}
+
+func TestRunWindow(t *testing.T) {
+	opts := tinyRun(t)
+	startFrom, stopAt = "a0", "10b"
+	interrupted(t, opts, 3)
+	if err := run(t.Context(), opts); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	start, end := runSlice()
+	if got, want := chunkWords(t), keyspaceWords(t, start, end); !slices.Equal(got, want) {
+		t.Fatalf("the chunks hold %d words, want the %d from %q to %q", len(got), len(want), startFrom, stopAt)
+	}
+
+	ks, err := newKeyspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(stateFile)
+	if err := recoverState(ks); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if pos, err := readState(stateFile, total); err != nil || pos != end {
+		t.Fatalf("recovered state is %d (%v), want %d", pos, err, end)
+	}
+}
+
+func TestRunNodes(t *testing.T) {
+	opts := tinyRun(t)
+	for n := 1; n <= 3; n++ {
+		nodes, node = 3, n
+		if err := run(t.Context(), opts); err != nil {
+			t.Fatalf("node %d: %v", n, err)
+		}
+	}
+	nodes, node = 0, 0
+	if got, want := chunkWords(t), keyspaceWords(t, 0, total); !slices.Equal(got, want) {
+		t.Fatalf("the nodes' chunks hold %d words, want the %d of the keyspace in order", len(got), len(want))
+	}
+}
+
+// TestRunShards interrupts and resumes a run over shards that starts past
+// the first chunk, and checks that the shards hold every word once.
+func TestRunShards(t *testing.T) {
+	opts := tinyRun(t)
+	opts.shards = 3
+	startFrom = "ba"
+	interrupted(t, opts, 4)
+	if err := run(t.Context(), opts); err != nil {
+		t.Fatalf("resumed run: %v", err)
+	}
+	names, err := filepath.Glob(filepath.Join(shardDir, "shard_*.txt"))
+	if err != nil || len(names) != opts.shards {
+		t.Fatalf("found shards %v (%v), want %d", names, err, opts.shards)
+	}
+	start, end := runSlice()
+	got, want := fileLines(t, names...), keyspaceWords(t, start, end)
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("the shards hold %d words, want the %d from %q once each", len(got), len(want), startFrom)
+	}
+}
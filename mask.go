@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Mask character classes in hashcat notation.
+var maskClasses = map[byte]string{
+	'l': "abcdefghijklmnopqrstuvwxyz",
+	'u': "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	'd': "0123456789",
+	's': " !\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~",
+}
+
+func init() {
+	maskClasses['a'] = maskClasses['l'] + maskClasses['u'] + maskClasses['d'] + maskClasses['s']
+}
+
+// parseMask splits a mask like "?u?l?l?l?d?d!" into the characters allowed
+// at each position: ?l ?u ?d ?s ?a are classes, ?? is a literal '?', and any
+// other character stands for itself.
+func parseMask(mask string) ([]string, error) {
+	var positions []string
+	for i := 0; i < len(mask); i++ {
+		if mask[i] != '?' {
+			positions = append(positions, mask[i:i+1])
+			continue
+		}
+		if i+1 == len(mask) {
+			return nil, fmt.Errorf("%w: mask %q ends with a lone '?'", ErrConfig, mask)
+		}
+		i++
+		switch set, ok := maskClasses[mask[i]]; {
+		case ok:
+			positions = append(positions, set)
+		case mask[i] == '?':
+			positions = append(positions, "?")
+		default:
+			return nil, fmt.Errorf("%w: mask %q: unknown class ?%c", ErrConfig, mask, mask[i])
+		}
+	}
+	return positions, nil
+}
+
+// maskSize is the number of words mask describes, saturating at MaxInt64.
+func maskSize(positions []string) int64 {
+	n := int64(1)
+	for _, set := range positions {
+		if n > math.MaxInt64/int64(len(set)) {
+			return math.MaxInt64
+		}
+		n *= int64(len(set))
+	}
+	return n
+}
+
+// maskFor builds the mask of one class letter per position: "uldd" gives
+// "?u?l?d?d".
+func maskFor(classes string) string {
+	var b strings.Builder
+	for _, c := range classes {
+		b.WriteByte('?')
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// maskSpace indexes the words of a parsed mask like a keyspace: index 0 takes
+// the first character at every position and the last position varies
+// fastest.
+type maskSpace []string
+
+// Total is the number of words, saturating at MaxInt64.
+func (m maskSpace) Total() int64 { return maskSize(m) }
+
+// AppendWord appends the word with the given index to dst.
+func (m maskSpace) AppendWord(dst []byte, index int64) ([]byte, error) {
+	if index < 0 || index >= m.Total() {
+		return dst, fmt.Errorf("index %d not in [0, %d)", index, m.Total())
+	}
+	start := len(dst)
+	dst = append(dst, make([]byte, len(m))...)
+	for i := len(m) - 1; i >= 0; i-- {
+		n := int64(len(m[i]))
+		dst[start+i] = m[i][index%n]
+		index /= n
+	}
+	return dst, nil
+}
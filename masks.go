@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// maskMinus returns masks holding the words of a that are not words of b,
+// disjoint from one another. Where a and b overlap, a word of a leaves b at
+// its first position k whose character b does not allow, so the piece for k
+// keeps the overlap before k, the rest of a's set at k and all of a after
+// it.
+func maskMinus(a, b maskSpace) []maskSpace {
+	if len(a) != len(b) {
+		return []maskSpace{a}
+	}
+	overlap := make(maskSpace, len(a))
+	for i := range a {
+		if overlap[i] = keepBytes(a[i], b[i], true); overlap[i] == "" {
+			return []maskSpace{a} // no word of a is in b
+		}
+	}
+	var pieces []maskSpace
+	for k := range a {
+		rest := keepBytes(a[k], b[k], false)
+		if rest == "" {
+			continue
+		}
+		piece := make(maskSpace, len(a))
+		copy(piece, overlap[:k])
+		piece[k] = rest
+		copy(piece[k+1:], a[k+1:])
+		pieces = append(pieces, piece)
+	}
+	return pieces
+}
+
+// keepBytes returns the bytes of set that are in other when in is true, or
+// that are not when it is false, in the order of set.
+func keepBytes(set, other string, in bool) string {
+	var b strings.Builder
+	for i := range len(set) {
+		if (strings.IndexByte(other, set[i]) >= 0) == in {
+			b.WriteByte(set[i])
+		}
+	}
+	return b.String()
+}
+
+// maskUnion splits masks into disjoint pieces: pieces[i] hold the words of
+// masks[i] that no earlier mask has, so together they hold every word of the
+// union once.
+func maskUnion(masks []maskSpace) [][]maskSpace {
+	pieces := make([][]maskSpace, len(masks))
+	for i, m := range masks {
+		own := []maskSpace{m}
+		for _, earlier := range masks[:i] {
+			var next []maskSpace
+			for _, p := range own {
+				next = append(next, maskMinus(p, earlier)...)
+			}
+			own = next
+		}
+		pieces[i] = own
+	}
+	return pieces
+}
+
+// addSaturating adds b to a, saturating at MaxInt64.
+func addSaturating(a, b int64) int64 {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
+
+func masksCmd(args []string) error {
+	fs := newToolFlags("masks", "mask...")
+	file := fs.String("f", "", "also read masks from this `file`, one per line (- for stdin)")
+	count := fs.Bool("count", false, "only report the sizes of the masks and of their union")
+	out := fs.String("o", "", "write the words to this `file` instead of stdout")
+	score := scoreFlag(fs)
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	texts := fs.Args()
+	if *file != "" {
+		lines, err := readLines(*file)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				texts = append(texts, line)
+			}
+		}
+	}
+	if len(texts) == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: masks needs at least one mask", ErrConfig)
+	}
+	masks := make([]maskSpace, len(texts))
+	for i, text := range texts {
+		positions, err := parseMask(text)
+		if err != nil {
+			return err
+		}
+		if len(positions) == 0 {
+			return fmt.Errorf("%w: an empty mask", ErrConfig)
+		}
+		masks[i] = positions
+	}
+
+	pieces := maskUnion(masks)
+	var sum, union int64
+	for i, m := range masks {
+		var own int64
+		for _, p := range pieces[i] {
+			own = addSaturating(own, p.Total())
+		}
+		sum, union = addSaturating(sum, m.Total()), addSaturating(union, own)
+		fmt.Fprintf(os.Stderr, "🎭 %-24s %12s words, %12s new\n", texts[i], fmtCount(m.Total()), fmtCount(own))
+	}
+	fmt.Fprintf(os.Stderr, "   union: %s words of %s, %s repeats (%s%%) skipped\n",
+		fmtInt(union), fmtInt(sum), fmtInt(sum-union), fmtFloat(percentOf(sum-union, sum), 1))
+	if *count {
+		return nil
+	}
+
+	model, err := loadScoreModel(*score)
+	if err != nil {
+		return err
+	}
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	w.model = model
+	var buf []byte
+	for _, own := range pieces {
+		for _, p := range own {
+			for i := range p.Total() {
+				buf, _ = p.AppendWord(buf[:0], i)
+				if err := w.writeLine(buf); err != nil {
+					w.close()
+					return err
+				}
+			}
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Wrote %s words\n", fmtInt(union))
+	return nil
+}
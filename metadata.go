@@ -0,0 +1,205 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxMetadataHead is how much of an image is read looking for its EXIF block,
+// which sits near the start.
+const maxMetadataHead = 256 << 10
+
+// officeFields are the document properties worth harvesting, by XML local
+// name, from OOXML docProps/core.xml and app.xml and ODF meta.xml.
+var officeFields = map[string]bool{
+	"title": true, "subject": true, "creator": true, "keywords": true, "keyword": true,
+	"description": true, "lastModifiedBy": true, "category": true, "initial-creator": true,
+	"Company": true, "Manager": true,
+}
+
+// officeParts are the zip members holding those properties.
+var officeParts = []string{"docProps/core.xml", "docProps/app.xml", "meta.xml"}
+
+// exifTags are the text tags worth harvesting: ImageDescription, Make,
+// Model, Software, Artist and Copyright in ASCII, and Windows' XPTitle,
+// XPComment, XPAuthor, XPKeywords and XPSubject in UTF-16.
+var exifTags = map[uint16]bool{
+	0x010e: true, 0x010f: true, 0x0110: true, 0x0131: true, 0x013b: true, 0x8298: true,
+	0x9c9b: true, 0x9c9c: true, 0x9c9d: true, 0x9c9e: true, 0x9c9f: true,
+}
+
+// fileMetadata returns the text properties of the document or image at path,
+// by its extension; other files have none.
+func fileMetadata(path string) ([]string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".docx", ".xlsx", ".pptx", ".docm", ".xlsm", ".pptm", ".odt", ".ods", ".odp":
+		return officeMetadata(path)
+	case ".jpg", ".jpeg", ".tif", ".tiff":
+		return imageMetadata(path)
+	case ".pdf":
+		data, err := readHead(path, maxDocumentText)
+		if err != nil {
+			return nil, err
+		}
+		return pdfMetadata(data), nil
+	}
+	return nil, nil
+}
+
+func officeMetadata(path string) ([]string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	var values []string
+	for _, f := range zr.File {
+		if !slices.Contains(officeParts, f.Name) {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return values, err
+		}
+		values, err = xmlFields(values, io.LimitReader(rc, maxMetadataHead))
+		rc.Close()
+		if err != nil {
+			return values, err
+		}
+	}
+	return values, nil
+}
+
+// xmlFields appends the text of the officeFields elements in r.
+func xmlFields(values []string, r io.Reader) ([]string, error) {
+	dec := xml.NewDecoder(r)
+	var field string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return values, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			field = ""
+			if officeFields[t.Name.Local] {
+				field = t.Name.Local
+			}
+		case xml.CharData:
+			if s := strings.TrimSpace(string(t)); field != "" && s != "" {
+				values = append(values, s)
+			}
+		case xml.EndElement:
+			field = ""
+		}
+	}
+}
+
+// imageMetadata returns the exifTags of a JPEG's EXIF block or a TIFF's
+// first directory.
+func imageMetadata(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	head, err := io.ReadAll(io.LimitReader(f, maxMetadataHead))
+	if err != nil {
+		return nil, err
+	}
+	tiff := head
+	if bytes.HasPrefix(head, []byte{0xff, 0xd8}) {
+		if tiff = jpegExif(head); tiff == nil {
+			return nil, nil
+		}
+	}
+	return exifStrings(tiff)
+}
+
+// jpegExif returns the TIFF structure inside a JPEG's APP1 Exif segment,
+// or nil when there is none in head.
+func jpegExif(head []byte) []byte {
+	for p := 2; p+4 <= len(head) && head[p] == 0xff; {
+		marker := head[p+1]
+		size := int(binary.BigEndian.Uint16(head[p+2:]))
+		if marker == 0xda || size < 2 { // image data follows
+			return nil
+		}
+		seg := head[p+4 : min(p+2+size, len(head))]
+		if marker == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:]
+		}
+		p += 2 + size
+	}
+	return nil
+}
+
+var errBadTIFF = errors.New("malformed EXIF")
+
+// exifStrings reads the text tags of the first directory of a TIFF structure.
+func exifStrings(tiff []byte) ([]string, error) {
+	if len(tiff) < 8 {
+		return nil, nil
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil, nil // not a TIFF
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return nil, errBadTIFF
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	var values []string
+	for i := range n {
+		e := ifd + 2 + 12*i
+		if e+12 > len(tiff) {
+			return values, errBadTIFF
+		}
+		tag, typ, count := order.Uint16(tiff[e:]), order.Uint16(tiff[e+2:]), int(order.Uint32(tiff[e+4:]))
+		if !exifTags[tag] || (typ != 1 && typ != 2) { // BYTE or ASCII
+			continue
+		}
+		data := tiff[e+8 : e+12]
+		if count > 4 {
+			off := int(order.Uint32(tiff[e+8:]))
+			if off < 0 || count > len(tiff) || off > len(tiff)-count {
+				continue // beyond what was read
+			}
+			data = tiff[off : off+count]
+		} else {
+			data = data[:count]
+		}
+		var s string
+		if tag >= 0x9c9b { // Windows XP tags are UTF-16LE
+			u := make([]uint16, len(data)/2)
+			for j := range u {
+				u[j] = binary.LittleEndian.Uint16(data[2*j:])
+			}
+			s = string(utf16.Decode(u))
+		} else {
+			s = string(data)
+		}
+		if s = strings.TrimSpace(strings.TrimRight(s, "\x00")); s != "" {
+			values = append(values, s)
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"main.go/wordlist"
+)
+
+func mirrorCmd(args []string) error {
+	fs := newToolFlags("mirror", "")
+	from := fs.String("from", "", "the published run: a `dir` (clone or mounted bucket), an http(s) URL the files are under, or github.com/OWNER/REPO")
+	to := fs.String("to", ".", "keep the mirror in this `dir`")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if *from == "" {
+		return fmt.Errorf("%w: mirror needs -from", ErrConfig)
+	}
+	src, err := wordlist.NewSource(*from)
+	if err != nil {
+		return fmt.Errorf("%w: -from: %w", ErrConfig, err)
+	}
+	if err := os.MkdirAll(*to, 0755); err != nil {
+		return diskError("create "+*to, err)
+	}
+	// Ctrl-C / SIGTERM stop the download; the .part is continued next time
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	records, err := newPublishRecords(ctx, src, filepath.Join(*to, publishedLog))
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		fmt.Fprintf(os.Stderr, "✅ %s is up to date with %s\n", *to, src)
+		return nil
+	}
+
+	sums, err := readManifest(filepath.Join(*to, manifestFile))
+	if err != nil {
+		return err
+	}
+	var files int
+	var size int64
+	for _, rec := range records {
+		for _, f := range rec.line.Files {
+			n, err := mirrorFile(ctx, src, *to, f)
+			if err != nil {
+				return err
+			}
+			sums[f.Name] = checksum{f.SHA256, f.XXH64}
+			files++
+			size += n
+		}
+		// The chunks first, then the manifest, then the record: a mirror
+		// cut short fetches the record again, and its chunks that made it
+		// are only checked.
+		if err := writeManifest(filepath.Join(*to, manifestFile), sums); err != nil {
+			return err
+		}
+		if err := appendLine(filepath.Join(*to, publishedLog), rec.raw); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "📥 Mirrored %d files, up to position %s\n", len(rec.line.Files), fmtInt(rec.line.Position))
+	}
+	fmt.Fprintf(os.Stderr, "✅ %d new files (%s) from %s in %s\n", files, fmtBytes(size), src, *to)
+	return nil
+}
+
+// mirroredRecord is a line of the source's published log with its text, to
+// append to the local copy as it was.
+type mirroredRecord struct {
+	raw  []byte
+	line publishRecord
+}
+
+// newPublishRecords returns the records of the source's published log that
+// the local copy at path lacks. The log is only ever appended to, so the
+// local copy has to be the start of it.
+func newPublishRecords(ctx context.Context, src wordlist.Source, path string) ([]mirroredRecord, error) {
+	local, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, diskError("read "+path, err)
+	}
+	r, err := src.Open(ctx, publishedLog, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetch, err)
+	}
+	defer r.Close()
+	remote, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read %s from %s: %w", ErrFetch, publishedLog, src, err)
+	}
+	if !bytes.HasPrefix(remote, local) {
+		return nil, fmt.Errorf("%w: %s does not continue the %s of %s; mirror it into another directory", ErrStateCorrupt, path, publishedLog, src)
+	}
+	var records []mirroredRecord
+	sc := bufio.NewScanner(bytes.NewReader(remote[len(local):]))
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		rec := mirroredRecord{raw: append([]byte(nil), sc.Bytes()...)}
+		if err := json.Unmarshal(rec.raw, &rec.line); err != nil {
+			return nil, fmt.Errorf("%w: %s of %s: %w", ErrFetch, publishedLog, src, err)
+		}
+		for _, f := range rec.line.Files {
+			if f.Name == "" || filepath.IsAbs(f.Name) || !filepath.IsLocal(filepath.FromSlash(f.Name)) {
+				return nil, fmt.Errorf("%w: %s of %s lists the file %q", ErrFetch, publishedLog, src, f.Name)
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, sc.Err()
+}
+
+// mirrorFile fetches the published file f into dir and returns the bytes it
+// downloaded. A complete copy already there is only checked; a .part left
+// by an earlier mirror is continued. If a continued download does not match
+// the checksum, the .part may be stale, so the file is fetched once more
+// from the start.
+func mirrorFile(ctx context.Context, src wordlist.Source, dir string, f publishedFile) (int64, error) {
+	path := filepath.Join(dir, filepath.FromSlash(f.Name))
+	want := checksum{f.SHA256, f.XXH64}
+	if sum, err := fileChecksum(path); err == nil && sum == want {
+		return 0, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, diskError("create "+filepath.Dir(path), err)
+	}
+	_, err := os.Stat(path + partSuffix)
+	resume := err == nil
+	var fetched int64
+	for {
+		n, sum, err := download(ctx, src, f.Name, path+partSuffix, resume)
+		fetched += n
+		if err != nil {
+			return fetched, err
+		}
+		if sum == want {
+			return fetched, diskError("rename "+path+partSuffix, os.Rename(path+partSuffix, path))
+		}
+		if !resume {
+			break
+		}
+		resume = false
+	}
+	os.Remove(path + partSuffix)
+	return fetched, fmt.Errorf("%w: %s from %s does not match its checksum in %s", ErrFetch, f.Name, src, publishedLog)
+}
+
+// download appends the file name of src to part, from the size part has
+// already when resuming, and returns the bytes it added and the checksum of
+// the whole part.
+func download(ctx context.Context, src wordlist.Source, name, part string, resume bool) (int64, checksum, error) {
+	flags := os.O_RDWR | os.O_CREATE
+	if !resume {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(part, flags, 0644)
+	if err != nil {
+		return 0, checksum{}, diskError("open "+part, err)
+	}
+	defer f.Close()
+	h := newHasher()
+	have, err := io.Copy(h, f)
+	if err != nil {
+		return 0, checksum{}, diskError("read "+part, err)
+	}
+	r, err := src.Open(ctx, name, have)
+	if err != nil {
+		return 0, checksum{}, fmt.Errorf("%w: %w", ErrFetch, err)
+	}
+	defer r.Close()
+	n, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) && pathErr.Path == part {
+			return n, checksum{}, diskError("write "+part, err)
+		}
+		// What arrived is kept in part for the next mirror to continue.
+		return n, checksum{}, fmt.Errorf("%w: fetch %s from %s: %w", ErrFetch, name, src, err)
+	}
+	if err := f.Sync(); err != nil {
+		return n, checksum{}, diskError("sync "+part, err)
+	}
+	return n, h.sum(), nil
+}
+
+// appendLine appends line and a newline to path.
+func appendLine(path string, line []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return diskError("open "+path, err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return diskError("write "+path, err)
+}
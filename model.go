@@ -0,0 +1,177 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// markovEnd marks the end of a password in the transitions; the start is
+// the empty string too, as the "previous character" of the first one.
+const markovEnd = ""
+
+// styleModel is what a set of known passwords says about how their owner
+// builds passwords: the lengths, the masks (the class of each character)
+// and the order-1 Markov transitions between characters. It holds counts,
+// not the passwords, and is meant to stay on the owner's machine.
+type styleModel struct {
+	Passwords   int64                       `json:"passwords"`
+	Lengths     map[int]int64               `json:"lengths"`
+	Masks       []maskCount                 `json:"masks"`       // most frequent first
+	Transitions map[string]map[string]int64 `json:"transitions"` // previous character → next → count
+
+	totals  map[string]int64 // transitions out of each character, for probability
+	symbols int              // distinct next characters, end included
+}
+
+// maskCount is a mask and how many passwords have it.
+type maskCount struct {
+	Mask  string `json:"mask"`
+	Count int64  `json:"count"`
+}
+
+func newStyleModel() *styleModel {
+	return &styleModel{Lengths: make(map[int]int64), Transitions: make(map[string]map[string]int64)}
+}
+
+// maskOf returns the mask of word: ?l ?u ?d or ?s for each character, by
+// classOf.
+func maskOf(word string) string {
+	var shape strings.Builder
+	for _, r := range word {
+		shape.WriteByte(classOf(r)[0])
+	}
+	return maskFor(shape.String())
+}
+
+// train adds passwords to the model.
+func (m *styleModel) train(passwords []string) {
+	masks := make(map[string]int64)
+	for _, mc := range m.Masks {
+		masks[mc.Mask] = mc.Count
+	}
+	for _, p := range passwords {
+		if p == "" {
+			continue
+		}
+		m.Passwords++
+		m.Lengths[utf8.RuneCountInString(p)]++
+		masks[maskOf(p)]++
+		prev := ""
+		for _, r := range p {
+			m.transition(prev, string(r))
+			prev = string(r)
+		}
+		m.transition(prev, markovEnd)
+	}
+	m.Masks = m.Masks[:0]
+	for mask, n := range masks {
+		m.Masks = append(m.Masks, maskCount{mask, n})
+	}
+	slices.SortFunc(m.Masks, func(a, b maskCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Mask, b.Mask)
+	})
+}
+
+func (m *styleModel) transition(prev, next string) {
+	t := m.Transitions[prev]
+	if t == nil {
+		t = make(map[string]int64)
+		m.Transitions[prev] = t
+	}
+	t[next]++
+}
+
+// rankedMasks returns the masks ordered by the share of passwords each
+// covers per candidate it costs, so the masks likeliest to hold one more
+// password per guess come first.
+func (m *styleModel) rankedMasks() []maskCount {
+	ranked := slices.Clone(m.Masks)
+	density := func(mc maskCount) float64 {
+		positions, err := parseMask(mc.Mask)
+		if err != nil {
+			return 0
+		}
+		return float64(mc.Count) / float64(maskSize(positions))
+	}
+	slices.SortStableFunc(ranked, func(a, b maskCount) int { return cmp.Compare(density(b), density(a)) })
+	return ranked
+}
+
+// probability is the chance the model gives word: the product of its
+// transitions, the end included, so length counts too. Add-one smoothing
+// keeps a transition the model never saw from zeroing a word; it only makes
+// it unlikely.
+func (m *styleModel) probability(word string) float64 {
+	if m.totals == nil {
+		m.totals = make(map[string]int64, len(m.Transitions))
+		seen := make(map[string]bool)
+		for prev, t := range m.Transitions {
+			for next, n := range t {
+				m.totals[prev] += n
+				seen[next] = true
+			}
+		}
+		m.symbols = len(seen)
+	}
+	p := 1.0
+	prev := ""
+	for _, r := range word {
+		next := string(r)
+		p *= m.chance(prev, next)
+		prev = next
+	}
+	return p * m.chance(prev, markovEnd)
+}
+
+func (m *styleModel) chance(prev, next string) float64 {
+	return float64(m.Transitions[prev][next]+1) / float64(m.totals[prev]+int64(m.symbols)+1)
+}
+
+// loadStyleModel reads a model in the JSON form the learn tool writes.
+func loadStyleModel(path string) (*styleModel, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	m := newStyleModel()
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrConfig, path, err)
+	}
+	if m.Passwords == 0 {
+		return nil, fmt.Errorf("%w: %s: the model was trained on no passwords", ErrConfig, path)
+	}
+	return m, nil
+}
+
+// scoreFlag registers -score on fs: the model whose probabilities a tool
+// appends to the words it writes.
+func scoreFlag(fs *flag.FlagSet) *string {
+	return fs.String("score", "", "append a tab and each word's probability under this `model` from learn")
+}
+
+// loadScoreModel loads the model of -score, or returns nil when there is
+// none.
+func loadScoreModel(path string) (*styleModel, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return loadStyleModel(path)
+}
+
+// save writes the model readable by its owner only.
+func (m *styleModel) save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return diskError("write model", os.WriteFile(path, append(data, '\n'), 0600))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// maxEditDistance bounds neighbors: a seed of 10 characters over the default
+// 64 symbols has about 1,350 neighbors at distance 1 and nearly a million at
+// 2, and
+// distance 3 would multiply that by another thousand.
+const maxEditDistance = 2
+
+// edits calls fn with every word one edit away from word: each deletion,
+// adjacent transposition, substitution and insertion of a symbol. The slice
+// passed to fn is reused after it returns. Some results repeat and some
+// equal word; the caller deduplicates.
+func edits(word, symbols []rune, fn func([]rune)) {
+	n := len(word)
+	buf := make([]rune, 0, n+1)
+	for i := range n {
+		buf = append(append(buf[:0], word[:i]...), word[i+1:]...)
+		fn(buf)
+	}
+	for i := 0; i+1 < n; i++ {
+		if word[i] == word[i+1] {
+			continue
+		}
+		buf = append(buf[:0], word...)
+		buf[i], buf[i+1] = buf[i+1], buf[i]
+		fn(buf)
+	}
+	for i := range n {
+		buf = append(buf[:0], word...)
+		for _, s := range symbols {
+			if s != word[i] {
+				buf[i] = s
+				fn(buf)
+			}
+		}
+	}
+	for i := 0; i <= n; i++ {
+		for _, s := range symbols {
+			buf = append(append(append(buf[:0], word[:i]...), s), word[i:]...)
+			fn(buf)
+		}
+	}
+}
+
+func neighborsCmd(args []string) error {
+	fs := newToolFlags("neighbors", "seeds...")
+	distance := fs.Int("distance", 1, fmt.Sprintf("largest edit distance from a seed, 1 to %d", maxEditDistance))
+	symbols := fs.String("charset", charset, "`symbols` that insertions and substitutions use")
+	keepSeeds := fs.Bool("keep-seeds", false, "also write the seeds themselves, first")
+	out := fs.String("o", "", "write the variants to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: neighbors needs at least one seed list (- for stdin)", ErrConfig)
+	}
+	if *distance < 1 || *distance > maxEditDistance {
+		return fmt.Errorf("%w: -distance %d (want 1 to %d)", ErrConfig, *distance, maxEditDistance)
+	}
+	alphabet := []rune(*symbols)
+	slices.Sort(alphabet)
+	alphabet = slices.Compact(alphabet)
+	if len(alphabet) == 0 {
+		return fmt.Errorf("%w: -charset is empty", ErrConfig)
+	}
+
+	var seeds [][]rune
+	if _, err := forEachLine(fs.Args(), "reading seeds", func(line []byte) error {
+		if len(line) > 0 {
+			seeds = append(seeds, []rune(string(line)))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	// Every word is written once, at its smallest distance from any seed:
+	// all of distance 1 comes before any of distance 2.
+	seen := make(map[uint64]struct{})
+	var enc []byte
+	var werr error
+	emit := func(word []rune, write bool) bool {
+		enc = enc[:0]
+		for _, r := range word {
+			enc = utf8.AppendRune(enc, r)
+		}
+		h := xxhash.Sum64(enc)
+		if _, dup := seen[h]; dup {
+			return false
+		}
+		seen[h] = struct{}{}
+		if write && werr == nil && len(enc) > 0 {
+			werr = w.writeLine(enc)
+		}
+		return write && len(enc) > 0
+	}
+	// The seeds are seen first, so no variant repeats one.
+	counts := make([]int64, *distance+1)
+	for _, s := range seeds {
+		if emit(s, *keepSeeds) {
+			counts[0]++
+		}
+	}
+
+	for d := 1; d <= *distance && werr == nil; d++ {
+		for _, s := range seeds {
+			var next func([]rune)
+			if d == 1 {
+				next = func(v []rune) {
+					if emit(v, true) {
+						counts[1]++
+					}
+				}
+			} else {
+				// Distance 2 edits every distance-1 variant of the seed,
+				// written or not.
+				next = func(v []rune) {
+					edits(v, alphabet, func(v2 []rune) {
+						if emit(v2, true) {
+							counts[2]++
+						}
+					})
+				}
+			}
+			edits(s, alphabet, next)
+		}
+	}
+	if cerr := w.close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		return werr
+	}
+
+	var parts []string
+	var written int64
+	for d, n := range counts {
+		if d > 0 {
+			parts = append(parts, fmt.Sprintf("%s at distance %d", fmtInt(n), d))
+		}
+		written += n
+	}
+	fmt.Fprintf(os.Stderr, "🧬 %s seeds over %d symbols: %s; %s written\n",
+		fmtInt(int64(len(seeds))), len(alphabet), strings.Join(parts, ", "), fmtInt(written))
+	return nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"main.go/wordlist"
+)
+
+// A run in keyspace order tries every word starting with the first symbol
+// before any starting with the second. -order shuffled walks the keyspace
+// in the permutation -seed picks instead, so any prefix of the run is
+// spread over every length and symbol. Positions, chunks, states and
+// resumes count along the shuffled walk, and the same seed always gives
+// the same walk; states record it, so a run resumes only in its own order.
+var (
+	keyspaceOrder        = "keyspace" // -order: keyspace or shuffled
+	seed          uint64 = 1          // -seed: picks the shuffled order and the words of -sample
+)
+
+// addOrderFlags registers -order and -seed on fs.
+func addOrderFlags(fs *flag.FlagSet) {
+	fs.StringVar(&keyspaceOrder, "order", keyspaceOrder, "walk the keyspace in `order`: keyspace (by length, then charset order) or shuffled (a permutation picked by -seed)")
+	fs.Uint64Var(&seed, "seed", seed, "random `seed` of -order shuffled and -sample; the same seed gives the same words in the same order")
+}
+
+// ordering returns ks in the order of -order.
+func ordering(ks *wordlist.Keyspace) (*wordlist.Keyspace, error) {
+	switch keyspaceOrder {
+	case "keyspace":
+		return ks, nil
+	case "shuffled":
+		return ks.Shuffle(seed)
+	}
+	return nil, fmt.Errorf("%w: -order %q: want keyspace or shuffled", ErrConfig, keyspaceOrder)
+}
+
+// orderSpec is the order as states record it: "" for keyspace order, as
+// states from before -order have it.
+func orderSpec() string {
+	if keyspaceOrder != "shuffled" {
+		return ""
+	}
+	return fmt.Sprintf("shuffled, seed %d", seed)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// generateParallel produces the chunks from position from to position to,
+// the end of the keyspace or of a node's slice, with workers goroutines, each owning one chunk, and so one
+// disjoint range of positions, at a time. Chunks complete in any order but
+// reach finish in order, so the state only ever records a position before
+// which every chunk is complete; chunks finished past a slow one are made
+// again if the run stops first. The first error stops every worker. A
+// worker waits for gate to let it run before it takes a chunk, so a paused
+// worker holds none up.
+func generateParallel(ctx context.Context, workers int, gate *powerGate, from, to int64,
+	produce func(ctx context.Context, fileNum int, start, end int64) (stored, error),
+	finish func(fileNum int, end int64, st stored) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		fileNum int
+		end     int64
+		st      stored
+		err     error
+	}
+	first := int(from/entriesPerFile) + 1
+	last := int((to + entriesPerFile - 1) / entriesPerFile)
+	jobs := make(chan int)
+	results := make(chan result)
+	go func() {
+		defer close(jobs)
+		for n := first; n <= last; n++ {
+			select {
+			case jobs <- n:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if err := gate.wait(ctx, w); err != nil {
+					results <- result{err: err}
+					return
+				}
+				fileNum, ok := <-jobs
+				if !ok {
+					return
+				}
+				start := max(int64(fileNum-1)*entriesPerFile, from)
+				end := min(int64(fileNum)*entriesPerFile, to)
+				st, err := produce(ctx, fileNum, start, end)
+				results <- result{fileNum, end, st, err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var err error
+	done := make(map[int]result)
+	next := first
+	for r := range results {
+		if next > last {
+			continue // every chunk is finished, and a paused worker stopped
+		}
+		if err == nil && r.err != nil {
+			err = r.err
+			cancel()
+		}
+		if err != nil {
+			continue // drain the workers
+		}
+		done[r.fileNum] = r
+		for d, ok := done[next]; ok; d, ok = done[next] {
+			delete(done, next)
+			if err = finish(d.fileNum, d.end, d.st); err != nil {
+				cancel()
+				break
+			}
+			next++
+		}
+		if next > last {
+			cancel()
+		}
+	}
+	return err
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+
+	"main.go/wordlist"
+)
+
+func phrasesCmd(args []string) error {
+	fs := newToolFlags("phrases", "seeds...")
+	seps := fs.String("separators", ",.,_,-", "comma-separated `separators` between tokens; an empty item joins them directly")
+	casesFlag := fs.String("cases", "lower,title,upper", "comma-separated `cases` for each token: asis, lower, title, upper")
+	maxTokens := fs.Int("max-tokens", 5, "skip seeds with more tokens than this (at most 8)")
+	perSeed := fs.Int64("max-per-seed", 0, "write at most the first `n` variants of each seed; 0 for all")
+	skip := fs.Int64("skip", 0, "start at this variant `index`, counted over all seeds")
+	limit := fs.Int64("limit", 0, "stop after this many variant indices; 0 for no limit")
+	count := fs.Bool("count", false, "only report how many variants each seed has")
+	out := fs.String("o", "", "write the variants to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: phrases needs at least one seed list (- for stdin)", ErrConfig)
+	}
+	if *maxTokens < 1 || *maxTokens > wordlist.MaxPhraseTokens || *perSeed < 0 || *skip < 0 || *limit < 0 {
+		return fmt.Errorf("%w: -max-tokens %d, -max-per-seed %d, -skip %d, -limit %d", ErrConfig, *maxTokens, *perSeed, *skip, *limit)
+	}
+	var cases []wordlist.Case
+	for _, name := range strings.Split(*casesFlag, ",") {
+		c, err := wordlist.ParseCase(name)
+		if err != nil {
+			return fmt.Errorf("%w: -cases: %w", ErrConfig, err)
+		}
+		cases = append(cases, c)
+	}
+	separators := strings.Split(*seps, ",")
+
+	// Seeds are built up front so every seed's place in the index is known.
+	var phrases []*wordlist.Phrase
+	var seeds []string
+	var tooLong int
+	if _, err := forEachLine(fs.Args(), "reading seeds", func(line []byte) error {
+		tokens := strings.Fields(string(line))
+		if len(tokens) == 0 {
+			return nil
+		}
+		if len(tokens) > *maxTokens {
+			tooLong++
+			return nil
+		}
+		p, err := wordlist.NewPhrase(tokens, separators, cases)
+		if err != nil {
+			return fmt.Errorf("%w: %q: %w", ErrConfig, line, err)
+		}
+		phrases = append(phrases, p)
+		seeds = append(seeds, string(line))
+		return nil
+	}); err != nil {
+		return err
+	}
+	if tooLong > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  Skipped %d seeds with more than %d tokens\n", tooLong, *maxTokens)
+	}
+
+	variants := func(p *wordlist.Phrase) int64 {
+		if *perSeed > 0 {
+			return min(p.Total(), *perSeed)
+		}
+		return p.Total()
+	}
+	if *count {
+		var total int64
+		for i, p := range phrases {
+			fmt.Printf("%s\t%s\n", fmtInt(variants(p)), seeds[i])
+			total += variants(p)
+		}
+		fmt.Fprintf(os.Stderr, "🔀 %s seeds have %s variants\n", fmtInt(int64(len(phrases))), fmtInt(total))
+		return nil
+	}
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	seen := make(map[uint64]struct{})
+	var buf []byte
+	var base, written, repeated int64
+	end := int64(-1)
+	if *limit > 0 {
+		end = *skip + *limit
+	}
+	for _, p := range phrases {
+		n := variants(p)
+		from, to := max(*skip-base, 0), n
+		if end >= 0 {
+			to = min(to, end-base)
+		}
+		for i := from; i < to; i++ {
+			buf, _ = p.AppendWord(buf[:0], i)
+			h := xxhash.Sum64(buf)
+			if _, dup := seen[h]; dup {
+				repeated++
+				continue
+			}
+			seen[h] = struct{}{}
+			if err := w.writeLine(buf); err != nil {
+				w.close()
+				return err
+			}
+			written++
+		}
+		base += n
+		if end >= 0 && base >= end {
+			break
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	next := base
+	if end >= 0 {
+		next = min(next, end)
+	}
+	fmt.Fprintf(os.Stderr, "🔀 %s seeds: wrote %s variants, %s repeats dropped; -skip %d continues\n",
+		fmtInt(int64(len(phrases))), fmtInt(written), fmtInt(repeated), next)
+	return nil
+}
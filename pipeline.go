@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// starvingStage is the share of its input a stage must drop to be called
+// out in the report: such a filter is likely discarding most of the work.
+const starvingStage = 99.0
+
+// pipeline is a chain of filters and transforms applied to every word, with
+// counts of what went into and came out of each stage.
+type pipeline struct {
+	stages []*pipeStage
+	in     int64
+}
+
+// pipeStage is one step. fn returns the word to pass on, which may be a
+// rewritten copy, and false to drop it.
+type pipeStage struct {
+	name    string
+	fn      func(word []byte) ([]byte, bool)
+	in, out int64
+}
+
+func (p *pipeline) add(name string, fn func(word []byte) ([]byte, bool)) {
+	p.stages = append(p.stages, &pipeStage{name: name, fn: fn})
+}
+
+// run passes word through the stages and returns what comes out the end.
+func (p *pipeline) run(word []byte) ([]byte, bool) {
+	p.in++
+	for _, s := range p.stages {
+		s.in++
+		var ok bool
+		if word, ok = s.fn(word); !ok {
+			return nil, false
+		}
+		s.out++
+	}
+	return word, true
+}
+
+// takeCounts returns the pipeline's counts, the words in and then each
+// stage's in and out, and zeroes them.
+func (p *pipeline) takeCounts() []int64 {
+	counts := []int64{p.in}
+	p.in = 0
+	for _, s := range p.stages {
+		counts = append(counts, s.in, s.out)
+		s.in, s.out = 0, 0
+	}
+	return counts
+}
+
+// addCounts adds counts taken from a pipeline with the same stages.
+func (p *pipeline) addCounts(counts []int64) {
+	p.in += counts[0]
+	for i, s := range p.stages {
+		s.in += counts[1+2*i]
+		s.out += counts[2+2*i]
+	}
+}
+
+// emitted is how many words made it through every stage.
+func (p *pipeline) emitted() int64 {
+	if len(p.stages) == 0 {
+		return p.in
+	}
+	return p.stages[len(p.stages)-1].out
+}
+
+// status is the short form for a progress line: the share of its input each
+// stage passed, "policy 35.2% → match 4.10% → emitted 1,234".
+func (p *pipeline) status() string {
+	var b strings.Builder
+	for _, s := range p.stages {
+		fmt.Fprintf(&b, "%s %s%% → ", s.name, fmtFloat(percentOf(s.out, s.in), 1))
+	}
+	b.WriteString("emitted " + fmtInt(p.emitted()))
+	return b.String()
+}
+
+// report writes a table of the stages: read, then for each stage what it
+// received, passed and dropped, then emitted.
+func (p *pipeline) report(w io.Writer) {
+	fmt.Fprintf(w, "   %-22s %15s\n", "read", fmtInt(p.in))
+	for _, s := range p.stages {
+		dropped := s.in - s.out
+		pct := percentOf(dropped, s.in)
+		fmt.Fprintf(w, "   %-22s %15s passed  %15s dropped (%s%%)", s.name, fmtInt(s.out), fmtInt(dropped), fmtFloat(pct, 2))
+		if s.in > 0 && pct >= starvingStage {
+			fmt.Fprint(w, "  ⚠️  drops nearly everything")
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "   %-22s %15s (%s%% of read)\n", "emitted", fmtInt(p.emitted()), fmtFloat(percentOf(p.emitted(), p.in), 2))
+}
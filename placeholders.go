@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"main.go/wordlist"
+)
+
+// writePlaceholders creates every chunk file of the planned layout from
+// currentPos to end, the end of the run's slice, without generating any
+// words, so naming and storage can be checked before the real run. Mode
+// "empty" creates zero-byte files; mode "sparse" sizes each file like its
+// real chunk, clipped to the slice, without allocating blocks, so quotas and
+// tools that look at apparent sizes see the final layout.
+func writePlaceholders(ks *wordlist.Keyspace, currentPos, end int64, mode string) error {
+	if mode != "empty" && mode != "sparse" {
+		return fmt.Errorf("%w: unknown -placeholders mode %q (want empty or sparse)", ErrConfig, mode)
+	}
+
+	var files, planned int64
+	for fileNum := int(currentPos/entriesPerFile) + 1; int64(fileNum-1)*entriesPerFile < end; fileNum++ {
+		name := chunkName(fileNum)
+		size := ks.Bytes(max(int64(fileNum-1)*entriesPerFile, currentPos), min(int64(fileNum)*entriesPerFile, end))
+
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return diskError("create the directory of "+name, err)
+		}
+		file, err := os.Create(name)
+		if err != nil {
+			return diskError("create "+name, err)
+		}
+		if mode == "sparse" {
+			err = file.Truncate(size)
+		}
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return diskError("size "+name, err)
+		}
+		files++
+		planned += size
+	}
+
+	fmt.Printf("📁 Created %s placeholder files (%s) for %s of output\n", fmtInt(files), mode, fmtBytes(planned))
+	slog.Info("placeholders created", "mode", mode, "files", files, "bytes", planned)
+	if fs, err := statFS("."); err == nil && fs.free > 0 {
+		fmt.Printf("💾 Free space: %s\n", fmtBytes(fs.free))
+		if planned > fs.free {
+			slog.Warn("planned output does not fit in the free space", "bytes", planned, "free", fs.free)
+		}
+	}
+	return nil
+}
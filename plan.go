@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"main.go/wordlist"
+)
+
+// plan's ETA comes from what this machine can do rather than a guess: the
+// first plan measures how fast one core generates words and how fast the
+// output directory takes a sequential write, and keeps the figures in
+// hardwareFile under the user's cache directory for the plans after it. A
+// directory it has not measured yet is measured when a plan first runs in
+// it; -reprobe measures everything again, after a hardware change.
+
+// hardwareFile is where the figures are kept, under os.UserCacheDir.
+const hardwareFile = "bruteforce-wordlists/hardware.json"
+
+// Probe budgets: each measurement stops at whichever comes first.
+const (
+	probeTime      = time.Second
+	probeDiskBytes = 256 << 20
+)
+
+// hardwareProfile is what the probes measured.
+type hardwareProfile struct {
+	Generate float64            `json:"generate_bytes_per_second"`   // one core, plain output
+	Disks    map[string]float64 `json:"disk_write_bytes_per_second"` // by absolute directory, synced
+	CPUs     int                `json:"cpus"`
+	Probed   time.Time          `json:"probed"`
+	Version  string             `json:"version"`
+}
+
+// hardwarePath is the file the profile is kept in.
+func hardwarePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, hardwareFile), nil
+}
+
+// storedHardware returns the profile earlier plans measured; ok is false
+// when there is none, or it is unreadable.
+func storedHardware() (p hardwareProfile, ok bool) {
+	path, err := hardwarePath()
+	if err != nil {
+		return p, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || json.Unmarshal(data, &p) != nil {
+		return hardwareProfile{}, false
+	}
+	return p, p.Generate > 0
+}
+
+// loadHardware returns the stored profile, measuring what it lacks for dir
+// first and storing the result; with reprobe, or after the number of CPUs
+// changed, it measures everything again. A profile that cannot be stored is
+// still used, with a warning.
+func loadHardware(ctx context.Context, dir string, reprobe bool) (hardwareProfile, error) {
+	var p hardwareProfile
+	if !reprobe {
+		p, _ = storedHardware()
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return p, diskError("resolve "+dir, err)
+	}
+	_, measured := p.Disks[abs]
+	if p.Generate > 0 && p.CPUs == runtime.NumCPU() && measured {
+		return p, nil
+	}
+	if p.Generate <= 0 || p.CPUs != runtime.NumCPU() {
+		fmt.Fprintln(os.Stderr, "⏱  Measuring this machine's generation speed...")
+		if p.Generate, err = probeGeneration(ctx); err != nil {
+			return p, err
+		}
+		p.CPUs, p.Probed, p.Version = runtime.NumCPU(), time.Now().UTC(), buildVersion()
+	}
+	fmt.Fprintf(os.Stderr, "⏱  Measuring the write speed of %s...\n", abs)
+	rate, err := probeDisk(ctx, abs)
+	if err != nil {
+		return p, err
+	}
+	if p.Disks == nil {
+		p.Disks = make(map[string]float64)
+	}
+	p.Disks[abs] = rate
+	path, err := hardwarePath()
+	if err == nil {
+		data, _ := json.MarshalIndent(p, "", "  ")
+		if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+			err = writeFileAtomic(path, append(data, '\n'))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  The measurements could not be kept (%v); the next plan measures again\n", err)
+	}
+	return p, nil
+}
+
+// probeGeneration measures how many bytes of words a second one goroutine
+// generates, over the alphanumeric words of length 8.
+func probeGeneration(ctx context.Context) (float64, error) {
+	g, err := wordlist.NewGenerator("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 8, 8)
+	if err != nil {
+		return 0, err
+	}
+	words, err := g.Range(0, g.Total())
+	if err != nil {
+		return 0, err
+	}
+	out := &countingWriter{w: io.Discard}
+	start := time.Now()
+	for time.Since(start) < probeTime {
+		if _, err := words.WriteN(ctx, out, 1_000_000); err != nil {
+			return 0, err
+		}
+	}
+	return float64(out.n) / time.Since(start).Seconds(), nil
+}
+
+// probeDisk measures how many bytes a second a sequential write into dir
+// takes, synced, through a temporary file it removes again. The bytes are
+// words, so a compressing filesystem does not flatter it.
+func probeDisk(ctx context.Context, dir string) (float64, error) {
+	f, err := os.CreateTemp(dir, ".probe-")
+	if err != nil {
+		return 0, diskError("create probe file", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	g, err := wordlist.NewGenerator("abcdefghijklmnopqrstuvwxyz0123456789", 8, 8)
+	if err != nil {
+		return 0, err
+	}
+	block := make([]byte, 0, 1<<20)
+	for i := int64(0); len(block) < cap(block)-16; i += 7919 {
+		block, _ = g.AppendWord(block, i)
+		block = append(block, '\n')
+	}
+	var written int64
+	start := time.Now()
+	for written < probeDiskBytes && time.Since(start) < probeTime {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		n, err := f.Write(block)
+		written += int64(n)
+		if err != nil {
+			return 0, diskError("write probe file", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return 0, diskError("sync probe file", err)
+	}
+	return float64(written) / time.Since(start).Seconds(), nil
+}
+
+// planCmd prints what the run would write and how long it should take on
+// this machine, from the measured speeds.
+func planCmd(ctx context.Context, opts *options, _ []string) error {
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	pos, err := readState(stateFile, total)
+	if err != nil {
+		return err
+	}
+	start, end := runSlice()
+	pos = max(pos, start)
+	fmt.Printf("Keyspace  : %s\n", describeKeyspace())
+	if node > 0 {
+		fmt.Printf("Node      : %d of %d, positions %s–%s\n", node, nodes, fmtInt(start), fmtInt(end-1))
+	}
+	files := sliceFiles(start, end)
+	fmt.Printf("Words     : %s, %s in %s chunks of %s\n", fmtInt(end-start), fmtBytes(ks.Bytes(start, end)), fmtInt(files), fmtInt(entriesPerFile))
+	if pos > start {
+		fmt.Printf("Remaining : %s words, %s, after %s\n", fmtInt(end-pos), fmtBytes(ks.Bytes(pos, end)), stateFile)
+	}
+	if pos >= end {
+		fmt.Println("Finished  : every word generated")
+		return nil
+	}
+
+	hw, err := loadHardware(ctx, ".", opts.reprobe)
+	if err != nil {
+		return err
+	}
+	abs, _ := filepath.Abs(".")
+	disk := hw.Disks[abs]
+	workers := 1
+	if opts.output == "files" || opts.output == "null" {
+		workers = min(max(opts.workers, 1), hw.CPUs)
+	}
+	generate := hw.Generate * float64(workers)
+	fmt.Printf("Machine   : %s/s generated a core, %s/s written to this directory (measured %s; -reprobe to measure again)\n",
+		fmtBytes(int64(hw.Generate)), fmtBytes(int64(disk)), hw.Probed.Local().Format(time.DateTime))
+	rate, bound := generate, fmt.Sprintf("generation on %d of %d cores", workers, hw.CPUs)
+	switch {
+	case opts.output == "files" && opts.shm == "" && disk < generate:
+		rate, bound = disk, "the disk"
+	case opts.shm != "" || opts.output != "files" && opts.output != "null":
+		bound += ", if the reader keeps up"
+	}
+	eta := time.Duration(float64(ks.Bytes(pos, end)) / rate * float64(time.Second))
+	fmt.Printf("ETA       : %s at %s/s, bound by %s\n", fmtDuration(eta), fmtBytes(int64(rate)), bound)
+	if opts.compress.codec != "none" || len(mangling.rules) > 0 {
+		fmt.Println("            for the plain words: compression and -rules change the bytes and the time")
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Character classes a password policy can require, in the order they are
+// reported.
+var charClasses = []string{"lower", "upper", "digit", "symbol"}
+
+// classOf returns the class of r.
+func classOf(r rune) string {
+	switch {
+	case unicode.IsLower(r):
+		return "lower"
+	case unicode.IsUpper(r):
+		return "upper"
+	case unicode.IsDigit(r):
+		return "digit"
+	}
+	return "symbol"
+}
+
+// classesIn returns the classes that occur in s, in charClasses order.
+func classesIn(s string) []string {
+	seen := make(map[string]bool)
+	for _, r := range s {
+		seen[classOf(r)] = true
+	}
+	var out []string
+	for _, c := range charClasses {
+		if seen[c] {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// shapeClasses returns the classes named by the mask class letters in shape
+// (l, u, d, s: the first letters of the class names).
+func shapeClasses(shape string) []string {
+	var out []string
+	for _, c := range charClasses {
+		if strings.IndexByte(shape, c[0]) >= 0 {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// unionClasses merges two class lists in charClasses order.
+func unionClasses(a, b []string) []string {
+	var out []string
+	for _, c := range charClasses {
+		if slices.Contains(a, c) || slices.Contains(b, c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// passwordPolicy is what a target system accepts. Lengths count characters;
+// zero fields do not constrain.
+type passwordPolicy struct {
+	MinLen     int      `json:"min_length,omitempty"`
+	MaxLen     int      `json:"max_length,omitempty"`
+	Require    []string `json:"require,omitempty"`     // classes that must all occur
+	MinClasses int      `json:"min_classes,omitempty"` // distinct classes that must occur
+}
+
+// validate checks the policy's fields and puts Require in canonical order.
+func (p *passwordPolicy) validate() error {
+	if p.MinLen < 0 || p.MaxLen < 0 || (p.MaxLen > 0 && p.MaxLen < p.MinLen) {
+		return fmt.Errorf("%w: policy lengths %d-%d", ErrConfig, p.MinLen, p.MaxLen)
+	}
+	for _, c := range p.Require {
+		if !slices.Contains(charClasses, c) {
+			return fmt.Errorf("%w: policy requires unknown class %q (want %s)", ErrConfig, c, strings.Join(charClasses, ", "))
+		}
+	}
+	if p.MinClasses < 0 || p.MinClasses > len(charClasses) {
+		return fmt.Errorf("%w: policy min_classes %d", ErrConfig, p.MinClasses)
+	}
+	var req []string
+	for _, c := range charClasses {
+		if slices.Contains(p.Require, c) {
+			req = append(req, c)
+		}
+	}
+	p.Require = req
+	return nil
+}
+
+// allows reports whether word satisfies the policy.
+func (p *passwordPolicy) allows(word string) bool {
+	return p.allowsClasses(utf8.RuneCountInString(word), classesIn(word))
+}
+
+// allowsClasses reports whether a word of n characters using classes would
+// satisfy the policy.
+func (p *passwordPolicy) allowsClasses(n int, classes []string) bool {
+	if n < p.MinLen || (p.MaxLen > 0 && n > p.MaxLen) {
+		return false
+	}
+	for _, c := range p.Require {
+		if !slices.Contains(classes, c) {
+			return false
+		}
+	}
+	return len(classes) >= p.MinClasses
+}
+
+// Why a word fails a policy, for reports.
+const (
+	policyOK = iota
+	policyTooShort
+	policyTooLong
+	policyClasses
+)
+
+// policyCheck is a policy compiled for checking many words quickly: classes
+// are bits in charClasses order, and ASCII needs no decoding.
+type policyCheck struct {
+	minLen, maxLen int
+	need           uint8
+	minClasses     int
+}
+
+func (p *passwordPolicy) compile() policyCheck {
+	c := policyCheck{minLen: p.MinLen, maxLen: p.MaxLen, minClasses: p.MinClasses}
+	for i, cl := range charClasses {
+		if slices.Contains(p.Require, cl) {
+			c.need |= 1 << i
+		}
+	}
+	return c
+}
+
+// check returns policyOK or why word fails.
+func (c policyCheck) check(word []byte) int {
+	n, has := 0, uint8(0)
+	for i := 0; i < len(word); n++ {
+		b := word[i]
+		switch {
+		case b >= 'a' && b <= 'z':
+			has |= 1
+		case b >= 'A' && b <= 'Z':
+			has |= 2
+		case b >= '0' && b <= '9':
+			has |= 4
+		case b < utf8.RuneSelf:
+			has |= 8
+		default:
+			r, size := utf8.DecodeRune(word[i:])
+			has |= 1 << slices.Index(charClasses, classOf(r))
+			i += size
+			continue
+		}
+		i++
+	}
+	switch {
+	case n < c.minLen:
+		return policyTooShort
+	case c.maxLen > 0 && n > c.maxLen:
+		return policyTooLong
+	case has&c.need != c.need || bits.OnesCount8(has) < c.minClasses:
+		return policyClasses
+	}
+	return policyOK
+}
+
+// String describes the policy for people: "8-16 chars, needs upper+digit".
+func (p *passwordPolicy) String() string {
+	var parts []string
+	switch {
+	case p.MaxLen > 0:
+		parts = append(parts, fmt.Sprintf("%d-%d chars", p.MinLen, p.MaxLen))
+	case p.MinLen > 0:
+		parts = append(parts, fmt.Sprintf("at least %d chars", p.MinLen))
+	}
+	if len(p.Require) > 0 {
+		parts = append(parts, "needs "+strings.Join(p.Require, "+"))
+	}
+	if p.MinClasses > 0 {
+		parts = append(parts, fmt.Sprintf("%d of %d classes", p.MinClasses, len(charClasses)))
+	}
+	if len(parts) == 0 {
+		return "no constraints"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// policyLengths are the minimum lengths systems usually enforce; an inferred
+// minimum is rounded down to one of them.
+var policyLengths = []int{4, 6, 8, 10, 12, 14, 16, 20}
+
+// minExamples is how many examples make an inferred policy trustworthy: with
+// fewer, a class can be in all of them by chance.
+const minExamples = 5
+
+// inferPolicy guesses the policy that accepted every one of examples: the
+// shortest example's length rounded down to a usual minimum, the classes
+// every example contains, and the fewest classes any example uses. It can
+// only err on the strict side, by requiring what users chose anyway.
+func inferPolicy(examples []string) (passwordPolicy, error) {
+	if len(examples) == 0 {
+		return passwordPolicy{}, fmt.Errorf("%w: no example passwords to infer a policy from", ErrConfig)
+	}
+	shortest := math.MaxInt
+	fewest := len(charClasses)
+	common := charClasses
+	for _, ex := range examples {
+		classes := classesIn(ex)
+		shortest = min(shortest, utf8.RuneCountInString(ex))
+		fewest = min(fewest, len(classes))
+		common = slices.DeleteFunc(slices.Clone(common), func(c string) bool { return !slices.Contains(classes, c) })
+	}
+	p := passwordPolicy{Require: common}
+	for _, n := range policyLengths {
+		if n <= shortest {
+			p.MinLen = n
+		}
+	}
+	if fewest > max(len(common), 1) {
+		p.MinClasses = fewest
+	}
+	return p, nil
+}
+
+// readPolicyExamples reads the example passwords in path, one per line.
+func readPolicyExamples(path string) ([]string, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(lines, func(l string) bool { return l == "" }), nil
+}
+
+// loadPolicy reads a policy in the JSON form the policy tool writes.
+func loadPolicy(path string) (passwordPolicy, error) {
+	var p passwordPolicy
+	f, err := os.Open(path)
+	if err != nil {
+		return p, fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	defer f.Close()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&p); err != nil {
+		return p, fmt.Errorf("%w: %s: %w", ErrConfig, path, err)
+	}
+	return p, p.validate()
+}
+
+// reportInferred tells the user which policy the examples suggest, so they
+// can confirm it or write their own.
+func reportInferred(p *passwordPolicy, examples int) {
+	fmt.Fprintf(os.Stderr, "🔎 Inferred policy from %d examples: %s\n", examples, p)
+	if examples < minExamples {
+		fmt.Fprintf(os.Stderr, "⚠️  Only %d examples; classes they share by chance look required. Check the policy before relying on it.\n", examples)
+	}
+}
+
+func policyCmd(args []string) error {
+	fs := newToolFlags("policy", "examples.txt")
+	out := fs.String("o", "", "write the policy to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("%w: policy needs one file of example passwords", ErrConfig)
+	}
+	examples, err := readPolicyExamples(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	p, err := inferPolicy(examples)
+	if err != nil {
+		return err
+	}
+	w, err := createOutput(*out)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		w.Close()
+		return diskError("write policy", err)
+	}
+	if err := w.Close(); err != nil {
+		return diskError("write policy", err)
+	}
+	reportInferred(&p, len(examples))
+	return nil
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Multi-day runs happen on laptops too. -thermal-limit pauses generation
+// while the hottest thermal zone is above a temperature, until it has
+// cooled thermalHysteresis below it, and -on-battery pauses it, or brings
+// -workers down to one, while the machine runs on battery. Both read Linux
+// sysfs; elsewhere, or on a machine without the files, they never throttle.
+// A worker checks before each chunk, so a chunk under way is finished.
+
+// sysClass is where Linux exposes power supplies and thermal zones.
+const sysClass = "/sys/class"
+
+// thermalHysteresis is how far below -thermal-limit a paused run must cool
+// before it continues, so it does not flap around the limit.
+const thermalHysteresis = 5.0
+
+// powerPolicy is -thermal-limit, -on-battery and -power-poll.
+type powerPolicy struct {
+	tempLimit float64       // °C; 0 for none
+	battery   string        // ignore, reduce or pause
+	poll      time.Duration // how often the sensors are read
+}
+
+// powerGate holds back workers the power policy does not allow to run.
+type powerGate struct {
+	policy  powerPolicy
+	workers int
+
+	mu      sync.Mutex
+	checked time.Time
+	allowed int
+	hot     bool // above the limit, and not yet cooled below the hysteresis
+}
+
+// newPowerGate checks the policy for a run of workers workers; it returns
+// nil when the policy never throttles.
+func newPowerGate(p powerPolicy, workers int) (*powerGate, error) {
+	switch {
+	case p.battery != "ignore" && p.battery != "reduce" && p.battery != "pause":
+		return nil, fmt.Errorf("%w: -on-battery %q (want ignore, reduce or pause)", ErrConfig, p.battery)
+	case p.tempLimit < 0 || p.poll <= 0:
+		return nil, fmt.Errorf("%w: -thermal-limit must not be negative, nor -power-poll below 1ns", ErrConfig)
+	case p.tempLimit == 0 && p.battery == "ignore":
+		return nil, nil
+	}
+	if _, ok := hottestZone(); !ok && p.tempLimit > 0 {
+		slog.Warn("no thermal zones to read; -thermal-limit will not throttle", "dir", filepath.Join(sysClass, "thermal"))
+	}
+	if _, ok := onBattery(); !ok && p.battery != "ignore" {
+		slog.Warn("no battery to read; -on-battery will not throttle", "dir", filepath.Join(sysClass, "power_supply"))
+	}
+	return &powerGate{policy: p, workers: workers, allowed: workers}, nil
+}
+
+// wait blocks worker, counting from 0, while the policy allows fewer
+// workers than that, reading the sensors every poll.
+func (g *powerGate) wait(ctx context.Context, worker int) error {
+	if g == nil {
+		return nil
+	}
+	for g.update() <= worker {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(g.policy.poll):
+		}
+	}
+	return nil
+}
+
+// update reads the sensors, at most once a poll, and returns how many
+// workers may run. Changes are logged as they happen.
+func (g *powerGate) update() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if time.Since(g.checked) < g.policy.poll {
+		return g.allowed
+	}
+	g.checked = time.Now()
+	allowed, reason := g.workers, ""
+	if temp, ok := hottestZone(); ok && g.policy.tempLimit > 0 {
+		g.hot = temp > g.policy.tempLimit || g.hot && temp > g.policy.tempLimit-thermalHysteresis
+		if g.hot {
+			allowed, reason = 0, fmt.Sprintf("%.0f°C, above -thermal-limit %.0f°C", temp, g.policy.tempLimit)
+		}
+	}
+	if battery, ok := onBattery(); ok && battery && reason == "" {
+		switch g.policy.battery {
+		case "pause":
+			allowed, reason = 0, "on battery"
+		case "reduce":
+			allowed, reason = min(allowed, 1), "on battery"
+		}
+	}
+	switch {
+	case allowed != g.allowed && reason != "":
+		slog.Warn("throttling generation", "workers", allowed, "of", g.workers, "reason", reason)
+	case allowed != g.allowed:
+		slog.Info("generation back to full speed", "workers", allowed)
+	}
+	g.allowed = allowed
+	return allowed
+}
+
+// hottestZone returns the highest temperature of the thermal zones, in °C.
+func hottestZone() (temp float64, ok bool) {
+	zones, _ := filepath.Glob(filepath.Join(sysClass, "thermal", "thermal_zone*", "temp"))
+	for _, zone := range zones {
+		milli, err := readSysInt(zone)
+		if err != nil || milli <= 0 {
+			continue // a zone without a sensor reads as an error or 0
+		}
+		temp, ok = max(temp, float64(milli)/1000), true
+	}
+	return temp, ok
+}
+
+// onBattery reports whether a battery is discharging; ok is false when the
+// machine has none.
+func onBattery() (battery, ok bool) {
+	supplies, _ := filepath.Glob(filepath.Join(sysClass, "power_supply", "*"))
+	for _, s := range supplies {
+		kind, _ := os.ReadFile(filepath.Join(s, "type"))
+		if strings.TrimSpace(string(kind)) != "Battery" {
+			continue
+		}
+		ok = true
+		status, _ := os.ReadFile(filepath.Join(s, "status"))
+		if strings.TrimSpace(string(status)) == "Discharging" {
+			return true, true
+		}
+	}
+	return false, ok
+}
+
+func readSysInt(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
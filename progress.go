@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"main.go/wordlist"
+)
+
+// progress reports how the run is going, as -progress says: bar draws the
+// live progress line, log logs a record at each interval, json writes one
+// JSON object a line for a program to read, and none stays quiet. auto, the
+// default, draws the bar on a terminal and logs otherwise (nohup, systemd,
+// a pipe), so captured output is not filled with carriage returns.
+type progress struct {
+	out        *bufio.Writer
+	ks         *wordlist.Keyspace
+	total      int64
+	start, end int64 // the run's slice, which percent and ETA are of
+	interval   time.Duration
+	mode       string
+	lastUpdate time.Time
+	sinceLast  int64
+	bytesLast  int64 // bytes written since the last redraw
+	written    int64 // bytes written this run
+}
+
+// newProgress reports in mode to out on a run over positions [start, end),
+// redrawing the bar every interval and writing a record every logInterval.
+func newProgress(ks *wordlist.Keyspace, start, end int64, out io.Writer, mode string, interval, logInterval time.Duration) (*progress, error) {
+	p := &progress{
+		out:        bufio.NewWriter(out),
+		ks:         ks,
+		total:      ks.Total(),
+		start:      start,
+		end:        end,
+		interval:   logInterval,
+		mode:       mode,
+		lastUpdate: time.Now(),
+	}
+	switch mode {
+	case "auto":
+		p.mode = "log"
+		if isTerminal(os.Stdout) {
+			p.mode = "bar"
+		}
+	case "bar", "log", "json", "none":
+	default:
+		return nil, fmt.Errorf("%w: -progress %q (want auto, bar, log, json or none)", ErrConfig, mode)
+	}
+	if p.mode == "bar" {
+		p.interval = interval
+	}
+	return p, nil
+}
+
+func (p *progress) handle(e event) {
+	switch {
+	case e.kind == evTick:
+		p.advance(e.fileNum, e.file, e.pos, e.n, e.bytes)
+	case e.kind == evDone && p.mode == "json":
+		p.record(progressRecord{Time: e.time, Position: e.pos, Total: p.total, Percent: 100, Done: true})
+	}
+}
+
+// progressRecord is a line of -progress json.
+type progressRecord struct {
+	Time        time.Time `json:"time"`
+	Position    int64     `json:"position"`
+	Total       int64     `json:"total"`
+	Percent     float64   `json:"percent"`
+	WordsPerSec int64     `json:"words_per_sec"`
+	BytesPerSec int64     `json:"bytes_per_sec"`
+	ETASeconds  int64     `json:"eta_seconds"`
+	File        int       `json:"file,omitempty"`
+	CurrentFile string    `json:"current_file,omitempty"`
+	Length      int       `json:"length,omitempty"`
+	Written     int64     `json:"bytes_written"`
+	Done        bool      `json:"done,omitempty"`
+}
+
+// record writes r as a line of JSON.
+func (p *progress) record(r progressRecord) {
+	r.Written = p.written
+	data, _ := json.Marshal(r)
+	p.out.Write(append(data, '\n'))
+	p.out.Flush()
+}
+
+// advance records n more generated words taking size bytes, currentPos being
+// the position now reached in the chunk name, and reports at most once per
+// interval.
+func (p *progress) advance(fileNum int, name string, currentPos, n, size int64) {
+	p.sinceLast += n
+	p.bytesLast += size
+	p.written += size
+
+	now := time.Now()
+	if now.Sub(p.lastUpdate) < p.interval {
+		return
+	}
+	elapsed := now.Sub(p.lastUpdate).Seconds()
+	speed := float64(p.sinceLast) / elapsed
+	throughput := float64(p.bytesLast) / elapsed
+	percent := percentOf(currentPos-p.start, p.end-p.start)
+
+	// Later words are longer, so remaining bytes at the current byte rate
+	// estimate better than remaining words at the current word rate.
+	etaSeconds := float64(p.ks.Bytes(min(currentPos, p.end), p.end)) / throughput
+	eta := time.Duration(etaSeconds * float64(time.Second))
+
+	switch p.mode {
+	case "log":
+		slog.Info("progress", "file", fileNum, "position", currentPos, "percent", percent,
+			"words_per_sec", int64(speed), "bytes_per_sec", int64(throughput), "eta", eta.Round(time.Second).String(),
+			"length", p.ks.LengthAt(currentPos))
+	case "json":
+		p.record(progressRecord{Time: now, Position: currentPos, Total: p.total, Percent: percent,
+			WordsPerSec: int64(speed), BytesPerSec: int64(throughput), ETASeconds: int64(etaSeconds),
+			File: fileNum, CurrentFile: name, Length: p.ks.LengthAt(currentPos)})
+	case "bar":
+		p.draw(fileNum, currentPos, percent, speed, throughput, eta)
+	}
+	p.sinceLast = 0
+	p.bytesLast = 0
+	p.lastUpdate = now
+}
+
+func (p *progress) draw(fileNum int, currentPos int64, percent, speed, throughput float64, eta time.Duration) {
+	barFilled := int(percent / 2)
+	if barFilled > 50 {
+		barFilled = 50
+	}
+	bar := strings.Repeat("█", barFilled) + strings.Repeat("░", 50-barFilled)
+
+	fmt.Fprintf(p.out,
+		"\r🔧 File %06d │ %s %s%% │ %13s / %13s │ %9s │ Speed: %8s/s %10s/s │ ETA: %-9s │ %s",
+		fileNum, bar, fmtFloat(percent, 4), fmtInt(currentPos), fmtInt(p.total),
+		fmtBytes(p.written), fmtCount(int64(speed)), fmtBytes(int64(throughput)), fmtDuration(eta),
+		p.lengths(currentPos))
+	p.out.Flush()
+}
+
+// lengths shows how far each word length has got, since the longest lengths
+// dominate the keyspace and the overall percent hides where the run is:
+// "L1✓ L2✓ L3 42.10% L4·". A shuffled run advances every length at once,
+// so it says so instead.
+func (p *progress) lengths(currentPos int64) string {
+	if p.ks.Shuffled() {
+		return "shuffled"
+	}
+	var b strings.Builder
+	for l := p.ks.MinLen(); l <= p.ks.MaxLen(); l++ {
+		start, end := p.ks.LengthRange(l)
+		if l > p.ks.MinLen() {
+			b.WriteByte(' ')
+		}
+		switch {
+		case currentPos >= end:
+			fmt.Fprintf(&b, "L%d✓", l)
+		case currentPos <= start:
+			fmt.Fprintf(&b, "L%d·", l)
+		default:
+			fmt.Fprintf(&b, "L%d %s%%", l, fmtFloat(float64(currentPos-start)/float64(end-start)*100, 2))
+		}
+	}
+	return b.String()
+}
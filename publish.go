@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+
+	"main.go/wordlist"
+)
+
+// manifestFile lists a strong (SHA-256) and a fast (XXH64) hash of every
+// published chunk in the BSD tagged format, which both `sha256sum -c` and
+// `xxhsum -c` read, skipping the other's lines.
+const manifestFile = "CHECKSUMS"
+
+// legacyManifestFile is the manifest of manifest format 1, only SHA-256 in
+// the lines of sha256sum, which manifestFile replaced.
+const legacyManifestFile = "SHA256SUMS"
+
+// gitCfg is how publishing uses git. With -git auto, the default, a
+// directory outside a git work tree keeps its manifest and published log up
+// to date without committing anything; -git off does the same anywhere, and
+// -git on fails a publish there.
+var gitCfg = struct {
+	mode    string // auto, on or off
+	remote  string
+	branch  string
+	every   int // completed chunks between publishes
+	message string
+	msg     *template.Template
+	enabled bool // decided from mode when the run starts
+}{mode: "auto", remote: "origin", branch: "main", every: commitEvery, message: defaultCommitMessage}
+
+// defaultCommitMessage is -git-message's default. The template gets a
+// commitInfo.
+const defaultCommitMessage = "Wordlist progress: added files up to {{.Last}} ({{.Files}} files)"
+
+// commitInfo is what a -git-message template is executed with.
+type commitInfo struct {
+	Last  string // the name of the last completed chunk
+	Files int    // chunks of the run's slice completed
+	Added int    // chunks in this commit
+	Done  int64  // the position the run has reached
+}
+
+// addGitFlags registers the -git flags on fs.
+func addGitFlags(fs *flag.FlagSet) {
+	fs.StringVar(&gitCfg.mode, "git", gitCfg.mode, "commit and push published chunks: `mode` on, off, or auto (only inside a git work tree)")
+	fs.StringVar(&gitCfg.remote, "git-remote", gitCfg.remote, "push to this `remote`")
+	fs.StringVar(&gitCfg.branch, "git-branch", gitCfg.branch, "push this `branch`")
+	fs.IntVar(&gitCfg.every, "git-every", gitCfg.every, "publish after every `n` completed chunks, and once more at the end")
+	fs.StringVar(&gitCfg.message, "git-message", gitCfg.message, "commit message `template`: {{.Last}} chunk name, {{.Files}} chunks completed, {{.Added}} chunks committed, {{.Done}} position")
+}
+
+// checkGit validates the -git flags and decides whether this run commits.
+func checkGit(ctx context.Context) error {
+	if gitCfg.every < 1 {
+		return fmt.Errorf("%w: -git-every %d is below 1", ErrConfig, gitCfg.every)
+	}
+	msg, err := template.New("git-message").Option("missingkey=error").Parse(gitCfg.message)
+	if err == nil {
+		err = msg.Execute(io.Discard, commitInfo{})
+	}
+	if err != nil {
+		return fmt.Errorf("%w: -git-message: %w", ErrConfig, err)
+	}
+	gitCfg.msg = msg
+	switch gitCfg.mode {
+	case "on":
+		gitCfg.enabled = true
+	case "off":
+		gitCfg.enabled = false
+	case "auto":
+		gitCfg.enabled = exec.CommandContext(ctx, "git", "rev-parse", "--is-inside-work-tree").Run() == nil
+		if !gitCfg.enabled {
+			slog.Info("no git work tree here; publishing only updates the manifest and the published log", "manifest", manifestFile, "log", publishedLog)
+		}
+	default:
+		return fmt.Errorf("%w: -git %q (want on, off or auto)", ErrConfig, gitCfg.mode)
+	}
+	return nil
+}
+
+// checksum is a chunk's hashes in hex.
+type checksum struct {
+	sha256 string
+	xxh64  string
+}
+
+// hasher computes a chunk's checksum from the bytes as they are written, so
+// a finished chunk never has to be read back.
+type hasher struct {
+	strong hash.Hash
+	fast   *xxhash.Digest
+}
+
+// matches reports whether c, computed from a file, is the checksum want
+// a manifest records for it; a manifest of format 1 records no XXH64.
+func (c checksum) matches(want checksum) bool {
+	return c.sha256 == want.sha256 && (want.xxh64 == "" || c.xxh64 == want.xxh64)
+}
+
+func newHasher() *hasher { return &hasher{sha256.New(), xxhash.New()} }
+
+func (h *hasher) Write(p []byte) (int, error) {
+	h.strong.Write(p)
+	h.fast.Write(p)
+	return len(p), nil
+}
+
+func (h *hasher) sum() checksum {
+	return checksum{hex.EncodeToString(h.strong.Sum(nil)), fmt.Sprintf("%016x", h.fast.Sum64())}
+}
+
+// gitCommitAndPush commits the chunk files that are new or changed since the
+// last manifest, together with the updated manifest, and pushes; without
+// git it only updates the manifest and the published log. fresh holds
+// the checksums of chunks written by this run, taken while writing them. When nothing
+// changed no commit is made, but earlier commits are still pushed.
+//
+// Only finalized chunks (those ending at or before done, the position saved
+// in the state), the manifest, the published log, the reproducibility
+// contract and the status page of
+// -status publish are ever committed: the state, logs, snapshots and a
+// chunk still being written stay out of the repository, and so does
+// anything else a user happened to stage.
+func gitCommitAndPush(ctx context.Context, filesCompleted int, done int64, fresh map[string]checksum) error {
+	events.emit(event{kind: evPublishStart, files: filesCompleted})
+
+	ctx, cancel := context.WithTimeout(ctx, pushTimeout)
+	defer cancel()
+
+	sums, err := upgradeManifest(manifestFile)
+	if err != nil {
+		return err
+	}
+	changed, err := changedChunks(sums, done, fresh)
+	if err != nil {
+		return err
+	}
+
+	if len(changed) == 0 {
+		slog.Info("no new or changed chunks to commit")
+	} else {
+		previous, readErr := os.ReadFile(manifestFile)
+		if err := writeManifest(manifestFile, sums); err != nil {
+			return err
+		}
+		logSize, err := appendPublished(changed, sums, done)
+		if err == nil && gitCfg.enabled {
+			slog.Debug("staging chunks", "files", changed)
+			var msg strings.Builder
+			err = gitCfg.msg.Execute(&msg, commitInfo{Last: chunkName(int((done + entriesPerFile - 1) / entriesPerFile)), Files: filesCompleted, Added: len(changed), Done: done})
+			paths := append(append(changed, manifestFile, publishedLog, contractFile), statusFiles...)
+			if err == nil {
+				err = git(ctx, "git add", append([]string{"add", "--"}, paths...)...)
+			}
+			if err == nil {
+				err = git(ctx, "git commit", append([]string{"commit", "-m", msg.String(), "--"}, paths...)...)
+			}
+			if err != nil {
+				os.Truncate(publishedLog, logSize)
+			}
+		}
+		if err != nil {
+			// Not committed: forget the new sums so the next publish retries them.
+			if readErr == nil {
+				writeFileAtomic(manifestFile, previous)
+			} else {
+				os.Remove(manifestFile)
+			}
+			return err
+		}
+	}
+	clear(fresh) // in the manifest now
+	if gitCfg.enabled {
+		if err := git(ctx, "git push", "push", gitCfg.remote, gitCfg.branch); err != nil {
+			return err
+		}
+	}
+	events.emit(event{kind: evPublish, files: filesCompleted})
+	return nil
+}
+
+// git runs one git command, naming it by op in errors.
+func git(ctx context.Context, op string, args ...string) error {
+	c := exec.CommandContext(ctx, "git", args...)
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrPublishFailed, op, err) // e.g. auth or network issue
+	}
+	return nil
+}
+
+// changedChunks returns the finalized chunk files whose checksum is not yet
+// in sums, recording it there. Checksums come from fresh where possible;
+// other chunks are hashed from disk, but only when they are missing from
+// sums or were modified after the manifest was written. Chunks reaching past
+// done are not finalized and are left out.
+func changedChunks(sums map[string]checksum, done int64, fresh map[string]checksum) ([]string, error) {
+	var since time.Time
+	if fi, err := os.Stat(manifestFile); err == nil {
+		since = fi.ModTime()
+	}
+	nums, err := chunkFiles()
+	if err != nil {
+		return nil, diskError("list chunks", err)
+	}
+	_, end := runSlice() // a run stopped by -stop-at finishes its last chunk there
+	var changed []string
+	for _, n := range nums {
+		name := chunkName(n)
+		if int64(n-1)*entriesPerFile >= total || min(int64(n)*entriesPerFile, end) > done {
+			slog.Debug("not publishing unfinished chunk", "file", name)
+			continue
+		}
+		sum, ok := fresh[name]
+		if !ok {
+			fi, err := os.Stat(name)
+			if err != nil {
+				return nil, diskError("stat "+name, err)
+			}
+			if _, ok := sums[name]; ok && !fi.ModTime().After(since) {
+				continue
+			}
+			if sum, err = fileChecksum(name); err != nil {
+				return nil, err
+			}
+		}
+		if sums[name] != sum {
+			sums[name] = sum
+			changed = append(changed, name)
+		}
+	}
+	return changed, nil
+}
+
+func fileChecksum(name string) (checksum, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return checksum{}, diskError("open "+name, err)
+	}
+	defer f.Close()
+	h := newHasher()
+	if _, err := io.Copy(h, f); err != nil {
+		return checksum{}, diskError("read "+name, err)
+	}
+	return h.sum(), nil
+}
+
+// readManifest returns the checksums recorded in path by file name; a
+// missing manifest is empty, and the legacyManifestFile of an older run
+// stands in for it. A manifest in an older format is read as it is, and
+// left so: only publishing migrates it, through upgradeManifest.
+func readManifest(path string) (map[string]checksum, error) {
+	sums, _, _, err := loadManifest(path)
+	return sums, err
+}
+
+// upgradeManifest is readManifest for publishing, which writes the
+// manifest: one in an older format, or only the legacyManifestFile of an
+// older run beside a missing one, is migrated to path first.
+func upgradeManifest(path string) (map[string]checksum, error) {
+	sums, from, version, err := loadManifest(path)
+	if err != nil || from == "" || from == path && !wordlist.ManifestFormat.Migrates(version) {
+		return sums, err
+	}
+	if err := migrateManifest(path, sums); err != nil {
+		return nil, err
+	}
+	slog.Info("migrated the manifest to the current format", "from", from, "format", version, "to", path, "now", wordlist.ManifestFormat.Current)
+	return sums, nil
+}
+
+// loadManifest reads the manifest at path, or the legacyManifestFile
+// beside it, and returns its checksums, which file they came from ("" for
+// neither) and its format.
+func loadManifest(path string) (map[string]checksum, string, wordlist.Version, error) {
+	from := path
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		from = filepath.Join(filepath.Dir(path), legacyManifestFile)
+		f, err = os.Open(from)
+		if errors.Is(err, fs.ErrNotExist) {
+			return make(map[string]checksum), "", wordlist.Version{}, nil
+		}
+	}
+	if err != nil {
+		return nil, "", wordlist.Version{}, diskError("open "+from, err)
+	}
+	defer f.Close()
+	entries, version, err := wordlist.ReadManifest(f)
+	if errors.Is(err, wordlist.ErrBadManifest) {
+		return nil, "", version, fmt.Errorf("%w: %s: %w", ErrStateCorrupt, from, err)
+	}
+	if err != nil {
+		return nil, "", version, diskError("read "+from, err)
+	}
+	if !wordlist.ManifestFormat.Reads(version) {
+		return nil, "", version, fmt.Errorf("%w: %s: %w", ErrConfig, from, wordlist.ErrFormatVersion)
+	}
+	sums := make(map[string]checksum, len(entries))
+	for name, e := range entries {
+		sums[name] = checksum{sha256: e.SHA256, xxh64: e.XXH64}
+	}
+	return sums, from, version, nil
+}
+
+// migrateManifest writes sums, read from a manifest of format 1, to path in
+// the current format. Format 1 has only SHA-256, so each chunk still in the
+// manifest's directory is hashed again for its XXH64, and must match the
+// SHA-256 it was published with.
+func migrateManifest(path string, sums map[string]checksum) error {
+	dir := filepath.Dir(path)
+	for name, c := range sums {
+		if c.xxh64 != "" {
+			continue
+		}
+		got, err := fileChecksum(filepath.Join(dir, name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue // its XXH64 stays unknown
+		}
+		if err != nil {
+			return err
+		}
+		if c.sha256 != "" && got.sha256 != c.sha256 {
+			return fmt.Errorf("%w: %s does not match its SHA-256 in the manifest, which is left unmigrated", ErrPublishFailed, name)
+		}
+		sums[name] = got
+	}
+	return writeManifest(path, sums)
+}
+
+func writeManifest(path string, sums map[string]checksum) error {
+	var b strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(sums)) {
+		if c := sums[name]; c.xxh64 == "" {
+			fmt.Fprintf(&b, "SHA256 (%s) = %s\n", name, c.sha256)
+		} else {
+			fmt.Fprintf(&b, "SHA256 (%s) = %s\nXXH64 (%s) = %s\n", name, c.sha256, name, c.xxh64)
+		}
+	}
+	return diskError("save manifest", writeFileAtomic(path, []byte(b.String())))
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// publishedLog gains a line for every publish that commits chunks: which
+// word ranges and files it added, with their checksums. It is only ever
+// appended to, so a mirror that remembers how many lines it has read knows
+// exactly what to fetch next.
+const publishedLog = "PUBLISHED.jsonl"
+
+// publishRecord is one line of publishedLog.
+type publishRecord struct {
+	Time      time.Time       `json:"time"`
+	Generator string          `json:"generator,omitempty"` // buildVersion of the program that published
+	Algorithm int             `json:"algorithm,omitempty"` // its algorithmRevision
+	Keyspace  string          `json:"keyspace"`
+	PerFile   int64           `json:"per_file"`
+	Start     int64           `json:"start"`    // the first index added
+	End       int64           `json:"end"`      // one past the last
+	Position  int64           `json:"position"` // every index before it is generated
+	Files     []publishedFile `json:"files"`
+}
+
+type publishedFile struct {
+	Name   string `json:"name"`
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"`
+	SHA256 string `json:"sha256"`
+	XXH64  string `json:"xxh64"`
+}
+
+// appendPublished records the publish of the chunks changed, at position
+// done, and returns the size the log had before, for undoing it when the
+// commit fails.
+func appendPublished(changed []string, sums map[string]checksum, done int64) (int64, error) {
+	var before int64
+	if fi, err := os.Stat(publishedLog); err == nil {
+		before = fi.Size()
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return 0, diskError("stat "+publishedLog, err)
+	}
+	rec := publishRecord{
+		Time:      time.Now().UTC(),
+		Generator: buildVersion(),
+		Algorithm: algorithmRevision,
+		Keyspace:  describeKeyspace(),
+		PerFile:   entriesPerFile,
+		Start:     total,
+		Position:  done,
+	}
+	from, to := runSlice() // -start-from and -stop-at cut the chunks at the ends short
+	for _, name := range changed {
+		n, ok := chunkLayout.Number(name)
+		if !ok {
+			continue
+		}
+		start, end := max((n-1)*entriesPerFile, from), min(n*entriesPerFile, to)
+		rec.Start, rec.End = min(rec.Start, start), max(rec.End, end)
+		rec.Files = append(rec.Files, publishedFile{name, start, end, sums[name].sha256, sums[name].xxh64})
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(publishedLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, diskError("open "+publishedLog, err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return before, diskError("write "+publishedLog, err)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"main.go/wordlist"
+)
+
+// shardChunk is a chunk listed in one shard's manifest.
+type shardChunk struct {
+	shard, name string
+	sum         checksum
+}
+
+func (c shardChunk) path() string { return filepath.Join(c.shard, c.name) }
+
+func reassembleCmd(args []string) error {
+	fs := newToolFlags("reassemble", "shard-dir...")
+	perFile := fs.Int64("per-file", entriesPerFile, "words per chunk file the shards were generated with")
+	out := fs.String("o", manifestFile, "write the unified manifest to this `file`; its names are relative to its directory")
+	partial := fs.Bool("partial", false, "accept gaps in the coverage (shards still running) and only fail on overlaps")
+	verify := fs.Bool("verify", false, "also rehash every chunk and compare its words with the keyspace")
+	addKeyspaceFlags(fs)
+	addLayoutFlags(fs)
+	addExclusionFlags(fs)
+	addOrderFlags(fs)
+	addRuleFlags(fs)
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if err := loadRules(); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: reassemble needs the shard directories", ErrConfig)
+	}
+	entriesPerFile = *perFile
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	expected := int((total + entriesPerFile - 1) / entriesPerFile)
+
+	byNum := make(map[int][]shardChunk)
+	var problems []string
+	problem := func(format string, a ...any) { problems = append(problems, fmt.Sprintf(format, a...)) }
+	for _, dir := range fs.Args() {
+		sums, err := readManifest(filepath.Join(dir, manifestFile))
+		if err != nil {
+			return err
+		}
+		if len(sums) == 0 {
+			problem("%s: no %s, or an empty one", dir, manifestFile)
+		}
+		for name, sum := range sums {
+			num, ok := chunkLayout.Number(name)
+			if !ok {
+				continue // not a chunk
+			}
+			n := int(num)
+			c := shardChunk{dir, name, sum}
+			if _, err := os.Stat(c.path()); err != nil {
+				problem("%s is in %s's manifest but missing", c.path(), dir)
+				continue
+			}
+			if n < 1 || n > expected {
+				problem("%s lies beyond the keyspace's %d chunks of %s", c.path(), expected, fmtInt(entriesPerFile))
+				continue
+			}
+			byNum[n] = append(byNum[n], c)
+		}
+		// Chunks on disk but never published are not covered by the shard.
+		names, _ := filepath.Glob(filepath.Join(dir, chunkLayout.Pattern()+"*"))
+		for _, p := range names {
+			rel, err := filepath.Rel(dir, p)
+			if _, ok := chunkLayout.Number(p); ok && err == nil && !strings.HasSuffix(p, partSuffix) {
+				if _, ok := sums[filepath.ToSlash(rel)]; !ok {
+					fmt.Fprintf(os.Stderr, "⚠️  %s is not in %s's manifest; it is left out\n", p, dir)
+				}
+			}
+		}
+	}
+
+	// Overlaps: a chunk in several shards is harmless when every copy is the
+	// same, and a conflict otherwise.
+	unified := make(map[string]checksum)
+	outDir := filepath.Dir(*out)
+	var duplicates int
+	for _, n := range slices.Sorted(maps.Keys(byNum)) {
+		copies := byNum[n]
+		slices.SortFunc(copies, func(a, b shardChunk) int { return strings.Compare(a.path(), b.path()) })
+		for _, c := range copies[1:] {
+			if c.sum != copies[0].sum {
+				problem("chunk %d differs between %s and %s", n, copies[0].path(), c.path())
+			} else {
+				duplicates++
+			}
+		}
+		c := copies[0]
+		if *verify {
+			if err := verifyShardChunk(ks, c, n); err != nil {
+				problem("%s: %v", c.path(), err)
+			}
+		}
+		rel, err := filepath.Rel(outDir, c.path())
+		if err != nil {
+			rel = c.path()
+		}
+		unified[filepath.ToSlash(rel)] = c.sum
+	}
+
+	// Gaps, as word index ranges.
+	var gaps []string
+	var missing int64
+	for n := 1; n <= expected; n++ {
+		if _, ok := byNum[n]; ok {
+			continue
+		}
+		first := n
+		for n < expected {
+			if _, ok := byNum[n+1]; ok {
+				break
+			}
+			n++
+		}
+		start := int64(first-1) * entriesPerFile
+		end := min(int64(n)*entriesPerFile, total)
+		missing += end - start
+		gaps = append(gaps, fmt.Sprintf("chunks %d-%d (words %s-%s)", first, n, fmtInt(start), fmtInt(end-1)))
+	}
+
+	fmt.Fprintf(os.Stderr, "🧩 %d shards cover %d of %d chunks (%s of %s words)",
+		fs.NArg(), len(byNum), expected, fmtInt(total-missing), fmtInt(total))
+	if duplicates > 0 {
+		fmt.Fprintf(os.Stderr, ", %d identical duplicates", duplicates)
+	}
+	fmt.Fprintln(os.Stderr)
+	for _, g := range gaps {
+		fmt.Fprintf(os.Stderr, "   gap: %s\n", g)
+	}
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "   ❌ %s\n", p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("reassemble: %d problems across the shards", len(problems))
+	}
+	if len(gaps) > 0 && !*partial {
+		return fmt.Errorf("reassemble: the coverage has %d gaps (pass -partial while shards are still running)", len(gaps))
+	}
+	if err := writeManifest(*out, unified); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Wrote %s listing %d chunks\n", *out, len(unified))
+	return nil
+}
+
+// verifyShardChunk checks c against its manifest entry and its words
+// against chunk n of the keyspace.
+func verifyShardChunk(ks *wordlist.Keyspace, c shardChunk, n int) error {
+	sum, err := fileChecksum(c.path())
+	if err != nil {
+		return err
+	}
+	if !sum.matches(c.sum) {
+		return errors.New("checksum does not match the manifest")
+	}
+	start := int64(n-1) * entriesPerFile
+	return verifyChunk(context.Background(), ks, c.path(), start, min(start+entriesPerFile, total))
+}
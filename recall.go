@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strings"
+)
+
+// recallMask is one shape a remembered password can take: a mask whose
+// fixed part holds the fragment it contains at offset at, after a start of
+// from characters. A word is only written by the shape that has the
+// fragment where it first occurs after the start, so shapes placing it at
+// different offsets never repeat a word.
+type recallMask struct {
+	positions maskSpace
+	contains  string
+	from, at  int // at is -1 when there is no fragment
+}
+
+// recallPlan turns remembered fragments into the masks that enumerate
+// exactly the words with them: a start, a fragment somewhere after it, an
+// end, a length in [minLen, maxLen], and fill characters everywhere else.
+// Every start, fragment and end may have alternatives.
+func recallPlan(starts, contains, ends [][]string, fill string, minLen, maxLen int) []recallMask {
+	if len(starts) == 0 {
+		starts = [][]string{nil}
+	}
+	if len(ends) == 0 {
+		ends = [][]string{nil}
+	}
+	fills := func(n int) []string { return slices.Repeat([]string{fill}, n) }
+	var plan []recallMask
+	for l := minLen; l <= maxLen; l++ {
+		for _, s := range starts {
+			for _, e := range ends {
+				free := l - len(s) - len(e)
+				if len(contains) == 0 {
+					if free >= 0 {
+						plan = append(plan, recallMask{concat(s, fills(free), e), "", len(s), -1})
+					}
+					continue
+				}
+				for _, c := range contains {
+					free := free - len(c)
+					for a := 0; a <= free; a++ {
+						plan = append(plan, recallMask{concat(s, fills(a), c, fills(free-a), e), strings.Join(c, ""), len(s), len(s) + a})
+					}
+				}
+			}
+		}
+	}
+	return plan
+}
+
+func concat(parts ...[]string) maskSpace {
+	var out maskSpace
+	for _, p := range parts {
+		out = append(out, p...)
+	}
+	return out
+}
+
+// parseFragments parses comma-separated alternatives, each a mask (so
+// "Sun" is literal and "?d?d" two digits), or each literal when literal is
+// set.
+func parseFragments(flag, list string, literal bool) ([][]string, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var out [][]string
+	for _, alt := range strings.Split(list, ",") {
+		var positions []string
+		if literal {
+			for i := range len(alt) {
+				positions = append(positions, alt[i:i+1])
+			}
+		} else {
+			var err error
+			if positions, err = parseMask(alt); err != nil {
+				return nil, fmt.Errorf("%s: %w", flag, err)
+			}
+		}
+		if len(positions) == 0 {
+			return nil, fmt.Errorf("%w: %s has an empty alternative", ErrConfig, flag)
+		}
+		out = append(out, positions)
+	}
+	return out, nil
+}
+
+// fillSet is the union of the characters a mask allows anywhere: "?l?d"
+// gives the lower-case letters and digits.
+func fillSet(mask string) (string, error) {
+	positions, err := parseMask(mask)
+	if err != nil {
+		return "", err
+	}
+	var set []byte
+	for _, p := range positions {
+		for i := range len(p) {
+			if bytes.IndexByte(set, p[i]) < 0 {
+				set = append(set, p[i])
+			}
+		}
+	}
+	if len(set) == 0 {
+		return "", fmt.Errorf("%w: -fill %q allows no characters", ErrConfig, mask)
+	}
+	return string(set), nil
+}
+
+// askFragments asks for the fragments on the terminal, for a user who ran
+// recall without any.
+func askFragments(starts, contains, ends, lengths, fill *string) {
+	in := bufio.NewScanner(os.Stdin)
+	ask := func(question string, answer *string) {
+		fmt.Fprintf(os.Stderr, "%s [%s]: ", question, *answer)
+		if in.Scan() && strings.TrimSpace(in.Text()) != "" {
+			*answer = strings.TrimSpace(in.Text())
+		}
+	}
+	fmt.Fprintln(os.Stderr, "🧩 What do you remember? Separate alternatives with commas; ?l ?u ?d ?s ?a are any lower, upper, digit, symbol or character.")
+	ask("Starts with (e.g. Sun,sun)", starts)
+	ask("Contains (e.g. your dog's name)", contains)
+	ask("Ends with (e.g. ?d?d or 2019)", ends)
+	ask("Length (MIN-MAX)", lengths)
+	ask("Other characters are among", fill)
+}
+
+func recallCmd(args []string) error {
+	fs := newToolFlags("recall", "")
+	starts := fs.String("starts", "", "the password starts with one of these comma-separated `masks` (Sun,sun or ?uun)")
+	contains := fs.String("contains", "", "it contains one of these comma-separated `words`, after the start")
+	ends := fs.String("ends", "", "it ends with one of these comma-separated `masks` (?d?d or 2019)")
+	lengths := fs.String("lengths", "8-12", "its length, `MIN-MAX`")
+	fill := fs.String("fill", "?l?d", "the other characters are among these `classes`, as a mask: ?l?d, ?a, ?l?u...")
+	count := fs.Bool("count", false, "only report how many candidates the fragments allow")
+	out := fs.String("o", "", "write the candidates to this `file` instead of stdout")
+	score := scoreFlag(fs)
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if *starts == "" && *contains == "" && *ends == "" {
+		if !isTerminal(os.Stdin) {
+			fs.Usage()
+			return fmt.Errorf("%w: recall needs at least one of -starts, -contains and -ends", ErrConfig)
+		}
+		askFragments(starts, contains, ends, lengths, fill)
+	}
+	minLen, maxLen, err := parseLengths(*lengths)
+	if err != nil {
+		return err
+	}
+	s, err := parseFragments("-starts", *starts, false)
+	if err != nil {
+		return err
+	}
+	c, err := parseFragments("-contains", *contains, true)
+	if err != nil {
+		return err
+	}
+	e, err := parseFragments("-ends", *ends, false)
+	if err != nil {
+		return err
+	}
+	set, err := fillSet(*fill)
+	if err != nil {
+		return err
+	}
+
+	plan := recallPlan(s, c, e, set, minLen, maxLen)
+	var bound int64
+	for _, m := range plan {
+		if size := m.positions.Total(); bound > math.MaxInt64-size {
+			bound = math.MaxInt64
+		} else {
+			bound += size
+		}
+	}
+	what := "candidates"
+	if len(c) > 0 {
+		what = "candidates at most (a fragment that repeats is written once)"
+	}
+	fmt.Fprintf(os.Stderr, "🧩 %d shapes, %s %s\n", len(plan), fmtCount(bound), what)
+	if *count || len(plan) == 0 {
+		return nil
+	}
+
+	model, err := loadScoreModel(*score)
+	if err != nil {
+		return err
+	}
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	w.model = model
+	var buf []byte
+	var written int64
+	for _, m := range plan {
+		for i := range m.positions.Total() {
+			buf, _ = m.positions.AppendWord(buf[:0], i)
+			if m.at >= 0 && m.from+bytes.Index(buf[m.from:], []byte(m.contains)) != m.at {
+				continue // another shape writes it
+			}
+			if err := w.writeLine(buf); err != nil {
+				w.close()
+				return err
+			}
+			written++
+		}
+	}
+	if err := w.close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "✅ Wrote %s candidates\n", fmtInt(written))
+	return nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"main.go/wordlist"
+)
+
+// tailWindow is how much of a chunk's end recover reads looking for its last
+// complete line; far more than the longest word.
+const tailWindow = 64 << 10
+
+// recoverState rebuilds stateFile from the chunk files on disk, for when it is
+// missing or no longer plausible. The newest chunk's last complete line is
+// mapped back to its position: a complete chunk resumes after it, an
+// incomplete one is regenerated from its start.
+func recoverState(ks *wordlist.Keyspace) error {
+	if len(mangling.rules) > 0 {
+		return fmt.Errorf("%w: recover maps a chunk's last line back to its position, which the words of -rules do not tell; use -list-snapshots and -rollback instead", ErrConfig)
+	}
+	chunks, err := chunkFiles()
+	if err != nil {
+		return err
+	}
+	old, stateErr := readState(stateFile, total)
+	switch {
+	case stateErr != nil:
+		fmt.Printf("⚠️  %s is unusable: %v\n", stateFile, stateErr)
+	case old == 0:
+		fmt.Printf("ℹ️  %s is missing or at the start\n", stateFile)
+	default:
+		fmt.Printf("ℹ️  %s says resume at position %s\n", stateFile, fmtInt(old))
+	}
+
+	// The chunks at the ends of a bounded run hold only its part of them.
+	first, last := runSlice()
+	resume := first
+	for _, fileNum := range slices.Backward(chunks) {
+		name := chunkName(fileNum)
+		start := int64(fileNum-1) * entriesPerFile
+		if start >= total {
+			return fmt.Errorf("%w: %s lies beyond the keyspace of %d with -per-file %d", ErrStateCorrupt, name, total, entriesPerFile)
+		}
+		start, end := max(start, first), min(start+entriesPerFile, last)
+		if start >= end {
+			continue // outside the run
+		}
+		word, err := lastLine(name)
+		if err != nil {
+			return err
+		}
+		if word == "" && ks.NextIncluded(start) >= end {
+			fmt.Printf("🔍 %s is complete: every word of it is excluded\n", name)
+			resume = end
+			break
+		}
+		if word == "" {
+			fmt.Printf("🔍 %s has no complete line, looking at the one before\n", name)
+			continue
+		}
+		indices, err := ks.IndicesOf(word)
+		if err != nil {
+			return fmt.Errorf("%w: %s: last line: %w", ErrStateCorrupt, name, err)
+		}
+		// A word a combinator keyspace holds twice in the chunk is taken at
+		// its first place, so at worst the chunk is regenerated.
+		pos := indices[0]
+		if i := slices.IndexFunc(indices, func(i int64) bool { return i >= start }); i >= 0 {
+			pos = indices[i]
+		}
+		if pos < start || pos >= end {
+			return fmt.Errorf("%w: %s ends with %q at position %d, outside its range [%d, %d) with -per-file %d",
+				ErrStateCorrupt, name, word, pos, start, end, entriesPerFile)
+		}
+		if ks.NextIncluded(pos+1) >= end {
+			fmt.Printf("🔍 %s is complete, ending with %q at position %s\n", name, word, fmtInt(pos))
+			resume = end
+		} else {
+			fmt.Printf("🔍 %s stops early at %q (position %s of %s–%s); it will be regenerated\n",
+				name, word, fmtInt(pos), fmtInt(start), fmtInt(end-1))
+			resume = start
+		}
+		break
+	}
+
+	if err := writeState(stateFile, resume-1); err != nil {
+		return err
+	}
+	slog.Info("state recovered", "resume", resume, "previous", old, "previous_err", stateErr)
+	if resume >= last {
+		fmt.Printf("✅ %s rebuilt: the whole run of %s words is already generated\n", stateFile, fmtInt(last-first))
+	} else {
+		fmt.Printf("✅ %s rebuilt: generation will resume at position %s in %s\n",
+			stateFile, fmtInt(resume), chunkName(int(resume/entriesPerFile)+1))
+	}
+	return nil
+}
+
+// chunkFiles returns the numbers of the chunk files on disk, ascending.
+func chunkFiles() ([]int, error) {
+	names, err := filepath.Glob(chunkPattern())
+	if err != nil {
+		return nil, err
+	}
+	var nums []int
+	for _, name := range names {
+		n, ok := chunkLayout.Number(name)
+		if ok && chunkName(int(n)) == filepath.ToSlash(name) {
+			nums = append(nums, int(n))
+		}
+	}
+	slices.Sort(nums)
+	return nums, nil
+}
+
+// lastLine returns the last newline-terminated line of name, or "" if it
+// has none. A trailing line without its newline was cut off mid-write and
+// does not count. A compressed chunk is decompressed from its start.
+func lastLine(name string) (string, error) {
+	if chunkExt != "" {
+		return lastLineStream(name)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return "", diskError("open "+name, err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", diskError("stat "+name, err)
+	}
+	offset := max(fi.Size()-tailWindow, 0)
+	buf := make([]byte, fi.Size()-offset)
+	if _, err := f.ReadAt(buf, offset); err != nil && err != io.EOF {
+		return "", diskError("read "+name, err)
+	}
+
+	end := bytes.LastIndexByte(buf, '\n')
+	if end < 0 {
+		return "", nil
+	}
+	start := bytes.LastIndexByte(buf[:end], '\n') + 1
+	if start == 0 && offset > 0 {
+		return "", fmt.Errorf("%w: %s: last line is longer than %d bytes", ErrStateCorrupt, name, tailWindow)
+	}
+	return string(buf[start:end]), nil
+}
+
+func lastLineStream(name string) (string, error) {
+	r, err := openChunk(name)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s: %w", ErrStateCorrupt, name, err)
+	}
+	defer r.Close()
+	br := bufio.NewReaderSize(r, 1<<16)
+	var last []byte
+	for {
+		line, err := br.ReadSlice('\n')
+		if err == nil {
+			last = append(last[:0], line[:len(line)-1]...)
+			continue
+		}
+		if err == io.EOF {
+			return string(last), nil
+		}
+		if err == bufio.ErrBufferFull {
+			return "", fmt.Errorf("%w: %s: line longer than %d bytes", ErrStateCorrupt, name, br.Size())
+		}
+		return "", fmt.Errorf("%w: %s: %w", ErrStateCorrupt, name, err)
+	}
+}
+
+// adoptChunk looks for the compressed chunk covering [start, end) that an
+// interrupted run finished but did not record: under its final name when the
+// state was not saved, or still under its .part name when it was not
+// renamed. A candidate whose frames all pass their checksums and which holds
+// exactly the expected words is finalized and reported as stored; anything
+// else is deleted, to be generated again.
+func adoptChunk(ctx context.Context, ks *wordlist.Keyspace, path string, start, end int64) (stored, bool, error) {
+	for _, candidate := range []string{path, path + partSuffix} {
+		if _, err := os.Stat(candidate); err != nil {
+			continue
+		}
+		if err := verifyChunk(ctx, ks, candidate, start, end); err != nil {
+			if ctx.Err() != nil {
+				return stored{}, false, ctx.Err()
+			}
+			slog.Warn("discarding damaged chunk", "file", candidate, "err", err)
+			os.Remove(candidate)
+			continue
+		}
+		if candidate != path {
+			if err := os.Rename(candidate, path); err != nil {
+				return stored{}, false, diskError("finalize "+path, err)
+			}
+		}
+		fi, err := os.Stat(path)
+		if err != nil {
+			return stored{}, false, diskError("stat "+path, err)
+		}
+		sum, err := fileChecksum(path)
+		if err != nil {
+			return stored{}, false, err
+		}
+		slog.Info("adopted chunk left complete by the interrupted run", "file", path)
+		return stored{fi.Size(), &sum}, true, nil
+	}
+	return stored{}, false, nil
+}
+
+// verifyChunk decompresses path, which checks every frame's checksum, and
+// compares the result with the words at [start, end).
+func verifyChunk(ctx context.Context, ks *wordlist.Keyspace, path string, start, end int64) error {
+	r, err := openChunk(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	words, err := ks.Range(start, end)
+	if err != nil {
+		return err
+	}
+	if _, err := words.WriteN(ctx, mangle(&compareWriter{r: r}), end-start); err != nil {
+		return err
+	}
+	if n, _ := io.ReadFull(r, make([]byte, 1)); n > 0 {
+		return errors.New("holds more than the expected words")
+	}
+	return nil
+}
+
+// compareWriter fails as soon as what is written differs from what r reads.
+type compareWriter struct {
+	r   io.Reader
+	buf []byte
+}
+
+func (c *compareWriter) Write(p []byte) (int, error) {
+	if cap(c.buf) < len(p) {
+		c.buf = make([]byte, len(p))
+	}
+	got := c.buf[:len(p)]
+	if _, err := io.ReadFull(c.r, got); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(got, p) {
+		return 0, errors.New("content differs from the expected words")
+	}
+	return len(p), nil
+}
+
+// lineCounter counts the lines written to it.
+type lineCounter struct{ n int64 }
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	c.n += int64(bytes.Count(p, []byte{'\n'}))
+	return len(p), nil
+}
+
+// checkLastChunk checks that the chunk the state counts as the last
+// complete one before pos holds as many lines as it has words, so a resume
+// does not build on a chunk a crash cut short or a copy truncated. A run
+// whose slice starts at or after pos has no such chunk, and one whose slice
+// ends before it, left by a run that went on past this one's -stop-at, is
+// complete with nothing to resume; a missing one, moved away or cleaned up
+// after publishing, is only warned about.
+func checkLastChunk(ctx context.Context, ks *wordlist.Keyspace, prefix string, from, to, pos int64) error {
+	if pos <= from || pos > to {
+		return nil
+	}
+	n := int((pos-1)/entriesPerFile) + 1
+	start, end := max(int64(n-1)*entriesPerFile, from), min(int64(n)*entriesPerFile, to)
+	name := prefix + chunkName(n)
+	want := end - start
+	if len(mangling.rules) > 0 {
+		// A rule may reject a word, so only applying them says how many
+		// lines the chunk holds.
+		words, err := ks.Range(start, end)
+		if err != nil {
+			return err
+		}
+		lines := &lineCounter{}
+		if _, err := words.WriteN(ctx, mangle(lines), end-start); err != nil {
+			return err
+		}
+		want = lines.n
+	} else if excludes() {
+		want = 0
+		for i := ks.NextIncluded(start); i < end; i = ks.NextIncluded(i + 1) {
+			want++
+		}
+	}
+	r, err := openChunk(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Warn("the last chunk the state records is not here to check", "chunk", name, "state", stateFile)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrStateCorrupt, name, err)
+	}
+	defer r.Close()
+	var lines int64
+	buf := make([]byte, 1<<20)
+	for ctx.Err() == nil {
+		k, err := r.Read(buf)
+		lines += int64(bytes.Count(buf[:k], []byte{'\n'}))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrStateCorrupt, name, err)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if lines != want {
+		return fmt.Errorf("%w: %s holds %s lines, but %s records it complete at %s lines; run recover, or -rollback to an earlier snapshot",
+			ErrStateCorrupt, name, fmtInt(lines), stateFile, fmtInt(want))
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"main.go/wordlist"
+)
+
+// contractFile is the reproducibility contract of the chunks in a
+// directory: the algorithm revision and configuration they were generated
+// with, and the program that started them. It is written when a run first
+// keeps chunks there and published with them; a later run, or verify,
+// checks itself against it before adding to them.
+const contractFile = "REPRODUCE.json"
+
+// algorithmRevision numbers the ways of turning a configuration into chunk
+// bytes. A change to which word a position holds, the chunk layout, the
+// rules or the compressed framing must bump it: a program with another
+// revision would not reproduce the chunks byte for byte, so it refuses to
+// continue a run started by one.
+const algorithmRevision = 1
+
+// reproContract is what contractFile holds.
+type reproContract struct {
+	Algorithm  int               `json:"algorithm"`
+	Generator  string            `json:"generator"`            // buildVersion of the program that started the run
+	Compressor string            `json:"compressor,omitempty"` // the codec's implementation, which may frame the same words differently between versions
+	Config     reproConfig       `json:"config"`
+	Formats    map[string]string `json:"formats,omitempty"` // the version of each of wordlist.Formats the files here are in
+	Created    time.Time         `json:"created"`
+}
+
+// reproConfig is the configuration that decides a run's chunk bytes.
+type reproConfig struct {
+	CharsetXXH64  string `json:"charset_xxh64"`
+	Mask          string `json:"mask,omitempty"`
+	Hybrid        string `json:"hybrid,omitempty"`
+	Combine       string `json:"combine,omitempty"`
+	Order         string `json:"order,omitempty"`
+	MinLength     int    `json:"min_length"`
+	MaxLength     int    `json:"max_length"`
+	PerFile       int64  `json:"per_file"`
+	Exclude       string `json:"exclude,omitempty"`
+	RulesXXH64    string `json:"rules_xxh64,omitempty"`
+	Compress      string `json:"compress"`
+	CompressLevel int    `json:"compress_level,omitempty"`
+}
+
+// currentContract is the contract of a run with the flags given.
+func currentContract(comp compression) reproContract {
+	return reproContract{
+		Algorithm:  algorithmRevision,
+		Generator:  buildVersion(),
+		Compressor: compressorVersion(comp.codec),
+		Config: reproConfig{
+			CharsetXXH64:  charsetXXH64(),
+			Mask:          keyspaceMask,
+			Hybrid:        hybridSpec(),
+			Combine:       combinatorSpec(),
+			Order:         orderSpec(),
+			MinLength:     minLength,
+			MaxLength:     maxLength,
+			PerFile:       entriesPerFile,
+			Exclude:       describeExclusions(),
+			RulesXXH64:    mangling.sum,
+			Compress:      comp.codec,
+			CompressLevel: comp.level,
+		},
+		Formats: currentFormats(),
+		Created: time.Now().UTC(),
+	}
+}
+
+// currentFormats is the version of each file format this program writes.
+func currentFormats() map[string]string {
+	formats := make(map[string]string)
+	for _, f := range wordlist.Formats() {
+		formats[f.Name] = f.Current.String()
+	}
+	return formats
+}
+
+// compressorVersion names what implements codec: the Go release for
+// gzip, the module version of the zstd library.
+func compressorVersion(codec string) string {
+	switch codec {
+	case "gzip":
+		return "compress/gzip " + runtime.Version()
+	case "zstd":
+		if info, ok := debug.ReadBuildInfo(); ok {
+			for _, dep := range info.Deps {
+				if dep.Path == "github.com/klauspost/compress" {
+					return dep.Path + " " + dep.Version
+				}
+			}
+		}
+		return "github.com/klauspost/compress"
+	}
+	return ""
+}
+
+// readContract returns the contract in the current directory; ok is false
+// when there is none.
+func readContract() (c reproContract, ok bool, err error) {
+	data, err := os.ReadFile(contractFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, false, nil
+	}
+	if err != nil {
+		return c, false, diskError("read "+contractFile, err)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, false, fmt.Errorf("%w: %s: %w", ErrStateCorrupt, contractFile, err)
+	}
+	return c, true, nil
+}
+
+// keepContract checks a run against the contract of the chunks already
+// here, or records its own when there is none yet. A contract naming older
+// formats, whose files the run migrates as it loads them, is brought up to
+// date.
+func keepContract(comp compression) error {
+	want := currentContract(comp)
+	have, ok, err := readContract()
+	if err != nil {
+		return err
+	}
+	if ok {
+		if err := have.check(want); err != nil || maps.Equal(have.Formats, want.Formats) {
+			return err
+		}
+		have.Formats = want.Formats
+		want = have
+	}
+	data, _ := json.MarshalIndent(want, "", "  ")
+	return diskError("write "+contractFile, writeFileAtomic(contractFile, append(data, '\n')))
+}
+
+// check refuses want, the contract of this program and flags, when it
+// would not reproduce the chunks of c.
+func (c *reproContract) check(want reproContract) error {
+	for _, f := range wordlist.Formats() {
+		v, ok := c.Formats[f.Name]
+		if !ok {
+			continue // a contract from before contracts recorded formats
+		}
+		have, err := wordlist.ParseVersion(v)
+		if err == nil {
+			_, err = f.Negotiate(have)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w; the files here were written by %s, a newer version of the program, which must continue the run",
+				ErrConfig, contractFile, err, c.Generator)
+		}
+	}
+	if c.Algorithm != want.Algorithm {
+		return fmt.Errorf("%w: %s: the chunks here were generated by %s with algorithm revision %d, which this program (%s, revision %d) does not reproduce byte for byte; continue the run with %s, or start a new one in another directory",
+			ErrConfig, contractFile, c.Generator, c.Algorithm, want.Generator, want.Algorithm, c.Generator)
+	}
+	if c.Config != want.Config {
+		return fmt.Errorf("%w: %s: the chunks here were generated with other settings (%s); pass the run's own to continue it, or start a new one in another directory",
+			ErrConfig, contractFile, strings.Join(configChanges(c.Config, want.Config), "; "))
+	}
+	if c.Compressor != want.Compressor {
+		slog.Warn("the chunks here were compressed by another implementation: the words are the same, the compressed bytes may not be",
+			"contract", contractFile, "compressed_by", c.Compressor, "compressing", want.Compressor)
+	}
+	if c.Generator != want.Generator {
+		slog.Info("continuing chunks another version of the program started, with the same algorithm revision",
+			"contract", contractFile, "started_by", c.Generator, "running", want.Generator)
+	}
+	return nil
+}
+
+// configChanges lists the fields in which now differs from was.
+func configChanges(was, now reproConfig) []string {
+	var a, b map[string]any
+	data, _ := json.Marshal(was)
+	json.Unmarshal(data, &a)
+	data, _ = json.Marshal(now)
+	json.Unmarshal(data, &b)
+	show := func(v any) string {
+		if v == nil {
+			return "none"
+		}
+		return fmt.Sprint(v)
+	}
+	keys := maps.Clone(a)
+	maps.Copy(keys, b)
+	var changes []string
+	for _, k := range slices.Sorted(maps.Keys(keys)) {
+		if show(a[k]) != show(b[k]) {
+			changes = append(changes, fmt.Sprintf("%s %s, not %s", k, show(a[k]), show(b[k])))
+		}
+	}
+	return changes
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// A rule file holds hashcat mangling rules, one per line: each is a series
+// of functions such as $1 (append 1), c (capitalize) or sa@ (replace every
+// a with @), applied in turn to a word. With -rules every generated word is
+// written once per rule, as that rule leaves it, in the order of the file;
+// a rule whose reject function refuses a word writes nothing for it.
+// Positions count from 0, written 0-9 then A-Z for 10-35, and a function
+// whose position falls outside the word leaves it unchanged, as in hashcat.
+// The memory functions (M, 4, 6, X, Q) are not supported.
+
+// mangling is -rules: the rules every generated word goes through before it
+// is written, or none.
+var mangling struct {
+	path  string
+	rules []rule
+	sum   string // XXH64 of the rule file, which the state records
+}
+
+// rule is one line of a rule file.
+type rule struct {
+	text string
+	ops  []ruleOp
+}
+
+// ruleOp is a rule function with its arguments: positions n and m,
+// characters x and y.
+type ruleOp struct {
+	fn   byte
+	n, m int
+	x, y byte
+}
+
+// ruleArgs are the arguments each rule function takes: N and M positions,
+// X and Y characters.
+var ruleArgs = map[byte]string{
+	':': "", 'l': "", 'u': "", 'c': "", 'C': "", 't': "", 'r': "", 'd': "", 'f': "",
+	'{': "", '}': "", '[': "", ']': "", 'q': "", 'k': "", 'K': "", 'E': "",
+	'T': "N", 'p': "N", 'D': "N", '\'': "N", 'z': "N", 'Z': "N", 'L': "N", 'R': "N",
+	'+': "N", '-': "N", '.': "N", ',': "N", 'y': "N", 'Y': "N", '<': "N", '>': "N", '_': "N",
+	'$': "X", '^': "X", '@': "X", '!': "X", '/': "X", '(': "X", ')': "X", 'e': "X",
+	'x': "NM", 'O': "NM", '*': "NM",
+	'i': "NX", 'o': "NX", '=': "NX", '%': "NX",
+	's': "XY",
+}
+
+// addRuleFlags registers -rules on fs.
+func addRuleFlags(fs *flag.FlagSet) {
+	fs.StringVar(&mangling.path, "rules", "", "apply the hashcat mangling rules of this `file` to every word, writing what each rule makes of it")
+}
+
+// loadRules reads the -rules file, if there is one.
+func loadRules() error {
+	if mangling.path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(mangling.path)
+	if err != nil {
+		return fmt.Errorf("%w: -rules: %w", ErrConfig, err)
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r, err := parseRule(line)
+		if err != nil {
+			return fmt.Errorf("%w: -rules %s, line %d: %w", ErrConfig, mangling.path, i+1, err)
+		}
+		mangling.rules = append(mangling.rules, r)
+	}
+	if len(mangling.rules) == 0 {
+		return fmt.Errorf("%w: -rules %s holds no rules", ErrConfig, mangling.path)
+	}
+	mangling.sum = fmt.Sprintf("%016x", xxhash.Sum64(data))
+	return nil
+}
+
+// parseRule parses one rule. Spaces between functions are ignored.
+func parseRule(text string) (rule, error) {
+	r := rule{text: text}
+	for i := 0; i < len(text); {
+		fn := text[i]
+		i++
+		if fn == ' ' {
+			continue
+		}
+		args, ok := ruleArgs[fn]
+		if !ok {
+			if strings.IndexByte("M46XQ", fn) >= 0 {
+				return r, fmt.Errorf("the memory function %q is not supported", fn)
+			}
+			return r, fmt.Errorf("unknown function %q", fn)
+		}
+		if i+len(args) > len(text) {
+			return r, fmt.Errorf("function %q wants %d arguments", fn, len(args))
+		}
+		op := ruleOp{fn: fn}
+		for j, kind := range []byte(args) {
+			c := text[i+j]
+			switch kind {
+			case 'N', 'M':
+				p, ok := rulePosition(c)
+				if !ok {
+					return r, fmt.Errorf("function %q: %q is not a position (0-9, A-Z)", fn, c)
+				}
+				if kind == 'N' {
+					op.n = p
+				} else {
+					op.m = p
+				}
+			case 'X':
+				op.x = c
+			case 'Y':
+				op.y = c
+			}
+		}
+		i += len(args)
+		r.ops = append(r.ops, op)
+	}
+	return r, nil
+}
+
+// rulePosition decodes a position: 0-9, then A-Z for 10-35.
+func rulePosition(c byte) (int, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'A' && c <= 'Z':
+		return int(c-'A') + 10, true
+	}
+	return 0, false
+}
+
+// apply runs the rule on w, which it may change, and returns the result;
+// ok is false when a reject function refuses the word.
+func (r *rule) apply(w []byte) ([]byte, bool) {
+	for _, op := range r.ops {
+		n, m := op.n, op.m
+		switch op.fn {
+		case 'l':
+			asciiCase(w, lowerByte)
+		case 'u':
+			asciiCase(w, upperByte)
+		case 'c':
+			asciiCase(w, lowerByte)
+			asciiCase(w[:min(1, len(w))], upperByte)
+		case 'C':
+			asciiCase(w, upperByte)
+			asciiCase(w[:min(1, len(w))], lowerByte)
+		case 't':
+			asciiCase(w, toggleByte)
+		case 'T':
+			if n < len(w) {
+				w[n] = toggleByte(w[n])
+			}
+		case 'E', 'e':
+			sep := byte(' ')
+			if op.fn == 'e' {
+				sep = op.x
+			}
+			asciiCase(w, lowerByte)
+			for i := range w {
+				if i == 0 || w[i-1] == sep {
+					w[i] = upperByte(w[i])
+				}
+			}
+		case 'r':
+			slices.Reverse(w)
+		case 'd':
+			w = append(w, w...)
+		case 'p':
+			l := len(w)
+			for range n {
+				w = append(w, w[:l]...)
+			}
+		case 'f':
+			l := len(w)
+			w = append(w, w...)
+			slices.Reverse(w[l:])
+		case '{':
+			if len(w) > 1 {
+				c := w[0]
+				copy(w, w[1:])
+				w[len(w)-1] = c
+			}
+		case '}':
+			if len(w) > 1 {
+				c := w[len(w)-1]
+				copy(w[1:], w)
+				w[0] = c
+			}
+		case '$':
+			w = append(w, op.x)
+		case '^':
+			w = slices.Insert(w, 0, op.x)
+		case '[':
+			if len(w) > 0 {
+				w = w[1:]
+			}
+		case ']':
+			if len(w) > 0 {
+				w = w[:len(w)-1]
+			}
+		case 'D':
+			if n < len(w) {
+				w = slices.Delete(w, n, n+1)
+			}
+		case 'x':
+			if n+m <= len(w) {
+				w = w[n : n+m]
+			}
+		case 'O':
+			if n+m <= len(w) {
+				w = slices.Delete(w, n, n+m)
+			}
+		case 'i':
+			if n <= len(w) {
+				w = slices.Insert(w, n, op.x)
+			}
+		case 'o':
+			if n < len(w) {
+				w[n] = op.x
+			}
+		case '\'':
+			if n < len(w) {
+				w = w[:n]
+			}
+		case 's':
+			for i, c := range w {
+				if c == op.x {
+					w[i] = op.y
+				}
+			}
+		case '@':
+			w = slices.DeleteFunc(w, func(c byte) bool { return c == op.x })
+		case 'z':
+			if len(w) > 0 {
+				w = slices.Insert(w, 0, bytes.Repeat(w[:1], n)...)
+			}
+		case 'Z':
+			if len(w) > 0 {
+				w = append(w, bytes.Repeat(w[len(w)-1:], n)...)
+			}
+		case 'q':
+			d := make([]byte, 0, 2*len(w))
+			for _, c := range w {
+				d = append(d, c, c)
+			}
+			w = d
+		case 'k':
+			if len(w) > 1 {
+				w[0], w[1] = w[1], w[0]
+			}
+		case 'K':
+			if l := len(w); l > 1 {
+				w[l-2], w[l-1] = w[l-1], w[l-2]
+			}
+		case '*':
+			if n < len(w) && m < len(w) {
+				w[n], w[m] = w[m], w[n]
+			}
+		case 'L', 'R', '+', '-':
+			if n < len(w) {
+				switch op.fn {
+				case 'L':
+					w[n] <<= 1
+				case 'R':
+					w[n] >>= 1
+				case '+':
+					w[n]++
+				case '-':
+					w[n]--
+				}
+			}
+		case '.':
+			if n+1 < len(w) {
+				w[n] = w[n+1]
+			}
+		case ',':
+			if n > 0 && n < len(w) {
+				w[n] = w[n-1]
+			}
+		case 'y':
+			if n <= len(w) {
+				w = slices.Insert(w, 0, bytes.Clone(w[:n])...)
+			}
+		case 'Y':
+			if n <= len(w) {
+				w = append(w, bytes.Clone(w[len(w)-n:])...)
+			}
+		case '<', '>', '_', '!', '/', '(', ')', '=', '%':
+			if !keeps(op, w) {
+				return nil, false
+			}
+		}
+	}
+	return w, true
+}
+
+// keeps reports whether the reject function op lets w through.
+func keeps(op ruleOp, w []byte) bool {
+	n := op.n
+	switch op.fn {
+	case '<':
+		return len(w) <= n
+	case '>':
+		return len(w) >= n
+	case '_':
+		return len(w) == n
+	case '!':
+		return bytes.IndexByte(w, op.x) < 0
+	case '/':
+		return bytes.IndexByte(w, op.x) >= 0
+	case '(':
+		return len(w) > 0 && w[0] == op.x
+	case ')':
+		return len(w) > 0 && w[len(w)-1] == op.x
+	case '=':
+		return n < len(w) && w[n] == op.x
+	}
+	return bytes.Count(w, []byte{op.x}) >= n // '%'
+}
+
+func asciiCase(w []byte, f func(byte) byte) {
+	for i, c := range w {
+		w[i] = f(c)
+	}
+}
+
+func lowerByte(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func upperByte(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - ('a' - 'A')
+	}
+	return c
+}
+
+func toggleByte(c byte) byte {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' {
+		return c ^ 0x20
+	}
+	return c
+}
+
+// mangle returns w, or with -rules a writer that passes w each rule's
+// result for every newline-terminated word written to it.
+func mangle(w io.Writer) io.Writer {
+	if len(mangling.rules) == 0 {
+		return w
+	}
+	return &ruleWriter{w: w, rules: mangling.rules}
+}
+
+// ruleWriter applies rules to the words written to it and writes the
+// results to w. A write ending inside a word keeps the rest until the next.
+type ruleWriter struct {
+	w     io.Writer
+	rules []rule
+	tail  []byte // an unfinished word
+	word  []byte
+	out   []byte
+}
+
+func (rw *ruleWriter) Write(p []byte) (int, error) {
+	data := p
+	if len(rw.tail) > 0 {
+		rw.tail = append(rw.tail, p...)
+		data = rw.tail
+	}
+	rw.out = rw.out[:0]
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		for k := range rw.rules {
+			word, ok := rw.rules[k].apply(append(rw.word[:0], data[:i]...))
+			if ok {
+				rw.out = append(append(rw.out, word...), '\n')
+			}
+			if cap(word) > cap(rw.word) {
+				rw.word = word[:0]
+			}
+		}
+		data = data[i+1:]
+	}
+	rw.tail = append(rw.tail[:0], data...)
+	if _, err := rw.w.Write(rw.out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
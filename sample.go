@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"main.go/wordlist"
+)
+
+// A test set should look like the keyspace without being all of it:
+// -sample N writes N of its words to stdout instead of generating chunks,
+// drawn uniformly at random without replacement. The draw is the first N
+// words of the permutation -seed picks, so the same seed and flags give the
+// same sample, and a larger N extends a smaller one. Excluded words are
+// passed over, -rules apply to every sampled word as they would in a run,
+// and -nodes, -start-from and -stop-at narrow what is sampled.
+
+// writeSample writes n words of the run's slice of ks, as the permutation
+// seed picks them, one per line to stdout.
+func writeSample(ctx context.Context, ks *wordlist.Keyspace, n int64, seed uint64) error {
+	start, end := runSlice()
+	if n > end-start {
+		return fmt.Errorf("%w: -sample %s is more than the %s words there are", ErrConfig, fmtInt(n), fmtInt(end-start))
+	}
+	perm, err := wordlist.NewPermutation(end-start, seed)
+	if err != nil {
+		return err
+	}
+	buf := bufio.NewWriterSize(os.Stdout, 1<<20)
+	w := mangle(buf)
+	var word []byte
+	var written int64
+	for i := int64(0); i < perm.Len() && written < n; i++ {
+		if i%(1<<16) == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		index := start + perm.At(i)
+		if ks.Excluded(index) {
+			continue
+		}
+		if word, err = ks.AppendWord(word[:0], index); err != nil {
+			return err
+		}
+		if _, err := w.Write(append(word, '\n')); err != nil {
+			return fmt.Errorf("%w: %w", ErrOutput, err)
+		}
+		written++
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("%w: %w", ErrOutput, err)
+	}
+	if written < n {
+		slog.Warn("the exclusions leave fewer words than -sample asks for; wrote them all", "sample", n, "words", written)
+	}
+	return nil
+}
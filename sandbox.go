@@ -0,0 +1,17 @@
+package main
+
+// sandboxConfig is the confinement asked for on the command line.
+type sandboxConfig struct {
+	confine  bool     // restrict filesystem writes (and network) with the kernel's help
+	user     string   // user to switch to when started as root
+	writable []string // directories the run may write to; everything else is read-only
+	network  bool     // allow outgoing TCP connections, for publishing and tcp:// streams
+	seccomp  bool     // filter dangerous syscalls and unexpected socket families
+
+	maxFileSize  uint64 // RLIMIT_FSIZE in bytes, 0 to leave unchanged
+	maxOpenFiles uint64 // RLIMIT_NOFILE, 0 to leave unchanged
+}
+
+// sandboxEnv marks a process that has already been confined by its parent,
+// so the re-executed binary does not try to confine itself again.
+const sandboxEnv = "WORDGEN_SANDBOXED"
@@ -0,0 +1,221 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+// Landlock ABI, from linux/landlock.h.
+const (
+	sysLandlockCreateRuleset = 444
+	sysLandlockAddRule       = 445
+	sysLandlockRestrictSelf  = 446
+
+	landlockCreateRulesetVersion = 1 << 0
+	landlockRulePathBeneath      = 1
+	prSetNoNewPrivs              = 38
+)
+
+const (
+	fsExecute = 1 << iota
+	fsWriteFile
+	fsReadFile
+	fsReadDir
+	fsRemoveDir
+	fsRemoveFile
+	fsMakeChar
+	fsMakeDir
+	fsMakeReg
+	fsMakeSock
+	fsMakeFifo
+	fsMakeBlock
+	fsMakeSym
+	fsRefer    // ABI 2
+	fsTruncate // ABI 3
+
+	fsRead = fsExecute | fsReadFile | fsReadDir
+)
+
+const (
+	netBindTCP = 1 << iota // ABI 4
+	netConnectTCP
+)
+
+// enterSandbox applies resource limits, drops root privileges and confines
+// the process. Landlock and seccomp filters apply per thread and Go runs
+// many, so both are installed on one locked thread which then re-executes
+// the binary: the new process starts inside the sandbox with every thread it
+// will ever have. When it installs anything it does not return on success.
+func enterSandbox(cfg sandboxConfig) error {
+	if os.Getenv(sandboxEnv) != "" {
+		return nil
+	}
+	if err := setLimits(cfg); err != nil {
+		return err
+	}
+	if cfg.user != "" {
+		if err := dropPrivileges(cfg.user); err != nil {
+			return err
+		}
+	} else if os.Geteuid() == 0 {
+		slog.Warn("running as root; pass -user to drop privileges")
+	}
+	if !cfg.confine && !cfg.seccomp {
+		return nil
+	}
+
+	runtime.LockOSThread() // never unlocked if this thread execs below
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("%w: sandbox: no_new_privs: %w", ErrConfig, errno)
+	}
+	installed := false
+	if cfg.confine {
+		ok, err := installLandlock(cfg)
+		if err != nil {
+			return err
+		}
+		installed = ok
+	}
+	if cfg.seccomp {
+		if err := installSeccomp(cfg.network); err != nil {
+			return fmt.Errorf("%w: seccomp: %w", ErrConfig, err)
+		}
+		installed = true
+	}
+	if !installed {
+		runtime.UnlockOSThread()
+		return nil
+	}
+
+	os.Setenv(sandboxEnv, "1")
+	return syscall.Exec("/proc/self/exe", os.Args, os.Environ())
+}
+
+// installLandlock restricts the calling thread to writing cfg.writable and,
+// on kernels that support it, to the TCP use cfg allows. It reports false
+// when the kernel has no landlock.
+func installLandlock(cfg sandboxConfig) (bool, error) {
+	abi, _, errno := syscall.Syscall(sysLandlockCreateRuleset, 0, 0, landlockCreateRulesetVersion)
+	if errno != 0 {
+		slog.Warn("landlock is unavailable; running without filesystem confinement", "err", errno)
+		return false, nil
+	}
+
+	handledFS := uint64(fsRead | fsWriteFile | fsRemoveDir | fsRemoveFile | fsMakeChar | fsMakeDir |
+		fsMakeReg | fsMakeSock | fsMakeFifo | fsMakeBlock | fsMakeSym)
+	if abi >= 2 {
+		handledFS |= fsRefer
+	}
+	if abi >= 3 {
+		handledFS |= fsTruncate
+	}
+	attr := []uint64{handledFS, 0}
+	if abi >= 4 {
+		// Landlock only governs TCP; we never listen, and connect only to publish.
+		attr[1] = netBindTCP
+		if !cfg.network {
+			attr[1] |= netConnectTCP
+		}
+	}
+
+	fd, _, errno := syscall.Syscall(sysLandlockCreateRuleset, uintptr(unsafe.Pointer(&attr[0])), 16, 0)
+	if errno != 0 {
+		return false, fmt.Errorf("%w: landlock ruleset: %w", ErrConfig, errno)
+	}
+	defer syscall.Close(int(fd))
+
+	rules := map[string]uint64{"/": fsRead, "/dev": fsRead | fsWriteFile}
+	for _, dir := range cfg.writable {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return false, fmt.Errorf("%w: sandbox: %w", ErrConfig, err)
+		}
+		rules[abs] = handledFS
+	}
+	for path, access := range rules {
+		if err := landlockAllow(int(fd), path, access&handledFS); err != nil {
+			return false, fmt.Errorf("%w: sandbox: allow %s: %w", ErrConfig, path, err)
+		}
+	}
+
+	if _, _, errno := syscall.RawSyscall(sysLandlockRestrictSelf, fd, 0, 0); errno != 0 {
+		return false, fmt.Errorf("%w: sandbox: restrict: %w", ErrConfig, errno)
+	}
+	slog.Debug("landlock installed", "abi", abi, "writable", cfg.writable, "network", cfg.network)
+	return true, nil
+}
+
+// setLimits lowers RLIMIT_FSIZE and RLIMIT_NOFILE as configured. Both are
+// inherited by git and by the re-executed sandboxed process.
+func setLimits(cfg sandboxConfig) error {
+	set := func(resource int, name string, value uint64) error {
+		if value == 0 {
+			return nil
+		}
+		lim := syscall.Rlimit{Cur: value, Max: value}
+		if err := syscall.Setrlimit(resource, &lim); err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrConfig, name, err)
+		}
+		slog.Debug("resource limit set", "limit", name, "value", value)
+		return nil
+	}
+	if err := set(syscall.RLIMIT_FSIZE, "RLIMIT_FSIZE", cfg.maxFileSize); err != nil {
+		return err
+	}
+	return set(syscall.RLIMIT_NOFILE, "RLIMIT_NOFILE", cfg.maxOpenFiles)
+}
+
+// landlockAllow grants access beneath path.
+func landlockAllow(ruleset int, path string, access uint64) error {
+	dir, err := syscall.Open(path, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(dir)
+
+	// struct landlock_path_beneath_attr is packed: u64 allowed_access, s32 parent_fd.
+	var rule [12]byte
+	binary.NativeEndian.PutUint64(rule[0:], access)
+	binary.NativeEndian.PutUint32(rule[8:], uint32(dir))
+	_, _, errno := syscall.Syscall6(sysLandlockAddRule, uintptr(ruleset), landlockRulePathBeneath,
+		uintptr(unsafe.Pointer(&rule[0])), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// dropPrivileges switches the process to name's uid, gid and home.
+func dropPrivileges(name string) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("%w: -user needs to start as root", ErrConfig)
+	}
+	u, err := user.Lookup(name)
+	if err != nil {
+		return fmt.Errorf("%w: -user: %w", ErrConfig, err)
+	}
+	uid, _ := strconv.Atoi(u.Uid)
+	gid, _ := strconv.Atoi(u.Gid)
+	if err := syscall.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("%w: setgroups: %w", ErrConfig, err)
+	}
+	if err := syscall.Setgid(gid); err != nil {
+		return fmt.Errorf("%w: setgid: %w", ErrConfig, err)
+	}
+	if err := syscall.Setuid(uid); err != nil {
+		return fmt.Errorf("%w: setuid: %w", ErrConfig, err)
+	}
+	os.Setenv("HOME", u.HomeDir)
+	slog.Info("dropped privileges", "user", name, "uid", uid, "gid", gid)
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// enterSandbox only exists on Linux; elsewhere the run is unconfined.
+func enterSandbox(cfg sandboxConfig) error {
+	if cfg.user != "" || cfg.seccomp || cfg.maxFileSize != 0 || cfg.maxOpenFiles != 0 {
+		return fmt.Errorf("%w: -user, -seccomp and -limit-* are only supported on Linux", ErrConfig)
+	}
+	if cfg.confine {
+		slog.Debug("sandboxing is only supported on Linux; running unconfined")
+	}
+	return nil
+}
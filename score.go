@@ -0,0 +1,61 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"slices"
+)
+
+func scoreCmd(args []string) error {
+	fs := newToolFlags("score", "list...")
+	modelPath := fs.String("model", "style.json", "score with this `model` from learn")
+	sorted := fs.Bool("sort", false, "write the likeliest words first (holds the lists in memory)")
+	out := fs.String("o", "", "write the scored words to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: score needs at least one list (- for stdin)", ErrConfig)
+	}
+	model, err := loadStyleModel(*modelPath)
+	if err != nil {
+		return err
+	}
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	w.model = model
+	var words []string
+	st, err := forEachLine(fs.Args(), "scoring", func(word []byte) error {
+		if *sorted {
+			words = append(words, string(word))
+			return nil
+		}
+		return w.writeLine(word)
+	})
+	if err == nil && *sorted {
+		probability := make(map[string]float64, len(words))
+		for _, word := range words {
+			probability[word] = model.probability(word)
+		}
+		slices.SortStableFunc(words, func(a, b string) int { return cmp.Compare(probability[b], probability[a]) })
+		for _, word := range words {
+			if err = w.writeLine([]byte(word)); err != nil {
+				break
+			}
+		}
+	}
+	if cerr := w.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "🎲 Scored %s words of %s with %s (%s passwords)\n",
+		fmtInt(st.lines), describeLists(fs.Args()), *modelPath, fmtInt(model.Passwords))
+	return nil
+}
@@ -0,0 +1,85 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetSeccomp      = 22
+	seccompModeFilter = 2
+	seccompRetKill    = 0x80000000 // SECCOMP_RET_KILL_PROCESS
+	seccompRetErrno   = 0x00050000
+	seccompRetAllow   = 0x7fff0000
+	seccompDataArch   = 4
+	seccompDataNr     = 0
+	seccompDataArg0   = 16 // low half of args[0] on little-endian
+	auditArchX86_64   = 0xc000003e
+	auditArchAarch64  = 0xc00000b7
+	x32SyscallBit     = 0x40000000 // __X32_SYSCALL_BIT: x32 numbers alias the x86_64 ones above it
+	afNetlink         = 16
+	errnoEAFNOSUPPORT = uint32(syscall.EAFNOSUPPORT)
+	errnoEPERM        = uint32(syscall.EPERM)
+	bpfLoadAbsWord    = syscall.BPF_LD | syscall.BPF_W | syscall.BPF_ABS
+	bpfJumpIfEqual    = syscall.BPF_JMP | syscall.BPF_JEQ | syscall.BPF_K
+	bpfJumpIfAtLeast  = syscall.BPF_JMP | syscall.BPF_JGE | syscall.BPF_K
+	bpfReturnConstant = syscall.BPF_RET | syscall.BPF_K
+)
+
+// deniedSyscalls have no business in a wordlist generator or in git.
+var deniedSyscalls = []uint32{
+	syscall.SYS_PTRACE, syscall.SYS_MOUNT, syscall.SYS_UMOUNT2, syscall.SYS_PIVOT_ROOT,
+	syscall.SYS_UNSHARE, syscall.SYS_REBOOT, syscall.SYS_KEXEC_LOAD, syscall.SYS_INIT_MODULE,
+	syscall.SYS_DELETE_MODULE, syscall.SYS_SWAPON, syscall.SYS_SWAPOFF, syscall.SYS_PERF_EVENT_OPEN,
+}
+
+// installSeccomp loads a filter on the calling thread that fails the denied
+// syscalls with EPERM and socket() with EAFNOSUPPORT unless the family is
+// AF_UNIX or, when network is set, AF_INET, AF_INET6 or AF_NETLINK. It
+// kills a process calling another architecture's syscalls, x32's included,
+// whose numbers would otherwise reach the denied calls under aliases. The
+// thread must already have no_new_privs; the filter survives exec.
+func installSeccomp(network bool) error {
+	arch := uint32(auditArchX86_64)
+	if runtime.GOARCH == "arm64" {
+		arch = auditArchAarch64
+	}
+	stmt := func(code uint16, k uint32) syscall.SockFilter { return syscall.SockFilter{Code: code, K: k} }
+	jeq := func(k uint32, jt, jf uint8) syscall.SockFilter {
+		return syscall.SockFilter{Code: bpfJumpIfEqual, Jt: jt, Jf: jf, K: k}
+	}
+
+	prog := []syscall.SockFilter{
+		stmt(bpfLoadAbsWord, seccompDataArch),
+		jeq(arch, 1, 0),
+		stmt(bpfReturnConstant, seccompRetKill),
+		stmt(bpfLoadAbsWord, seccompDataNr),
+		{Code: bpfJumpIfAtLeast, Jt: 0, Jf: 1, K: x32SyscallBit},
+		stmt(bpfReturnConstant, seccompRetKill),
+	}
+	for _, nr := range deniedSyscalls {
+		prog = append(prog, jeq(nr, 0, 1), stmt(bpfReturnConstant, seccompRetErrno|errnoEPERM))
+	}
+	prog = append(prog, jeq(syscall.SYS_SOCKET, 1, 0), stmt(bpfReturnConstant, seccompRetAllow))
+
+	families := []uint32{syscall.AF_UNIX}
+	if network {
+		families = append(families, syscall.AF_INET, syscall.AF_INET6, afNetlink)
+	}
+	prog = append(prog, stmt(bpfLoadAbsWord, seccompDataArg0))
+	for _, family := range families {
+		prog = append(prog, jeq(family, 0, 1), stmt(bpfReturnConstant, seccompRetAllow))
+	}
+	prog = append(prog, stmt(bpfReturnConstant, seccompRetErrno|errnoEAFNOSUPPORT))
+
+	fprog := syscall.SockFprog{Len: uint16(len(prog)), Filter: &prog[0]}
+	_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetSeccomp, seccompModeFilter, uintptr(unsafe.Pointer(&fprog)))
+	runtime.KeepAlive(prog)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
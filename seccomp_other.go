@@ -0,0 +1,13 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+// installSeccomp is only implemented for linux/amd64 and linux/arm64.
+func installSeccomp(network bool) error {
+	return errors.New("seccomp is not supported on " + runtime.GOOS + "/" + runtime.GOARCH)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// shardDir holds the shard files of -shard-output, and shardLog in it the
+// size every shard had after each committed chunk.
+const (
+	shardDir = "shards"
+	shardLog = "COMMITTED"
+)
+
+// shardSink spreads the words over a fixed set of files instead of chunks:
+// each word goes to the shard its xxhash picks, so every shard gets an even
+// share of the keyspace whatever its structure, and each downstream consumer
+// can take one. The shards only grow, so a chunk cut off halfway would leave
+// words that the rerun writes again; rewind truncates them back to the
+// sizes shardLog recorded for the last chunk in the state.
+type shardSink struct {
+	files []*os.File
+	sizes []int64 // of the shards, as committed so far
+	log   *os.File
+}
+
+func newShardSink(n int) (*shardSink, error) {
+	if err := os.MkdirAll(shardDir, 0755); err != nil {
+		return nil, diskError("create "+shardDir, err)
+	}
+	s := &shardSink{sizes: make([]int64, n)}
+	for i := range n {
+		f, err := os.OpenFile(shardPath(i, n), os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			s.close()
+			return nil, diskError("open shard", err)
+		}
+		s.files = append(s.files, f)
+	}
+	var err error
+	if s.log, err = os.OpenFile(filepath.Join(shardDir, shardLog), os.O_RDWR|os.O_CREATE, 0644); err != nil {
+		s.close()
+		return nil, diskError("open shard log", err)
+	}
+	return s, nil
+}
+
+// shardPath names shard i of n, numbered with as many digits as n needs.
+func shardPath(i, n int) string {
+	return filepath.Join(shardDir, fmt.Sprintf("shard_%0*d.txt", len(strconv.Itoa(n-1)), i))
+}
+
+// rewind cuts the shards and the log back to how they were when chunk
+// files completed, the last chunk the state records (0 for none), and
+// appends from there.
+func (s *shardSink) rewind(files int) error {
+	data, err := os.ReadFile(s.log.Name())
+	if err != nil {
+		return diskError("read shard log", err)
+	}
+	clear(s.sizes)
+	var keep int64 // bytes of the log up to that chunk's line
+	if files > 0 {
+		found := false
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			fields := strings.Fields(line)
+			keep += int64(len(line))
+			if len(fields) != len(s.sizes)+1 || fields[0] != strconv.Itoa(files) {
+				continue
+			}
+			for i, f := range fields[1:] {
+				if s.sizes[i], err = strconv.ParseInt(f, 10, 64); err != nil {
+					return fmt.Errorf("%w: %s: %q", ErrStateCorrupt, s.log.Name(), line)
+				}
+			}
+			found = true
+			break
+		}
+		if !found {
+			return fmt.Errorf("%w: %s has no sizes for chunk %d of %d shards; they belong to another run", ErrStateCorrupt, s.log.Name(), files, len(s.sizes))
+		}
+	}
+	if err := s.truncate(); err != nil {
+		return err
+	}
+	if err := s.log.Truncate(keep); err != nil {
+		return diskError("rewind shard log", err)
+	}
+	_, err = s.log.Seek(keep, 0)
+	return diskError("rewind shard log", err)
+}
+
+// truncate drops whatever follows the committed words in each shard.
+func (s *shardSink) truncate() error {
+	for i, f := range s.files {
+		if err := f.Truncate(s.sizes[i]); err != nil {
+			return diskError("rewind shard", err)
+		}
+		if _, err := f.Seek(s.sizes[i], 0); err != nil {
+			return diskError("rewind shard", err)
+		}
+	}
+	return nil
+}
+
+// open starts a chunk where the last commit ended, dropping the words of a
+// failed attempt at it that a retry is about to write again.
+func (s *shardSink) open(name string) (chunk, error) {
+	n, ok := chunkLayout.Number(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: shard chunk %q", ErrOutput, name)
+	}
+	if err := s.truncate(); err != nil {
+		return nil, err
+	}
+	c := &hashShardChunk{s: s, num: strconv.FormatInt(n, 10)}
+	for _, f := range s.files {
+		c.w = append(c.w, bufio.NewWriterSize(f, 64<<10))
+	}
+	return c, nil
+}
+
+func (*shardSink) keeps() bool { return false }
+
+func (s *shardSink) close() error {
+	var first error
+	for _, f := range append(s.files, s.log) {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil && first == nil {
+			first = diskError("close shard", err)
+		}
+	}
+	return first
+}
+
+// hashShardChunk routes the lines of one chunk to the shards. Writes may split
+// a line, so the start of one waits in line for the rest.
+type hashShardChunk struct {
+	s    *shardSink
+	num  string // the chunk's number, for the log
+	w    []*bufio.Writer
+	n    []int64 // bytes written to each shard
+	line []byte
+}
+
+func (c *hashShardChunk) Write(p []byte) (int, error) {
+	if c.n == nil {
+		c.n = make([]int64, len(c.w))
+	}
+	size := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			c.line = append(c.line, p...)
+			break
+		}
+		word := p[:i+1]
+		if len(c.line) > 0 {
+			c.line = append(c.line, word...)
+			word = c.line
+		}
+		k := xxhash.Sum64(word[:len(word)-1]) % uint64(len(c.w))
+		if _, err := c.w[k].Write(word); err != nil {
+			return 0, err
+		}
+		c.n[k] += int64(len(word))
+		c.line, p = c.line[:0], p[i+1:]
+	}
+	return size, nil
+}
+
+// commit flushes and syncs the shards, then logs their sizes: once the line
+// is in the log, a rewind to this chunk keeps its words.
+func (c *hashShardChunk) commit() (stored, error) {
+	var total int64
+	line := []byte(c.num)
+	for i, w := range c.w {
+		if err := w.Flush(); err != nil {
+			return stored{}, diskError("write shard", err)
+		}
+		if err := c.s.files[i].Sync(); err != nil {
+			return stored{}, diskError("sync shard", err)
+		}
+		if c.n != nil {
+			c.s.sizes[i] += c.n[i]
+			total += c.n[i]
+		}
+		line = fmt.Appendf(line, " %d", c.s.sizes[i])
+	}
+	if _, err := c.s.log.Write(append(line, '\n')); err != nil {
+		return stored{}, diskError("write shard log", err)
+	}
+	if err := c.s.log.Sync(); err != nil {
+		return stored{}, diskError("sync shard log", err)
+	}
+	return stored{size: total}, nil
+}
+
+// abort leaves what reached the shards: the next open or run drops it.
+func (c *hashShardChunk) abort() {}
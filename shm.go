@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+// shmDir is where Linux keeps POSIX shared-memory objects: shm_open("/x")
+// opens /dev/shm/x, so a consumer can use either interface.
+const shmDir = "/dev/shm"
+
+// shmDoneMarker appears once the last segment has been published.
+func shmDoneMarker(prefix string) string {
+	return filepath.Join(shmDir, prefix+".done")
+}
+
+// waitForSegmentSlot blocks until fewer than limit finished segments are
+// waiting for the consumer, which frees a slot by unlinking a segment it has
+// read. This bounds the memory the run can hold, like a ring of buffers.
+func waitForSegmentSlot(ctx context.Context, prefix string, limit int) error {
+	pattern := filepath.Join(shmDir, prefix+"."+chunkPattern())
+	logged := false
+	for {
+		ready, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		if len(ready) < limit {
+			return nil
+		}
+		if !logged {
+			slog.Debug("waiting for the consumer to release a segment", "ready", len(ready))
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// outputSink is where generated chunks go. Generation only ever sees this
+// interface, so a new backend needs nothing but an implementation and a
+// case in newTarget.
+type outputSink interface {
+	// open starts the chunk called name.
+	open(name string) (chunk, error)
+	// keeps reports whether completed chunks stay on disk under their
+	// names, where they can be published.
+	keeps() bool
+	close() error
+}
+
+// chunk receives the words of one chunk.
+type chunk interface {
+	io.Writer
+	// commit completes the chunk and reports what the sink stored.
+	commit() (stored, error)
+	// abort gives up on an unfinished chunk; it does nothing after commit.
+	abort()
+}
+
+// keeper is a chunk that can be left unfinished on purpose, for resumer to
+// continue: checkpoint syncs what was written so far and returns its size,
+// and keep does the same and closes the chunk.
+type keeper interface {
+	checkpoint() (int64, error)
+	keep() (int64, error)
+}
+
+// resumer is a sink that can continue a chunk keep left, holding size bytes.
+type resumer interface {
+	resume(name string, size int64) (chunk, error)
+}
+
+// stored is what a sink kept of a committed chunk.
+type stored struct {
+	size int64
+	sum  *checksum // of the bytes on disk; nil unless the sink keeps files
+}
+
+// newOutputSink returns the sink for -output spec: files (under prefix),
+// null, stdout or tcp://host:port, or several of them separated by commas,
+// each with its own queue of buffer bytes. A pool compresses on the way in,
+// once for every target.
+func newOutputSink(spec, prefix string, pool *compressPool, buffer int64) (outputSink, error) {
+	targets := outputTargets(spec)
+	sinks := make([]outputSink, 0, len(targets))
+	for _, target := range targets {
+		s, err := newTarget(target, prefix)
+		if err != nil {
+			for _, s := range sinks {
+				s.close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, s)
+	}
+	s := sinks[0]
+	if len(sinks) > 1 {
+		tee, err := newTeeSink(targets, sinks, buffer)
+		if err != nil {
+			for _, s := range sinks {
+				s.close()
+			}
+			return nil, err
+		}
+		s = tee
+	}
+	if pool != nil {
+		s = compressSink{s, pool}
+	}
+	return s, nil
+}
+
+// newTarget returns the sink for one target of -output.
+func newTarget(target, prefix string) (outputSink, error) {
+	switch {
+	case target == "files":
+		return fileSink{prefix: prefix}, nil
+	case target == "null":
+		return nullSink{}, nil
+	case target == "stdout":
+		return &streamSink{w: stdout}, nil
+	case strings.HasPrefix(target, "tcp://"):
+		conn, err := net.Dial("tcp", strings.TrimPrefix(target, "tcp://"))
+		if err != nil {
+			return nil, fmt.Errorf("%w: -output %s: %w", ErrOutput, target, err)
+		}
+		return &streamSink{w: conn, c: conn}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown -output %q (want files, null, stdout or tcp://host:port, or several separated by commas)", ErrConfig, target)
+}
+
+// stdout is the process's real standard output. With -output stdout, main
+// points os.Stdout at stderr so console messages stay out of the words.
+var stdout = os.Stdout
+
+// fileSink writes each chunk to prefix+name. The words go to name.part
+// first, which is synced and only then renamed to name, so a file under its
+// final name is always complete and an interrupted one is recognisable by
+// its suffix.
+type fileSink struct {
+	prefix string
+}
+
+func (s fileSink) open(name string) (chunk, error) {
+	path := s.prefix + name
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, diskError("create the directory of "+path, err)
+	}
+	f, err := os.Create(path + partSuffix)
+	if err != nil {
+		return nil, diskError("create "+path+partSuffix, err)
+	}
+	return &fileChunk{f: f, path: path, h: newHasher()}, nil
+}
+
+// resume reopens the .part file keep left, drops anything past size and
+// rehashes the rest, so the checksum still covers the whole chunk.
+func (s fileSink) resume(name string, size int64) (chunk, error) {
+	path := s.prefix + name
+	f, err := os.OpenFile(path+partSuffix, os.O_RDWR, 0)
+	if err != nil {
+		return nil, diskError("reopen "+path+partSuffix, err)
+	}
+	c := &fileChunk{f: f, path: path, n: size, h: newHasher()}
+	if _, err := io.CopyN(c.h, f, size); err != nil {
+		f.Close()
+		return nil, diskError("read "+path+partSuffix, err)
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, diskError("truncate "+path+partSuffix, err)
+	}
+	return c, nil
+}
+
+func (fileSink) keeps() bool  { return true }
+func (fileSink) close() error { return nil }
+
+type fileChunk struct {
+	f    *os.File
+	path string
+	n    int64
+	h    *hasher
+	done bool
+}
+
+func (c *fileChunk) Write(p []byte) (int, error) {
+	n, err := c.f.Write(p)
+	c.n += int64(n)
+	c.h.Write(p[:n])
+	return n, err
+}
+
+func (c *fileChunk) commit() (stored, error) {
+	part := c.path + partSuffix
+	c.done = true
+	if err := c.f.Sync(); err != nil {
+		c.f.Close()
+		return stored{}, diskError("sync "+part, err)
+	}
+	if err := c.f.Close(); err != nil {
+		return stored{}, diskError("close "+part, err)
+	}
+	if err := os.Rename(part, c.path); err != nil {
+		return stored{}, diskError("finalize "+c.path, err)
+	}
+	if err := syncDir(filepath.Dir(c.path)); err != nil {
+		return stored{}, diskError("sync the directory of "+c.path, err)
+	}
+	sum := c.h.sum()
+	return stored{c.n, &sum}, nil
+}
+
+func (c *fileChunk) checkpoint() (int64, error) {
+	return c.n, diskError("sync "+c.path+partSuffix, c.f.Sync())
+}
+
+func (c *fileChunk) keep() (int64, error) {
+	c.done = true
+	if _, err := c.checkpoint(); err != nil {
+		c.f.Close()
+		return 0, err
+	}
+	return c.n, diskError("close "+c.path+partSuffix, c.f.Close())
+}
+
+func (c *fileChunk) abort() {
+	if !c.done {
+		c.f.Close()
+	}
+}
+
+// nullSink discards everything, to measure generation alone.
+type nullSink struct{}
+
+func (nullSink) open(string) (chunk, error) { return &nullChunk{}, nil }
+func (nullSink) keeps() bool                { return false }
+func (nullSink) close() error               { return nil }
+
+type nullChunk struct{ n int64 }
+
+func (c *nullChunk) Write(p []byte) (int, error) { c.n += int64(len(p)); return len(p), nil }
+func (c *nullChunk) commit() (stored, error)     { return stored{size: c.n}, nil }
+func (c *nullChunk) abort()                      {}
+
+// streamSink writes every chunk, in order, to one stream: stdout or a
+// connection. A chunk interrupted halfway has already been sent in part.
+type streamSink struct {
+	w io.Writer
+	c io.Closer // nil for stdout
+}
+
+func (s *streamSink) open(string) (chunk, error) { return &streamChunk{w: s.w}, nil }
+func (s *streamSink) keeps() bool                { return false }
+
+func (s *streamSink) close() error {
+	if s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
+
+type streamChunk struct {
+	w io.Writer
+	n int64
+}
+
+func (c *streamChunk) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if errors.Is(err, syscall.EPIPE) {
+		err = fmt.Errorf("%w: %w", ErrReaderGone, err)
+	}
+	return n, err
+}
+
+func (c *streamChunk) commit() (stored, error) { return stored{size: c.n}, nil }
+func (c *streamChunk) abort()                  {}
+
+// compressSink compresses each chunk with the pool's workers before it
+// reaches the sink underneath.
+type compressSink struct {
+	outputSink
+	pool *compressPool
+}
+
+func (s compressSink) open(name string) (chunk, error) {
+	c, err := s.outputSink.open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &compressedChunk{blockWriter: s.pool.writer(c), next: c}, nil
+}
+
+type compressedChunk struct {
+	*blockWriter
+	next chunk
+}
+
+func (c *compressedChunk) commit() (stored, error) {
+	if err := c.blockWriter.Close(); err != nil {
+		c.next.abort()
+		return stored{}, diskError("compress", err)
+	}
+	return c.next.commit()
+}
+
+func (c *compressedChunk) abort() {
+	c.blockWriter.Close()
+	c.next.abort()
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"main.go/wordlist"
+)
+
+// A split run divides the keyspace between -nodes machines by whole chunks,
+// so every node names its files as a single run would and reassemble can
+// merge the nodes' manifests into one.
+var (
+	nodes int // -nodes; 0 for a run over the whole keyspace
+	node  int // -node, from 1 to nodes
+)
+
+func addNodeFlags(fs *flag.FlagSet) {
+	fs.IntVar(&nodes, "nodes", 0, "split the keyspace by whole chunks between this many `machines` (see split)")
+	fs.IntVar(&node, "node", 0, "with -nodes, generate only the slice of this `node`, from 1 to -nodes, keeping its own state file")
+}
+
+// selectNode checks -nodes and -node against the keyspace and makes a node
+// keep its own state file.
+func selectNode() error {
+	if nodes == 0 && node == 0 {
+		return nil
+	}
+	files := (total + entriesPerFile - 1) / entriesPerFile
+	switch {
+	case nodes < 1:
+		return fmt.Errorf("%w: -node needs -nodes", ErrConfig)
+	case int64(nodes) > files:
+		return fmt.Errorf("%w: -nodes %d is more than the %s chunks there are to share", ErrConfig, nodes, fmtInt(files))
+	case node < 0 || node > nodes:
+		return fmt.Errorf("%w: -node %d is not one of 1 to %d", ErrConfig, node, nodes)
+	}
+	if node > 0 {
+		stateFile = nodeStateFile(node)
+	}
+	return nil
+}
+
+func nodeStateFile(n int) string { return fmt.Sprintf("state-node%d-of-%d.txt", n, nodes) }
+
+// nodeSlice returns the positions [start, end) of node n and the numbers of
+// its first and last chunks. Chunks are dealt out as evenly as they go, the
+// later nodes taking one more when they do not divide.
+func nodeSlice(n int) (start, end int64, first, last int) {
+	files := (total + entriesPerFile - 1) / entriesPerFile
+	first = int(files*int64(n-1)/int64(nodes)) + 1
+	last = int(files * int64(n) / int64(nodes))
+	return int64(first-1) * entriesPerFile, min(int64(last)*entriesPerFile, total), first, last
+}
+
+// A run can also be bounded by words: -start-from and -stop-at limit it to
+// the words from one to the other, both included, wherever they fall in the
+// chunks. The chunks keep their numbers and boundaries, so the first and
+// last may hold only part of what they would in a whole run.
+var (
+	startFrom, stopAt      string // -start-from and -stop-at; "" for either end
+	windowStart, windowEnd int64  // their positions, [windowStart, windowEnd)
+)
+
+func addWindowFlags(fs *flag.FlagSet) {
+	fs.StringVar(&startFrom, "start-from", "", "begin the run at this `word` of the keyspace instead of its first")
+	fs.StringVar(&stopAt, "stop-at", "", "end the run with this `word` of the keyspace instead of its last")
+}
+
+// selectWindow finds the positions of -start-from and -stop-at in ks.
+func selectWindow(ks *wordlist.Keyspace) error {
+	windowStart, windowEnd = 0, total
+	var err error
+	if startFrom != "" {
+		if windowStart, err = ks.IndexOf(startFrom); err != nil {
+			return fmt.Errorf("%w: -start-from: %w", ErrConfig, err)
+		}
+	}
+	if stopAt != "" {
+		if windowEnd, err = ks.IndexOf(stopAt); err != nil {
+			return fmt.Errorf("%w: -stop-at: %w", ErrConfig, err)
+		}
+		windowEnd++
+	}
+	if windowStart >= windowEnd {
+		return fmt.Errorf("%w: -stop-at %q comes before -start-from %q in the keyspace", ErrConfig, stopAt, startFrom)
+	}
+	return nil
+}
+
+// runSlice is the part of the keyspace this run covers: all of it, or the
+// slice of -node, within -start-from and -stop-at.
+func runSlice() (start, end int64) {
+	start, end = 0, total
+	if node > 0 {
+		start, end, _, _ = nodeSlice(node)
+	}
+	start, end = max(start, windowStart), min(end, windowEnd)
+	return start, max(start, end)
+}
+
+// sliceFiles is how many chunks hold positions of [start, end).
+func sliceFiles(start, end int64) int64 {
+	return (end+entriesPerFile-1)/entriesPerFile - start/entriesPerFile
+}
+
+// splitCmd prints the slice of every node, or of -node, as tab-separated
+// node, start and end positions ([start, end)), first and last chunk
+// numbers, and state file.
+func splitCmd(_ context.Context, _ *options, _ []string) error {
+	if _, err := newKeyspace(); err != nil {
+		return err
+	}
+	if nodes < 1 {
+		return fmt.Errorf("%w: split needs -nodes", ErrConfig)
+	}
+	fmt.Println("node\tstart\tend\tfirst_file\tlast_file\tstate")
+	for n := 1; n <= nodes; n++ {
+		if node != 0 && n != node {
+			continue
+		}
+		start, end, first, last := nodeSlice(n)
+		fmt.Printf("%d\t%d\t%d\t%d\t%d\t%s\n", n, start, end, first, last, nodeStateFile(n))
+	}
+	return nil
+}
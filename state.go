@@ -0,0 +1,483 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/klauspost/compress/zstd"
+
+	"main.go/wordlist"
+)
+
+// snapshotDir holds the zstd-compressed copies of earlier states, named
+// state-<UTC time>.zst so they sort oldest first.
+const (
+	snapshotDir    = "state-history"
+	snapshotLayout = "20060102T150405.000000000Z"
+)
+
+// stateHistory is how many snapshots writeState keeps; 0 keeps none.
+var stateHistory = 10
+
+// runState is what state.txt holds: the last position written to a
+// completed chunk, and the configuration it is a position in, so a run with
+// another one refuses to resume rather than continue at a position that now
+// means other words.
+type runState struct {
+	Format       string         `json:"format"` // wordlist.StateFormat's version
+	Last         int64          `json:"last"`
+	CharsetXXH64 string         `json:"charset_xxh64"`
+	Mask         string         `json:"mask,omitempty"`
+	Hybrid       string         `json:"hybrid,omitempty"`
+	Combine      string         `json:"combine,omitempty"`
+	Order        string         `json:"order,omitempty"`
+	MinLength    int            `json:"min_length"`
+	MaxLength    int            `json:"max_length"`
+	PerFile      int64          `json:"per_file"`
+	Layout       string         `json:"layout,omitempty"`
+	Written      time.Time      `json:"written"`
+	RulesXXH64   string         `json:"rules_xxh64,omitempty"`
+	Version      string         `json:"version"`
+	Partial      *partialRecord `json:"partial,omitempty"`
+
+	charset string           // the charset itself, which only the old text states record
+	format  wordlist.Version // Format, or the version of a state from before states recorded it
+}
+
+// partialRecord is a partial as a state records it.
+type partialRecord struct {
+	Name string `json:"name"`
+	Pos  int64  `json:"pos"`
+	Size int64  `json:"size"`
+}
+
+// readState returns the position to resume from: one past the last position
+// recorded in path, or 0 when there is no state yet. A state in an older
+// format is read as it is, leaving the file alone (see migrateState).
+func readState(path string, total int64) (int64, error) {
+	st, err := loadState(path, total)
+	if err != nil || st == nil {
+		return 0, err
+	}
+	return st.Last + 1, nil
+}
+
+// migrateState rewrites the state in path in the current format when it is
+// in an older one. Only a run, which rewrites the state anyway, calls it, so
+// commands that just read the state never change it.
+func migrateState(path string, total int64) error {
+	st, err := loadState(path, total)
+	if err != nil || st == nil || !wordlist.StateFormat.Migrates(st.format) {
+		return err
+	}
+	var p *partial
+	if r := st.Partial; r != nil {
+		p = &partial{name: r.Name, pos: r.Pos, size: r.Size}
+	}
+	if err := storeState(path, stateData(st.Last, p)); err != nil {
+		return err
+	}
+	slog.Info("migrated the state to the current format", "state", path, "format", st.format, "now", wordlist.StateFormat.Current)
+	return nil
+}
+
+// loadState reads and checks the state in path; it is nil when there is
+// none yet.
+func loadState(path string, total int64) (*runState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrStateCorrupt, err)
+	}
+	st, err := parseState(data)
+	if errors.Is(err, wordlist.ErrFormatVersion) {
+		return nil, fmt.Errorf("%w: %s: %w; it was written by a newer version of the program, which must resume it", ErrConfig, path, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w (see -list-snapshots and -rollback)", ErrStateCorrupt, path, err)
+	}
+	if err := st.matchConfig(path); err != nil {
+		return nil, err
+	}
+	if st.Last < -1 || st.Last >= total {
+		return nil, fmt.Errorf("%w: %s: position %d is outside the keyspace of %d (see -list-snapshots and -rollback)", ErrStateCorrupt, path, st.Last, total)
+	}
+	return &st, nil
+}
+
+// matchConfig checks the state was written by a run over this keyspace,
+// -per-file and -rules. A state from before a field was recorded passes on it; one
+// from another version of the program is only warned about.
+func (st *runState) matchConfig(path string) error {
+	switch {
+	case st.CharsetXXH64 != "" && st.CharsetXXH64 != charsetXXH64():
+		if st.charset != "" {
+			return fmt.Errorf("%w: %s belongs to a run over the charset %q; pass it with -charset to resume that run", ErrConfig, path, st.charset)
+		}
+		return fmt.Errorf("%w: %s belongs to a run over another charset (XXH64 %s, this one is %s); pass that run's -charset or -charset-file to resume it",
+			ErrConfig, path, st.CharsetXXH64, charsetXXH64())
+	case st.Mask != keyspaceMask:
+		return fmt.Errorf("%w: %s belongs to a run over the mask %q; pass it with -mask, or the -t template spelling it, to resume that run", ErrConfig, path, st.Mask)
+	case st.Hybrid != hybridSpec() && st.Hybrid == "":
+		return fmt.Errorf("%w: %s belongs to a run without -hybrid-dict; leave it out to resume that run", ErrConfig, path)
+	case st.Hybrid != hybridSpec():
+		return fmt.Errorf("%w: %s belongs to a hybrid run over %s (the dictionary by its XXH64); pass that dictionary and its masks to resume that run", ErrConfig, path, st.Hybrid)
+	case st.Combine != combinatorSpec() && st.Combine == "":
+		return fmt.Errorf("%w: %s belongs to a run that is not combine's; leave out the lists to resume that run", ErrConfig, path)
+	case st.Combine != combinatorSpec():
+		return fmt.Errorf("%w: %s belongs to a combine run over %s (the lists by their XXH64, the separators between them); pass those lists and separators to resume that run", ErrConfig, path, st.Combine)
+	case st.Order != orderSpec() && st.Order == "":
+		return fmt.Errorf("%w: %s belongs to a run in keyspace order; drop -order to resume that run", ErrConfig, path)
+	case st.Order != orderSpec():
+		return fmt.Errorf("%w: %s belongs to a run in the order %s; pass -order shuffled and that -seed to resume it", ErrConfig, path, st.Order)
+	case st.MaxLength > 0 && (st.MinLength != minLength || st.MaxLength != maxLength):
+		return fmt.Errorf("%w: %s belongs to a run over lengths %d-%d; pass them with -min-len and -max-len to resume that run", ErrConfig, path, st.MinLength, st.MaxLength)
+	case st.PerFile > 0 && st.PerFile != entriesPerFile:
+		return fmt.Errorf("%w: %s belongs to a run of %s words per chunk; pass -per-file %d to resume that run", ErrConfig, path, fmtInt(st.PerFile), st.PerFile)
+	case st.Layout != layoutSpec() && st.Layout == "":
+		return fmt.Errorf("%w: %s belongs to a run with the default chunk names; drop -out-dir, -file-template and -per-dir to resume that run", ErrConfig, path)
+	case st.Layout != layoutSpec():
+		return fmt.Errorf("%w: %s belongs to a run with the chunks named %s; pass that run's -out-dir, -file-template and -per-dir to resume it", ErrConfig, path, st.Layout)
+	case st.RulesXXH64 != mangling.sum && st.RulesXXH64 == "":
+		return fmt.Errorf("%w: %s belongs to a run without -rules; leave it out to resume that run", ErrConfig, path)
+	case st.RulesXXH64 != mangling.sum:
+		return fmt.Errorf("%w: %s belongs to a run with other -rules (XXH64 %s); pass that run's rule file to resume it", ErrConfig, path, st.RulesXXH64)
+	}
+	if st.Version != "" && st.Version != buildVersion() {
+		slog.Warn("the state was written by another version of the program", "state", path, "written_by", st.Version, "running", buildVersion())
+	}
+	return nil
+}
+
+// parseState decodes a state: the JSON document, or the text of earlier
+// versions, whose first line is the last position and whose next lines
+// are "charset", "lengths", "mask" and "partial" fields.
+func parseState(data []byte) (runState, error) {
+	var st runState
+	if text := bytes.TrimSpace(data); len(text) > 0 && text[0] == '{' {
+		// A newer minor version may add fields, which this one ignores.
+		var head struct {
+			Format string `json:"format"`
+		}
+		st.format = wordlist.Version{Major: 2}
+		if json.Unmarshal(text, &head) == nil && head.Format != "" {
+			v, err := wordlist.ParseVersion(head.Format)
+			if err != nil {
+				return st, err
+			}
+			st.format = v
+		}
+		if _, err := wordlist.StateFormat.Negotiate(st.format); err != nil {
+			return st, err
+		}
+		dec := json.NewDecoder(bytes.NewReader(text))
+		if !wordlist.StateFormat.Current.Less(st.format) {
+			dec.DisallowUnknownFields()
+		}
+		err := dec.Decode(&st)
+		return st, err
+	}
+	st.format = wordlist.Version{Major: 1}
+	first, _, _ := strings.Cut(string(data), "\n")
+	var err error
+	if st.Last, err = strconv.ParseInt(strings.TrimSpace(first), 10, 64); err != nil {
+		return st, err
+	}
+	if quoted, ok := stateField(data, "charset"); ok {
+		if st.charset, err = strconv.Unquote(quoted); err != nil {
+			return st, fmt.Errorf("charset: %w", err)
+		}
+		st.CharsetXXH64 = fmt.Sprintf("%016x", xxhash.Sum64String(st.charset))
+	}
+	if quoted, ok := stateField(data, "mask"); ok {
+		if st.Mask, err = strconv.Unquote(quoted); err != nil {
+			return st, fmt.Errorf("mask: %w", err)
+		}
+	}
+	if lengths, ok := stateField(data, "lengths"); ok {
+		if _, err := fmt.Sscanf(lengths, "%d-%d", &st.MinLength, &st.MaxLength); err != nil {
+			return st, fmt.Errorf("lengths: %w", err)
+		}
+	}
+	if value, ok := stateField(data, "partial"); ok {
+		p := &partialRecord{}
+		if n, _ := fmt.Sscanf(value, "%s %d %d", &p.Name, &p.Pos, &p.Size); n == 3 {
+			st.Partial = p
+		}
+	}
+	return st, nil
+}
+
+// stateField returns the value of a state's line starting with name and a
+// space; ok is false for a state from before they recorded it.
+func stateField(data []byte, name string) (value string, ok bool) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if value, ok = strings.CutPrefix(strings.TrimSpace(line), name+" "); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// charsetXXH64 is the fingerprint of the charset a state records.
+func charsetXXH64() string { return fmt.Sprintf("%016x", xxhash.Sum64String(charset)) }
+
+// buildVersion names the build of the program: its module version, which
+// for a build from a checkout names the commit, or failing that the commit.
+func buildVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return "(devel)"
+}
+
+// writeState records last as the last position written to a completed file,
+// with the configuration it is a position in, and keeps a snapshot of it in
+// the history.
+func writeState(path string, last int64) error {
+	return replaceState(path, stateData(last, nil))
+}
+
+// writePartialState is writeState for a run an interrupt stopped inside the
+// chunk after last, recording what of that chunk is on disk.
+func writePartialState(path string, last int64, p partial) error {
+	return replaceState(path, stateData(last, &p))
+}
+
+// writeCheckpoint is writePartialState for a chunk still being written. It
+// takes no snapshot: checkpoints come often, and would push the snapshots of
+// whole chunks out of the history.
+func writeCheckpoint(path string, last int64, p partial) error {
+	return storeState(path, stateData(last, &p))
+}
+
+func stateData(last int64, p *partial) []byte {
+	st := runState{
+		Format:       wordlist.StateFormat.Current.String(),
+		Last:         last,
+		CharsetXXH64: charsetXXH64(),
+		Mask:         keyspaceMask,
+		Hybrid:       hybridSpec(),
+		Combine:      combinatorSpec(),
+		Order:        orderSpec(),
+		MinLength:    minLength,
+		MaxLength:    maxLength,
+		PerFile:      entriesPerFile,
+		Layout:       layoutSpec(),
+		RulesXXH64:   mangling.sum,
+		Written:      time.Now().UTC(),
+		Version:      buildVersion(),
+	}
+	if p != nil {
+		st.Partial = &partialRecord{Name: p.name, Pos: p.pos, Size: p.size}
+	}
+	data, _ := json.MarshalIndent(st, "", "  ")
+	return append(data, '\n')
+}
+
+// replaceState replaces path with data and keeps a snapshot of it.
+func replaceState(path string, data []byte) error {
+	if err := storeState(path, data); err != nil {
+		return err
+	}
+	return snapshotState(data, stateHistory)
+}
+
+// storeState replaces path with data atomically, so a crash or a second
+// signal mid-write leaves the old state or the new one, never a torn one.
+func storeState(path string, data []byte) error {
+	return diskError("save state", writeFileAtomic(path, data))
+}
+
+// writeFileAtomic replaces path with data through a temporary file that is
+// synced, renamed over it and made durable by syncing the directory.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// partial is a chunk an interrupt cut short and left on disk under its .part
+// name: it holds size bytes, the words before position pos.
+type partial struct {
+	name string
+	pos  int64
+	size int64
+}
+
+// statePartial reads the partial chunk a state records, if any.
+func statePartial(path string) (partial, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return partial{}, false
+	}
+	st, err := parseState(data)
+	if err != nil || st.Partial == nil {
+		return partial{}, false
+	}
+	return partial{name: st.Partial.Name, pos: st.Partial.Pos, size: st.Partial.Size}, true
+}
+
+// snapshotState compresses data into a new snapshot and prunes the history
+// to the keep newest.
+func snapshotState(data []byte, keep int) error {
+	if keep < 1 {
+		return nil
+	}
+	if err := os.MkdirAll(snapshotDir, 0755); err != nil {
+		return diskError("create "+snapshotDir, err)
+	}
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return err
+	}
+	defer enc.Close()
+	name := "state-" + time.Now().UTC().Format(snapshotLayout) + ".zst"
+	if err := os.WriteFile(filepath.Join(snapshotDir, name), enc.EncodeAll(data, nil), 0644); err != nil {
+		return diskError("save snapshot", err)
+	}
+
+	names, err := snapshotNames()
+	if err != nil {
+		return err
+	}
+	for _, old := range names[:max(len(names)-keep, 0)] {
+		os.Remove(filepath.Join(snapshotDir, old))
+	}
+	return nil
+}
+
+// snapshotNames lists the snapshot files, oldest first.
+func snapshotNames() ([]string, error) {
+	entries, err := os.ReadDir(snapshotDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, diskError("list snapshots", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "state-") && strings.HasSuffix(e.Name(), ".zst") {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// snapshot is one entry of the state history.
+type snapshot struct {
+	name string
+	time time.Time
+	last int64
+	err  error // set when the snapshot itself cannot be read
+}
+
+// listSnapshots decodes the state history, newest first.
+func listSnapshots() ([]snapshot, error) {
+	names, err := snapshotNames()
+	if err != nil {
+		return nil, err
+	}
+	var snaps []snapshot
+	for _, name := range slices.Backward(names) {
+		s := snapshot{name: name}
+		s.time, _ = time.Parse(snapshotLayout, strings.TrimSuffix(strings.TrimPrefix(name, "state-"), ".zst"))
+		var data []byte
+		if data, s.err = readSnapshot(name); s.err == nil {
+			var st runState
+			st, s.err = parseState(data)
+			s.last = st.Last
+		}
+		snaps = append(snaps, s)
+	}
+	return snaps, nil
+}
+
+func readSnapshot(name string) ([]byte, error) {
+	compressed, err := os.ReadFile(filepath.Join(snapshotDir, filepath.Base(name)))
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer dec.Close()
+	return dec.DecodeAll(compressed, nil)
+}
+
+// rollbackState replaces path with the snapshot called name and returns the
+// position it records.
+func rollbackState(path, name string) (int64, error) {
+	data, err := readSnapshot(name)
+	if err != nil {
+		return 0, fmt.Errorf("%w: snapshot %s: %w", ErrConfig, name, err)
+	}
+	st, err := parseState(data)
+	if err != nil {
+		return 0, fmt.Errorf("%w: snapshot %s: %w", ErrStateCorrupt, name, err)
+	}
+	return st.Last, storeState(path, data)
+}
+
+// printSnapshots lists the state history for -list-snapshots.
+func printSnapshots() error {
+	snaps, err := listSnapshots()
+	if err != nil {
+		return err
+	}
+	if len(snaps) == 0 {
+		fmt.Println("No state snapshots in " + snapshotDir)
+		return nil
+	}
+	for _, s := range snaps {
+		if s.err != nil {
+			fmt.Printf("%s  unreadable: %v\n", s.name, s.err)
+			continue
+		}
+		fmt.Printf("%s  %s  last position %s\n", s.name, s.time.Local().Format(time.DateTime), fmtInt(s.last))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"time"
+)
+
+// The status page of -status, rewritten after every completed file. With
+// -status publish, publishing commits it with the chunks, so whoever follows
+// the repository sees how far the run is without cloning it.
+const (
+	statusJSON = "status.json"
+	statusHTML = "status.html"
+)
+
+// statusFiles are committed with every publish; nil unless -status publish.
+var statusFiles []string
+
+// runStatus is the content of status.json.
+type runStatus struct {
+	State          string    `json:"state"` // running, complete, interrupted or failed
+	Keyspace       string    `json:"keyspace"`
+	Position       int64     `json:"position"` // words generated, from the start of the keyspace
+	Total          int64     `json:"total"`
+	Percent        float64   `json:"percent"` // of the run's slice, like the files
+	FilesCompleted int       `json:"files_completed"`
+	FilesTotal     int64     `json:"files_total"`
+	LastFile       string    `json:"last_file,omitempty"`
+	FilesPublished int       `json:"files_published"`
+	WordsPerSecond float64   `json:"words_per_second"` // this run's average
+	ETA            time.Time `json:"eta,omitzero"`
+	Started        time.Time `json:"started"` // this run
+	Updated        time.Time `json:"updated"`
+}
+
+// statusSink keeps the status page up to date from the progress events.
+type statusSink struct {
+	st         runStatus
+	startPos   int64
+	start, end int64 // the run's slice
+}
+
+func newStatusSink(keyspace string, start, end int64) *statusSink {
+	return &statusSink{start: start, end: end, st: runStatus{
+		Keyspace:   keyspace,
+		Total:      total,
+		FilesTotal: sliceFiles(start, end),
+	}}
+}
+
+func (s *statusSink) handle(e event) {
+	st := &s.st
+	switch e.kind {
+	case evStart:
+		s.startPos = e.pos
+		st.State, st.Position, st.FilesCompleted, st.Started = "running", e.pos, e.files, e.time
+		st.FilesPublished = e.files // what an earlier run left is published or about to be
+	case evFile:
+		st.Position, st.FilesCompleted, st.LastFile = e.pos, e.files, e.file
+		if st.Position >= s.end {
+			st.State = "complete" // before the final publish commits the page
+		}
+	case evPublishStart:
+		st.FilesPublished = e.files
+	case evError:
+		switch e.action {
+		case "interrupted":
+			st.State = "interrupted"
+		case "fatal":
+			st.State = "failed"
+		default:
+			return
+		}
+	case evDone:
+		st.State, st.Position, st.FilesCompleted = "complete", e.pos, e.files
+	default:
+		return
+	}
+	st.Updated = e.time
+	st.Percent = percentOf(st.Position-s.start, s.end-s.start)
+	st.WordsPerSecond, st.ETA = 0, time.Time{}
+	if secs := e.time.Sub(st.Started).Seconds(); secs > 0 && st.Position > s.startPos {
+		st.WordsPerSecond = float64(st.Position-s.startPos) / secs
+		if st.State == "running" {
+			left := float64(s.end-st.Position) / st.WordsPerSecond
+			st.ETA = e.time.Add(time.Duration(left * float64(time.Second))).Round(time.Second)
+		}
+	}
+	if err := writeStatus(st); err != nil {
+		slog.Warn("could not update the status page", "err", err)
+	}
+}
+
+var statusPage = template.Must(template.New(statusHTML).Funcs(template.FuncMap{
+	"int":   fmtInt,
+	"pct":   func(p float64) string { return fmtFloat(p, 2) },
+	"rate":  func(r float64) string { return fmtFloat(r, 0) },
+	"stamp": func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04 MST") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="300">
+<title>Wordlist generation: {{pct .Percent}}%</title>
+<style>
+body { font: 16px/1.5 system-ui, sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+.bar { background: #ddd; border-radius: 4px; height: 1.5em; }
+.bar div { background: #2a7; border-radius: 4px; height: 100%; }
+th { text-align: left; padding-right: 1em; font-weight: normal; color: #555; }
+</style>
+</head>
+<body>
+<h1>Wordlist generation: {{.State}}</h1>
+<div class="bar"><div style="width: {{printf "%.2f" .Percent}}%"></div></div>
+<p>{{int .Position}} of {{int .Total}} words ({{pct .Percent}}%)</p>
+<table>
+<tr><th>Keyspace</th><td>{{.Keyspace}}</td></tr>
+<tr><th>Files</th><td>{{.FilesCompleted}} of {{.FilesTotal}} completed, {{.FilesPublished}} published{{with .LastFile}}; last {{.}}{{end}}</td></tr>
+{{- if .WordsPerSecond}}
+<tr><th>Speed</th><td>{{rate .WordsPerSecond}} words/s</td></tr>
+{{- end}}
+{{- if not .ETA.IsZero}}
+<tr><th>Expected done</th><td>{{stamp .ETA}}</td></tr>
+{{- end}}
+<tr><th>Updated</th><td>{{stamp .Updated}}</td></tr>
+</table>
+<p>The same as JSON: <a href="` + statusJSON + `">` + statusJSON + `</a></p>
+</body>
+</html>
+`))
+
+// writeStatus writes status.json and status.html, each through a
+// temporary file so a reader never sees half of one.
+func writeStatus(st *runStatus) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	var page bytes.Buffer
+	if err := statusPage.Execute(&page, st); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{statusJSON, append(data, '\n')}, {statusHTML, page.Bytes()}} {
+		if err := writeFileAtomic(f.name, f.data); err != nil {
+			return diskError("write "+f.name, err)
+		}
+	}
+	return nil
+}
+
+// describeKeyspace is the keyspace in a line, for the status page.
+func describeKeyspace() string {
+	if combinator.left != "" {
+		return fmt.Sprintf("combine %s + %s, separated by %s", combinator.left, combinator.right, describeSeparators())
+	}
+	if hybrid.dict != "" {
+		desc := hybrid.dict
+		if hybrid.prefix != "" {
+			desc = hybrid.prefix + " " + desc
+		}
+		if hybrid.suffix != "" {
+			desc += " " + hybrid.suffix
+		}
+		return "hybrid " + desc
+	}
+	if keyspaceMask != "" {
+		return "mask " + keyspaceMask
+	}
+	return fmt.Sprintf("%s, lengths %d-%d", describeCharset(), minLength, maxLength)
+}
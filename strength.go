@@ -0,0 +1,526 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The strength estimate follows zxcvbn: a word is cut into the pieces an
+// attacker would guess separately (dictionary words, keyboard runs,
+// sequences, repeats, dates, and brute force for the rest), each piece is
+// given a number of guesses, and the word gets the cheapest cut. The number
+// of guesses maps to zxcvbn's scores from 0 (guessed within a thousand
+// tries) to 4 (over ten billion).
+const (
+	strengthScores    = 5
+	maxStrengthLen    = 64    // longer words are scored by brute force alone
+	minSequenceGuess  = 10000 // what each extra piece of a cut costs
+	bruteCardinality  = 10
+	referenceYear     = 2025
+	minYearSpace      = 20
+	minDictionaryPart = 3
+)
+
+// strengthThresholds are the guesses below which each score is given.
+var strengthThresholds = [strengthScores - 1]float64{1e3 + 5, 1e6 + 5, 1e8 + 5, 1e10 + 5}
+
+// commonPasswords ranks the words guessed first, most common first; -strength-dict
+// adds a list in the same order, such as one written by freq.
+const commonPasswords = `123456 password 12345678 qwerty 123456789 12345 1234 111111 1234567 dragon
+123123 baseball abc123 football monkey letmein 696969 shadow master 666666 qwertyuiop 123321
+mustang 1234567890 michael 654321 superman 1qaz2wsx 7777777 121212 000000 qazwsx 123qwe
+killer trustno1 jordan jennifer zxcvbnm asdfgh hunter buster soccer harley batman andrew tigger
+sunshine iloveyou 2000 charlie robert thomas hockey ranger daniel starwars klaster 112233 george
+computer michelle jessica pepper 1111 zxcvbn 555555 11111111 131313 freedom 777777 pass maggie
+159753 aaaaaa ginger princess joshua cheese amanda summer love ashley nicole chelsea biteme
+matthew access yankees 987654321 dallas austin thunder taylor matrix welcome admin login
+secret test guest root changeme default hello world money family friend flower purple orange
+winter spring autumn cookie chocolate banana apple coffee angel baby lucky happy smile
+forever london paris berlin google facebook internet samsung nokia master hello123 passw0rd
+password1 qwerty123 iloveyou1 abc 1q2w3e4r 1q2w3e 123abc q1w2e3r4 asdf zaq12wsx`
+
+// strengthEstimator rates words. It is read-only once built, so chains on
+// several goroutines share one.
+type strengthEstimator struct {
+	rank map[string]int // lower-cased word → rank, from 1
+}
+
+// newStrengthEstimator ranks the common passwords, then the words of each
+// dictionary in order after them.
+func newStrengthEstimator(dicts []string) (*strengthEstimator, error) {
+	e := &strengthEstimator{rank: make(map[string]int)}
+	add := func(word string) {
+		word = strings.ToLower(word)
+		if _, ok := e.rank[word]; !ok && word != "" {
+			e.rank[word] = len(e.rank) + 1
+		}
+	}
+	for _, word := range strings.Fields(commonPasswords) {
+		add(word)
+	}
+	if len(dicts) > 0 {
+		if _, err := forEachLine(dicts, "loading dictionaries", func(word []byte) error {
+			add(string(word))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// strengthMatch is a piece of a word [i, j] and the guesses it takes.
+type strengthMatch struct {
+	i, j    int
+	guesses float64
+}
+
+// guesses estimates how many tries an attacker needs to find word.
+func (e *strengthEstimator) guesses(word string) float64 {
+	n := len(word)
+	if n == 0 {
+		return 1
+	}
+	if n > maxStrengthLen {
+		return math.Pow(bruteCardinality, float64(n))
+	}
+	byEnd := make([][]strengthMatch, n)
+	for _, m := range e.matches(word) {
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	// best[k][l] is the smallest product of guesses covering word[:k] in l
+	// pieces; the estimate weighs each l by l! and the extra pieces' cost.
+	best := make([][]float64, n+1)
+	for k := range best {
+		best[k] = make([]float64, n+1)
+		for l := range best[k] {
+			best[k][l] = math.Inf(1)
+		}
+	}
+	best[0][0] = 1
+	for j := range n {
+		for l := 1; l <= j+1; l++ {
+			for i := 0; i <= j; i++ {
+				if g := best[i][l-1] * bruteGuesses(j-i+1); g < best[j+1][l] {
+					best[j+1][l] = g
+				}
+			}
+			for _, m := range byEnd[j] {
+				if g := best[m.i][l-1] * m.guesses; g < best[j+1][l] {
+					best[j+1][l] = g
+				}
+			}
+		}
+	}
+	guesses, factorial := math.Inf(1), 1.0
+	for l := 1; l <= n; l++ {
+		factorial *= float64(l)
+		if g := factorial*best[n][l] + math.Pow(minSequenceGuess, float64(l-1)); g < guesses {
+			guesses = g
+		}
+	}
+	return guesses
+}
+
+// score is the zxcvbn score of word, from 0 to 4.
+func (e *strengthEstimator) score(word string) int {
+	return strengthScore(e.guesses(word))
+}
+
+func strengthScore(guesses float64) int {
+	for s, t := range strengthThresholds {
+		if guesses < t {
+			return s
+		}
+	}
+	return strengthScores - 1
+}
+
+func bruteGuesses(n int) float64 {
+	g := math.Pow(bruteCardinality, float64(n))
+	if n == 1 {
+		return max(g+1, 11)
+	}
+	return max(g+1, 51)
+}
+
+// matches finds every piece of word an attacker would guess as a whole.
+func (e *strengthEstimator) matches(word string) []strengthMatch {
+	var out []strengthMatch
+	out = append(out, e.dictionaryMatches(word)...)
+	out = append(out, sequenceMatches(word)...)
+	out = append(out, keyboardMatches(word)...)
+	out = append(out, e.repeatMatches(word)...)
+	out = append(out, dateMatches(word)...)
+	return out
+}
+
+// leetSubs undoes the usual character substitutions; 1 may stand for i or l.
+var leetSubs = [...]map[byte]byte{
+	{'4': 'a', '@': 'a', '3': 'e', '1': 'i', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't'},
+	{'4': 'a', '@': 'a', '3': 'e', '1': 'l', '!': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't'},
+}
+
+// dictionaryMatches finds ranked words, in any case, reversed or with
+// substitutions undone.
+func (e *strengthEstimator) dictionaryMatches(word string) []strengthMatch {
+	var out []strengthMatch
+	for i := range len(word) {
+		for j := i + minDictionaryPart - 1; j < len(word); j++ {
+			part := word[i : j+1]
+			lower := strings.ToLower(part)
+			upper := uppercaseVariations(part)
+			best := math.Inf(1)
+			if r, ok := e.rank[lower]; ok {
+				best = float64(r) * upper
+			}
+			if r, ok := e.rank[reverseString(lower)]; ok {
+				best = min(best, float64(r)*upper*2)
+			}
+			for _, subs := range leetSubs {
+				plain, subbed := unleet(lower, subs)
+				if subbed == 0 {
+					break
+				}
+				if r, ok := e.rank[plain]; ok {
+					best = min(best, float64(r)*upper*leetVariations(lower, plain))
+				}
+			}
+			if !math.IsInf(best, 1) {
+				out = append(out, strengthMatch{i, j, best})
+			}
+		}
+	}
+	return out
+}
+
+func reverseString(s string) string {
+	b := []byte(s)
+	slices.Reverse(b)
+	return string(b)
+}
+
+// unleet returns s with subs undone and how many characters that changed.
+func unleet(s string, subs map[byte]byte) (string, int) {
+	b, n := []byte(s), 0
+	for i, c := range b {
+		if p, ok := subs[c]; ok {
+			b[i] = p
+			n++
+		}
+	}
+	return string(b), n
+}
+
+// uppercaseVariations is how many ways of casing a word an attacker tries
+// before part's: one for lower case, two for a capital at either end or all
+// capitals, and the ways to pick that many capitals otherwise.
+func uppercaseVariations(part string) float64 {
+	var upper, lower int
+	for i := range len(part) {
+		switch c := part[i]; {
+		case c >= 'A' && c <= 'Z':
+			upper++
+		case c >= 'a' && c <= 'z':
+			lower++
+		}
+	}
+	first, last := part[0], part[len(part)-1]
+	switch {
+	case upper == 0:
+		return 1
+	case lower == 0,
+		upper == 1 && first >= 'A' && first <= 'Z',
+		upper == 1 && last >= 'A' && last <= 'Z':
+		return 2
+	}
+	return choices(upper, lower)
+}
+
+// leetVariations counts the substitutions tried before those in subbed,
+// which reads as plain with them undone.
+func leetVariations(subbed, plain string) float64 {
+	v, seen := 1.0, make(map[byte]bool)
+	for i := range len(subbed) {
+		if subbed[i] == plain[i] || seen[subbed[i]] {
+			continue
+		}
+		seen[subbed[i]] = true
+		s := strings.Count(subbed, subbed[i:i+1])
+		u := strings.Count(subbed, plain[i:i+1])
+		if u == 0 {
+			v *= 2
+		} else {
+			v *= choices(s, u)
+		}
+	}
+	return v
+}
+
+// choices sums C(a+b, k) for k from 1 to min(a, b).
+func choices(a, b int) float64 {
+	var sum float64
+	for k := 1; k <= min(a, b); k++ {
+		sum += binomial(a+b, k)
+	}
+	return max(sum, 1)
+}
+
+func binomial(n, k int) float64 {
+	r := 1.0
+	for i := 1; i <= k; i++ {
+		r = r * float64(n-k+i) / float64(i)
+	}
+	return r
+}
+
+// sequenceMatches finds runs of three or more characters a constant step
+// apart, such as abc, 2468 or zyx.
+func sequenceMatches(word string) []strengthMatch {
+	var out []strengthMatch
+	for i := 0; i+2 < len(word); {
+		step := int(word[i+1]) - int(word[i])
+		j := i + 1
+		for j+1 < len(word) && int(word[j+1])-int(word[j]) == step {
+			j++
+		}
+		if n := j - i + 1; n >= 3 && step != 0 && step >= -5 && step <= 5 {
+			var base float64
+			switch c := word[i]; {
+			case strings.IndexByte("aAzZ019", c) >= 0:
+				base = 4
+			case c >= '0' && c <= '9':
+				base = 10
+			default:
+				base = 26
+			}
+			if step < 0 {
+				base *= 2
+			}
+			out = append(out, strengthMatch{i, j, base * float64(n)})
+		}
+		i = j
+	}
+	return out
+}
+
+// The rows of a US keyboard, with and without shift, and its columns from
+// left to right.
+var (
+	keyboardRows = []string{
+		"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./",
+		"~!@#$%^&*()_+", "QWERTYUIOP{}|", "ASDFGHJKL:\"", "ZXCVBNM<>?",
+	}
+	keyboardColumns = []string{"1qaz", "2wsx", "3edc", "4rfv", "5tgb", "6yhn", "7ujm", "8ik,", "9ol.", "0p;/"}
+)
+
+// keyboardMatches finds runs of four or more keys along a keyboard row or
+// column, either way.
+func keyboardMatches(word string) []strengthMatch {
+	const minRun = 4
+	var out []strengthMatch
+	for i := 0; i+minRun <= len(word); i++ {
+		longest := 0
+		for _, row := range slices.Concat(keyboardRows, keyboardColumns) {
+			for _, r := range []string{row, reverseString(row)} {
+				for n := min(len(r), len(word)-i); n > longest; n-- {
+					if strings.Contains(r, word[i:i+n]) {
+						longest = n
+						break
+					}
+				}
+			}
+		}
+		if longest >= minRun {
+			out = append(out, strengthMatch{i, i + longest - 1, float64(len(keyboardRows)+len(keyboardColumns)) * 2 * float64(longest)})
+		}
+	}
+	return out
+}
+
+// repeatMatches finds a piece repeated two or more times, such as aaaa or
+// abcabc, costing the guesses of the piece times the repeats.
+func (e *strengthEstimator) repeatMatches(word string) []strengthMatch {
+	var out []strengthMatch
+	for i := range len(word) {
+		for unit := 1; i+2*unit <= len(word); unit++ {
+			n := 2
+			for i+(n+1)*unit <= len(word) && word[i+n*unit:i+(n+1)*unit] == word[i:i+unit] {
+				n++
+			}
+			if word[i+unit:i+2*unit] != word[i:i+unit] {
+				continue
+			}
+			out = append(out, strengthMatch{i, i + n*unit - 1, e.guesses(word[i:i+unit]) * float64(n)})
+		}
+	}
+	return out
+}
+
+// dateMatches finds years and all-digit dates: 1987, 0412, 041287,
+// 19870412.
+func dateMatches(word string) []strengthMatch {
+	var out []strengthMatch
+	for i := range len(word) {
+		for _, n := range []int{4, 6, 8} {
+			if i+n > len(word) {
+				break
+			}
+			part := word[i : i+n]
+			if strings.Trim(part, "0123456789") != "" {
+				break
+			}
+			if year, ok := dateYear(part); ok {
+				space := max(math.Abs(float64(year-referenceYear)), minYearSpace)
+				g := space
+				if n > 4 {
+					g *= 365
+				}
+				out = append(out, strengthMatch{i, i + n - 1, g})
+			}
+		}
+	}
+	return out
+}
+
+// dateYear reads part as a year (4 digits) or a day, month and year in one
+// of the usual orders.
+func dateYear(part string) (int, bool) {
+	num := func(s string) int { v, _ := strconv.Atoi(s); return v }
+	dayMonth := func(a, b int) bool {
+		return (a >= 1 && a <= 31 && b >= 1 && b <= 12) || (b >= 1 && b <= 31 && a >= 1 && a <= 12)
+	}
+	fullYear := func(y int) int {
+		if y < 100 {
+			if y > referenceYear%100 {
+				return 1900 + y
+			}
+			return 2000 + y
+		}
+		return y
+	}
+	inRange := func(y int) bool { return y >= 1900 && y <= referenceYear+25 }
+	switch len(part) {
+	case 4:
+		y := num(part)
+		if inRange(y) {
+			return y, true
+		}
+		if dayMonth(num(part[:2]), num(part[2:])) {
+			return referenceYear, true // a day and month of this year
+		}
+	case 6:
+		if dayMonth(num(part[:2]), num(part[2:4])) {
+			return fullYear(num(part[4:])), true
+		}
+		if dayMonth(num(part[2:4]), num(part[4:])) {
+			return fullYear(num(part[:2])), true
+		}
+	case 8:
+		if y := num(part[4:]); inRange(y) && dayMonth(num(part[:2]), num(part[2:4])) {
+			return y, true
+		}
+		if y := num(part[:4]); inRange(y) && dayMonth(num(part[4:6]), num(part[6:])) {
+			return y, true
+		}
+	}
+	return 0, false
+}
+
+func strengthCmd(args []string) error {
+	fs := newToolFlags("strength", "list...")
+	var dicts stringList
+	fs.Var(&dicts, "strength-dict", "also rank the words of this `list`, most common first; may be repeated")
+	buckets := fs.String("buckets", "", "write the words into strength-0.txt to strength-4.txt in this `directory` instead")
+	sorted := fs.Bool("sort", false, "write the weakest words first (holds the lists in memory)")
+	out := fs.String("o", "", "write the rated words to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: strength needs at least one list (- for stdin)", ErrConfig)
+	}
+	est, err := newStrengthEstimator(dicts)
+	if err != nil {
+		return err
+	}
+
+	// Every word goes to the output of its score: one shared output, where
+	// each line gets the score and log10 of the guesses, or one per bucket.
+	var outs [strengthScores]*listOutput
+	if *buckets == "" {
+		w, err := newListOutput(*out)
+		if err != nil {
+			return err
+		}
+		for s := range outs {
+			outs[s] = w
+		}
+	} else {
+		if err := os.MkdirAll(*buckets, 0o755); err != nil {
+			return diskError("create "+*buckets, err)
+		}
+		for s := range outs {
+			if outs[s], err = newListOutput(filepath.Join(*buckets, fmt.Sprintf("strength-%d.txt", s))); err != nil {
+				return err
+			}
+		}
+	}
+	var counts [strengthScores]int64
+	write := func(word []byte, guesses float64) error {
+		s := strengthScore(guesses)
+		counts[s]++
+		if *buckets != "" {
+			return outs[s].writeLine(word)
+		}
+		line := fmt.Appendf(word[:len(word):len(word)], "\t%d\t%.2f", s, math.Log10(guesses))
+		return outs[s].writeLine(line)
+	}
+
+	type rated struct {
+		word    string
+		guesses float64
+	}
+	var words []rated
+	st, err := forEachLine(fs.Args(), "rating", func(word []byte) error {
+		g := est.guesses(string(word))
+		if *sorted {
+			words = append(words, rated{string(word), g})
+			return nil
+		}
+		return write(word, g)
+	})
+	if err == nil && *sorted {
+		slices.SortStableFunc(words, func(a, b rated) int { return cmp.Compare(a.guesses, b.guesses) })
+		for _, r := range words {
+			if err = write([]byte(r.word), r.guesses); err != nil {
+				break
+			}
+		}
+	}
+	for s, w := range outs {
+		if s > 0 && w == outs[0] {
+			break
+		}
+		if cerr := w.close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "💪 Rated %s words of %s by estimated strength\n", fmtInt(st.lines), describeLists(fs.Args()))
+	for s, n := range counts {
+		fmt.Fprintf(os.Stderr, "   score %d  %15s (%s%%)\n", s, fmtInt(n), fmtFloat(percentOf(n, st.lines), 2))
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// One generation pass can feed several targets: -output takes a comma-
+// separated list, such as files,stdout to archive the chunks while a
+// cracker reads them live. Each target has its own queue, -tee-buffer
+// long, drained by its own goroutine, so a target that stalls for a moment
+// holds up the others only once its queue is full. A chunk commits once
+// every target has taken all of it, so the state never gets ahead of the
+// slowest of them.
+
+// teeBlock is how much a tee gathers before it queues the bytes for its
+// targets.
+const teeBlock = 64 << 10
+
+// defaultTeeBuffer is -tee-buffer's default, and the queue a tool's tee
+// gets.
+const defaultTeeBuffer = 64 << 20
+
+// outputTargets splits an -output spec into its targets.
+func outputTargets(spec string) []string {
+	return strings.Split(spec, ",")
+}
+
+// teeSink writes every chunk to all of its sinks.
+type teeSink struct {
+	sinks  []outputSink
+	blocks int // queued blocks per target
+}
+
+// newTeeSink returns a tee over sinks, named by targets, queuing up to
+// buffer bytes for each. At most one of them may keep its chunks.
+func newTeeSink(targets []string, sinks []outputSink, buffer int64) (*teeSink, error) {
+	keeping := 0
+	for i, s := range sinks {
+		switch {
+		case s.keeps():
+			keeping++
+		case targets[i] == "null":
+			return nil, fmt.Errorf("%w: -output %s: null discards what the other targets get anyway", ErrConfig, strings.Join(targets, ","))
+		}
+		if slices.Index(targets, targets[i]) != i {
+			return nil, fmt.Errorf("%w: -output names %s twice", ErrConfig, targets[i])
+		}
+	}
+	if keeping > 1 {
+		return nil, fmt.Errorf("%w: -output %s keeps the chunks more than once", ErrConfig, strings.Join(targets, ","))
+	}
+	return &teeSink{sinks: sinks, blocks: int(max(buffer/teeBlock, 1))}, nil
+}
+
+func (s *teeSink) open(name string) (chunk, error) {
+	c := &teeChunk{pending: make([]byte, 0, teeBlock)}
+	for _, sink := range s.sinks {
+		next, err := sink.open(name)
+		if err != nil {
+			c.abort()
+			return nil, err
+		}
+		q := &queuedChunk{chunk: next, blocks: make(chan []byte, s.blocks), done: make(chan struct{})}
+		go q.drain()
+		c.targets = append(c.targets, q)
+	}
+	return c, nil
+}
+
+// keeps reports whether one of the sinks keeps its chunks; that one's are
+// what is published.
+func (s *teeSink) keeps() bool {
+	return slices.ContainsFunc(s.sinks, outputSink.keeps)
+}
+
+func (s *teeSink) close() error {
+	var errs []error
+	for _, sink := range s.sinks {
+		errs = append(errs, sink.close())
+	}
+	return errors.Join(errs...)
+}
+
+// teeChunk gathers the words into blocks of teeBlock and queues every
+// block for each target. A block is never written to again once queued, so
+// the targets share it.
+type teeChunk struct {
+	targets []*queuedChunk
+	pending []byte
+	n       int64
+}
+
+func (c *teeChunk) Write(p []byte) (int, error) {
+	c.pending = append(c.pending, p...)
+	c.n += int64(len(p))
+	if len(c.pending) >= teeBlock {
+		return len(p), c.flush()
+	}
+	return len(p), nil
+}
+
+// flush queues the pending bytes for every target, waiting for room in a
+// full queue, and fails with the error of a target that gave up.
+func (c *teeChunk) flush() error {
+	if len(c.pending) == 0 {
+		return nil
+	}
+	block := c.pending
+	c.pending = make([]byte, 0, teeBlock)
+	for _, q := range c.targets {
+		select {
+		case q.blocks <- block:
+		case <-q.done:
+			return q.err
+		}
+	}
+	return nil
+}
+
+// commit waits for every target to write the whole chunk, then commits
+// them all, and reports what the one that keeps its chunks stored.
+func (c *teeChunk) commit() (stored, error) {
+	if err := c.flush(); err != nil {
+		return stored{}, err
+	}
+	for _, q := range c.targets {
+		if err := q.finish(); err != nil {
+			return stored{}, err
+		}
+	}
+	st := stored{size: c.n}
+	for _, q := range c.targets {
+		s, err := q.chunk.commit()
+		if err != nil {
+			return stored{}, err
+		}
+		if s.sum != nil {
+			st = s
+		}
+	}
+	return st, nil
+}
+
+// abort drops what the targets have still queued.
+func (c *teeChunk) abort() {
+	for _, q := range c.targets {
+		q.dropped.Store(true)
+		q.finish()
+		q.chunk.abort()
+	}
+}
+
+// queuedChunk is one target of a tee: drain writes its queued blocks to
+// the chunk until the queue is closed or a write fails.
+type queuedChunk struct {
+	chunk
+	blocks  chan []byte
+	done    chan struct{} // closed when drain returns
+	err     error         // why drain stopped early; set before done closes
+	closed  bool
+	dropped atomic.Bool // the chunk was aborted: skip the rest of the queue
+}
+
+func (q *queuedChunk) drain() {
+	defer close(q.done)
+	for block := range q.blocks {
+		if q.dropped.Load() {
+			continue
+		}
+		if _, err := q.chunk.Write(block); err != nil {
+			q.err = err
+			return
+		}
+	}
+}
+
+// finish closes the queue and waits for drain to return.
+func (q *queuedChunk) finish() error {
+	if !q.closed {
+		q.closed = true
+		close(q.blocks)
+	}
+	<-q.done
+	return q.err
+}
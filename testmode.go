@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"main.go/wordlist"
+)
+
+// Test mode runs the whole pipeline on a keyspace small enough to finish in
+// seconds: 4 symbols up to length 4 make 340 words in 34 chunks of 10, so a
+// run publishes twice and can be interrupted halfway.
+const (
+	testCharset   = "ab01"
+	testPerFile   = 10
+	testInterrupt = 7 // chunks completed before the first run is stopped
+)
+
+// interrupter cancels the run once it has completed a number of files.
+type interrupter struct {
+	after  int
+	cancel context.CancelFunc
+}
+
+func (i *interrupter) handle(e event) {
+	if e.kind == evFile && e.files >= i.after {
+		i.cancel()
+	}
+}
+
+// useTestKeyspace points the flags a run reads at the test keyspace, in
+// chunks of testPerFile in the current directory without git, and resets
+// the others, so runs in one process do not inherit each other's.
+func useTestKeyspace(opts *options) {
+	charset, charsetFile, keyspaceMask, entriesPerFile, maxFileSize = testCharset, "", "", testPerFile, ""
+	minLength, maxLength = 1, 4
+	crunch.template, crunch.literal = "", ""
+	startFrom, stopAt = "", ""
+	keyspaceOrder, seed = "keyspace", 1
+	hybrid.dict, hybrid.prefix, hybrid.suffix = "", "", ""
+	combinator.left, combinator.right, combinator.seps = "", "", nil
+	mangling.path, mangling.rules, mangling.sum = "", nil, ""
+	fastLane.target, fastLane.model, fastLane.policy = "", "", ""
+	layoutFlags.dir, layoutFlags.template, layoutFlags.perDir = "", wordlist.DefaultLayout.Template, 0
+	gitCfg.mode = "off"
+	exclusions.masks, exclusions.ranges, exclusions.constraints = nil, nil, nil
+	nodes, node, stateFile = 0, 0, "state.txt"
+	statusFiles = nil
+	opts.output, opts.shm, opts.shards, opts.placeholders = "files", "", 0, ""
+	opts.workers, opts.compress, opts.checkpoint = 1, compression{codec: "none"}, checkpointInterval{}
+	opts.status, opts.dashboard = "none", ""
+}
+
+// runTestMode generates the test keyspace in a temporary git repository
+// publishing to a local bare one, interrupts it, resumes it, and checks the
+// chunks, the manifest, the pushed commits and recover. Everything the runs
+// print is discarded; each check reports a line instead.
+func runTestMode(ctx context.Context, opts *options) error {
+	dir, err := os.MkdirTemp("", "wordgen-test-")
+	if err != nil {
+		return diskError("create test directory", err)
+	}
+	remote, work := filepath.Join(dir, "remote.git"), filepath.Join(dir, "work")
+	fmt.Printf("🧪 Test mode in %s\n", dir)
+
+	console := os.Stdout
+	step := func(format string, a ...any) { fmt.Fprintf(console, "✅ "+format+"\n", a...) }
+	fail := func(format string, a ...any) error {
+		fmt.Fprintf(console, "❌ %s\n   (files kept in %s)\n", fmt.Sprintf(format, a...), dir)
+		return fmt.Errorf("test mode: "+format, a...)
+	}
+
+	setup := [][]string{
+		{"init", "-q", "--bare", "-b", "main", remote},
+		{"init", "-q", "-b", "main", work},
+		{"-C", work, "config", "user.name", "wordgen test"},
+		{"-C", work, "config", "user.email", "test@localhost"},
+		{"-C", work, "remote", "add", "origin", remote},
+	}
+	for _, args := range setup {
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			return fail("git %s: %v: %s", args[0], err, out)
+		}
+	}
+	if err := os.Chdir(work); err != nil {
+		return fail("%v", err)
+	}
+	useTestKeyspace(opts)
+	gitCfg.mode, gitCfg.remote, gitCfg.branch, gitCfg.every, gitCfg.message = "on", "origin", "main", commitEvery, defaultCommitMessage
+	opts.errs.publish = abort
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return fail("%v", err)
+	}
+	defer devNull.Close()
+	stderr := os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull // git reports pushes on stderr
+	defer func() { os.Stdout, os.Stderr = console, stderr }()
+
+	// First run: stopped after testInterrupt chunks, like a Ctrl-C.
+	first, cancel := context.WithCancel(ctx)
+	stop := &interrupter{testInterrupt, cancel}
+	events.subscribe(stop)
+	err = run(first, opts)
+	events.unsubscribe(stop)
+	cancel()
+	if !errors.Is(err, context.Canceled) || ctx.Err() != nil {
+		return fail("interrupted run ended with %v, want it cancelled", err)
+	}
+	pos, err := readState(stateFile, total)
+	if err != nil || pos != testInterrupt*testPerFile {
+		return fail("state after interruption is %d (%v), want %d", pos, err, testInterrupt*testPerFile)
+	}
+	step("interrupted after %d chunks, state at %d", testInterrupt, pos)
+
+	// Second run: resumes and finishes.
+	if err := run(ctx, opts); err != nil {
+		return fail("resumed run failed: %v", err)
+	}
+	ks, err := newKeyspace()
+	if err != nil {
+		return fail("%v", err)
+	}
+	if pos, err = readState(stateFile, total); err != nil || pos != total {
+		return fail("state after resume is %d (%v), want %d", pos, err, total)
+	}
+	step("resumed and completed %d words", total)
+
+	// The chunks hold every word exactly once, in order.
+	nums, err := chunkFiles()
+	if err != nil {
+		return fail("%v", err)
+	}
+	want := int((total + testPerFile - 1) / testPerFile)
+	if len(nums) != want || nums[len(nums)-1] != want {
+		return fail("found %d chunk files, want %d", len(nums), want)
+	}
+	var readers []io.Reader
+	for _, n := range nums {
+		r, err := openChunk(chunkName(n))
+		if err != nil {
+			return fail("%v", err)
+		}
+		defer r.Close()
+		readers = append(readers, r)
+	}
+	all := io.MultiReader(readers...)
+	words, _ := ks.Range(0, total)
+	if _, err := words.WriteN(ctx, &compareWriter{r: all}, total); err != nil {
+		return fail("chunks do not match the keyspace: %v", err)
+	}
+	if n, _ := io.ReadFull(all, make([]byte, 1)); n > 0 {
+		return fail("chunks hold more than the keyspace")
+	}
+	step("%d chunks hold the keyspace in order", len(nums))
+
+	// The manifest vouches for every chunk.
+	sums, err := readManifest(manifestFile)
+	if err != nil {
+		return fail("%v", err)
+	}
+	for _, n := range nums {
+		sum, err := fileChecksum(chunkName(n))
+		if err != nil || sums[chunkName(n)] != sum {
+			return fail("%s: checksum %v does not match %s (%v)", chunkName(n), sum, manifestFile, err)
+		}
+	}
+	step("%s matches every chunk", manifestFile)
+
+	// The remote got exactly the chunks, the manifest, the publish log and
+	// the contract, in two pushes.
+	out, err := exec.Command("git", "--git-dir", remote, "ls-tree", "--name-only", "main").Output()
+	if err != nil {
+		return fail("reading the published tree: %v", err)
+	}
+	published := strings.Fields(string(out))
+	expected := []string{manifestFile, publishedLog, contractFile}
+	for _, n := range nums {
+		expected = append(expected, chunkName(n))
+	}
+	slices.Sort(expected)
+	if !slices.Equal(published, expected) {
+		return fail("published %v, want the %d chunks, %s, %s and %s", published, len(nums), manifestFile, publishedLog, contractFile)
+	}
+	out, err = exec.Command("git", "--git-dir", remote, "rev-list", "--count", "main").Output()
+	if commits := strings.TrimSpace(string(out)); err != nil || commits != "2" {
+		return fail("remote has %s commits (%v), want 2", commits, err)
+	}
+	step("published %d chunks and %s in 2 commits, nothing else", len(nums), manifestFile)
+
+	// recover rebuilds a lost state from the chunks.
+	os.Remove(stateFile)
+	if err := recoverState(ks); err != nil {
+		return fail("recover: %v", err)
+	}
+	if pos, err = readState(stateFile, total); err != nil || pos != total {
+		return fail("recovered state is %d (%v), want %d", pos, err, total)
+	}
+	step("recover rebuilt the state")
+
+	os.RemoveAll(dir)
+	fmt.Fprintln(console, "🎉 Test mode passed")
+	return nil
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+)
+
+// tool is a subcommand that works on wordlists and targets rather than
+// generating the keyspace. Each parses its own flags from args.
+type tool struct {
+	summary string
+	run     func(args []string) error
+}
+
+// tools is filled in init, since the tools' usage messages read it.
+var tools map[string]tool
+
+func init() {
+	tools = map[string]tool{
+		"audit":       {"list the passwords a policy should refuse (too short, dictionary-based, keyboard walks) with a report", auditCmd},
+		"augment":     {"append frequent symbol and emoji suffixes to dictionary words", augmentCmd},
+		"blocklist":   {"export a banned-password list for Entra ID (Azure AD) or NIST-style checks, likeliest first", blocklistCmd},
+		"campaign":    {"turn a target descriptor into a prioritized campaign config", campaignCmd},
+		"clean":       {"sanitize a dirty wordlist: BOMs, CRs, control bytes, long lines, bad UTF-8", cleanCmd},
+		"dedup":       {"merge wordlists, dropping repeated, NFC-equivalent or case-folded words", dedupCmd},
+		"emitted":     {"tell whether words were generated or published, and in which file", emittedCmd},
+		"estimate":    {"estimate how many words of a keyspace or mask pass the filters, by sampling", estimateCmd},
+		"freq":        {"turn a corpus into a wordlist ordered by frequency", freqCmd},
+		"hash-ranges": {"write the SHA-1 or NTLM hashes of lists as Pwned Passwords-style range files for k-anonymous lookups", hashRangesCmd},
+		"hashcat":     {"convert positions to and from hashcat masks, --skip and restore points", hashcatCmd},
+		"harvest-fs":  {"collect candidate words from file names, directory names and document metadata", harvestCmd},
+		"learn":       {"model the style of your own known passwords from browser and password-manager exports", learnCmd},
+		"masks":       {"enumerate the union of several masks, each word once, and what overlaps", masksCmd},
+		"mirror":      {"fetch the chunks a run published since the last mirror, checking each against its checksum", mirrorCmd},
+		"neighbors":   {"expand seed words to every variant within edit distance 1 or 2", neighborsCmd},
+		"phrases":     {"permute the tokens, separators and cases of multi-word seeds", phrasesCmd},
+		"policy":      {"infer a target's password policy from passwords it accepted", policyCmd},
+		"recall":      {"enumerate the passwords that fit the fragments you remember of one", recallCmd},
+		"reassemble":  {"check shards' manifests cover the keyspace once and merge them", reassembleCmd},
+		"score":       {"append to each word its probability under a model from learn, for weighted ordering", scoreCmd},
+		"transform":   {"pass existing lists through the filters and encodings into chunks, without generating", transformCmd},
+		"trim":        {"filter a wordlist down to the words a policy accepts", trimCmd},
+		"strength":    {"rate words by estimated strength, as zxcvbn does, or sort them into one list per score", strengthCmd},
+		"views":       {"link the published chunks into one directory per word length", viewsCmd},
+	}
+}
+
+// toolUsage lists the tools for the main usage message.
+func toolUsage(w io.Writer) {
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", name, tools[name].summary)
+	}
+}
+
+// runTool runs the tool named by args[0], if there is one, and reports
+// whether it did. Tools log to stderr and share the exit codes of a run.
+func runTool(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	t, ok := tools[args[0]]
+	if !ok {
+		return false
+	}
+	events.subscribe(logSink{})
+	setLocale("")
+	if err := t.run(args[1:]); err != nil {
+		exit(err)
+	}
+	return true
+}
+
+// newToolFlags returns the flag set of a tool; usage is its argument
+// synopsis.
+func newToolFlags(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s [flags] %s\n\n%s.\n\n", os.Args[0], name, usage, tools[name].summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseToolFlags parses args into fs; a usage error is a configuration error.
+func parseToolFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(exitCompleted)
+		}
+		return fmt.Errorf("%w: %s: %w", ErrConfig, fs.Name(), err)
+	}
+	return nil
+}
+
+// createOutput opens path for a tool's result, or stdout for "" or "-".
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, diskError("create "+path, err)
+	}
+	return f, nil
+}
+
+// readLines reads the lines of path, or of stdin for "-".
+func readLines(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConfig, err)
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n"), nil
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// capitalize upper-cases the first letter of s and lower-cases the rest.
+func capitalize(s string) string {
+	r := []rune(strings.ToLower(s))
+	if len(r) > 0 {
+		r[0] = []rune(strings.ToUpper(string(r[0])))[0]
+	}
+	return string(r)
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// encodeStage returns the pipeline stage writing each word in encoding:
+// hex, base64, or hashcat's $HEX[...] for the words hashcat would not read
+// as they are (control or non-ASCII bytes, or a literal "$HEX[" prefix).
+func encodeStage(encoding string) (func(word []byte) ([]byte, bool), error) {
+	var buf []byte
+	switch encoding {
+	case "hex":
+		return func(word []byte) ([]byte, bool) {
+			buf = hex.AppendEncode(buf[:0], word)
+			return buf, true
+		}, nil
+	case "base64":
+		return func(word []byte) ([]byte, bool) {
+			buf = base64.StdEncoding.AppendEncode(buf[:0], word)
+			return buf, true
+		}, nil
+	case "hashcat":
+		return func(word []byte) ([]byte, bool) {
+			if !bytes.HasPrefix(word, []byte("$HEX[")) && !bytes.ContainsFunc(word, func(r rune) bool { return r < 0x20 || r > 0x7e }) {
+				return word, true
+			}
+			buf = append(hex.AppendEncode(append(buf[:0], "$HEX["...), word), ']')
+			return buf, true
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: unknown -encode %q (want none, hex, base64 or hashcat)", ErrConfig, encoding)
+}
+
+// transformState is where an interrupted transform resumes: the place in
+// its inputs just past the last word of its last complete chunk.
+type transformState struct {
+	inputs string // the input paths, quoted, to tell another transform's state
+	at     listOffset
+	chunks int
+}
+
+// readTransformState reads the state at path; a missing file is a fresh
+// start.
+func readTransformState(path, inputs string) (transformState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return transformState{inputs: inputs}, nil
+	}
+	if err != nil {
+		return transformState{}, fmt.Errorf("%w: %w", ErrStateCorrupt, err)
+	}
+	s := transformState{inputs: inputs}
+	saved, _ := stateField(data, "inputs")
+	if saved != inputs {
+		return s, fmt.Errorf("%w: %s belongs to a transform of %s, not %s; remove it to start over", ErrConfig, path, saved, inputs)
+	}
+	for _, f := range []struct {
+		name string
+		v    *int64
+	}{{"bytes", &s.at.bytes}, {"lines", &s.at.lines}} {
+		value, _ := stateField(data, f.name)
+		if *f.v, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return s, fmt.Errorf("%w: %s: %s: %w", ErrStateCorrupt, path, f.name, err)
+		}
+	}
+	for _, f := range []struct {
+		name string
+		v    *int
+	}{{"list", &s.at.list}, {"chunks", &s.chunks}} {
+		value, _ := stateField(data, f.name)
+		if *f.v, err = strconv.Atoi(value); err != nil {
+			return s, fmt.Errorf("%w: %s: %s: %w", ErrStateCorrupt, path, f.name, err)
+		}
+	}
+	return s, nil
+}
+
+func (s transformState) write(path string) error {
+	data := fmt.Appendf(nil, "inputs %s\nlist %d\nbytes %d\nlines %d\nchunks %d\n", s.inputs, s.at.list, s.at.bytes, s.at.lines, s.chunks)
+	return diskError("save transform state", writeFileAtomic(path, data))
+}
+
+// chunkedOutput cuts the words written to it into chunks of perFile words
+// named prefix000001.txt, prefix000002.txt... on a sink.
+type chunkedOutput struct {
+	sink      outputSink
+	prefix    string
+	ext       string
+	perFile   int64
+	committed func() error // called after each chunk completes, if set
+
+	cur     chunk
+	w       *bufio.Writer
+	inChunk int64
+	chunks  int
+	bytes   int64
+}
+
+func (o *chunkedOutput) writeLine(word []byte) error {
+	if o.cur == nil {
+		name := fmt.Sprintf("%s%06d.txt", o.prefix, o.chunks+1) + o.ext
+		c, err := o.sink.open(name)
+		if err != nil {
+			return err
+		}
+		o.cur, o.inChunk = c, 0
+		if o.w == nil {
+			o.w = bufio.NewWriterSize(c, listBufferSize)
+		} else {
+			o.w.Reset(c)
+		}
+	}
+	o.w.Write(word)
+	if err := o.w.WriteByte('\n'); err != nil {
+		return diskError("write output", err)
+	}
+	if o.inChunk++; o.inChunk == o.perFile {
+		return o.commit()
+	}
+	return nil
+}
+
+// commit completes the current chunk, if one is open.
+func (o *chunkedOutput) commit() error {
+	if o.cur == nil {
+		return nil
+	}
+	c := o.cur
+	o.cur = nil
+	if err := o.w.Flush(); err != nil {
+		c.abort()
+		return diskError("write output", err)
+	}
+	st, err := c.commit()
+	if err != nil {
+		return err
+	}
+	o.chunks++
+	o.bytes += st.size
+	if o.committed != nil {
+		return o.committed()
+	}
+	return nil
+}
+
+func (o *chunkedOutput) close() error {
+	err := o.commit()
+	if o.cur != nil {
+		o.cur.abort()
+	}
+	if cerr := o.sink.close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func transformCmd(args []string) error {
+	fs := newToolFlags("transform", "[list...]")
+	ff := addFilterFlags(fs)
+	encoding := fs.String("encode", "none", "write the words as `none`, hex, base64 or hashcat ($HEX[...] where needed)")
+	output := fs.String("output", "stdout", "where chunks go: files, null, `stdout` or tcp://host:port, or several separated by commas")
+	perFile := fs.Int64("per-file", entriesPerFile, "`words` per chunk")
+	prefix := fs.String("prefix", "transformed_", "name chunks `prefix`000001.txt, prefix000002.txt...")
+	statePath := fs.String("state", "", "record the input offset of every completed chunk in this `file`, and resume from it")
+	workers := fs.Int("workers", 1, "filter and encode on this many goroutines; the output keeps the input's order")
+	var c compression
+	fs.StringVar(&c.codec, "compress", "none", "compress chunks with this `codec`: none, gzip or zstd")
+	fs.IntVar(&c.level, "compress-level", 0, "codec `level` (gzip 1-9, zstd 1-22; 0 for the codec default)")
+	fs.IntVar(&c.workers, "compress-workers", 0, "compressing goroutines (0 for one per CPU)")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	if *perFile < 1 || *workers < 1 {
+		return fmt.Errorf("%w: -per-file and -workers must be positive", ErrConfig)
+	}
+
+	// One pipeline per worker, and one more adding up their counts.
+	fl, err := ff.build()
+	if err != nil {
+		return err
+	}
+	pipes := make([]*pipeline, *workers+1)
+	for i := range pipes {
+		if i > 0 {
+			if fl, err = ff.chain(fl.policy); err != nil {
+				return err
+			}
+		}
+		pipes[i] = &fl.pipe
+		if *encoding != "none" {
+			stage, err := encodeStage(*encoding)
+			if err != nil {
+				return err
+			}
+			pipes[i].add("encode "+*encoding, stage)
+		}
+	}
+	pipe := pipes[0]
+	ext, err := c.ext()
+	if err != nil {
+		return err
+	}
+	pool, err := newCompressPool(c)
+	if err != nil {
+		return err
+	}
+	sink, err := newOutputSink(*output, "", pool, defaultTeeBuffer)
+	if err != nil {
+		return err
+	}
+	out := &chunkedOutput{sink: sink, prefix: *prefix, ext: ext, perFile: *perFile}
+
+	var state transformState
+	if *statePath != "" {
+		quoted := make([]string, len(paths))
+		for i, p := range paths {
+			quoted[i] = strconv.Quote(p)
+		}
+		if state, err = readTransformState(*statePath, strings.Join(quoted, " ")); err != nil {
+			return err
+		}
+		if state.chunks > 0 {
+			fmt.Fprintf(os.Stderr, "⏩ Resuming after chunk %d: %s, line %s (byte %s)\n",
+				state.chunks, paths[min(state.at.list, len(paths)-1)], fmtInt(state.at.lines), fmtInt(state.at.bytes))
+		}
+		out.chunks = state.chunks
+		out.committed = func() error {
+			state.chunks = out.chunks
+			return state.write(*statePath)
+		}
+	}
+	next := state.at
+	st, err := transformBlocks(paths, state.at, pipe, pipes[1:], func(word []byte, at listOffset) error {
+		state.at = at
+		return out.writeLine(word)
+	}, func(at listOffset) { next = at })
+	if cerr := out.close(); err == nil {
+		err = cerr
+	}
+	if err == nil && out.committed != nil {
+		state.at = next // past any dropped lines after the last word
+		err = out.committed()
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "🔁 Transformed %s: %s of %s words written to %d chunks (%s)\n",
+		describeLists(paths), fmtInt(pipe.emitted()), fmtInt(st.lines), out.chunks, fmtBytes(out.bytes))
+	pipe.report(os.Stderr)
+	return nil
+}
+
+// transformBlockLines is how many input lines go to a worker at once.
+const transformBlockLines = 4096
+
+// transformBlock is a run of input lines, numbered in input order, and the
+// words a worker made of them.
+type transformBlock struct {
+	seq    int
+	data   []byte       // the lines, back to back
+	ends   []int        // where each line ends in data
+	ats    []listOffset // the place just past each line
+	out    []byte       // the words that came out of the pipeline
+	outEnd []int
+	outAt  []listOffset // the place just past each word's line
+	counts []int64      // the pipeline's counts for the block
+}
+
+func (b *transformBlock) run(p *pipeline) {
+	start := 0
+	for i, end := range b.ends {
+		if word, ok := p.run(b.data[start:end]); ok {
+			b.out = append(b.out, word...)
+			b.outEnd = append(b.outEnd, len(b.out))
+			b.outAt = append(b.outAt, b.ats[i])
+		}
+		start = end
+	}
+	b.counts = p.takeCounts()
+}
+
+// errWriterFailed stops the reader once writing has failed; the writer's
+// own error is the one reported.
+var errWriterFailed = errors.New("writer failed")
+
+// transformBlocks reads paths from the place from in blocks of lines, runs
+// them through the pipes in parallel, one goroutine each, and hands the
+// words to write in input order, with the place just past each one's line.
+// report adds up the pipes' counts for the progress line and the report;
+// last learns the place past the last line read.
+func transformBlocks(paths []string, from listOffset, report *pipeline, pipes []*pipeline,
+	write func(word []byte, at listOffset) error, last func(listOffset)) (listStats, error) {
+	var mu sync.Mutex // guards report, read by the progress line as blocks add to it
+	blocks := make(chan *transformBlock, len(pipes))
+	results := make(chan *transformBlock, len(pipes))
+	var wg sync.WaitGroup
+	for _, p := range pipes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for b := range blocks {
+				b.run(p)
+				results <- b
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	failed := make(chan struct{})
+	written := make(chan error, 1)
+	go func() {
+		var err error
+		pending := make(map[int]*transformBlock)
+		next := 0
+		for b := range results {
+			mu.Lock()
+			report.addCounts(b.counts)
+			mu.Unlock()
+			if err != nil {
+				continue // drain the workers
+			}
+			pending[b.seq] = b
+			for b, ok := pending[next]; ok && err == nil; b, ok = pending[next] {
+				delete(pending, next)
+				next++
+				start := 0
+				for i, end := range b.outEnd {
+					if err = write(b.out[start:end], b.outAt[i]); err != nil {
+						close(failed)
+						break
+					}
+					start = end
+				}
+			}
+		}
+		written <- err
+	}()
+
+	cur := &transformBlock{}
+	seq := 0
+	send := func() error {
+		cur.seq = seq
+		seq++
+		select {
+		case blocks <- cur:
+		case <-failed:
+			return errWriterFailed
+		}
+		cur = &transformBlock{}
+		return nil
+	}
+	status := func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return report.status()
+	}
+	st, err := forEachLineFrom(paths, "transforming", status, from, func(line []byte, at listOffset) error {
+		cur.data = append(cur.data, line...)
+		cur.ends = append(cur.ends, len(cur.data))
+		cur.ats = append(cur.ats, at)
+		last(at)
+		if len(cur.ends) == transformBlockLines {
+			return send()
+		}
+		return nil
+	})
+	if err == nil && len(cur.ends) > 0 {
+		err = send()
+	}
+	close(blocks)
+	if werr := <-written; werr != nil {
+		err = werr
+	}
+	return st, err
+}
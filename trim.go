@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func trimCmd(args []string) error {
+	fs := newToolFlags("trim", "list...")
+	ff := addFilterFlags(fs)
+	out := fs.String("o", "", "write the kept words to this `file` instead of stdout")
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("%w: trim needs at least one list (- for stdin)", ErrConfig)
+	}
+
+	fl, err := ff.build()
+	if err != nil {
+		return err
+	}
+	pipe := &fl.pipe
+
+	w, err := newListOutput(*out)
+	if err != nil {
+		return err
+	}
+	st, err := forEachLineStatus(fs.Args(), "trimming", pipe.status, func(word []byte) error {
+		if word, ok := pipe.run(word); ok {
+			return w.writeLine(word)
+		}
+		return nil
+	})
+	if cerr := w.close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	kept := pipe.emitted()
+	fmt.Fprintf(os.Stderr, "✂️  Trimmed %s to %s: kept %s of %s words (%s%%)\n",
+		describeLists(fs.Args()), &fl.policy, fmtInt(kept), fmtInt(st.lines), fmtFloat(percentOf(kept, st.lines), 2))
+	pipe.report(os.Stderr)
+	fmt.Fprintf(os.Stderr, "   Policy dropped %s too short, %s too long, %s missing classes",
+		fmtInt(fl.dropped[policyTooShort]), fmtInt(fl.dropped[policyTooLong]), fmtInt(fl.dropped[policyClasses]))
+	if st.tooLong > 0 {
+		fmt.Fprintf(os.Stderr, ", %s lines over %s skipped", fmtInt(st.tooLong), fmtBytes(maxListLine))
+	}
+	fmt.Fprintf(os.Stderr, "\n   Read %s in %s (%s/s)\n", fmtBytes(st.bytes), fmtDuration(st.duration), fmtBytes(int64(float64(st.bytes)/st.duration.Seconds())))
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// linesFile, in each length directory of a view, gives the lines of every
+// chunk that hold words of that length: a chunk on a length boundary holds
+// words of two lengths.
+const linesFile = "LINES"
+
+func viewsCmd(args []string) error {
+	fs := newToolFlags("views", "")
+	perFile := fs.Int64("per-file", entriesPerFile, "words per chunk file the run was generated with")
+	manifest := fs.String("manifest", manifestFile, "link the chunks listed in this `manifest`")
+	out := fs.String("o", "by-length", "build the views in this `dir`: one len1/, len2/... directory per word length")
+	hard := fs.Bool("hard", false, "make hard links instead of symlinks, for consumers that do not follow links")
+	addKeyspaceFlags(fs)
+	addLayoutFlags(fs)
+	if err := parseToolFlags(fs, args); err != nil {
+		return err
+	}
+	entriesPerFile = *perFile
+	ks, err := newKeyspace()
+	if err != nil {
+		return err
+	}
+	sums, err := readManifest(*manifest)
+	if err != nil {
+		return err
+	}
+	chunks := make(map[int]string) // chunk number → path
+	for name := range sums {
+		num, ok := chunkLayout.Number(name)
+		if !ok {
+			continue // not a chunk
+		}
+		n := int(num)
+		if n < 1 || int64(n-1)*entriesPerFile >= total {
+			return fmt.Errorf("%w: %s lists %s, beyond the keyspace's %s words", ErrPublishFailed, *manifest, name, fmtInt(total))
+		}
+		chunks[n] = filepath.Join(filepath.Dir(*manifest), filepath.FromSlash(name))
+	}
+	if len(chunks) == 0 {
+		return fmt.Errorf("%w: %s lists no chunks", ErrConfig, *manifest)
+	}
+	if err := clearViews(*out); err != nil {
+		return err
+	}
+
+	for l := ks.MinLen(); l <= ks.MaxLen(); l++ {
+		start, end := ks.LengthRange(l)
+		dir := filepath.Join(*out, "len"+strconv.Itoa(l))
+		var lines strings.Builder
+		var linked, missing int
+		for n := int(start/entriesPerFile) + 1; int64(n-1)*entriesPerFile < end; n++ {
+			path, ok := chunks[n]
+			if !ok {
+				missing++
+				continue
+			}
+			if linked == 0 {
+				if err := os.MkdirAll(dir, 0755); err != nil {
+					return diskError("create "+dir, err)
+				}
+			}
+			if err := linkChunk(path, dir, *hard); err != nil {
+				return err
+			}
+			linked++
+			// The words of length l in this chunk, as 1-based lines.
+			first := int64(n-1) * entriesPerFile
+			from := max(start, first) - first + 1
+			to := min(end, first+entriesPerFile) - first
+			fmt.Fprintf(&lines, "%s\t%d\t%d\n", filepath.Base(path), from, to)
+		}
+		if linked > 0 {
+			if err := os.WriteFile(filepath.Join(dir, linesFile), []byte(lines.String()), 0644); err != nil {
+				return diskError("write "+linesFile, err)
+			}
+		}
+		fmt.Fprintf(os.Stderr, "🔗 %s: %d chunks", dir, linked)
+		if missing > 0 {
+			fmt.Fprintf(os.Stderr, ", %d not published yet", missing)
+		}
+		fmt.Fprintln(os.Stderr)
+	}
+	fmt.Fprintf(os.Stderr, "✅ Linked %d chunks from %s by length\n", len(chunks), *manifest)
+	return nil
+}
+
+// linkChunk links the chunk at path into dir under its own name; a symlink
+// is relative, so the view can move with the chunks.
+func linkChunk(path, dir string, hard bool) error {
+	link := filepath.Join(dir, filepath.Base(path))
+	if hard {
+		return diskError("link "+link, os.Link(path, link))
+	}
+	target, err := filepath.Rel(dir, path)
+	if err != nil {
+		if target, err = filepath.Abs(path); err != nil {
+			return err
+		}
+	}
+	return diskError("link "+link, os.Symlink(target, link))
+}
+
+// clearViews removes the links and LINES files of an earlier build of the
+// views in out, and leaves anything else alone.
+func clearViews(out string) error {
+	dirs, err := filepath.Glob(filepath.Join(out, "len*"))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue // not a directory
+		}
+		for _, e := range entries {
+			if _, ok := chunkLayout.Number(e.Name()); ok || e.Name() == linesFile {
+				if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+					return diskError("remove old view", err)
+				}
+			}
+		}
+		os.Remove(dir) // only if empty
+	}
+	return nil
+}
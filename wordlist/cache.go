@@ -0,0 +1,143 @@
+package wordlist
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Prefetch makes the corpus fetch chunks whole into memory, several at once,
+// ahead of a stream that reads them in order, so a consumer reading over
+// HTTP is not held up by each chunk's latency. The fetched chunks are kept
+// while they fit in budget bytes, the least recently used going first, so
+// seeking back into a recent chunk costs nothing either.
+//
+// The number of chunks fetched ahead follows the access pattern: it doubles,
+// up to ahead, each time the stream moves on to the next chunk, and drops to
+// none after a seek elsewhere, so random lookups do not fetch chunks nobody
+// reads. A budget of 0 turns the cache off and goes back to streaming each
+// chunk as it is read.
+//
+// Every chunk fetched into memory is checked against its XXH64 on arrival.
+func (c *Corpus) Prefetch(ahead int, budget int64) {
+	c.Close()
+	c.cache, c.ahead, c.window = nil, max(ahead, 0), 0
+	if budget > 0 {
+		c.cache = newChunkCache(budget, c.fetch)
+	}
+}
+
+// prefetch starts fetching the chunks after num that the window covers.
+func (c *Corpus) prefetch(num int64) {
+	if num == c.lastNum+1 {
+		c.window = min(max(2*c.window, 1), c.ahead)
+	} else {
+		c.window = 0
+	}
+	c.lastNum = num
+	for next := num + 1; next <= num+int64(c.window); next++ {
+		if _, ok := c.chunks[next]; ok {
+			c.cache.start(next)
+		}
+	}
+}
+
+// fetch reads the whole chunk num and checks it against the manifest.
+func (c *Corpus) fetch(num int64) ([]byte, error) {
+	ch := c.chunks[num]
+	r, err := c.src.Open(c.ctx, ch.name, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if ch.xxh64 != "" {
+		if got := fmt.Sprintf("%016x", xxhash.Sum64(data)); got != ch.xxh64 {
+			return nil, fmt.Errorf("%w: its XXH64 is %s, the manifest has %s", ErrBadChunk, got, ch.xxh64)
+		}
+	}
+	return data, nil
+}
+
+// chunkCache holds whole chunk files by number, fetched in the background,
+// up to a memory budget.
+type chunkCache struct {
+	fetch   func(num int64) ([]byte, error)
+	mu      sync.Mutex
+	budget  int64
+	size    int64 // of the fetched entries
+	entries map[int64]*cacheEntry
+	lru     list.List // of the fetched entries, most recently used first
+}
+
+type cacheEntry struct {
+	num  int64
+	data []byte
+	err  error
+	done chan struct{} // closed once data or err is set
+	elem *list.Element // in lru once fetched
+}
+
+func newChunkCache(budget int64, fetch func(int64) ([]byte, error)) *chunkCache {
+	return &chunkCache{fetch: fetch, budget: budget, entries: make(map[int64]*cacheEntry)}
+}
+
+// get returns chunk num, waiting for it to be fetched.
+func (cc *chunkCache) get(num int64) ([]byte, error) {
+	e := cc.start(num)
+	<-e.done
+	cc.mu.Lock()
+	if e.elem != nil {
+		cc.lru.MoveToFront(e.elem)
+	}
+	cc.mu.Unlock()
+	return e.data, e.err
+}
+
+// start begins fetching chunk num unless it is cached or on its way.
+func (cc *chunkCache) start(num int64) *cacheEntry {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if e, ok := cc.entries[num]; ok {
+		return e
+	}
+	e := &cacheEntry{num: num, done: make(chan struct{})}
+	cc.entries[num] = e
+	go func() {
+		data, err := cc.fetch(num)
+		cc.mu.Lock()
+		e.data, e.err = data, err
+		if err != nil {
+			delete(cc.entries, num) // the next get tries again
+		} else {
+			e.elem = cc.lru.PushFront(e)
+			cc.size += int64(len(data))
+			cc.evict(e)
+		}
+		cc.mu.Unlock()
+		close(e.done)
+	}()
+	return e
+}
+
+// evict drops the least recently used chunks other than keep until the
+// cache fits its budget. A reader still holding an evicted chunk's data
+// keeps it until done with it.
+func (cc *chunkCache) evict(keep *cacheEntry) {
+	for el := cc.lru.Back(); el != nil && cc.size > cc.budget; {
+		e := el.Value.(*cacheEntry)
+		el = el.Prev()
+		if e == keep {
+			continue
+		}
+		cc.lru.Remove(e.elem)
+		delete(cc.entries, e.num)
+		cc.size -= int64(len(e.data))
+	}
+}
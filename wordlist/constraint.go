@@ -0,0 +1,597 @@
+package wordlist
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var ErrBadConstraint = errors.New("wordlist: invalid constraint")
+
+// Constraints are rules on the symbols of a word, by position, that a mask
+// cannot express, written as statements separated by semicolons or
+// newlines:
+//
+//	pos1 in [A-Z]               the first symbol is an upper-case letter
+//	pos2..4 != pos1             the second to fourth differ from the first
+//	all != prev                 no symbol repeats the one before it
+//	last in ?d                  the last is a digit
+//	pos3..last not in [aeiou]   from the third on, no vowel
+//	sum(pos1..last-1) mod 10 == last
+//	                            the last digit is a check digit
+//	luhn(all)                   the digits pass the Luhn check
+//	mod97(pos3..last) == 1      a number, letters as 10 to 35, is 1 mod 97
+//	iban(all)                   the word is an IBAN
+//
+// Positions count from 1: posN, first (pos1), last and last-N; a range
+// runs from one to another, with a bare number after .. meaning posN. A
+// class is a bracket expression, such as [a-z0-9_] or [^aeiou], or one of
+// hashcat's ?l ?u ?d ?s ?a. A comparison is with another position, or with
+// prev, the position before each one. sum adds up digit symbols, taken mod
+// 10 unless told otherwise, and compares with a number or a position's
+// digit; a word whose sum reaches a symbol that is not a digit fails it.
+// luhn and mod97 check digits the same way, mod97 reading A to Z, in
+// either case, as 10 to 35, and iban is mod97 == 1 with the first four
+// symbols moved to the end.
+// A statement about a position a word does not have says nothing about
+// that word, so statements about pos8 leave shorter words alone.
+//
+// A keyspace applies them through an Exclusion, which skips the words that
+// break one a block at a time: when a statement fails on the symbols up to
+// some position, every word sharing them fails it too.
+type Constraints struct {
+	src   string
+	stmts []statement
+}
+
+// ParseConstraints parses constraints in the language above.
+func ParseConstraints(src string) (*Constraints, error) {
+	c := &Constraints{src: strings.TrimSpace(src)}
+	for _, line := range strings.FieldsFunc(src, func(r rune) bool { return r == ';' || r == '\n' }) {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		st, err := parseStatement(line)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrBadConstraint, line, err)
+		}
+		c.stmts = append(c.stmts, st)
+	}
+	if len(c.stmts) == 0 {
+		return nil, fmt.Errorf("%w: no statements in %q", ErrBadConstraint, src)
+	}
+	return c, nil
+}
+
+func (c *Constraints) String() string { return c.src }
+
+// posRef is a position as written: posN counts from the start, last-N
+// from the end.
+type posRef struct {
+	fromEnd bool
+	n       int
+}
+
+// at is the position, counting from 0, in a word of l symbols, and false
+// when the word does not have it.
+func (p posRef) at(l int) (int, bool) {
+	i := p.n - 1
+	if p.fromEnd {
+		i = l - 1 - p.n
+	}
+	return i, i >= 0 && i < l
+}
+
+// Statement kinds.
+const (
+	stmtIn    = iota // every position of the range is in the class
+	stmtCmp          // every position of the range equals, or not, another
+	stmtPrev         // every position of the range equals, or not, the one before
+	stmtSum          // the sum of the range's digits mod m equals, or not, a value
+	stmtLuhn         // the range's digits pass the Luhn check
+	stmtMod97        // the range, letters as 10 to 35, mod 97 equals, or not, a value
+	stmtIBAN         // the range, its first four symbols moved last, is 1 mod 97
+)
+
+type statement struct {
+	kind     int
+	from, to posRef
+	class    symbolClass // stmtIn
+	equal    bool        // == rather than !=
+	other    posRef      // stmtCmp, and stmtSum and stmtMod97 when byPos
+	mod      int         // stmtSum
+	value    int         // stmtSum and stmtMod97 unless byPos
+	byPos    bool        // stmtSum and stmtMod97: compared with other's value
+}
+
+// parseStatement parses one statement: a subject, a relation and what it
+// relates to.
+func parseStatement(s string) (statement, error) {
+	p := &constraintParser{s: s}
+	var st statement
+	switch {
+	case p.keyword("sum"):
+		if err := p.parseSum(&st); err != nil {
+			return st, err
+		}
+		if err := p.parseSumRelation(&st); err != nil {
+			return st, err
+		}
+		return st, p.end()
+	case p.keyword("mod97"):
+		st.kind = stmtMod97
+		if err := p.parseArgs(&st); err != nil {
+			return st, err
+		}
+		if err := p.parseSumRelation(&st); err != nil {
+			return st, err
+		}
+		return st, p.end()
+	case p.keyword("luhn"), p.keyword("iban"):
+		st.kind, st.equal = stmtLuhn, true
+		if p.last == "iban" {
+			st.kind = stmtIBAN
+		}
+		if err := p.parseArgs(&st); err != nil {
+			return st, err
+		}
+		return st, p.end()
+	}
+	if err := p.parseRange(&st); err != nil {
+		return st, err
+	}
+	switch {
+	case p.keyword("not"):
+		if !p.keyword("in") {
+			return st, errors.New("want in after not")
+		}
+		class, err := p.parseClass()
+		if err != nil {
+			return st, err
+		}
+		class.negate = !class.negate
+		st.kind, st.class = stmtIn, class
+	case p.keyword("in"):
+		st.kind = stmtIn
+		class, err := p.parseClass()
+		if err != nil {
+			return st, err
+		}
+		st.class = class
+	case p.op("=="), p.op("="), p.op("!="):
+		st.equal = p.last != "!="
+		switch {
+		case p.keyword("prev"):
+			st.kind = stmtPrev
+		case p.keyword("sum"):
+			// posN == sum(...): the same as sum(...) == posN.
+			if st.from != st.to {
+				return st, errors.New("a sum compares with a single position")
+			}
+			other, equal := st.from, st.equal
+			if err := p.parseSum(&st); err != nil {
+				return st, err
+			}
+			st.other, st.equal, st.byPos = other, equal, true
+		default:
+			other, err := p.parseRef()
+			if err != nil {
+				return st, err
+			}
+			st.kind, st.other = stmtCmp, other
+		}
+	default:
+		return st, errors.New("want in, not in, == or != after the positions")
+	}
+	return st, p.end()
+}
+
+// parseArgs parses the (range) after a function's name.
+func (p *constraintParser) parseArgs(st *statement) error {
+	name := p.last
+	if !p.op("(") {
+		return fmt.Errorf("want ( after %s", name)
+	}
+	if err := p.parseRange(st); err != nil {
+		return err
+	}
+	if !p.op(")") {
+		return fmt.Errorf("want ) after the positions of %s", name)
+	}
+	return nil
+}
+
+// parseSum parses the (range) after sum and an optional mod m.
+func (p *constraintParser) parseSum(st *statement) error {
+	if err := p.parseArgs(st); err != nil {
+		return err
+	}
+	st.kind, st.mod = stmtSum, 10
+	if p.keyword("mod") {
+		m, err := p.number()
+		if err != nil || m < 1 {
+			return errors.New("want a positive number after mod")
+		}
+		st.mod = m
+	}
+	return nil
+}
+
+// parseSumRelation parses what a sum or mod97 is compared with: a number
+// or a position.
+func (p *constraintParser) parseSumRelation(st *statement) error {
+	switch {
+	case p.op("=="), p.op("="), p.op("!="):
+		st.equal = p.last != "!="
+	default:
+		return errors.New("want == or != after the function")
+	}
+	if n, err := p.number(); err == nil {
+		st.value = n
+		return nil
+	}
+	other, err := p.parseRef()
+	if err != nil {
+		return errors.New("want a number or a position to compare with")
+	}
+	st.other, st.byPos = other, true
+	return nil
+}
+
+// parseRange parses a position, a range of them, or all.
+func (p *constraintParser) parseRange(st *statement) error {
+	if p.keyword("all") {
+		st.from, st.to = posRef{n: 1}, posRef{fromEnd: true}
+		return nil
+	}
+	from, err := p.parseRef()
+	if err != nil {
+		return err
+	}
+	st.from, st.to = from, from
+	if !p.op("..") {
+		return nil
+	}
+	if n, err := p.number(); err == nil {
+		st.to = posRef{n: n}
+	} else if st.to, err = p.parseRef(); err != nil {
+		return err
+	}
+	if st.to.n < 1 && !st.to.fromEnd {
+		return errors.New("positions count from 1")
+	}
+	return nil
+}
+
+// parseRef parses posN, first, last or last-N.
+func (p *constraintParser) parseRef() (posRef, error) {
+	switch {
+	case p.keyword("first"):
+		return posRef{n: 1}, nil
+	case p.keyword("last"):
+		if !p.op("-") {
+			return posRef{fromEnd: true}, nil
+		}
+		n, err := p.number()
+		if err != nil {
+			return posRef{}, errors.New("want a number after last-")
+		}
+		return posRef{fromEnd: true, n: n}, nil
+	case p.keyword("pos"):
+		n, err := p.number()
+		if err != nil || n < 1 {
+			return posRef{}, errors.New("want a position from 1 after pos")
+		}
+		return posRef{n: n}, nil
+	}
+	return posRef{}, fmt.Errorf("want a position (posN, first, last or last-N) at %q", p.rest())
+}
+
+// symbolClass is a set of characters; a symbol is in it when it is one
+// character of the set.
+type symbolClass struct {
+	ranges [][2]rune
+	negate bool
+}
+
+func (c symbolClass) contains(symbol string) bool {
+	r, size := utf8.DecodeRuneInString(symbol)
+	if size == 0 || size != len(symbol) {
+		return c.negate
+	}
+	for _, rg := range c.ranges {
+		if rg[0] <= r && r <= rg[1] {
+			return !c.negate
+		}
+	}
+	return c.negate
+}
+
+// parseClass parses a bracket expression or a hashcat class.
+func (p *constraintParser) parseClass() (symbolClass, error) {
+	p.space()
+	var c symbolClass
+	if strings.HasPrefix(p.s[p.i:], "?") && p.i+1 < len(p.s) {
+		name := p.s[p.i+1]
+		p.i += 2
+		switch name {
+		case 'l':
+			c.ranges = [][2]rune{{'a', 'z'}}
+		case 'u':
+			c.ranges = [][2]rune{{'A', 'Z'}}
+		case 'd':
+			c.ranges = [][2]rune{{'0', '9'}}
+		case 's':
+			c.ranges = [][2]rune{{' ', '/'}, {':', '@'}, {'[', '`'}, {'{', '~'}}
+		case 'a':
+			c.ranges = [][2]rune{{' ', '~'}}
+		default:
+			return c, fmt.Errorf("unknown class ?%c (want ?l, ?u, ?d, ?s or ?a)", name)
+		}
+		return c, nil
+	}
+	if !p.op("[") {
+		return c, errors.New("want a class, [...] or ?l ?u ?d ?s ?a")
+	}
+	if strings.HasPrefix(p.s[p.i:], "^") {
+		c.negate = true
+		p.i++
+	}
+	next := func() (rune, bool) {
+		if p.i >= len(p.s) {
+			return 0, false
+		}
+		r, size := utf8.DecodeRuneInString(p.s[p.i:])
+		p.i += size
+		if r == '\\' && p.i < len(p.s) {
+			r, size = utf8.DecodeRuneInString(p.s[p.i:])
+			p.i += size
+		}
+		return r, true
+	}
+	for {
+		if strings.HasPrefix(p.s[p.i:], "]") && len(c.ranges) > 0 {
+			p.i++
+			return c, nil
+		}
+		lo, ok := next()
+		if !ok {
+			return c, errors.New("unterminated [")
+		}
+		hi := lo
+		if strings.HasPrefix(p.s[p.i:], "-") && !strings.HasPrefix(p.s[p.i:], "-]") {
+			p.i++
+			if hi, ok = next(); !ok || hi < lo {
+				return c, fmt.Errorf("bad range %c-%c", lo, hi)
+			}
+		}
+		c.ranges = append(c.ranges, [2]rune{lo, hi})
+	}
+}
+
+// constraintParser reads a statement from left to right.
+type constraintParser struct {
+	s    string
+	i    int
+	last string // the operator or keyword last matched
+}
+
+func (p *constraintParser) space() {
+	for p.i < len(p.s) && unicode.IsSpace(rune(p.s[p.i])) {
+		p.i++
+	}
+}
+
+func (p *constraintParser) rest() string { return p.s[p.i:] }
+
+// keyword consumes word when it comes next, as a whole word.
+func (p *constraintParser) keyword(word string) bool {
+	p.space()
+	rest := p.s[p.i:]
+	if len(rest) < len(word) || !strings.EqualFold(rest[:len(word)], word) {
+		return false
+	}
+	if len(rest) > len(word) && (isLetter(rest[len(word)])) {
+		return false
+	}
+	p.i += len(word)
+	p.last = strings.ToLower(word)
+	return true
+}
+
+func isLetter(b byte) bool { return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' }
+
+// op consumes the operator op when it comes next.
+func (p *constraintParser) op(op string) bool {
+	p.space()
+	if !strings.HasPrefix(p.s[p.i:], op) {
+		return false
+	}
+	if op == "=" && strings.HasPrefix(p.s[p.i:], "==") {
+		return false
+	}
+	p.i += len(op)
+	p.last = op
+	return true
+}
+
+// number consumes a decimal number, or leaves the input alone.
+func (p *constraintParser) number() (int, error) {
+	p.space()
+	j := p.i
+	for j < len(p.s) && '0' <= p.s[j] && p.s[j] <= '9' {
+		j++
+	}
+	n, err := strconv.Atoi(p.s[p.i:j])
+	if err != nil {
+		return 0, err
+	}
+	p.i = j
+	return n, nil
+}
+
+func (p *constraintParser) end() error {
+	p.space()
+	if p.i < len(p.s) {
+		return fmt.Errorf("unexpected %q", p.s[p.i:])
+	}
+	return nil
+}
+
+// constraintCheck is one test of a statement on the words of a given
+// length: it reads the positions up to at, and no later one.
+type constraintCheck struct {
+	at    int
+	kind  int
+	a, b  int    // the positions compared; b is -1 in a sum or mod97 against value
+	allow []bool // stmtIn: by the digit at a
+	equal bool
+	over  []int   // stmtSum, stmtLuhn and stmtMod97: the positions read, in order
+	mod   int     // stmtSum
+	value int     // stmtSum and stmtMod97, when b is -1
+	of    [][]int // stmtSum, stmtLuhn and stmtMod97: by position, the value of each digit's symbol, -1 for none
+}
+
+// compile turns the statements into the checks on words of l symbols of k.
+// An IBAN becomes a mod97 check reading its positions in the order the
+// standard does.
+func (c *Constraints) compile(k *Keyspace, l int) []constraintCheck {
+	var checks []constraintCheck
+	digits, alnums := make([][]int, l), make([][]int, l)
+	value := func(of [][]int, alnum bool, j int) {
+		if of[j] != nil {
+			return
+		}
+		syms := k.symbolsAt(j)
+		of[j] = make([]int, len(syms))
+		for d, s := range syms {
+			of[j][d] = symbolValue(s, alnum)
+		}
+	}
+	for _, st := range c.stmts {
+		from, _ := st.from.at(l)
+		to, _ := st.to.at(l)
+		from, to = max(from, 0), min(to, l-1)
+		if from > to {
+			continue
+		}
+		switch st.kind {
+		case stmtIn:
+			for j := from; j <= to; j++ {
+				syms := k.symbolsAt(j)
+				allow := make([]bool, len(syms))
+				for d, s := range syms {
+					allow[d] = st.class.contains(s)
+				}
+				checks = append(checks, constraintCheck{at: j, kind: stmtIn, a: j, allow: allow})
+			}
+		case stmtCmp:
+			other, ok := st.other.at(l)
+			if !ok {
+				continue
+			}
+			for j := from; j <= to; j++ {
+				if j != other {
+					checks = append(checks, constraintCheck{at: max(j, other), kind: stmtCmp, a: j, b: other, equal: st.equal})
+				}
+			}
+		case stmtPrev:
+			for j := max(from, 1); j <= to; j++ {
+				checks = append(checks, constraintCheck{at: j, kind: stmtCmp, a: j, b: j - 1, equal: st.equal})
+			}
+		default:
+			ch := constraintCheck{at: to, kind: st.kind, b: -1, equal: st.equal, mod: st.mod, value: st.value, of: digits}
+			if st.kind == stmtMod97 || st.kind == stmtIBAN {
+				ch.kind, ch.of = stmtMod97, alnums
+			}
+			for j := from; j <= to; j++ {
+				ch.over = append(ch.over, j)
+				value(ch.of, ch.kind == stmtMod97, j)
+			}
+			if st.kind == stmtIBAN {
+				if len(ch.over) < 5 {
+					continue
+				}
+				ch.over, ch.value = append(ch.over[4:], ch.over[:4]...), 1
+			}
+			if st.byPos {
+				other, ok := st.other.at(l)
+				if !ok {
+					continue
+				}
+				ch.b, ch.at = other, max(to, other)
+				value(ch.of, ch.kind == stmtMod97, other)
+			}
+			checks = append(checks, ch)
+		}
+	}
+	return checks
+}
+
+// symbolValue is the value of a digit symbol and, with alnum, of a letter,
+// A or a being 10 up to Z at 35; -1 for any other symbol.
+func symbolValue(s string, alnum bool) int {
+	switch {
+	case len(s) != 1:
+	case '0' <= s[0] && s[0] <= '9':
+		return int(s[0] - '0')
+	case alnum && 'A' <= s[0] && s[0] <= 'Z':
+		return int(s[0]-'A') + 10
+	case alnum && 'a' <= s[0] && s[0] <= 'z':
+		return int(s[0]-'a') + 10
+	}
+	return -1
+}
+
+// holds reports whether the word at offset among those of l symbols of k
+// passes the check.
+func (ch *constraintCheck) holds(k *Keyspace, l int, offset int64) bool {
+	at := func(j int) int {
+		return int(offset / k.place(l, j) % int64(len(k.symbolsAt(j))))
+	}
+	switch ch.kind {
+	case stmtIn:
+		return ch.allow[at(ch.a)]
+	case stmtCmp:
+		return (k.symbolsAt(ch.a)[at(ch.a)] == k.symbolsAt(ch.b)[at(ch.b)]) == ch.equal
+	}
+	got := 0
+	for i, j := range ch.over {
+		v := ch.of[j][at(j)]
+		switch {
+		case v < 0:
+			return false
+		case ch.kind == stmtSum:
+			got += v
+		case ch.kind == stmtLuhn:
+			// Every second digit left of the check digit counts double,
+			// less 9 when that takes it past 9.
+			if (len(ch.over)-1-i)%2 == 1 {
+				if v *= 2; v > 9 {
+					v -= 9
+				}
+			}
+			got += v
+		case v > 9: // stmtMod97: a letter reads as its two digits
+			got = (got*100 + v) % 97
+		default:
+			got = (got*10 + v) % 97
+		}
+	}
+	want := ch.value
+	switch ch.kind {
+	case stmtSum:
+		got %= ch.mod
+	case stmtLuhn:
+		got, want = got%10, 0
+	}
+	if ch.b >= 0 {
+		if want = ch.of[ch.b][at(ch.b)]; want < 0 {
+			return false
+		}
+	}
+	return (got == want) == ch.equal
+}
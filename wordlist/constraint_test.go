@@ -0,0 +1,199 @@
+package wordlist
+
+import (
+	"errors"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParseConstraintsErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"# only a comment",
+		"pos0 in [a]",
+		"pos1",
+		"pos1 in",
+		"pos1 in [ab",
+		"pos1 not [a]",
+		"pos1 ==",
+		"pos1 in [a] and more",
+		"pos1..0 in [a]",
+		"last- in [a]",
+		"pos1..2 == sum(all)",
+		"sum(all) mod 0 == 1",
+		"sum(all)",
+		"sum all == 1",
+		"luhn(all",
+		"mod97(all)",
+		"frobnicate(all)",
+	} {
+		if _, err := ParseConstraints(src); !errors.Is(err, ErrBadConstraint) {
+			t.Errorf("ParseConstraints(%q) = %v, want ErrBadConstraint", src, err)
+		}
+	}
+}
+
+// digitsOf returns the values of the symbols of word, -1 for one that is
+// not a digit or, with alnum, a letter.
+func digitsOf(word string, alnum bool) []int {
+	values := make([]int, len(word))
+	for i := range word {
+		values[i] = -1
+		switch c := word[i]; {
+		case '0' <= c && c <= '9':
+			values[i] = int(c - '0')
+		case alnum && 'a' <= c && c <= 'z':
+			values[i] = int(c-'a') + 10
+		case alnum && 'A' <= c && c <= 'Z':
+			values[i] = int(c-'A') + 10
+		}
+	}
+	return values
+}
+
+// sumOf adds up the digits of word, or returns false when one is not a digit.
+func sumOf(word string) (int, bool) {
+	sum := 0
+	for _, v := range digitsOf(word, false) {
+		if v < 0 {
+			return 0, false
+		}
+		sum += v
+	}
+	return sum, true
+}
+
+// luhn reports whether word is digits that pass the Luhn check.
+func luhn(word string) bool {
+	sum := 0
+	for i, v := range digitsOf(word, false) {
+		if v < 0 {
+			return false
+		}
+		if (len(word)-1-i)%2 == 1 {
+			if v *= 2; v > 9 {
+				v -= 9
+			}
+		}
+		sum += v
+	}
+	return sum%10 == 0
+}
+
+// mod97 returns word, letters read as 10 to 35, mod 97, or false when a
+// symbol is neither digit nor letter.
+func mod97(word string) (int, bool) {
+	var b strings.Builder
+	for _, v := range digitsOf(word, true) {
+		if v < 0 {
+			return 0, false
+		}
+		b.WriteString(strconv.Itoa(v))
+	}
+	n, _ := new(big.Int).SetString(b.String(), 10)
+	return int(new(big.Int).Mod(n, big.NewInt(97)).Int64()), true
+}
+
+// noRepeats reports whether no symbol of word is the one before it again.
+func noRepeats(word string) bool {
+	for i := 1; i < len(word); i++ {
+		if word[i] == word[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestConstraintsMatchFilter checks each statement form, excluding the
+// words that break it, against a filter written out by hand.
+func TestConstraintsMatchFilter(t *testing.T) {
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	for _, c := range []struct {
+		src    string
+		passes func(w string) bool
+	}{
+		{"pos1 in [a-z]", func(w string) bool { return 'a' <= w[0] && w[0] <= 'z' }},
+		{"pos1 not in [^a0]", func(w string) bool { return w[0] == 'a' || w[0] == '0' }},
+		{"last in ?d", func(w string) bool { return isDigit(w[len(w)-1]) }},
+		{"pos3..last not in [0-2]", func(w string) bool {
+			return len(w) < 3 || !strings.ContainsAny(w[2:], "012")
+		}},
+		{"last-2..last in [9Z]", func(w string) bool {
+			return strings.Trim(w[max(len(w)-3, 0):], "9Z") == ""
+		}},
+		{"pos6 in [a]", func(string) bool { return true }},
+		{"pos2..4 != pos1", func(w string) bool {
+			return !strings.ContainsRune(w[min(len(w), 1):min(len(w), 4)], rune(w[0]))
+		}},
+		{"pos1..2 == last", func(w string) bool {
+			return len(w) == 1 || w[0] == w[len(w)-1] && (len(w) == 2 || w[1] == w[len(w)-1])
+		}},
+		{"last-1 == first", func(w string) bool { return len(w) < 2 || w[len(w)-2] == w[0] }},
+		{"all != prev", noRepeats},
+		{"all == prev", func(w string) bool { return strings.Count(w, w[:1]) == len(w) }},
+		{"sum(pos1..last-1) mod 10 == last", func(w string) bool {
+			if len(w) < 2 {
+				return true
+			}
+			sum, ok := sumOf(w[:len(w)-1])
+			return ok && isDigit(w[len(w)-1]) && sum%10 == int(w[len(w)-1]-'0')
+		}},
+		{"sum(all) mod 3 != 0", func(w string) bool {
+			sum, ok := sumOf(w)
+			return ok && sum%3 != 0
+		}},
+		{"sum(all) == 5", func(w string) bool {
+			sum, ok := sumOf(w)
+			return ok && sum%10 == 5
+		}},
+		{"pos1 == sum(pos2..last) mod 7", func(w string) bool {
+			if len(w) < 2 {
+				return true
+			}
+			sum, ok := sumOf(w[1:])
+			return ok && isDigit(w[0]) && sum%7 == int(w[0]-'0')
+		}},
+		{"luhn(all)", luhn},
+		{"luhn(pos2..last)", func(w string) bool { return len(w) < 2 || luhn(w[1:]) }},
+		{"mod97(all) == 1", func(w string) bool {
+			m, ok := mod97(w)
+			return ok && m == 1
+		}},
+		{"mod97(pos2..last) != pos1", func(w string) bool {
+			if len(w) < 2 {
+				return true
+			}
+			m, ok := mod97(w[1:])
+			first := digitsOf(w[:1], true)[0]
+			return ok && first >= 0 && m != first
+		}},
+		{"iban(all)", func(w string) bool {
+			if len(w) < 5 {
+				return true
+			}
+			m, ok := mod97(w[4:] + w[:4])
+			return ok && m == 1
+		}},
+		{"pos1 in ?l; last in ?d\n# comment\nall != prev", func(w string) bool {
+			return 'a' <= w[0] && w[0] <= 'z' && isDigit(w[len(w)-1]) && noRepeats(w)
+		}},
+	} {
+		t.Run(c.src, func(t *testing.T) {
+			k, err := NewKeyspace(Runes("0129aZ"), 1, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cs, err := ParseConstraints(c.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			e := NewExclusion(k)
+			if err := e.AddConstraints(cs); err != nil {
+				t.Fatal(err)
+			}
+			checkExclusion(t, k.Without(e), func(w string) bool { return !c.passes(w) })
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package wordlist
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	ErrBadManifest  = errors.New("wordlist: malformed manifest")
+	ErrNotPublished = errors.New("wordlist: chunk is not in the manifest")
+	ErrBadChunk     = errors.New("wordlist: chunk does not hold the words it should")
+)
+
+// chunkNumber finds the number in a chunk's file name, such as
+// combos_000042.txt.zst.
+var chunkNumber = regexp.MustCompile(`(\d+)\.txt(\.gz|\.zst)?$`)
+
+// Corpus is a published run read back as one stream of words: the chunks its
+// manifest lists, fetched from a Source and decompressed as the stream
+// reaches them, so a consumer can read or seek through the whole keyspace
+// with only one chunk in flight and nothing written to disk:
+//
+//	src, err := wordlist.NewSource("https://bucket.example.com/run")
+//	c, err := wordlist.OpenCorpus(ctx, src, "CHECKSUMS", ks, 2_000_000)
+//	err = c.SeekIndex(1_000_000_000)
+//	_, err = io.Copy(hashcat, c)
+//
+// Chunk N holds the words at indices [(N-1)·perFile, N·perFile) of ks,
+// without the ones ks excludes (see Without). A plain chunk is entered at
+// the byte the wanted word starts, with a range request for a URL; a
+// compressed one is decompressed from its start. A chunk read from its
+// start is checked against the manifest's XXH64 when its end is reached.
+// Prefetch fetches chunks ahead of the stream instead.
+//
+// After an error other than io.EOF the stream stays at the word it stopped
+// in, so calling Read or Next again retries from there.
+type Corpus struct {
+	ctx       context.Context // every fetch is made under
+	src       Source
+	ks        *Keyspace
+	perFile   int64
+	chunks    map[int64]corpusChunk // by chunk number
+	published Ranges
+	pos       int64        // one past the last word read in full
+	mid       int64        // bytes of the word at pos that Read returned
+	cur       *chunkStream // the chunk holding pos; nil until it is needed
+
+	cache   *chunkCache // whole chunks in memory; nil to stream them (see Prefetch)
+	ahead   int         // the most chunks to prefetch
+	window  int         // chunks prefetched now, growing while reads are in order
+	lastNum int64       // the chunk opened before
+}
+
+type corpusChunk struct {
+	name  string // in the manifest
+	xxh64 string // hex, or "" when the manifest has none
+}
+
+// OpenCorpus reads the manifest of a run over ks, which lists its chunks in
+// any version ReadManifest reads, from src. perFile is the number of
+// words per chunk the run was made with. Any file whose name ends in a
+// number and .txt is taken for a chunk; OpenCorpusLayout reads a run whose
+// chunks are named otherwise. The corpus fetches its chunks under ctx:
+// once ctx is cancelled, reads fail with its error.
+func OpenCorpus(ctx context.Context, src Source, manifest string, ks *Keyspace, perFile int64) (*Corpus, error) {
+	return openCorpus(ctx, src, manifest, ks, perFile, func(name string) (int64, bool) {
+		m := chunkNumber.FindStringSubmatch(path.Base(name))
+		if m == nil {
+			return 0, false
+		}
+		num, err := strconv.ParseInt(m[1], 10, 64)
+		return num, err == nil
+	})
+}
+
+// OpenCorpusLayout is OpenCorpus for a run whose chunks are named by layout.
+func OpenCorpusLayout(ctx context.Context, src Source, manifest string, ks *Keyspace, perFile int64, layout Layout) (*Corpus, error) {
+	return openCorpus(ctx, src, manifest, ks, perFile, layout.Number)
+}
+
+func openCorpus(ctx context.Context, src Source, manifest string, ks *Keyspace, perFile int64, number func(name string) (int64, bool)) (*Corpus, error) {
+	if perFile <= 0 {
+		return nil, fmt.Errorf("%w: %d words per chunk", ErrOutOfRange, perFile)
+	}
+	r, err := src.Open(ctx, manifest, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	entries, _, err := ReadManifest(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", manifest, err)
+	}
+	c := &Corpus{ctx: ctx, src: src, ks: ks, perFile: perFile, chunks: make(map[int64]corpusChunk)}
+	for _, name := range slices.Sorted(maps.Keys(entries)) {
+		num, ok := number(name)
+		if !ok {
+			continue // not a chunk
+		}
+		if num < 1 || num > (ks.Total()+perFile-1)/perFile {
+			return nil, fmt.Errorf("%w: %s: %s is outside the keyspace", ErrBadManifest, manifest, name)
+		}
+		if _, ok := c.chunks[num]; ok {
+			continue // a copy published by another shard
+		}
+		c.chunks[num] = corpusChunk{name: name, xxh64: entries[name].XXH64}
+		c.published.Add((num-1)*perFile, min(num*perFile, ks.Total()))
+	}
+	return c, nil
+}
+
+// Published returns the index ranges of the chunks in the manifest. Reading
+// into any other index fails with ErrNotPublished; SeekIndex past it.
+func (c *Corpus) Published() *Ranges { return &c.published }
+
+// Pos is the index the next word is at, or the first index it may be at when
+// the keyspace excludes some.
+func (c *Corpus) Pos() int64 { return c.pos }
+
+// SeekIndex moves the stream to the word at index; the keyspace's Total
+// moves it to the end. A seek forward within the chunk being read skips
+// words; any other seek fetches the chunk again when it is first read.
+func (c *Corpus) SeekIndex(index int64) error {
+	if index < 0 || index > c.ks.Total() {
+		return fmt.Errorf("%w: %d not in [0, %d]", ErrOutOfRange, index, c.ks.Total())
+	}
+	if s := c.cur; s != nil && c.mid == 0 && index >= c.pos && c.ks.NextIncluded(index) < s.end {
+		if err := c.skip(s, c.pos, index); err != nil {
+			return c.drop(err)
+		}
+		c.pos = index
+		return nil
+	}
+	c.Close()
+	c.pos, c.mid = index, 0
+	return nil
+}
+
+// Next returns the next word, without its newline, or io.EOF after the last
+// one. The word is only valid until the next call. After a Read that stopped
+// inside a word, Next returns the rest of it.
+func (c *Corpus) Next() ([]byte, error) {
+	s, err := c.chunk()
+	if err != nil {
+		return nil, err
+	}
+	line, err := s.r.ReadSlice('\n')
+	if err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("%w: it ends inside the words", ErrBadChunk)
+		}
+		return nil, c.drop(err)
+	}
+	c.pos, c.mid = c.ks.NextIncluded(c.pos)+1, 0
+	return line[:len(line)-1], nil
+}
+
+// Read reads the words from Pos on as one newline-terminated stream, as if
+// every chunk were a single decompressed file.
+func (c *Corpus) Read(p []byte) (int, error) {
+	s, err := c.chunk()
+	if err != nil {
+		return 0, err
+	}
+	n, err := s.r.Read(p)
+	for rest := p[:n]; ; {
+		i := bytes.IndexByte(rest, '\n')
+		if i < 0 {
+			c.mid += int64(len(rest))
+			break
+		}
+		c.pos, c.mid = c.ks.NextIncluded(c.pos)+1, 0
+		rest = rest[i+1:]
+	}
+	switch {
+	case c.pos > s.end:
+		return n, c.drop(fmt.Errorf("%w: it holds more words than that", ErrBadChunk))
+	case n > 0:
+		return n, nil
+	case err == io.EOF:
+		err = fmt.Errorf("%w: it ends inside the words", ErrBadChunk)
+	}
+	return 0, c.drop(err)
+}
+
+// Close releases the chunk being read. The stream stays where it is and can
+// still be read from.
+func (c *Corpus) Close() error {
+	if c.cur == nil {
+		return nil
+	}
+	err := c.cur.close()
+	c.cur = nil
+	return err
+}
+
+// chunk returns the stream positioned at the next word, finishing the chunk
+// before it and opening its own as needed, or io.EOF at the end.
+func (c *Corpus) chunk() (*chunkStream, error) {
+	i := c.ks.NextIncluded(c.pos)
+	if c.cur != nil && i < c.cur.end {
+		return c.cur, nil
+	}
+	if c.cur != nil {
+		if err := c.cur.finish(); err != nil {
+			return nil, c.drop(err)
+		}
+		c.Close()
+	}
+	if i >= c.ks.Total() {
+		return nil, io.EOF
+	}
+	num := i/c.perFile + 1
+	ch, ok := c.chunks[num]
+	if !ok {
+		return nil, fmt.Errorf("%w: chunk %d, holding index %d", ErrNotPublished, num, i)
+	}
+	start, end := (num-1)*c.perFile, min(num*c.perFile, c.ks.Total())
+	var offset int64
+	if !compressed(ch.name) && c.ks.sequential() {
+		offset, start = c.ks.Bytes(start, i)+c.mid, i
+	}
+	var s *chunkStream
+	var err error
+	if c.cache != nil {
+		c.prefetch(num)
+		var data []byte
+		if data, err = c.cache.get(num); err == nil {
+			// Checked on arrival, so not again.
+			body := io.NopCloser(bytes.NewReader(data[min(offset, int64(len(data))):]))
+			s, err = newChunkStream(corpusChunk{name: ch.name}, body, end)
+		}
+	} else if body, oerr := c.src.Open(c.ctx, ch.name, offset); oerr != nil {
+		err = oerr
+	} else {
+		if offset > 0 {
+			ch.xxh64 = "" // only a chunk read whole is checked
+		}
+		s, err = newChunkStream(ch, body, end)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ch.name, err)
+	}
+	c.cur = s
+	if err := c.skip(s, start, i); err != nil {
+		return nil, c.drop(err)
+	}
+	if offset == 0 && c.mid > 0 {
+		if _, err := io.CopyN(io.Discard, s.r, c.mid); err != nil {
+			return nil, c.drop(err)
+		}
+	}
+	return s, nil
+}
+
+// skip reads past the words from index from, whose word s is at, to index
+// to.
+func (c *Corpus) skip(s *chunkStream, from, to int64) error {
+	if c.ks.sequential() {
+		_, err := io.CopyN(io.Discard, s.r, c.ks.Bytes(from, to))
+		return err
+	}
+	for p := c.ks.NextIncluded(from); p < to; p = c.ks.NextIncluded(p + 1) {
+		if _, err := s.r.ReadSlice('\n'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drop closes the chunk being read after err, so the next read fetches it
+// again, and returns err naming the chunk.
+func (c *Corpus) drop(err error) error {
+	name := c.cur.name
+	c.Close()
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = fmt.Errorf("%w: it ends early", ErrBadChunk)
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// chunkStream is one chunk as it is fetched and decompressed.
+type chunkStream struct {
+	name string
+	end  int64         // one past the chunk's last index
+	body io.ReadCloser // the file as fetched
+	dec  io.Closer     // the decompressor reading body, or nil
+	raw  io.Reader     // body, through sum when there is one
+	r    *bufio.Reader // the words
+	sum  *xxhash.Digest
+	want string // the XXH64 sum should have
+}
+
+// newChunkStream reads the words of ch from body, checking body against
+// the XXH64 of ch at the end when it has one.
+func newChunkStream(ch corpusChunk, body io.ReadCloser, end int64) (*chunkStream, error) {
+	s := &chunkStream{name: ch.name, end: end, body: body, raw: body, want: ch.xxh64}
+	if ch.xxh64 != "" {
+		s.sum = xxhash.New()
+		s.raw = io.TeeReader(body, s.sum)
+	}
+	words := s.raw
+	switch {
+	case strings.HasSuffix(ch.name, ".gz"):
+		zr, err := gzip.NewReader(s.raw)
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		words, s.dec = zr, zr
+	case strings.HasSuffix(ch.name, ".zst"):
+		zr, err := zstd.NewReader(s.raw, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			body.Close()
+			return nil, err
+		}
+		words, s.dec = zr, zr.IOReadCloser()
+	}
+	s.r = bufio.NewReaderSize(words, 1<<16)
+	return s, nil
+}
+
+// finish checks that nothing follows the chunk's last word and, when it was
+// read whole, that it matches its checksum.
+func (s *chunkStream) finish() error {
+	if _, err := s.r.ReadByte(); err != io.EOF {
+		if err == nil {
+			return fmt.Errorf("%w: it holds more words than that", ErrBadChunk)
+		}
+		return err
+	}
+	if s.sum == nil {
+		return nil
+	}
+	if _, err := io.Copy(io.Discard, s.raw); err != nil {
+		return err
+	}
+	if got := fmt.Sprintf("%016x", s.sum.Sum64()); got != s.want {
+		return fmt.Errorf("%w: its XXH64 is %s, the manifest has %s", ErrBadChunk, got, s.want)
+	}
+	return nil
+}
+
+func (s *chunkStream) close() error {
+	if s.dec != nil {
+		s.dec.Close()
+	}
+	return s.body.Close()
+}
+
+func compressed(name string) bool {
+	return strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".zst")
+}
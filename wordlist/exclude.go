@@ -0,0 +1,195 @@
+package wordlist
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Exclusion is a set of words of a keyspace to skip, given as index ranges,
+// as masks and as the words that break constraints. Iterators of a keyspace returned by Without jump over them
+// arithmetically, a run of excluded words at a time, rather than generating
+// each one to filter it out.
+type Exclusion struct {
+	ks     *Keyspace
+	ranges Ranges
+	masks  [][][]bool          // masks[m][position][digit]: the digit is excluded there
+	checks [][]constraintCheck // by length: what a word must pass
+}
+
+// NewExclusion returns an empty exclusion for the words of k.
+func NewExclusion(k *Keyspace) *Exclusion {
+	ordered := *k
+	ordered.perm = nil
+	return &Exclusion{ks: &ordered}
+}
+
+// AddRange excludes the words at indices [start, end) in keyspace order,
+// even of a shuffled keyspace.
+func (e *Exclusion) AddRange(start, end int64) {
+	e.ranges.Add(max(start, 0), min(end, e.ks.Total()))
+}
+
+// AddMask excludes the words that have, at every position, one of the
+// characters of the set for that position: {"0123456789", "0123456789"}
+// excludes every two-digit word. Symbols of more than one byte never match.
+func (e *Exclusion) AddMask(sets []string) error {
+	if len(sets) < e.ks.minLen || len(sets) > e.ks.maxLen {
+		return fmt.Errorf("%w: a mask of %d positions, the keyspace has lengths %d-%d", ErrBadLength, len(sets), e.ks.minLen, e.ks.maxLen)
+	}
+	mask := make([][]bool, len(sets))
+	for i, set := range sets {
+		symbols := e.ks.symbolsAt(i)
+		mask[i] = make([]bool, len(symbols))
+		for d, s := range symbols {
+			mask[i][d] = len(s) == 1 && strings.IndexByte(set, s[0]) >= 0
+		}
+	}
+	e.masks = append(e.masks, mask)
+	return nil
+}
+
+// AddConstraints excludes the words that break c. Hybrid and combinator
+// keyspaces take whole words at some positions, which constraints do not
+// speak of, so they refuse them.
+func (e *Exclusion) AddConstraints(c *Constraints) error {
+	if len(e.ks.dicts) > 0 {
+		return fmt.Errorf("%w: constraints on a keyspace of whole words", ErrBadConstraint)
+	}
+	if e.checks == nil {
+		e.checks = make([][]constraintCheck, e.ks.maxLen+1)
+	}
+	for l := e.ks.minLen; l <= e.ks.maxLen; l++ {
+		e.checks[l] = append(e.checks[l], c.compile(e.ks, l)...)
+	}
+	return nil
+}
+
+// Excluded reports whether the word at index is excluded.
+func (e *Exclusion) Excluded(index int64) bool {
+	return e.ranges.Contains(index) || e.maskRun(index) > 0 || e.constraintRun(index) > 0
+}
+
+// Next returns the first index from index on that is not excluded, or
+// Total when there is none.
+func (e *Exclusion) Next(index int64) int64 {
+	total := e.ks.Total()
+	for index < total {
+		if s, ok := e.ranges.Find(index); ok {
+			index = s.End
+			continue
+		}
+		run := max(e.maskRun(index), e.constraintRun(index))
+		if run == 0 {
+			return index
+		}
+		index += run
+	}
+	return total
+}
+
+// maskRun returns how many consecutive words from index on one excluded
+// mask matches, or 0 when none matches the word at index.
+//
+// Past the last position whose set is not every symbol, a matching word's
+// suffix is free, so the whole block sharing its prefix matches; and so
+// does the next block, for as long as the digit at that position stays in
+// its set. Longer runs that carry into earlier positions are found by
+// calling again.
+func (e *Exclusion) maskRun(index int64) int64 {
+	l := e.ks.LengthAt(index)
+	offset := index - e.ks.cum[l-1]
+	var best int64
+	for _, mask := range e.masks {
+		if len(mask) != l || !e.matches(mask, offset) {
+			continue
+		}
+		k := l - 1
+		for k >= 0 && !slices.Contains(mask[k], false) {
+			k--
+		}
+		if k < 0 { // every word of this length
+			return e.ks.cum[l] - index
+		}
+		n := int64(len(mask[k]))
+		block := e.ks.place(l, k)
+		run := block - offset%block
+		for d := offset/block%n + 1; d < n && mask[k][d]; d++ {
+			run += block
+		}
+		best = max(best, run)
+	}
+	return best
+}
+
+// constraintRun returns how many consecutive words from index on break a
+// constraint, or 0 when the word at index passes them all. A check that
+// fails on the symbols up to some position fails for every word sharing
+// them, so the run is the rest of that block; the earliest such position
+// gives the longest. The blocks after it at that position are skipped too
+// while the check still fails on them, so a check digit costs one step
+// rather than one per symbol it rules out.
+func (e *Exclusion) constraintRun(index int64) int64 {
+	if e.checks == nil {
+		return 0
+	}
+	l := e.ks.LengthAt(index)
+	offset := index - e.ks.cum[l-1]
+	var failed *constraintCheck
+	for i := range e.checks[l] {
+		ch := &e.checks[l][i]
+		if (failed == nil || ch.at < failed.at) && !ch.holds(e.ks, l, offset) {
+			failed = ch
+		}
+	}
+	if failed == nil {
+		return 0
+	}
+	block := e.ks.place(l, failed.at)
+	run := block - offset%block
+	next := offset - offset%block + block
+	for d := offset / block % int64(len(e.ks.symbolsAt(failed.at))); d+1 < int64(len(e.ks.symbolsAt(failed.at))) && !failed.holds(e.ks, l, next); d++ {
+		run += block
+		next += block
+	}
+	return run
+}
+
+func (e *Exclusion) matches(mask [][]bool, offset int64) bool {
+	for j := range mask {
+		if !mask[j][offset/e.ks.place(len(mask), j)%int64(len(mask[j]))] {
+			return false
+		}
+	}
+	return true
+}
+
+// Without returns a copy of k whose iterators skip the words e excludes.
+// Indices are unchanged: WordAt, IndexOf and Total still count every word,
+// so chunk boundaries and positions stay where they were.
+func (k *Keyspace) Without(e *Exclusion) *Keyspace {
+	c := *k
+	c.exclude = e
+	return &c
+}
+
+// Excluded reports whether the iterators of k skip the word at index.
+func (k *Keyspace) Excluded(index int64) bool {
+	return k.exclude != nil && k.exclude.Excluded(k.ordered(index))
+}
+
+// NextIncluded returns the first index from index on whose word the
+// iterators of k do not skip, or Total when there is none.
+func (k *Keyspace) NextIncluded(index int64) int64 {
+	if k.exclude == nil {
+		return min(index, k.Total())
+	}
+	if k.perm != nil {
+		// Shuffled, the excluded words are no longer runs of indices.
+		for index < k.Total() && k.exclude.Excluded(k.perm.At(index)) {
+			index++
+		}
+		return min(index, k.Total())
+	}
+	return k.exclude.Next(index)
+}
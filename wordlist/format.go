@@ -0,0 +1,150 @@
+package wordlist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Every file a run leaves behind has a format with a semantic version,
+// major.minor. A new minor version only adds what an older reader can
+// ignore, so a reader takes every minor version of the majors it knows. A
+// new major version changes what is there: a reader migrates an older major
+// to its own when it loads the file, and refuses a newer one with
+// ErrFormatVersion rather than misread it. Published repositories outlive
+// the program that started them, and this is what lets a newer program pick
+// them up, or an older one say why it cannot.
+
+var ErrFormatVersion = errors.New("wordlist: unsupported format version")
+
+// Version is a format's semantic version.
+type Version struct {
+	Major, Minor int
+}
+
+// ParseVersion parses a version written as major.minor.
+func ParseVersion(s string) (Version, error) {
+	major, minor, ok := strings.Cut(s, ".")
+	var v Version
+	var err1, err2 error
+	v.Major, err1 = strconv.Atoi(major)
+	v.Minor, err2 = strconv.Atoi(minor)
+	if !ok || err1 != nil || err2 != nil || v.Major < 0 || v.Minor < 0 {
+		return Version{}, fmt.Errorf("%w: %q is not major.minor", ErrFormatVersion, s)
+	}
+	return v, nil
+}
+
+func (v Version) String() string { return fmt.Sprintf("%d.%d", v.Major, v.Minor) }
+
+// Less reports whether v is older than w.
+func (v Version) Less(w Version) bool {
+	return v.Major < w.Major || v.Major == w.Major && v.Minor < w.Minor
+}
+
+// Format is one of the formats of a run's files, with the versions this
+// package handles: it writes Current and reads everything from Oldest on.
+type Format struct {
+	Name    string
+	Current Version
+	Oldest  Version
+}
+
+// The formats of a run's files. A chunk is newline-terminated words, plain
+// or compressed as its name ends in .gz or .zst. The manifest is version 2
+// in BSD tagged lines, SHA-256 and XXH64, as CHECKSUMS; version 1 was the
+// SHA-256 lines of sha256sum, as SHA256SUMS. The state is version 2 in
+// JSON; version 1 was lines of text, the position first.
+var (
+	ChunkFormat    = Format{Name: "chunk", Current: Version{1, 0}, Oldest: Version{1, 0}}
+	ManifestFormat = Format{Name: "manifest", Current: Version{2, 0}, Oldest: Version{1, 0}}
+	StateFormat    = Format{Name: "state", Current: Version{2, 0}, Oldest: Version{1, 0}}
+)
+
+// Formats lists the formats this package reads and writes: what a program
+// built on it can take from a repository, and what it leaves there.
+func Formats() []Format { return []Format{ChunkFormat, ManifestFormat, StateFormat} }
+
+// Reads reports whether f reads files of version v, migrating them when
+// they are older than Current.
+func (f Format) Reads(v Version) bool {
+	return !v.Less(f.Oldest) && v.Major <= f.Current.Major
+}
+
+// Migrates reports whether a file of version v is migrated to Current when
+// it is loaded.
+func (f Format) Migrates(v Version) bool { return v.Major < f.Current.Major && f.Reads(v) }
+
+// Negotiate returns the version to write into a repository whose files are
+// in version have: Current, once the older ones are migrated, for any
+// version f reads. A newer major version fails with ErrFormatVersion, since
+// writing beside it would leave the repository half in each.
+func (f Format) Negotiate(have Version) (Version, error) {
+	if !f.Reads(have) {
+		return Version{}, fmt.Errorf("%w: %s format %s, and this program reads %s to %d.x",
+			ErrFormatVersion, f.Name, have, f.Oldest, f.Current.Major)
+	}
+	return f.Current, nil
+}
+
+// ManifestEntry is what a manifest records of a file: its digests in hex,
+// "" for one the manifest lacks.
+type ManifestEntry struct {
+	SHA256 string
+	XXH64  string
+}
+
+// ReadManifest parses a manifest in any version ManifestFormat reads and
+// returns its entries by file name and the version it is in: 2 for the BSD
+// tagged lines of `xxhsum --tag`, "ALGO (name) = hex", and 1 for the
+// "hex  name" lines of sha256sum, which only have SHA-256. An empty
+// manifest is in the current version.
+func ReadManifest(r io.Reader) (map[string]ManifestEntry, Version, error) {
+	entries := make(map[string]ManifestEntry)
+	var version Version
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := sc.Text()
+		var algo, name, sum string
+		var v Version
+		if a, rest, ok := strings.Cut(line, " ("); ok && strings.Contains(rest, ") = ") {
+			name, sum, _ = strings.Cut(rest, ") = ")
+			algo, v = a, Version{2, 0}
+		} else if s, n, ok := strings.Cut(line, "  "); ok && len(s) == 64 && isHex(s) && n != "" {
+			algo, name, sum, v = "SHA256", strings.TrimPrefix(n, "*"), s, Version{1, 0}
+		} else {
+			return nil, version, fmt.Errorf("%w: line %q", ErrBadManifest, line)
+		}
+		if version != (Version{}) && version != v {
+			return nil, version, fmt.Errorf("%w: it mixes manifest formats %s and %s", ErrBadManifest, version, v)
+		}
+		version = v
+		e := entries[name]
+		switch algo {
+		case "SHA256":
+			e.SHA256 = sum
+		case "XXH64":
+			e.XXH64 = sum
+		}
+		entries[name] = e
+	}
+	if err := sc.Err(); err != nil {
+		return nil, version, err
+	}
+	if version == (Version{}) {
+		version = ManifestFormat.Current
+	}
+	return entries, version, nil
+}
+
+func isHex(s string) bool {
+	for _, c := range []byte(s) {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package wordlist
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// Generator is the keyspace of a charset string, for tools that want words
+// by position without the rest of the wordlist binary:
+//
+//	g, err := wordlist.NewGenerator("abc123", 1, 6)
+//	word, err := g.At(42)
+//	pos, err := g.IndexOf("cab")
+//	err = g.WriteFile(ctx, "part1.txt", 0, g.Total()/2)
+//
+// Every Keyspace method is available on it too, Range and Without included.
+type Generator struct {
+	*Keyspace
+}
+
+// NewGenerator returns the generator of every word of minLen..maxLen
+// characters of charset, one symbol per character, in charset order.
+func NewGenerator(charset string, minLen, maxLen int) (*Generator, error) {
+	ks, err := NewKeyspace(Runes(charset), minLen, maxLen)
+	if err != nil {
+		return nil, err
+	}
+	return &Generator{ks}, nil
+}
+
+// At returns the word at position pos, the inverse of IndexOf.
+func (g *Generator) At(pos int64) (string, error) { return g.WordAt(pos) }
+
+// WriteFile writes the words at positions [start, end) to path, one per
+// line. The words go to a temporary file beside path that is renamed over
+// it once complete, so path never holds a partial list; on error or
+// cancellation the temporary file is removed and path left as it was.
+func (g *Generator) WriteFile(ctx context.Context, path string, start, end int64) error {
+	it, err := g.Range(start, end)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly once renamed
+	w := bufio.NewWriterSize(f, 1<<20)
+	if _, err = it.WriteN(ctx, w, end-start); err == nil {
+		err = w.Flush()
+	}
+	if err == nil {
+		err = f.Chmod(0644) // CreateTemp makes it private
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
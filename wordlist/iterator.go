@@ -0,0 +1,131 @@
+package wordlist
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// writeChunk is how many words WriteN generates between cancellation checks.
+const writeChunk = 1 << 16
+
+// Iterator walks a half-open index range of a keyspace in order.
+//
+// The batch methods write into caller-owned memory and never allocate once
+// the caller's buffers have grown to size, so an embedder can pull millions
+// of candidates per second without per-word garbage.
+type Iterator struct {
+	ks    *Keyspace
+	start int64
+	pos   int64
+	end   int64
+	buf   []byte   // scratch space for WriteN
+	odo   odometer // the word at pos, while the iterator steps one by one
+	watch *watcher
+}
+
+// Range returns an iterator over indexes [start, end).
+func (k *Keyspace) Range(start, end int64) (*Iterator, error) {
+	if start < 0 || end > k.Total() || start > end {
+		return nil, fmt.Errorf("%w: [%d, %d) not in [0, %d)", ErrOutOfRange, start, end, k.Total())
+	}
+	return &Iterator{ks: k, start: start, pos: start, end: end, odo: odometer{index: -1}}, nil
+}
+
+// Pos is the index of the next word the iterator will produce.
+func (it *Iterator) Pos() int64 { return it.pos }
+
+// Remaining is the number of indices left in the range: the words left, or
+// an upper bound on them when the keyspace excludes some (see Without).
+func (it *Iterator) Remaining() int64 { return it.end - it.pos }
+
+// NextBatch fills up to n entries of dst with the next words, reusing the
+// storage of each dst[i], and returns how many were written. It writes fewer
+// than n only when dst is shorter than n or the range is exhausted.
+func (it *Iterator) NextBatch(dst [][]byte, n int) int {
+	n = it.clamp(min(n, len(dst)))
+	for i := 0; i < n; i++ {
+		if !it.skip() {
+			n = i
+			break
+		}
+		dst[i] = it.appendNext(dst[i][:0])
+	}
+	if it.watch != nil {
+		it.watch.check(it)
+	}
+	return n
+}
+
+// AppendBatch appends up to n newline-terminated words to buf and returns
+// the extended buffer and the number of words appended.
+func (it *Iterator) AppendBatch(buf []byte, n int) ([]byte, int) {
+	n = it.clamp(n)
+	for i := 0; i < n; i++ {
+		if !it.skip() {
+			n = i
+			break
+		}
+		buf = append(it.appendNext(buf), '\n')
+	}
+	if it.watch != nil {
+		it.watch.check(it)
+	}
+	return buf, n
+}
+
+// WriteN writes the next n words to w, newline-terminated, and returns how
+// many were written. It checks ctx between chunks, so cancelling stops a long
+// write promptly; on any error the iterator is left just past the last word
+// that reached w.
+func (it *Iterator) WriteN(ctx context.Context, w io.Writer, n int64) (int64, error) {
+	var done int64
+	for done < n && it.pos < it.end {
+		if err := ctx.Err(); err != nil {
+			return done, err
+		}
+		var k int
+		pos := it.pos
+		it.buf, k = it.AppendBatch(it.buf[:0], int(min(n-done, writeChunk)))
+		if _, err := w.Write(it.buf); err != nil {
+			it.pos = pos
+			return done, err
+		}
+		done += int64(k)
+	}
+	return done, nil
+}
+
+// appendNext appends the word at pos to dst and steps past it. Consecutive
+// words come from the odometer; only a jump — the first word, a skip over
+// excluded words, a rollback in WriteN — pays for index arithmetic. In a
+// shuffled keyspace every word is a jump.
+func (it *Iterator) appendNext(dst []byte) []byte {
+	if it.ks.perm != nil {
+		dst = it.ks.appendWord(dst, it.ks.perm.At(it.pos))
+		it.pos++
+		return dst
+	}
+	if it.odo.index != it.pos {
+		it.odo.seek(it.ks, it.pos)
+	}
+	dst = it.odo.appendWord(it.ks, dst)
+	it.odo.next(it.ks)
+	it.pos++
+	return dst
+}
+
+// skip moves past excluded words and reports whether a word is left.
+func (it *Iterator) skip() bool {
+	if it.ks.exclude != nil {
+		it.pos = min(it.ks.NextIncluded(it.pos), it.end)
+	}
+	return it.pos < it.end
+}
+
+func (it *Iterator) clamp(n int) int {
+	if left := it.end - it.pos; int64(n) > left {
+		return int(left)
+	}
+	return max(n, 0)
+}
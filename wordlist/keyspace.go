@@ -0,0 +1,379 @@
+// Package wordlist enumerates brute-force keyspaces by index.
+//
+// Words are ordered by length first and then by charset order, so every word
+// has exactly one index and every index in [0, Total) has exactly one word.
+// WordAt and IndexOf convert between the two; sharding, resume and lookups
+// all rely on them being exact inverses. Shuffle trades that order for a
+// seeded permutation of it, under which they stay inverses. The one
+// exception is a combinator keyspace whose words split between its word
+// positions in more than one way: such a word has several indices, which
+// IndicesOf returns, and IndexOf gives the first.
+package wordlist
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrEmptyCharset     = errors.New("wordlist: charset is empty")
+	ErrAmbiguousCharset = errors.New("wordlist: charset symbols are not prefix-free")
+	ErrBadLength        = errors.New("wordlist: invalid length range")
+	ErrKeyspaceTooLarge = errors.New("wordlist: keyspace does not fit in int64")
+	ErrOutOfRange       = errors.New("wordlist: index out of range")
+	ErrNotInKeyspace    = errors.New("wordlist: word is not in keyspace")
+)
+
+// Keyspace is every word of MinLen..MaxLen symbols drawn from a charset.
+//
+// A symbol is usually a single character, but may be any non-empty string
+// (a unicode character or a multi-character token). Symbols must be
+// prefix-free — no symbol may start with another — so that every word splits
+// into symbols in exactly one way.
+type Keyspace struct {
+	symbols []string
+	digits  map[string]int
+	bytes   *[256]int16 // digit per byte when every symbol is one byte
+	longest int         // longest symbol in bytes
+	lenSum  []int64     // lenSum[d] = total bytes of symbols 0..d-1
+	minLen  int
+	maxLen  int
+	pow     []int64      // pow[l] = len(symbols)^l
+	cum     []int64      // cum[l] = number of words no longer than l
+	exclude *Exclusion   // words iterators skip; see Without
+	perm    *Permutation // the order of the indices; nil for keyspace order, see Shuffle
+
+	mask   []maskPosition // per-position symbols; nil unless NewMaskKeyspace
+	places []int64        // places[j] = words per digit at position j of a mask
+	dicts  []int          // the positions of a mask that take whole words
+}
+
+// Runes splits a charset string into one symbol per unicode character.
+func Runes(charset string) []string {
+	symbols := make([]string, 0, utf8.RuneCountInString(charset))
+	for _, r := range charset {
+		symbols = append(symbols, string(r))
+	}
+	return symbols
+}
+
+// NewKeyspace returns the keyspace of all words of minLen..maxLen symbols.
+func NewKeyspace(symbols []string, minLen, maxLen int) (*Keyspace, error) {
+	if len(symbols) == 0 {
+		return nil, ErrEmptyCharset
+	}
+	if minLen < 1 || maxLen < minLen {
+		return nil, fmt.Errorf("%w: %d..%d", ErrBadLength, minLen, maxLen)
+	}
+
+	k := &Keyspace{
+		symbols: append([]string(nil), symbols...),
+		digits:  make(map[string]int, len(symbols)),
+		minLen:  minLen,
+		maxLen:  maxLen,
+	}
+	single := true
+	k.lenSum = make([]int64, len(k.symbols)+1)
+	for i, s := range k.symbols {
+		k.lenSum[i+1] = k.lenSum[i] + int64(len(s))
+		if s == "" {
+			return nil, fmt.Errorf("%w: empty symbol at position %d", ErrAmbiguousCharset, i)
+		}
+		if _, dup := k.digits[s]; dup {
+			return nil, fmt.Errorf("%w: duplicate symbol %q", ErrAmbiguousCharset, s)
+		}
+		k.digits[s] = i
+		k.longest = max(k.longest, len(s))
+		single = single && len(s) == 1
+	}
+	for _, a := range k.symbols {
+		for _, b := range k.symbols {
+			if a != b && strings.HasPrefix(b, a) {
+				return nil, fmt.Errorf("%w: %q is a prefix of %q", ErrAmbiguousCharset, a, b)
+			}
+		}
+	}
+	if single {
+		k.bytes = new([256]int16)
+		for i := range k.bytes {
+			k.bytes[i] = -1
+		}
+		for i, s := range k.symbols {
+			k.bytes[s[0]] = int16(i)
+		}
+	}
+
+	n := int64(len(k.symbols))
+	k.pow = make([]int64, maxLen+1)
+	k.cum = make([]int64, maxLen+1)
+	k.pow[0] = 1
+	for l := 1; l <= maxLen; l++ {
+		if k.pow[l-1] > math.MaxInt64/n {
+			return nil, fmt.Errorf("%w: %d symbols, length %d", ErrKeyspaceTooLarge, n, l)
+		}
+		k.pow[l] = k.pow[l-1] * n
+		k.cum[l] = k.cum[l-1]
+		if l >= minLen {
+			if k.cum[l] > math.MaxInt64-k.pow[l] {
+				return nil, fmt.Errorf("%w: %d symbols, length %d", ErrKeyspaceTooLarge, n, l)
+			}
+			k.cum[l] += k.pow[l]
+		}
+	}
+	return k, nil
+}
+
+// Symbols returns a copy of the charset in enumeration order. For a mask
+// keyspace it is every symbol any position takes, in first-seen order.
+func (k *Keyspace) Symbols() []string { return append([]string(nil), k.symbols...) }
+
+// MinLen is the shortest word length, in symbols.
+func (k *Keyspace) MinLen() int { return k.minLen }
+
+// MaxLen is the longest word length, in symbols.
+func (k *Keyspace) MaxLen() int { return k.maxLen }
+
+// MaxWordBytes is the length of the longest word, in bytes: no run of n
+// words takes more than n*(MaxWordBytes()+1) bytes with their newlines.
+func (k *Keyspace) MaxWordBytes() int {
+	if k.mask == nil {
+		return k.maxLen * k.longest
+	}
+	size := 0
+	for _, p := range k.mask {
+		size += max(p.longest, 1)
+	}
+	return size
+}
+
+// Total is the number of words in the keyspace.
+func (k *Keyspace) Total() int64 { return k.cum[k.maxLen] }
+
+// LengthRange returns the indices [start, end) of the words with l symbols;
+// it is empty when l is outside MinLen..MaxLen. They are indices in
+// keyspace order even when k is shuffled, which scatters those words over
+// every index.
+func (k *Keyspace) LengthRange(l int) (start, end int64) {
+	if l < k.minLen || l > k.maxLen {
+		return 0, 0
+	}
+	return k.cum[l-1], k.cum[l]
+}
+
+// LengthAt returns the length in symbols of the word with the given index,
+// or 0 when index is out of range.
+func (k *Keyspace) LengthAt(index int64) int {
+	if index < 0 || index >= k.Total() {
+		return 0
+	}
+	index = k.ordered(index)
+	l := k.minLen
+	for index >= k.cum[l] {
+		l++
+	}
+	return l
+}
+
+// WordAt returns the word with the given index.
+func (k *Keyspace) WordAt(index int64) (string, error) {
+	b, err := k.AppendWord(nil, index)
+	return string(b), err
+}
+
+// AppendWord appends the word with the given index to dst.
+func (k *Keyspace) AppendWord(dst []byte, index int64) ([]byte, error) {
+	if index < 0 || index >= k.Total() {
+		return dst, fmt.Errorf("%w: %d not in [0, %d)", ErrOutOfRange, index, k.Total())
+	}
+	return k.appendWord(dst, k.ordered(index)), nil
+}
+
+// appendWord is AppendWord without the range check.
+func (k *Keyspace) appendWord(dst []byte, index int64) []byte {
+	l := k.minLen
+	for index >= k.cum[l] {
+		l++
+	}
+	offset := index - k.cum[l-1]
+
+	if k.mask != nil {
+		for j, p := range k.mask {
+			dst = append(dst, p.symbols[offset/k.places[j]%int64(len(p.symbols))]...)
+		}
+		return dst
+	}
+	n := int64(len(k.symbols))
+	if k.bytes != nil {
+		start := len(dst)
+		dst = append(dst, make([]byte, l)...)
+		for j := start + l - 1; j >= start; j-- {
+			dst[j] = k.symbols[offset%n][0]
+			offset /= n
+		}
+		return dst
+	}
+	for j := l - 1; j >= 0; j-- {
+		dst = append(dst, k.symbols[offset/k.pow[j]]...)
+		offset %= k.pow[j]
+	}
+	return dst
+}
+
+// ByteOffset returns how many bytes the newline-terminated words before
+// index occupy: where that word starts in a file holding the whole keyspace.
+// The arithmetic wraps like any int64, so differences of offsets (see Bytes)
+// are exact whenever the difference itself fits. In a shuffled keyspace it
+// is an estimate, the whole size in proportion to index, which the words of
+// any long run of indices come close to.
+func (k *Keyspace) ByteOffset(index int64) int64 {
+	if k.perm != nil {
+		hi, lo := bits.Mul64(uint64(k.byteOffset(k.Total())), uint64(min(max(index, 0), k.Total())))
+		q, _ := bits.Div64(hi, lo, uint64(k.Total()))
+		return int64(q)
+	}
+	return k.byteOffset(index)
+}
+
+// byteOffset is ByteOffset in keyspace order.
+func (k *Keyspace) byteOffset(index int64) int64 {
+	var off int64
+	l := k.minLen
+	for ; l <= k.maxLen && index >= k.cum[l]; l++ {
+		off += k.blockBytes(l, k.pow[l])
+	}
+	if l > k.maxLen {
+		return off
+	}
+	return off + k.blockBytes(l, index-k.cum[l-1])
+}
+
+// Bytes returns the size of the newline-terminated words at [start, end).
+func (k *Keyspace) Bytes(start, end int64) int64 {
+	return k.ByteOffset(end) - k.ByteOffset(start)
+}
+
+// blockBytes is the size of the first count words of length l, newlines
+// included. Below each fixed leading digit, the free positions cycle through
+// every symbol equally often, so each digit contributes in closed form.
+func (k *Keyspace) blockBytes(l int, count int64) int64 {
+	if len(k.dicts) > 0 {
+		size := count * int64(l+1-len(k.dicts))
+		for _, j := range k.dicts {
+			size += k.wordBytes(j, count)
+		}
+		return size
+	}
+	if k.mask != nil {
+		return count * int64(l+1)
+	}
+	n := int64(len(k.symbols))
+	all := k.lenSum[n]
+	if count == k.pow[l] {
+		return count + int64(l)*k.pow[l-1]*all
+	}
+	size := count // newlines
+	var prefix int64
+	for j := l - 1; j >= 0; j-- {
+		d := count / k.pow[j]
+		count %= k.pow[j]
+		size += d*k.pow[j]*prefix + k.pow[j]*k.lenSum[d]
+		if j > 0 {
+			size += d * int64(j) * k.pow[j-1] * all
+		}
+		prefix += int64(len(k.symbols[d]))
+	}
+	return size
+}
+
+// IndexOf returns the index of word, the inverse of WordAt. A word that a
+// combinator keyspace holds more than once has the first of its IndicesOf.
+func (k *Keyspace) IndexOf(word string) (int64, error) {
+	indices, err := k.IndicesOf(word)
+	if err != nil {
+		return -1, err
+	}
+	return indices[0], nil
+}
+
+// IndicesOf returns every index of word, ascending. Only a combinator
+// keyspace holds a word at more than one: where the words of a position end
+// is not written down, so {"a", "ab"} then {"bc", "c"} give "abc" twice, as
+// "a"+"bc" and "ab"+"c". A hybrid keyspace has one word position between
+// single characters, which always split one way.
+func (k *Keyspace) IndicesOf(word string) ([]int64, error) {
+	var indices []int64
+	if len(k.dicts) > 0 {
+		if indices = k.wordsIndicesOf(word); len(indices) == 0 {
+			return nil, fmt.Errorf("%w: %q", ErrNotInKeyspace, word)
+		}
+	} else {
+		index, err := k.indexOf(word)
+		if err != nil {
+			return nil, err
+		}
+		indices = []int64{index}
+	}
+	if k.perm != nil {
+		for i, index := range indices {
+			indices[i] = k.perm.Index(index)
+		}
+	}
+	slices.Sort(indices)
+	return indices, nil
+}
+
+// indexOf is IndexOf in keyspace order for a keyspace without word
+// positions, which holds every word once.
+func (k *Keyspace) indexOf(word string) (int64, error) {
+	if k.mask != nil {
+		return k.maskIndexOf(word)
+	}
+	n := int64(len(k.symbols))
+	var offset int64
+	l := 0
+	for i := 0; i < len(word); l++ {
+		d := k.digitAt(word, i)
+		if d < 0 || l >= k.maxLen {
+			return -1, fmt.Errorf("%w: %q", ErrNotInKeyspace, word)
+		}
+		offset = offset*n + int64(d)
+		i += len(k.symbols[d])
+	}
+	if l < k.minLen {
+		return -1, fmt.Errorf("%w: %q", ErrNotInKeyspace, word)
+	}
+	return k.cum[l-1] + offset, nil
+}
+
+func (k *Keyspace) maskIndexOf(word string) (int64, error) {
+	if len(word) != len(k.mask) {
+		return -1, fmt.Errorf("%w: %q", ErrNotInKeyspace, word)
+	}
+	var index int64
+	for j, p := range k.mask {
+		d := p.digits[word[j]]
+		if d < 0 {
+			return -1, fmt.Errorf("%w: %q", ErrNotInKeyspace, word)
+		}
+		index = index*int64(len(p.symbols)) + int64(d)
+	}
+	return index, nil
+}
+
+// digitAt returns the digit of the symbol starting at word[i], or -1.
+func (k *Keyspace) digitAt(word string, i int) int {
+	if k.bytes != nil {
+		return int(k.bytes[word[i]])
+	}
+	for size := 1; size <= k.longest && i+size <= len(word); size++ {
+		if d, ok := k.digits[word[i:i+size]]; ok {
+			return d
+		}
+	}
+	return -1
+}
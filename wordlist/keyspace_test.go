@@ -0,0 +1,312 @@
+package wordlist
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKeyspaces are a keyspace of every kind whose words the tests check
+// against their indices.
+func testKeyspaces(t testing.TB) map[string]*Keyspace {
+	t.Helper()
+	must := func(k *Keyspace, err error) *Keyspace {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return k
+	}
+	unicode := must(NewKeyspace(Runes("aé日🙂"), 1, 5))
+	return map[string]*Keyspace{
+		"ascii":    must(NewKeyspace(Runes("ab0_"), 1, 5)),
+		"unicode":  unicode,
+		"tokens":   must(NewKeyspace([]string{"ab", "c", "xyz", "é"}, 2, 4)),
+		"mask":     must(NewMaskKeyspace([]string{"Ab", "0123456789", "x", "!?"})),
+		"hybrid":   must(NewHybridKeyspace([]string{"pass", "word", "x"}, []string{"12"}, []string{"0123456789", "ab"})),
+		"combine":  must(NewCombinatorKeyspace([]string{"blue", "red"}, []string{"cat", "dog", "fish"}, []string{"", "-"})),
+		"shuffled": must(unicode.Shuffle(7)),
+	}
+}
+
+func TestWordAtIndexOf(t *testing.T) {
+	for name, k := range testKeyspaces(t) {
+		seen := make(map[string]bool, k.Total())
+		longest := 0
+		for i := range k.Total() {
+			word, err := k.WordAt(i)
+			if err != nil {
+				t.Fatalf("%s: WordAt(%d): %v", name, i, err)
+			}
+			longest = max(longest, len(word))
+			if seen[word] {
+				t.Fatalf("%s: WordAt(%d) = %q, a word of an earlier index", name, i, word)
+			}
+			seen[word] = true
+			if got, err := k.IndexOf(word); err != nil || got != i {
+				t.Fatalf("%s: IndexOf(WordAt(%d) = %q) = %d, %v", name, i, word, got, err)
+			}
+		}
+		if k.MaxWordBytes() != longest {
+			t.Fatalf("%s: MaxWordBytes() = %d, the longest word has %d", name, k.MaxWordBytes(), longest)
+		}
+	}
+}
+
+func FuzzWordAtIndexOf(f *testing.F) {
+	for i, word := range []string{"a", "é日", "abxyz", "b3x?", "1word7b", "red-fish", "🙂🙂🙂🙂🙂"} {
+		f.Add(uint8(i), int64(i*37), word)
+	}
+	keyspaces := testKeyspaces(f)
+	kinds := slices.Sorted(maps.Keys(keyspaces))
+	f.Fuzz(func(t *testing.T, kind uint8, index int64, word string) {
+		k := keyspaces[kinds[int(kind)%len(kinds)]]
+		if index < 0 {
+			index = -(index + 1)
+		}
+		index %= k.Total()
+		w, err := k.WordAt(index)
+		if err != nil {
+			t.Fatalf("WordAt(%d): %v", index, err)
+		}
+		if got, err := k.IndexOf(w); err != nil || got != index {
+			t.Fatalf("IndexOf(WordAt(%d) = %q) = %d, %v", index, w, got, err)
+		}
+		i, err := k.IndexOf(word)
+		if err != nil {
+			return // not a word of k
+		}
+		if got, err := k.WordAt(i); err != nil || got != word {
+			t.Fatalf("WordAt(IndexOf(%q) = %d) = %q, %v", word, i, got, err)
+		}
+	})
+}
+
+// TestIteratorMatchesWordAt checks that the iterators give the words
+// WordAt gives, from every start and in either order.
+func TestIteratorMatchesWordAt(t *testing.T) {
+	for name, k := range testKeyspaces(t) {
+		for _, start := range []int64{0, 1, k.Total() / 3, k.Total() - 1} {
+			it, err := k.Range(start, k.Total())
+			if err != nil {
+				t.Fatal(err)
+			}
+			dst := make([][]byte, 7)
+			for i := start; ; {
+				n := it.NextBatch(dst, len(dst))
+				if n == 0 {
+					break
+				}
+				for _, got := range dst[:n] {
+					if want, _ := k.WordAt(i); string(got) != want {
+						t.Fatalf("%s: the iterator from %d gives %q at %d, WordAt %q", name, start, got, i, want)
+					}
+					i++
+				}
+			}
+			if it.Pos() != k.Total() {
+				t.Fatalf("%s: the iterator from %d stops at %d of %d", name, start, it.Pos(), k.Total())
+			}
+		}
+	}
+}
+
+// TestProgressChanSendsTheEnd reads a ProgressChan slower than the iterator
+// fills it and checks that the last update still arrives.
+func TestProgressChanSendsTheEnd(t *testing.T) {
+	k, err := NewKeyspace(Runes("ab"), 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := k.Range(0, k.Total())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan Progress, 1)
+	it.OnProgress(0, ProgressChan(ch))
+	last := make(chan Progress)
+	go func() {
+		var p Progress
+		for p = range ch {
+			time.Sleep(time.Millisecond)
+		}
+		last <- p
+	}()
+	dst := make([][]byte, 3)
+	for it.NextBatch(dst, len(dst)) > 0 {
+	}
+	close(ch)
+	if p := <-last; p.Pos != k.Total() || p.Fraction() != 1 {
+		t.Fatalf("the last update read is at %d of %d", p.Pos, k.Total())
+	}
+}
+
+// TestExclusionMatchesFilter checks a keyspace without ranges, masks and
+// the words breaking constraints against filtering out its words one at a
+// time.
+func TestExclusionMatchesFilter(t *testing.T) {
+	k, err := NewKeyspace(Runes("ab0_"), 1, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewExclusion(k)
+	e.AddRange(10, 30)
+	e.AddRange(200, 210)
+	masks := [][]string{{"0_", "ab"}, {"ab0_", "0", "ab0_"}, {"_", "_", "_", "_"}}
+	for _, m := range masks {
+		if err := e.AddMask(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c, err := ParseConstraints("pos1 in [ab0]; all != prev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.AddConstraints(c); err != nil {
+		t.Fatal(err)
+	}
+	excluded := func(word string) bool {
+		i, _ := k.IndexOf(word)
+		if 10 <= i && i < 30 || 200 <= i && i < 210 {
+			return true
+		}
+		if slices.ContainsFunc(masks, func(m []string) bool { return matchesMask(word, m) }) {
+			return true
+		}
+		for j := 1; j < len(word); j++ {
+			if word[j] == word[j-1] {
+				return true
+			}
+		}
+		return word[0] == '_'
+	}
+	checkExclusion(t, k.Without(e), excluded)
+	shuffled, err := k.Without(e).Shuffle(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkExclusion(t, shuffled, excluded)
+}
+
+// matchesMask reports whether every byte of word is in the set for its
+// position.
+func matchesMask(word string, sets []string) bool {
+	if len(word) != len(sets) {
+		return false
+	}
+	for i, set := range sets {
+		if strings.IndexByte(set, word[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkExclusion checks Excluded, NextIncluded and the iterator of k
+// against excluded.
+func checkExclusion(t *testing.T, k *Keyspace, excluded func(word string) bool) {
+	t.Helper()
+	var want []string
+	for i := range k.Total() {
+		word, _ := k.WordAt(i)
+		if k.Excluded(i) != excluded(word) {
+			t.Fatalf("Excluded(%d) = %v for %q", i, k.Excluded(i), word)
+		}
+		if !excluded(word) {
+			want = append(want, word)
+		}
+	}
+	for i, next := k.Total()-1, k.Total(); i >= 0; i-- {
+		if word, _ := k.WordAt(i); !excluded(word) {
+			next = i
+		}
+		if got := k.NextIncluded(i); got != next {
+			t.Fatalf("NextIncluded(%d) = %d, want %d", i, got, next)
+		}
+	}
+	it, err := k.Range(0, k.Total())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	dst := make([][]byte, 5)
+	for n := it.NextBatch(dst, len(dst)); n > 0; n = it.NextBatch(dst, len(dst)) {
+		for _, word := range dst[:n] {
+			got = append(got, string(word))
+		}
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("the iterator gives %d words, want the %d not excluded", len(got), len(want))
+	}
+}
+
+// TestOdometerMatchesAppendWord steps the odometer over the whole of each
+// keyspace in keyspace order.
+func TestOdometerMatchesAppendWord(t *testing.T) {
+	for name, k := range testKeyspaces(t) {
+		if k.Shuffled() {
+			continue // iterators of a shuffled keyspace do not step it
+		}
+		o := odometer{index: -1}
+		o.seek(k, 0)
+		for i := range k.Total() {
+			if o.index != i {
+				t.Fatalf("%s: the odometer holds %d, want %d", name, o.index, i)
+			}
+			got, want := o.appendWord(k, nil), k.appendWord(nil, i)
+			if string(got) != string(want) {
+				t.Fatalf("%s: the odometer gives %q at %d, appendWord %q", name, got, i, want)
+			}
+			o.next(k)
+		}
+		if o.index != -1 {
+			t.Fatalf("%s: the odometer holds %d past the last word", name, o.index)
+		}
+	}
+}
+
+func TestPermutationIsBijection(t *testing.T) {
+	for n := int64(1); n <= 300; n++ {
+		for _, seed := range []uint64{0, 1, 7, 1 << 63} {
+			p, err := NewPermutation(n, seed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen := make([]bool, n)
+			for i := range n {
+				x := p.At(i)
+				if x < 0 || x >= n || seen[x] {
+					t.Fatalf("n %d, seed %d: At(%d) = %d, outside [0, n) or taken", n, seed, i, x)
+				}
+				seen[x] = true
+				if back := p.Index(x); back != i {
+					t.Fatalf("n %d, seed %d: Index(At(%d) = %d) = %d", n, seed, i, x, back)
+				}
+			}
+		}
+	}
+}
+
+// TestIndicesOfDuplicates checks a combinator keyspace holding a word once
+// for each way it splits between the lists.
+func TestIndicesOfDuplicates(t *testing.T) {
+	k, err := NewCombinatorKeyspace([]string{"a", "ab"}, []string{"bc", "c"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range k.Total() {
+		word, _ := k.WordAt(i)
+		indices, err := k.IndicesOf(word)
+		if err != nil || !slices.Contains(indices, i) || !slices.IsSorted(indices) {
+			t.Fatalf("IndicesOf(WordAt(%d) = %q) = %v, %v", i, word, indices, err)
+		}
+		if first, _ := k.IndexOf(word); first != indices[0] {
+			t.Fatalf("IndexOf(%q) = %d, want the first of %v", word, first, indices)
+		}
+	}
+	if indices, _ := k.IndicesOf("abc"); !slices.Equal(indices, []int64{0, 3}) {
+		t.Fatalf(`IndicesOf("abc") = %v, want [0 3]`, indices)
+	}
+}
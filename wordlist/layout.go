@@ -0,0 +1,85 @@
+package wordlist
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrBadLayout = errors.New("wordlist: invalid chunk layout")
+
+// Layout is how a run names its chunk files, before a codec's extension: a
+// file name template holding {num}, the chunk number padded to six digits,
+// in a directory, and with PerDir set in numbered subdirectories of it,
+// 00/, 01/..., of PerDir chunks each, so a run of tens of thousands of
+// chunks does not put them all in one directory.
+type Layout struct {
+	Dir      string // "" for the current directory; slash-separated
+	Template string // such as combos_{num}.txt
+	PerDir   int64  // chunks per subdirectory; 0 for none
+
+	number *regexp.Regexp // matches a file name, capturing {num}
+}
+
+// DefaultLayout names chunks combos_000001.txt, combos_000002.txt... in the
+// current directory.
+var DefaultLayout, _ = NewLayout("", "combos_{num}.txt", 0)
+
+// NewLayout checks a layout and returns it. The template must hold {num}
+// once and no other placeholder or slash.
+func NewLayout(dir, template string, perDir int64) (Layout, error) {
+	l := Layout{Template: template, PerDir: perDir}
+	if dir != "" && path.Clean(dir) != "." {
+		l.Dir = path.Clean(dir)
+	}
+	before, after, ok := strings.Cut(template, "{num}")
+	switch {
+	case !ok || strings.Contains(after, "{num}"):
+		return l, fmt.Errorf("%w: template %q must hold {num} once", ErrBadLayout, template)
+	case strings.ContainsAny(before+after, "{}"):
+		return l, fmt.Errorf("%w: template %q holds a placeholder other than {num}", ErrBadLayout, template)
+	case strings.ContainsRune(template, '/'):
+		return l, fmt.Errorf("%w: template %q names a directory; use the layout's Dir and PerDir", ErrBadLayout, template)
+	case perDir < 0:
+		return l, fmt.Errorf("%w: %d chunks per directory", ErrBadLayout, perDir)
+	}
+	l.number = regexp.MustCompile(`^` + regexp.QuoteMeta(before) + `(\d{6,})` + regexp.QuoteMeta(after) + `(\.gz|\.zst)?$`)
+	return l, nil
+}
+
+// Name is the slash-separated path of chunk num, counting from 1.
+func (l Layout) Name(num int64) string {
+	name := strings.Replace(l.Template, "{num}", fmt.Sprintf("%06d", num), 1)
+	if l.PerDir > 0 {
+		name = path.Join(fmt.Sprintf("%02d", (num-1)/l.PerDir), name)
+	}
+	return path.Join(l.Dir, name)
+}
+
+// Pattern is a glob matching every chunk name, for path.Match or
+// filepath.Glob.
+func (l Layout) Pattern() string {
+	name := strings.Replace(l.Template, "{num}", "[0-9]*", 1)
+	if l.PerDir > 0 {
+		name = path.Join("[0-9]*", name)
+	}
+	return path.Join(l.Dir, name)
+}
+
+// Number returns the chunk number in the file name of name, with or without
+// a codec's extension, whatever directory it is in; ok is false when it is
+// not a chunk's.
+func (l Layout) Number(name string) (num int64, ok bool) {
+	m := l.number.FindStringSubmatch(path.Base(name))
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil || n < 1 || fmt.Sprintf("%06d", n) != m[1] {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,43 @@
+package wordlist
+
+import "fmt"
+
+// Location is where a word sits in the chunk files of a run that writes
+// perFile positions to each chunk.
+type Location struct {
+	Index int64 // position in the keyspace
+	Chunk int64 // chunk number, counting from 1
+	Line  int64 // line in the chunk, counting from 1
+}
+
+// Locate returns where word is in a run over k with perFile positions per
+// chunk: the inverse of the run's writing it. Excluded words take no line,
+// so for a keyspace with exclusions the lines before the word are counted,
+// and an excluded word itself is ErrNotInKeyspace.
+func (k *Keyspace) Locate(word string, perFile int64) (Location, error) {
+	if perFile <= 0 {
+		return Location{}, fmt.Errorf("%w: %d words per chunk", ErrOutOfRange, perFile)
+	}
+	index, err := k.IndexOf(word)
+	if err != nil {
+		return Location{}, err
+	}
+	if k.Excluded(index) {
+		return Location{}, fmt.Errorf("%w: %q is excluded", ErrNotInKeyspace, word)
+	}
+	return Location{Index: index, Chunk: index/perFile + 1, Line: k.ChunkLine(index, perFile)}, nil
+}
+
+// ChunkLine is the line, counting from 1, of the word at index in its chunk
+// of perFile positions.
+func (k *Keyspace) ChunkLine(index, perFile int64) int64 {
+	start := index / perFile * perFile
+	if k.exclude == nil {
+		return index - start + 1
+	}
+	line := int64(1)
+	for i := k.NextIncluded(start); i < index; i = k.NextIncluded(i + 1) {
+		line++
+	}
+	return line
+}
@@ -0,0 +1,239 @@
+package wordlist
+
+import (
+	"fmt"
+	"math"
+	"slices"
+)
+
+// maskPosition is what one position of a mask keyspace takes.
+type maskPosition struct {
+	symbols []string
+	digits  [256]int16 // digit per byte, or -1
+
+	// A word position of a hybrid or combinator keyspace takes whole
+	// words: their digits by word, lenSum[d], the bytes of words 0..d-1,
+	// and the longest in bytes.
+	words   map[string]int
+	lenSum  []int64
+	longest int
+}
+
+// positionSpec is what newPositionKeyspace makes a position of: a byte of
+// set, or one of words when that is not nil.
+type positionSpec struct {
+	set   string
+	words []string
+}
+
+// NewMaskKeyspace returns the keyspace of the words with one byte of sets[j]
+// at each position j, like a hashcat mask: {"ab", "0123456789"} holds a0
+// through b9. Positions count like the digits of a number in mixed radix,
+// the last fastest, each through its set in order; every word has
+// len(sets) symbols.
+func NewMaskKeyspace(sets []string) (*Keyspace, error) {
+	if len(sets) == 0 {
+		return nil, fmt.Errorf("%w: a mask of no positions", ErrBadLength)
+	}
+	return newPositionKeyspace(maskSpecs(sets))
+}
+
+// NewHybridKeyspace returns the keyspace of every word of words between a
+// word of the prefix mask and one of the suffix mask, like hashcat's
+// hybrid attacks: {"pass", "word"} with the suffix {"0123456789"} holds
+// pass0 through word9. The words form one position in mixed radix with
+// the mask's, so with a suffix each word comes with every suffix in turn,
+// and with only a prefix every prefix with each word. Words must be
+// distinct and not empty; the masks may be empty, not both.
+func NewHybridKeyspace(words, prefix, suffix []string) (*Keyspace, error) {
+	if len(prefix)+len(suffix) == 0 {
+		return nil, fmt.Errorf("%w: a hybrid keyspace without a prefix or suffix mask", ErrBadLength)
+	}
+	if err := checkWords(words, "word"); err != nil {
+		return nil, err
+	}
+	return newPositionKeyspace(slices.Concat(maskSpecs(prefix), []positionSpec{{words: words}}, maskSpecs(suffix)))
+}
+
+// NewCombinatorKeyspace returns the keyspace of every word of left joined
+// to every word of right, like hashcat's combinator attack, with each of
+// seps between them when there are any: {"blue", "red"} and {"cat",
+// "dog"} with the separators {"", "-"} hold bluecat, bluedog, blue-cat,
+// blue-dog, redcat and so on, the right word turning fastest. Words must
+// be distinct and not empty; a separator may be empty. Distinct pairs may
+// still join into the same word, as a+mend and am+end do, and then the
+// keyspace holds it once for each (see IndicesOf).
+func NewCombinatorKeyspace(left, right, seps []string) (*Keyspace, error) {
+	if err := checkWords(left, "left word"); err != nil {
+		return nil, err
+	}
+	if err := checkWords(right, "right word"); err != nil {
+		return nil, err
+	}
+	specs := []positionSpec{{words: left}}
+	if len(seps) > 0 {
+		specs = append(specs, positionSpec{words: seps})
+	}
+	return newPositionKeyspace(append(specs, positionSpec{words: right}))
+}
+
+// checkWords rejects a word position that is empty or holds an empty word.
+func checkWords(words []string, what string) error {
+	if len(words) == 0 {
+		return fmt.Errorf("%w: no %ss", ErrEmptyCharset, what)
+	}
+	for d, w := range words {
+		if w == "" {
+			return fmt.Errorf("%w: empty %s %d", ErrAmbiguousCharset, what, d+1)
+		}
+	}
+	return nil
+}
+
+func maskSpecs(sets []string) []positionSpec {
+	specs := make([]positionSpec, len(sets))
+	for j, set := range sets {
+		specs[j].set = set
+	}
+	return specs
+}
+
+// newPositionKeyspace builds a mask keyspace of the positions specs give.
+func newPositionKeyspace(specs []positionSpec) (*Keyspace, error) {
+	l := len(specs)
+	k := &Keyspace{
+		digits:  make(map[string]int),
+		longest: 1,
+		minLen:  l,
+		maxLen:  l,
+		mask:    make([]maskPosition, l),
+		places:  make([]int64, l),
+		pow:     make([]int64, l+1),
+		cum:     make([]int64, l+1),
+	}
+	for j, spec := range specs {
+		p := &k.mask[j]
+		if spec.words != nil {
+			k.dicts = append(k.dicts, j)
+			p.words = make(map[string]int, len(spec.words))
+			p.lenSum = make([]int64, len(spec.words)+1)
+			for d, w := range spec.words {
+				if _, dup := p.words[w]; dup {
+					return nil, fmt.Errorf("%w: duplicate word %q at position %d", ErrAmbiguousCharset, w, j+1)
+				}
+				p.words[w] = d
+				p.lenSum[d+1] = p.lenSum[d] + int64(len(w))
+				p.longest = max(p.longest, len(w))
+			}
+			p.symbols = append([]string(nil), spec.words...)
+			continue
+		}
+		set := spec.set
+		if set == "" {
+			return nil, fmt.Errorf("%w: mask position %d", ErrEmptyCharset, j+1)
+		}
+		for i := range p.digits {
+			p.digits[i] = -1
+		}
+		for i := range len(set) {
+			if p.digits[set[i]] >= 0 {
+				return nil, fmt.Errorf("%w: duplicate symbol %q at mask position %d", ErrAmbiguousCharset, set[i:i+1], j+1)
+			}
+			p.digits[set[i]] = int16(len(p.symbols))
+			p.symbols = append(p.symbols, set[i:i+1])
+			if _, ok := k.digits[set[i:i+1]]; !ok {
+				k.digits[set[i:i+1]] = len(k.symbols)
+				k.symbols = append(k.symbols, set[i:i+1])
+			}
+		}
+	}
+	size := int64(1)
+	for j := l - 1; j >= 0; j-- {
+		k.places[j] = size
+		n := int64(len(k.mask[j].symbols))
+		if size > math.MaxInt64/n {
+			return nil, fmt.Errorf("%w: mask of %d positions", ErrKeyspaceTooLarge, l)
+		}
+		size *= n
+	}
+	k.pow[l], k.cum[l] = size, size
+	return k, nil
+}
+
+// Masked reports whether k is a mask keyspace (see NewMaskKeyspace), or a
+// hybrid or combinator one.
+func (k *Keyspace) Masked() bool { return k.mask != nil }
+
+// Hybrid reports whether some position of k takes whole words: whether it
+// is a hybrid or combinator keyspace (see NewHybridKeyspace and
+// NewCombinatorKeyspace).
+func (k *Keyspace) Hybrid() bool { return len(k.dicts) > 0 }
+
+// FirstSymbols returns the symbols a word of k can start with, in order.
+// Within each length, the words starting with each take an equal run of
+// consecutive indices; for a hybrid keyspace the symbols are whole words.
+func (k *Keyspace) FirstSymbols() []string { return append([]string(nil), k.symbolsAt(0)...) }
+
+// symbolsAt returns the symbols position j takes, in order.
+func (k *Keyspace) symbolsAt(j int) []string {
+	if k.mask != nil {
+		return k.mask[j].symbols
+	}
+	return k.symbols
+}
+
+// place is how many consecutive words share each digit at position j of
+// the words with l symbols.
+func (k *Keyspace) place(l, j int) int64 {
+	if k.mask != nil {
+		return k.places[j]
+	}
+	return k.pow[l-1-j]
+}
+
+// singleBytes reports whether every symbol is one byte.
+func (k *Keyspace) singleBytes() bool { return k.bytes != nil || k.mask != nil && len(k.dicts) == 0 }
+
+// wordBytes is the bytes word position j contributes to the first count
+// words of the keyspace. Each word repeats for a block of places words,
+// and the blocks cycle through the words.
+func (k *Keyspace) wordBytes(j int, count int64) int64 {
+	p := &k.mask[j]
+	n := int64(len(p.symbols))
+	block := k.places[j]
+	cycles, rest := count/(block*n), count%(block*n)
+	d := rest / block
+	return cycles*block*p.lenSum[n] + block*p.lenSum[d] + rest%block*int64(len(p.symbols[d%n]))
+}
+
+// wordsIndicesOf is IndicesOf in keyspace order for a keyspace with word
+// positions, unsorted. Where the words of one position end is not written
+// down, so each way of splitting word over the positions is tried, and
+// every one that fits, as "ab"+"c" and "a"+"bc" may both, is an index.
+func (k *Keyspace) wordsIndicesOf(word string) []int64 {
+	var indices []int64
+	var walk func(j int, rest string, index int64)
+	walk = func(j int, rest string, index int64) {
+		if j == len(k.mask) {
+			if rest == "" {
+				indices = append(indices, index)
+			}
+			return
+		}
+		p := &k.mask[j]
+		n := int64(len(p.symbols))
+		if p.words == nil {
+			if rest != "" && p.digits[rest[0]] >= 0 {
+				walk(j+1, rest[1:], index*n+int64(p.digits[rest[0]]))
+			}
+			return
+		}
+		for size := 0; size <= min(p.longest, len(rest)); size++ {
+			if d, ok := p.words[rest[:size]]; ok {
+				walk(j+1, rest[size:], index*n+int64(d))
+			}
+		}
+	}
+	walk(0, word, 0)
+	return indices
+}
@@ -0,0 +1,74 @@
+package wordlist
+
+// odometer holds the word at one index as its digits, so that stepping to the
+// next index turns the last digit and carries into the ones before it
+// instead of dividing the index down again. Most steps touch a single byte;
+// a carry across all digits rolls over to the first word one symbol longer.
+type odometer struct {
+	index  int64  // index of the word held; -1 when nothing is held
+	digits []int  // digit per position, most significant first
+	word   []byte // the word itself, kept only when every symbol is one byte
+}
+
+// seek loads the word at index, doing the division appendWord does once.
+func (o *odometer) seek(k *Keyspace, index int64) {
+	l := k.minLen
+	for index >= k.cum[l] {
+		l++
+	}
+	offset := index - k.cum[l-1]
+
+	o.index = index
+	o.digits = o.digits[:0]
+	o.digits = append(o.digits, make([]int, l)...)
+	for j := l - 1; j >= 0; j-- {
+		n := int64(len(k.symbolsAt(j)))
+		o.digits[j] = int(offset % n)
+		offset /= n
+	}
+	o.word = o.word[:0]
+	if k.singleBytes() {
+		for j, d := range o.digits {
+			o.word = append(o.word, k.symbolsAt(j)[d][0])
+		}
+	}
+}
+
+// appendWord appends the word held to dst.
+func (o *odometer) appendWord(k *Keyspace, dst []byte) []byte {
+	if k.singleBytes() {
+		return append(dst, o.word...)
+	}
+	for j, d := range o.digits {
+		dst = append(dst, k.symbolsAt(j)[d]...)
+	}
+	return dst
+}
+
+// next advances to the following index. Past the last word of the keyspace
+// the odometer holds nothing, and the next use seeks.
+func (o *odometer) next(k *Keyspace) {
+	o.index++
+	single := k.singleBytes()
+	for j := len(o.digits) - 1; j >= 0; j-- {
+		symbols := k.symbolsAt(j)
+		if o.digits[j]++; o.digits[j] < len(symbols) {
+			if single {
+				o.word[j] = symbols[o.digits[j]][0]
+			}
+			return
+		}
+		o.digits[j] = 0
+		if single {
+			o.word[j] = symbols[0][0]
+		}
+	}
+	if len(o.digits) == k.maxLen {
+		o.index = -1
+		return
+	}
+	o.digits = append(o.digits, 0)
+	if k.bytes != nil {
+		o.word = append(o.word, k.symbols[0][0])
+	}
+}
@@ -0,0 +1,91 @@
+package wordlist
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// feistelRounds is how many rounds a Permutation mixes its halves; four
+// rounds of a good round function already look random, and more cost
+// little.
+const feistelRounds = 6
+
+// Permutation is a reproducible shuffle of the indices [0, n) that takes no
+// memory, however large n: a Feistel network keyed by a seed permutes the
+// smallest power of four holding n, and an index it takes outside [0, n) is
+// put through it again until it lands inside. That stays a permutation of
+// [0, n), and with at most four times n to walk, At takes a few passes on
+// average.
+type Permutation struct {
+	n    int64
+	half uint   // bits in each half of an index
+	mask uint64 // the low half
+	keys [feistelRounds]uint64
+}
+
+// NewPermutation returns the permutation of [0, n) that seed picks.
+func NewPermutation(n int64, seed uint64) (*Permutation, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("%w: a permutation of %d indices", ErrOutOfRange, n)
+	}
+	half := uint(bits.Len64(uint64(n-1))+1) / 2
+	p := &Permutation{n: n, half: half, mask: 1<<half - 1}
+	state := seed
+	for r := range p.keys {
+		state += 0x9e3779b97f4a7c15
+		p.keys[r] = mix64(state)
+	}
+	return p, nil
+}
+
+// Len is the number of indices permuted.
+func (p *Permutation) Len() int64 { return p.n }
+
+// At returns the index the permutation puts at position i of [0, n).
+func (p *Permutation) At(i int64) int64 {
+	x := uint64(i)
+	for {
+		x = p.round(x)
+		if x < uint64(p.n) {
+			return int64(x)
+		}
+	}
+}
+
+// Index returns the position at which the permutation puts index x of
+// [0, n): the inverse of At, walking the cycle backwards.
+func (p *Permutation) Index(x int64) int64 {
+	i := uint64(x)
+	for {
+		i = p.unround(i)
+		if i < uint64(p.n) {
+			return int64(i)
+		}
+	}
+}
+
+// round is one pass of the Feistel network over 2·half bits.
+func (p *Permutation) round(x uint64) uint64 {
+	left, right := x>>p.half, x&p.mask
+	for _, k := range p.keys {
+		left, right = right, left^mix64(right^k)&p.mask
+	}
+	return left<<p.half | right
+}
+
+// unround undoes round, taking the keys in reverse.
+func (p *Permutation) unround(x uint64) uint64 {
+	left, right := x>>p.half, x&p.mask
+	for r := len(p.keys) - 1; r >= 0; r-- {
+		left, right = right^mix64(left^p.keys[r])&p.mask, left
+	}
+	return left<<p.half | right
+}
+
+// mix64 is the finalizer of SplitMix64: every bit of x reaches every bit of
+// the result.
+func mix64(x uint64) uint64 {
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	return x ^ x>>31
+}
@@ -0,0 +1,169 @@
+package wordlist
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrNoTokens       = errors.New("wordlist: phrase has no tokens")
+	ErrTooManyTokens  = errors.New("wordlist: phrase has too many tokens")
+	ErrNoVariants     = errors.New("wordlist: phrase needs at least one separator and one case")
+	ErrPhraseTooLarge = errors.New("wordlist: phrase variants do not fit in int64")
+)
+
+// MaxPhraseTokens bounds the tokens of a Phrase: their orders grow
+// factorially, and 8 tokens already have 40,320.
+const MaxPhraseTokens = 8
+
+// Case is how a Phrase writes one token.
+type Case int
+
+const (
+	AsIs  Case = iota // as given
+	Lower             // blue
+	Title             // Blue
+	Upper             // BLUE
+)
+
+var caseNames = []string{"asis", "lower", "title", "upper"}
+
+func (c Case) String() string {
+	if c < 0 || int(c) >= len(caseNames) {
+		return fmt.Sprintf("Case(%d)", int(c))
+	}
+	return caseNames[c]
+}
+
+// ParseCase returns the Case called name: asis, lower, title or upper.
+func ParseCase(name string) (Case, error) {
+	for i, n := range caseNames {
+		if n == name {
+			return Case(i), nil
+		}
+	}
+	return 0, fmt.Errorf("wordlist: unknown case %q (want %s)", name, strings.Join(caseNames, ", "))
+}
+
+func (c Case) apply(tok string) string {
+	switch c {
+	case Lower:
+		return strings.ToLower(tok)
+	case Upper:
+		return strings.ToUpper(tok)
+	case Title:
+		r, size := utf8.DecodeRuneInString(tok)
+		return string(unicode.ToTitle(r)) + strings.ToLower(tok[size:])
+	}
+	return tok
+}
+
+// Phrase is every variant of a multi-token seed such as "blue dragon 1999":
+// each order of its tokens, joined by each separator, with each token in
+// each case. Like a Keyspace it is indexed: every index in [0, Total) has
+// exactly one variant, the same on every run.
+//
+// The index is a mixed-radix number whose lowest digits are the tokens'
+// cases, then the separator, then the order (in lexicographic order of the
+// token positions), so index 0 is the seed as given in its first case and
+// the first separator. Variants may repeat, for instance when tokens repeat
+// or a case leaves a token unchanged; callers that need unique words
+// deduplicate.
+type Phrase struct {
+	tokens []string
+	seps   []string
+	cases  [][]string // cases[t][c]: token t in case c
+	fact   []int64    // fact[n] = n!
+	styles int64      // len(cases)^len(tokens)
+	total  int64
+}
+
+// NewPhrase returns the variants of tokens joined by seps (which may include
+// "") in cases.
+func NewPhrase(tokens, seps []string, cases []Case) (*Phrase, error) {
+	switch {
+	case len(tokens) == 0:
+		return nil, ErrNoTokens
+	case len(tokens) > MaxPhraseTokens:
+		return nil, fmt.Errorf("%w: %d, at most %d", ErrTooManyTokens, len(tokens), MaxPhraseTokens)
+	case len(seps) == 0 || len(cases) == 0:
+		return nil, ErrNoVariants
+	}
+	p := &Phrase{
+		tokens: append([]string(nil), tokens...),
+		seps:   append([]string(nil), seps...),
+		fact:   make([]int64, len(tokens)+1),
+		styles: 1,
+	}
+	p.fact[0] = 1
+	for n := 1; n <= len(tokens); n++ {
+		p.fact[n] = p.fact[n-1] * int64(n)
+	}
+	for _, tok := range tokens {
+		forms := make([]string, len(cases))
+		for i, c := range cases {
+			forms[i] = c.apply(tok)
+		}
+		p.cases = append(p.cases, forms)
+		if p.styles > math.MaxInt64/int64(len(cases)) {
+			return nil, fmt.Errorf("%w: %d tokens in %d cases", ErrPhraseTooLarge, len(tokens), len(cases))
+		}
+		p.styles *= int64(len(cases))
+	}
+	perms := p.fact[len(tokens)] * int64(len(seps))
+	if perms > math.MaxInt64/p.styles {
+		return nil, fmt.Errorf("%w: %d tokens, %d separators, %d cases", ErrPhraseTooLarge, len(tokens), len(seps), len(cases))
+	}
+	p.total = perms * p.styles
+	return p, nil
+}
+
+// Total is the number of variants.
+func (p *Phrase) Total() int64 { return p.total }
+
+// WordAt returns the variant with the given index.
+func (p *Phrase) WordAt(index int64) (string, error) {
+	b, err := p.AppendWord(nil, index)
+	return string(b), err
+}
+
+// AppendWord appends the variant with the given index to dst.
+func (p *Phrase) AppendWord(dst []byte, index int64) ([]byte, error) {
+	if index < 0 || index >= p.total {
+		return dst, fmt.Errorf("%w: %d not in [0, %d)", ErrOutOfRange, index, p.total)
+	}
+	n := len(p.tokens)
+	style := index % p.styles
+	index /= p.styles
+	sep := p.seps[index%int64(len(p.seps))]
+	index /= int64(len(p.seps))
+
+	// The order's index is a Lehmer code: its digits pick each next
+	// position among those left.
+	var left [MaxPhraseTokens]int
+	for i := range n {
+		left[i] = i
+	}
+	nc := int64(len(p.cases[0]))
+	for i := range n {
+		f := p.fact[n-1-i]
+		d := int(index / f)
+		index %= f
+		t := left[d]
+		copy(left[d:n-i], left[d+1:n-i])
+		if i > 0 {
+			dst = append(dst, sep...)
+		}
+		// Token t's case is digit t of style, token 0's the highest.
+		c := style
+		for range n - 1 - t {
+			c /= nc
+		}
+		dst = append(dst, p.cases[t][c%nc]...)
+	}
+	return dst, nil
+}
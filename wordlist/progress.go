@@ -0,0 +1,80 @@
+package wordlist
+
+import "time"
+
+// Progress is how far an iterator has got through its range.
+type Progress struct {
+	Start, End int64 // the iterator's range
+	Pos        int64 // index of the next word
+	Length     int   // length of the word at Pos, or of the last one when done
+}
+
+// Done is the number of words produced so far.
+func (p Progress) Done() int64 { return p.Pos - p.Start }
+
+// Fraction is the share of the range produced so far, from 0 to 1.
+func (p Progress) Fraction() float64 {
+	if p.End == p.Start {
+		return 1
+	}
+	return float64(p.Pos-p.Start) / float64(p.End-p.Start)
+}
+
+// OnProgress makes the iterator call fn as it advances, at most once per
+// interval (every batch when interval is 0), and always once when the range
+// is exhausted. fn runs on the goroutine calling NextBatch, AppendBatch or
+// WriteN, so it should return quickly; ProgressChan hands updates to another
+// goroutine. A nil fn removes the callback.
+func (it *Iterator) OnProgress(interval time.Duration, fn func(Progress)) {
+	if fn == nil {
+		it.watch = nil
+		return
+	}
+	it.watch = &watcher{fn: fn, interval: interval, last: time.Now()}
+}
+
+// ProgressChan returns a callback for OnProgress that sends each update to
+// ch without blocking. Updates ch has no room for are dropped, so a UI
+// reading a buffered channel at its own pace never slows generation down.
+// The last one, at the end of the range, waits for room instead, so the
+// reader always sees the range finish.
+func ProgressChan(ch chan<- Progress) func(Progress) {
+	return func(p Progress) {
+		if p.Pos == p.End {
+			ch <- p
+			return
+		}
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// watcher throttles an iterator's progress callback.
+type watcher struct {
+	fn       func(Progress)
+	interval time.Duration
+	last     time.Time
+	finished bool
+}
+
+func (w *watcher) check(it *Iterator) {
+	done := it.pos == it.end
+	if done && w.finished {
+		return
+	}
+	if !done {
+		now := time.Now()
+		if now.Sub(w.last) < w.interval {
+			return
+		}
+		w.last = now
+	}
+	w.finished = done
+	length := it.ks.LengthAt(it.pos)
+	if done && it.pos > 0 {
+		length = it.ks.LengthAt(it.pos - 1)
+	}
+	w.fn(Progress{Start: it.start, End: it.end, Pos: it.pos, Length: length})
+}
@@ -0,0 +1,62 @@
+package wordlist
+
+import (
+	"slices"
+	"sort"
+)
+
+// Span is the half-open range of indices [Start, End).
+type Span struct {
+	Start, End int64
+}
+
+// Ranges is a set of indices kept as sorted, disjoint, non-adjacent spans,
+// such as the parts of a keyspace that have been generated or published.
+// Contains answers in O(log n) of the spans. The zero value is empty.
+type Ranges struct {
+	spans []Span
+}
+
+// Add adds [start, end) to the set, merging it with any span it overlaps
+// or touches. An empty range is ignored.
+func (r *Ranges) Add(start, end int64) {
+	if start >= end {
+		return
+	}
+	// The spans from i to j overlap or touch [start, end).
+	i := sort.Search(len(r.spans), func(i int) bool { return r.spans[i].End >= start })
+	j := i
+	for j < len(r.spans) && r.spans[j].Start <= end {
+		start = min(start, r.spans[j].Start)
+		end = max(end, r.spans[j].End)
+		j++
+	}
+	r.spans = slices.Replace(r.spans, i, j, Span{start, end})
+}
+
+// Contains reports whether index is in the set.
+func (r *Ranges) Contains(index int64) bool {
+	_, ok := r.Find(index)
+	return ok
+}
+
+// Find returns the span holding index, and false when none does.
+func (r *Ranges) Find(index int64) (Span, bool) {
+	i := sort.Search(len(r.spans), func(i int) bool { return r.spans[i].End > index })
+	if i < len(r.spans) && r.spans[i].Start <= index {
+		return r.spans[i], true
+	}
+	return Span{}, false
+}
+
+// Count returns how many indices the set holds.
+func (r *Ranges) Count() int64 {
+	var n int64
+	for _, s := range r.spans {
+		n += s.End - s.Start
+	}
+	return n
+}
+
+// Spans returns the spans in order. The caller must not modify them.
+func (r *Ranges) Spans() []Span { return r.spans }
@@ -0,0 +1,40 @@
+package wordlist
+
+// Shuffle returns a copy of k whose indices count its words in the order
+// seed picks instead of by length and charset order: index i of the copy is
+// the word at index p.At(i) of k, for the Permutation p of NewPermutation.
+// The first words of a walk are then spread over every length and symbol
+// rather than all starting with the first symbol, and since the same seed
+// always gives the same order, positions, chunks and resumes work as they
+// do in keyspace order.
+//
+// WordAt, IndexOf, LengthAt, Excluded and the iterators count in the
+// shuffled order; LengthRange stays in keyspace order, and ByteOffset and
+// Bytes become estimates. Exclusions are of words, so they may be added
+// before or after shuffling. A shuffled k is shuffled afresh from keyspace
+// order.
+func (k *Keyspace) Shuffle(seed uint64) (*Keyspace, error) {
+	p, err := NewPermutation(k.Total(), seed)
+	if err != nil {
+		return nil, err
+	}
+	c := *k
+	c.perm = p
+	return &c, nil
+}
+
+// Shuffled reports whether k counts its words in a shuffled order.
+func (k *Keyspace) Shuffled() bool { return k.perm != nil }
+
+// ordered is the index in keyspace order of the word at index.
+func (k *Keyspace) ordered(index int64) int64 {
+	if k.perm == nil {
+		return index
+	}
+	return k.perm.At(index)
+}
+
+// sequential reports whether the iterators of k write every word in
+// keyspace order, so a run of indices is a run of bytes whose size Bytes
+// gives exactly.
+func (k *Keyspace) sequential() bool { return k.exclude == nil && k.perm == nil }
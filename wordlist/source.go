@@ -0,0 +1,162 @@
+package wordlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrBadSource is returned by NewSource for a location it cannot read.
+var ErrBadSource = errors.New("wordlist: not a directory or an http(s) URL")
+
+// Source is where the files of a published run are read from: a clone of its
+// repository, a mounted bucket, or a URL they are served under.
+type Source interface {
+	// Open returns the file name, a slash-separated path relative to the
+	// run's top directory, from byte offset on. An offset at or past the
+	// end gives an empty reader. Cancelling ctx stops the fetch, the
+	// reading of the returned body included.
+	Open(ctx context.Context, name string, offset int64) (io.ReadCloser, error)
+	String() string
+}
+
+// NewSource returns the source at from: a directory, an http(s) URL the
+// published files are under, such as a bucket's endpoint, or a GitHub
+// repository (https://github.com/OWNER/REPO or github.com/OWNER/REPO), whose
+// raw files on the default branch are read.
+func NewSource(from string) (Source, error) {
+	switch {
+	case strings.HasPrefix(from, "https://github.com/"), strings.HasPrefix(from, "github.com/"):
+		repo := strings.Trim(strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(from, "https://"), "github.com/"), ".git"), "/")
+		if strings.Count(repo, "/") != 1 {
+			return nil, fmt.Errorf("%w: %s: want github.com/OWNER/REPO", ErrBadSource, from)
+		}
+		return URL("https://raw.githubusercontent.com/" + repo + "/HEAD/"), nil
+	case strings.HasPrefix(from, "http://"), strings.HasPrefix(from, "https://"):
+		return URL(strings.TrimSuffix(from, "/") + "/"), nil
+	}
+	if fi, err := os.Stat(from); err != nil || !fi.IsDir() {
+		return nil, fmt.Errorf("%w: %q", ErrBadSource, from)
+	}
+	return Dir(from), nil
+}
+
+// Dir is a source on the local file system.
+type Dir string
+
+func (d Dir) Open(ctx context.Context, name string, offset int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(string(d), filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (d Dir) String() string { return string(d) }
+
+// URL is a source served over http(s): a base URL ending in a slash.
+type URL string
+
+// stallTimeout is how long a URL source waits for a server that sends
+// nothing, to answer a request or to go on with a body, before it gives
+// up on the fetch with ErrStalled. A download that keeps moving may take
+// as long as it needs.
+const stallTimeout = time.Minute
+
+// ErrStalled is returned by a URL source whose server sent nothing for
+// a minute.
+var ErrStalled = errors.New("wordlist: the server stopped sending")
+
+// Open asks for the bytes from offset on with a Range header, and skips them
+// itself when the server sends the whole file anyway.
+func (u URL) Open(ctx context.Context, name string, offset int64) (io.ReadCloser, error) {
+	url := string(u) + name
+	ctx, cancel := context.WithCancelCause(ctx)
+	body := &stallReader{cancel: cancel}
+	body.timer = time.AfterFunc(stallTimeout, func() {
+		cancel(fmt.Errorf("%w: %s: nothing for %s", ErrStalled, url, stallTimeout))
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		body.Close()
+		return nil, causeOf(ctx, err)
+	}
+	body.ctx, body.r = ctx, resp.Body
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		return body, nil
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		body.Close() // nothing past offset
+		return http.NoBody, nil
+	case resp.StatusCode == http.StatusOK:
+		if _, err := io.CopyN(io.Discard, body, offset); err != nil {
+			body.Close()
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+		return body, nil
+	}
+	body.Close()
+	return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+}
+
+// stallReader is the body of a URL fetch. Each read puts off the timer
+// that cancels the fetch for stallTimeout more.
+type stallReader struct {
+	ctx    context.Context
+	r      io.ReadCloser // nil until the server answers
+	timer  *time.Timer
+	cancel context.CancelCauseFunc
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.timer.Reset(stallTimeout)
+	}
+	if err != nil && err != io.EOF {
+		err = causeOf(s.ctx, err)
+	}
+	return n, err
+}
+
+func (s *stallReader) Close() error {
+	s.timer.Stop()
+	var err error
+	if s.r != nil {
+		err = s.r.Close()
+	}
+	s.cancel(context.Canceled)
+	return err
+}
+
+// causeOf returns why ctx was cancelled in place of err, the error a fetch
+// failed with, when that is what stopped it.
+func causeOf(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil {
+		return cause
+	}
+	return err
+}
+
+func (u URL) String() string { return string(u) }